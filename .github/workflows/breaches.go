@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Builds the Breached Password Corpus used by the backend (BREACH_CORPUS_FILE)
+//
+// Input is the Pwned Passwords list in its SHA-1 flavour, one 'HASH:COUNT'
+// per line, as produced by https://github.com/HaveIBeenPwned/PwnedPasswordsDownloader.
+// The list is read twice: once to size the Bloom Filter and once to fill it.
+// The archive is not compressed as a filled Bloom Filter is random noise.
+
+var (
+	INPUT_LOCATION  = os.Getenv("INPUT_LOCATION")
+	OUTPUT_LOCATION = os.Getenv("OUTPUT_LOCATION")
+	MINIMUM_COUNT   = EnvNumber("MINIMUM_COUNT", 1)      // Skip Passwords seen fewer times
+	FALSE_POSITIVES = EnvFloat("FALSE_POSITIVES", 0.001) // Acceptable False Positive Rate
+)
+
+func EnvNumber(field string, initial int) int {
+	if value := os.Getenv(field); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			log.Fatalf("Variable '%s' is not a number.\n", field)
+		}
+		return n
+	}
+	return initial
+}
+
+func EnvFloat(field string, initial float64) float64 {
+	if value := os.Getenv(field); value != "" {
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil || n <= 0 || n >= 1 {
+			log.Fatalf("Variable '%s' must be between 0 and 1.\n", field)
+		}
+		return n
+	}
+	return initial
+}
+
+// Read every Digest in the Input that was seen often enough
+func Scan(fn func(digest []byte)) int {
+	f, err := os.Open(INPUT_LOCATION)
+	if err != nil {
+		log.Fatalln("Cannot Open File:", err)
+	}
+	defer f.Close()
+
+	var reader io.Reader = f
+	if strings.HasSuffix(INPUT_LOCATION, ".gz") {
+		gunzip, err := gzip.NewReader(f)
+		if err != nil {
+			log.Fatalln("Invalid Archive Header:", err)
+		}
+		defer gunzip.Close()
+		reader = gunzip
+	}
+
+	entries := 0
+	digest := make([]byte, 20)
+	scanner := bufio.NewScanner(reader)
+	for line := 1; scanner.Scan(); line++ {
+		hash, count, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if count != "" {
+			n, err := strconv.Atoi(count)
+			if err != nil {
+				log.Fatalf("Error Decoding Count on Line %d: %s\n", line, err)
+			}
+			if n < MINIMUM_COUNT {
+				continue
+			}
+		}
+		if len(hash) != 40 {
+			log.Fatalf("Error Decoding Hash on Line %d\n", line)
+		}
+		if _, err := hex.Decode(digest, []byte(hash)); err != nil {
+			log.Fatalf("Error Decoding Hash on Line %d\n", line)
+		}
+		fn(digest)
+		entries++
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatalln("Error Reading File:", err)
+	}
+	return entries
+}
+
+func main() {
+	t := time.Now()
+	if INPUT_LOCATION == "" {
+		log.Fatalln("Variable 'INPUT_LOCATION' is not set.")
+	}
+	if OUTPUT_LOCATION == "" {
+		OUTPUT_LOCATION = "breaches.kani"
+	}
+
+	// Size Filter
+	log.Println("Counting Entries")
+	n := float64(max(Scan(func([]byte) {}), 1))
+	m := math.Ceil(-n * math.Log(FALSE_POSITIVES) / (math.Ln2 * math.Ln2))
+	k := uint8(math.Max(math.Round(m/n*math.Ln2), 1))
+	size := uint64(m)
+	bits := make([]byte, (size+7)/8)
+
+	// Fill Filter, indexes must match tools.BreachFilter
+	log.Printf("Filling Filter: %d Entries, %d Bits, %d Hashes", int(n), size, k)
+	Scan(func(digest []byte) {
+		h1 := binary.LittleEndian.Uint64(digest[0:8])
+		h2 := binary.LittleEndian.Uint64(digest[8:16]) | 1
+		for i := uint64(0); i < uint64(k); i++ {
+			bit := (h1 + i*h2) % size
+			bits[bit/8] |= 1 << (bit % 8)
+		}
+	})
+
+	// Write Archive
+	if err := os.MkdirAll(path.Dir(OUTPUT_LOCATION), 0755); err != nil {
+		log.Fatalln("Error Creating Archive Folder:", err)
+	}
+	output, err := os.Create(OUTPUT_LOCATION)
+	if err != nil {
+		log.Fatalln("Error Creating Archive File:", err)
+	}
+	defer output.Close()
+
+	writer := bufio.NewWriter(output)
+	binary.Write(writer, binary.LittleEndian, k)
+	binary.Write(writer, binary.LittleEndian, size)
+	writer.Write(bits)
+	if err := writer.Flush(); err != nil {
+		log.Fatalln("Error Writing Archive File:", err)
+	}
+	log.Printf("Complete in %s", time.Since(t))
+}
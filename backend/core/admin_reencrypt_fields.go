@@ -0,0 +1,40 @@
+package core
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+// Encryption Keys are rotated like every other Key but are never retired
+// automatically, as Values sealed under them would become unreadable. This
+// job re-wraps every Value under the current Master Key and then retires the
+// Keys nothing depends on anymore.
+
+func AdminReencryptFields(args []string) {
+	var stopCtx, stop = context.WithCancel(context.Background())
+	var stopWg sync.WaitGroup
+
+	tools.SetupLogger(stopCtx, &stopWg)
+	tools.SetupDatabase(stopCtx, &stopWg)
+	tools.SetupKeystore(stopCtx, &stopWg)
+	if len(args) != 0 {
+		tools.LoggerFields.Fatal("Usage: admin_reencrypt_fields", nil)
+	}
+	if len(tools.FIELD_INDEX_KEY) == 0 {
+		tools.LoggerFields.Fatal("Index Key Required", nil)
+	}
+	migrated, err := tools.ReencryptFields(stopCtx)
+	if err != nil {
+		tools.LoggerFields.Fatal("Re-encryption Failed", err.Error())
+	}
+	tools.LoggerFields.Info("Re-encryption Complete", map[string]any{
+		"count": migrated,
+	})
+
+	stop()
+	stopWg.Wait()
+	os.Exit(0)
+}
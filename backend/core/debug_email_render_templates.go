@@ -2,10 +2,12 @@ package core
 
 import (
 	"fmt"
-	"html/template"
+	htmltemplate "html/template"
+	"io"
 	"os"
 	"path"
 	"strings"
+	texttemplate "text/template"
 
 	"github.com/bakonpancakz/template-auth/include"
 	"github.com/bakonpancakz/template-auth/tools"
@@ -19,20 +21,24 @@ func DebugEmailRenderTemplates() {
 	var (
 		exampleUsername = tools.EMAIL_DEFAULT_DISPLAYNAME
 		exampleAddress  = "127.0.0.1"
-		exampleToken    = tools.GenerateSignedString()
+		exampleToken    = tools.GenerateRandomToken()
 		exampleLocation = "Fresno, California, United States"
 		exampleBrowser  = "Chrome on Windows 10.0"
 		exampleTime     = "10/23/2025 07:45am"
 		defaults        = map[string]any{
-			"EMAIL_VERIFY.html": tools.LocalsEmailVerify{
+			"EMAIL_VERIFY": tools.LocalsEmailVerify{
 				Displayname: exampleUsername,
 				Token:       exampleToken,
 			},
-			"LOGIN_FORGOT_PASSWORD.html": tools.LocalsLoginForgotPassword{
+			"LOGIN_FORGOT_PASSWORD": tools.LocalsLoginForgotPassword{
 				Displayname: exampleUsername,
 				Token:       exampleToken,
 			},
-			"LOGIN_NEW_LOCATION.html": tools.LocalsLoginNewLocation{
+			"LOGIN_PASSWORD_BREACHED": tools.LocalsLoginPasswordBreached{
+				Displayname: exampleUsername,
+				Token:       exampleToken,
+			},
+			"LOGIN_NEW_LOCATION": tools.LocalsLoginNewLocation{
 				Displayname:    exampleUsername,
 				Token:          exampleToken,
 				IpAddress:      exampleAddress,
@@ -40,26 +46,35 @@ func DebugEmailRenderTemplates() {
 				DeviceBrowser:  exampleBrowser,
 				DeviceLocation: exampleLocation,
 			},
-			"LOGIN_NEW_DEVICE.html": tools.LocalsLoginNewDevice{
+			"LOGIN_NEW_DEVICE": tools.LocalsLoginNewDevice{
 				Displayname:    exampleUsername,
 				Timestamp:      exampleTime,
 				IpAddress:      exampleAddress,
 				DeviceBrowser:  exampleBrowser,
 				DeviceLocation: exampleLocation,
 			},
-			"LOGIN_PASSCODE.html": tools.LocalsLoginPasscode{
+			"LOGIN_ACCOUNT_LOCKED": tools.LocalsLoginAccountLocked{
+				Displayname:    exampleUsername,
+				Token:          exampleToken,
+				LockedUntil:    exampleTime,
+				IpAddress:      exampleAddress,
+				Timestamp:      exampleTime,
+				DeviceBrowser:  exampleBrowser,
+				DeviceLocation: exampleLocation,
+			},
+			"LOGIN_PASSCODE": tools.LocalsLoginPasscode{
 				Displayname: exampleUsername,
 				Code:        tools.GeneratePasscode(),
 				Lifetime:    fmt.Sprint(tools.LIFETIME_TOKEN_EMAIL_PASSCODE.Minutes()),
 			},
-			"NOTIFY_USER_DELETED.html": tools.LocalsNotifyUserDeleted{
+			"NOTIFY_USER_DELETED": tools.LocalsNotifyUserDeleted{
 				Displayname: exampleUsername,
 				Reason:      "User Request",
 			},
-			"NOTIFY_USER_EMAIL_MODIFIED.html": tools.LocalsNotifyUserEmailModified{
+			"NOTIFY_USER_EMAIL_MODIFIED": tools.LocalsNotifyUserEmailModified{
 				Displayname: exampleUsername,
 			},
-			"NOTIFY_USER_PASS_MODIFIED.html": tools.LocalsNotifyUserPasswordModified{
+			"NOTIFY_USER_PASS_MODIFIED": tools.LocalsNotifyUserPasswordModified{
 				Displayname: exampleUsername,
 			},
 		}
@@ -79,18 +94,30 @@ func DebugEmailRenderTemplates() {
 			fmt.Printf("Ignoring Template: %s\n", filename)
 			continue
 		}
-		locals, ok := defaults[ent.Name()]
+		extension := path.Ext(filename)
+		locals, ok := defaults[strings.TrimSuffix(filename, extension)]
 		if !ok {
 			fmt.Printf("Ignoring Template, contribute some locals!: %s\n", filename)
 			continue
 		}
 
-		// Process Template
-		template, err := template.ParseFS(
-			include.EmailTemplates,
-			"templates/_TEMPLATE.html",
-			"templates/"+filename,
-		)
+		// Process Template, Plain-Text Alternatives aren't HTML escaped
+		var template interface {
+			Execute(w io.Writer, data any) error
+		}
+		if extension == ".txt" {
+			template, err = texttemplate.ParseFS(
+				include.EmailTemplates,
+				"templates/_TEMPLATE.txt",
+				"templates/"+filename,
+			)
+		} else {
+			template, err = htmltemplate.ParseFS(
+				include.EmailTemplates,
+				"templates/_TEMPLATE.html",
+				"templates/"+filename,
+			)
+		}
 		if err != nil {
 			fmt.Printf("Cannot parse template '%s': %s\n", filename, err)
 			return
@@ -63,8 +63,10 @@ func SetupMux() *http.ServeMux {
 	var (
 		mux       = http.NewServeMux()
 		session   = tools.UseSession
+		usersOnly = tools.UseUsersOnly
 		limitFILE = tools.NewBodyLimit(10 * 1024 * 1024) // 10MB
 		limitJSON = tools.NewBodyLimit(10 * 1024)        // 10KB
+		limitSAML = tools.NewBodyLimit(256 * 1024)       // 256KB
 		rateLogin = tools.NewRatelimit(&tools.RatelimitOptions{
 			Bucket: "RATE_LOGIN",
 			Period: time.Minute,
@@ -96,11 +98,38 @@ func SetupMux() *http.ServeMux {
 	mux.Handle("/auth/login", tools.MethodHandler{
 		http.MethodPost: tools.Chain(routes.POST_Auth_Login, rateLogin, limitJSON),
 	})
+	mux.Handle("/auth/login/email", tools.MethodHandler{
+		http.MethodPost:  tools.Chain(routes.POST_Auth_Login_Email, rateLogin, limitJSON),
+		http.MethodPatch: tools.Chain(routes.PATCH_Auth_Login_Email, rateLogin, limitJSON),
+	})
+	mux.Handle("/auth/login/passkey", tools.MethodHandler{
+		http.MethodGet:  tools.Chain(routes.GET_Auth_Login_Passkey, rateLogin),
+		http.MethodPost: tools.Chain(routes.POST_Auth_Login_Passkey, rateLogin, limitJSON),
+	})
+	mux.Handle("/auth/federated", tools.MethodHandler{
+		http.MethodGet: tools.Chain(routes.GET_Auth_Federated, rateClientRead),
+	})
+	mux.Handle("/auth/federated/{provider}", tools.MethodHandler{
+		http.MethodGet:  tools.Chain(routes.GET_Auth_Federated_Provider, rateLogin),
+		http.MethodPost: tools.Chain(routes.POST_Auth_Federated_Provider, rateLogin, limitJSON),
+	})
+	mux.Handle("/auth/saml/{connection}", tools.MethodHandler{
+		http.MethodGet:   tools.Chain(routes.GET_Auth_SAML_Connection, rateLogin),
+		http.MethodPatch: tools.Chain(routes.PATCH_Auth_SAML_Connection, rateLogin, limitJSON),
+	})
+	mux.Handle("/auth/saml/{connection}/metadata", tools.MethodHandler{
+		http.MethodGet: tools.Chain(routes.GET_Auth_SAML_Connection_Metadata, rateClientRead),
+	})
+	// Posted from the Identity Provider's Origin, so CORS does not apply
+	tools.CORSExempt["/auth/saml/{connection}/acs"] = true
+	mux.Handle("/auth/saml/{connection}/acs", tools.MethodHandler{
+		http.MethodPost: tools.Chain(routes.POST_Auth_SAML_Connection_ACS, rateLogin, limitSAML),
+	})
 	mux.Handle("/auth/signup", tools.MethodHandler{
 		http.MethodPost: tools.Chain(routes.POST_Auth_Signup, rateLogin, limitJSON),
 	})
 	mux.Handle("/auth/logout", tools.MethodHandler{
-		http.MethodPost: tools.Chain(routes.POST_Auth_Logout, rateLogin, limitJSON, session),
+		http.MethodPost: tools.Chain(routes.POST_Auth_Logout, rateLogin, limitJSON, session, usersOnly),
 	})
 	mux.Handle("/auth/password-reset", tools.MethodHandler{
 		http.MethodPost:  tools.Chain(routes.POST_Auth_ResetPassword, rateLogin, limitJSON),
@@ -109,14 +138,17 @@ func SetupMux() *http.ServeMux {
 	mux.Handle("/auth/verify-login", tools.MethodHandler{
 		http.MethodPost: tools.Chain(routes.POST_Auth_VerifyLogin, rateLogin, limitJSON),
 	})
+	mux.Handle("/auth/unlock", tools.MethodHandler{
+		http.MethodPost: tools.Chain(routes.POST_Auth_Unlock, rateLogin, limitJSON),
+	})
 	mux.Handle("/auth/verify-email", tools.MethodHandler{
 		http.MethodPost: tools.Chain(routes.POST_Auth_VerifyEmail, rateLogin, limitJSON),
 	})
 
 	// oAuth2
 	mux.Handle("/oauth2/authorize", tools.MethodHandler{
-		http.MethodGet:  tools.Chain(routes.GET_OAuth2_Authorize, rateClientRead, session),
-		http.MethodPost: tools.Chain(routes.POST_OAuth2_Authorize, rateClientWrite, session),
+		http.MethodGet:  tools.Chain(routes.GET_OAuth2_Authorize, rateClientRead, session, usersOnly),
+		http.MethodPost: tools.Chain(routes.POST_OAuth2_Authorize, rateClientWrite, session, usersOnly),
 	})
 	mux.Handle("/oauth2/token", tools.MethodHandler{
 		http.MethodPost: tools.Chain(routes.POST_OAuth2_Token, rateServerWrite),
@@ -124,76 +156,149 @@ func SetupMux() *http.ServeMux {
 	mux.Handle("/oauth2/token/revoke", tools.MethodHandler{
 		http.MethodPost: tools.Chain(routes.POST_OAuth2_Token_Revoke, rateServerWrite),
 	})
+	mux.Handle("/oauth2/token/introspect", tools.MethodHandler{
+		http.MethodPost: tools.Chain(routes.POST_OAuth2_Token_Introspect, rateServerWrite),
+	})
+	mux.Handle("/oauth2/device", tools.MethodHandler{
+		http.MethodGet:    tools.Chain(routes.GET_OAuth2_Device, rateClientRead, session, usersOnly),
+		http.MethodPost:   tools.Chain(routes.POST_OAuth2_Device, rateClientWrite, session, usersOnly),
+		http.MethodDelete: tools.Chain(routes.DELETE_OAuth2_Device, rateClientWrite, session, usersOnly),
+	})
+	mux.Handle("/oauth2/device/code", tools.MethodHandler{
+		http.MethodPost: tools.Chain(routes.POST_OAuth2_Device_Code, rateServerWrite),
+	})
+
+	// OpenID Connect
+	mux.Handle("/.well-known/openid-configuration", tools.MethodHandler{
+		http.MethodGet: tools.Chain(routes.GET_WellKnown_OpenIDConfiguration, rateClientRead),
+	})
+	mux.Handle("/oauth2/jwks", tools.MethodHandler{
+		http.MethodGet: tools.Chain(routes.GET_OAuth2_JWKS, rateClientRead),
+	})
+	mux.Handle("/oauth2/userinfo", tools.MethodHandler{
+		http.MethodGet:  tools.Chain(routes.GET_OAuth2_UserInfo, rateClientRead, session, tools.NewScopes(tools.SCOPE_OPENID)),
+		http.MethodPost: tools.Chain(routes.GET_OAuth2_UserInfo, rateClientRead, session, tools.NewScopes(tools.SCOPE_OPENID)),
+	})
 
 	// User
 	mux.Handle("/users/@me", tools.MethodHandler{
-		http.MethodGet:    tools.Chain(routes.GET_Users_Me, rateClientRead, session),
-		http.MethodPatch:  tools.Chain(routes.PATCH_Users_Me, rateClientWrite, limitJSON, session),
-		http.MethodDelete: tools.Chain(routes.DELETE_Users_Me, rateClientWrite, session),
+		http.MethodGet:    tools.Chain(routes.GET_Users_Me, rateClientRead, session, tools.NewScopes(tools.SCOPE_READ_IDENTIFY)),
+		http.MethodPatch:  tools.Chain(routes.PATCH_Users_Me, rateClientWrite, limitJSON, session, tools.NewScopes(tools.SCOPE_WRITE_PROFILE)),
+		http.MethodDelete: tools.Chain(routes.DELETE_Users_Me, rateClientWrite, session, usersOnly),
 	})
 	mux.Handle("/users/@me/avatar", tools.MethodHandler{
-		http.MethodPut:    tools.Chain(routes.PUT_Users_Me_Avatar, rateClientImage, limitFILE, session),
-		http.MethodDelete: tools.Chain(routes.DELETE_Users_Me_Avatar, rateClientWrite, session),
+		http.MethodPut:    tools.Chain(routes.PUT_Users_Me_Avatar, rateClientImage, limitFILE, session, tools.NewScopes(tools.SCOPE_WRITE_AVATAR)),
+		http.MethodDelete: tools.Chain(routes.DELETE_Users_Me_Avatar, rateClientWrite, session, tools.NewScopes(tools.SCOPE_WRITE_AVATAR)),
 	})
 	mux.Handle("/users/@me/banner", tools.MethodHandler{
-		http.MethodPut:    tools.Chain(routes.PUT_Users_Me_Banner, rateClientImage, limitFILE, session),
-		http.MethodDelete: tools.Chain(routes.DELETE_Users_Me_Banner, rateClientWrite, session),
+		http.MethodPut:    tools.Chain(routes.PUT_Users_Me_Banner, rateClientImage, limitFILE, session, tools.NewScopes(tools.SCOPE_WRITE_BANNER)),
+		http.MethodDelete: tools.Chain(routes.DELETE_Users_Me_Banner, rateClientWrite, session, tools.NewScopes(tools.SCOPE_WRITE_BANNER)),
+	})
+	mux.Handle("/users/{id}", tools.MethodHandler{
+		http.MethodGet: tools.Chain(routes.GET_Users_ID, rateClientRead, session, tools.NewScopes(tools.SCOPE_READ_PROFILES)),
 	})
 
 	// User Applications
 	mux.Handle("/users/@me/applications", tools.MethodHandler{
-		http.MethodGet:  tools.Chain(routes.GET_Users_Me_Applications, rateClientRead, session),
-		http.MethodPost: tools.Chain(routes.POST_Users_Me_Applications, rateClientWrite, session),
+		http.MethodGet:  tools.Chain(routes.GET_Users_Me_Applications, rateClientRead, session, usersOnly),
+		http.MethodPost: tools.Chain(routes.POST_Users_Me_Applications, rateClientWrite, session, usersOnly),
 	})
 	mux.Handle("/users/@me/applications/{id}", tools.MethodHandler{
-		http.MethodPatch:  tools.Chain(routes.PATCH_Users_Me_Applications_ID, rateClientWrite, limitJSON, session),
-		http.MethodDelete: tools.Chain(routes.DELETE_Users_Me_Applications_ID, rateClientWrite, session),
+		http.MethodPatch:  tools.Chain(routes.PATCH_Users_Me_Applications_ID, rateClientWrite, limitJSON, session, usersOnly),
+		http.MethodDelete: tools.Chain(routes.DELETE_Users_Me_Applications_ID, rateClientWrite, session, usersOnly),
 	})
 	mux.Handle("/users/@me/applications/{id}/icon", tools.MethodHandler{
-		http.MethodPut:    tools.Chain(routes.PUT_Users_Me_Applications_ID_Icon, rateClientImage, limitFILE, session),
-		http.MethodDelete: tools.Chain(routes.DELETE_Users_Me_Applications_ID_Icon, rateClientWrite, session),
+		http.MethodPut:    tools.Chain(routes.PUT_Users_Me_Applications_ID_Icon, rateClientImage, limitFILE, session, usersOnly),
+		http.MethodDelete: tools.Chain(routes.DELETE_Users_Me_Applications_ID_Icon, rateClientWrite, session, usersOnly),
 	})
 	mux.Handle("/users/@me/applications/{id}/reset", tools.MethodHandler{
-		http.MethodDelete: tools.Chain(routes.DELETE_Users_Me_Applications_ID_Reset, rateClientWrite, session),
+		http.MethodDelete: tools.Chain(routes.DELETE_Users_Me_Applications_ID_Reset, rateClientWrite, session, usersOnly),
 	})
 
 	// User Connections
 	mux.Handle("/users/@me/connections", tools.MethodHandler{
-		http.MethodGet: tools.Chain(routes.GET_Users_Me_Connections, rateClientRead, session),
+		http.MethodGet: tools.Chain(routes.GET_Users_Me_Connections, rateClientRead, session, tools.NewScopes(tools.SCOPE_READ_CONNECTIONS)),
 	})
 	mux.Handle("/users/@me/connections/{id}", tools.MethodHandler{
-		http.MethodDelete: tools.Chain(routes.DELETE_Users_Me_Connections_ID, rateClientWrite, session),
+		http.MethodDelete: tools.Chain(routes.DELETE_Users_Me_Connections_ID, rateClientWrite, session, usersOnly),
+	})
+	mux.Handle("/users/@me/connections/{id}/scopes/{scope}", tools.MethodHandler{
+		http.MethodDelete: tools.Chain(routes.DELETE_Users_Me_Connections_ID_Scopes_Scope, rateClientWrite, session, usersOnly),
 	})
 
 	// User Sessions
 	mux.Handle("/users/@me/security/sessions", tools.MethodHandler{
-		http.MethodGet: tools.Chain(routes.GET_Users_Me_Security_Sessions, rateClientRead, session),
+		http.MethodGet: tools.Chain(routes.GET_Users_Me_Security_Sessions, rateClientRead, session, usersOnly),
 	})
 	mux.Handle("/users/@me/security/sessions/{id}", tools.MethodHandler{
-		http.MethodDelete: tools.Chain(routes.DELETE_Users_Me_Security_Sessions_ID, rateClientWrite, session),
+		http.MethodDelete: tools.Chain(routes.DELETE_Users_Me_Security_Sessions_ID, rateClientWrite, session, usersOnly),
+	})
+
+	// User Devices
+	mux.Handle("/users/@me/security/devices", tools.MethodHandler{
+		http.MethodGet: tools.Chain(routes.GET_Users_Me_Security_Devices, rateClientRead, session, usersOnly),
+	})
+	mux.Handle("/users/@me/security/devices/{id}", tools.MethodHandler{
+		http.MethodDelete: tools.Chain(routes.DELETE_Users_Me_Security_Devices_ID, rateClientWrite, session, usersOnly),
+	})
+
+	// User Identities
+	mux.Handle("/users/@me/security/identities", tools.MethodHandler{
+		http.MethodGet: tools.Chain(routes.GET_Users_Me_Security_Identities, rateClientRead, session, usersOnly),
+	})
+	mux.Handle("/users/@me/security/identities/{provider}", tools.MethodHandler{
+		http.MethodPost:   tools.Chain(routes.POST_Users_Me_Security_Identities_Provider, rateClientWrite, session, usersOnly),
+		http.MethodDelete: tools.Chain(routes.DELETE_Users_Me_Security_Identities_Provider, rateClientWrite, session, usersOnly),
 	})
 
 	// User MFA
 	mux.Handle("/users/@me/security/mfa/setup", tools.MethodHandler{
-		http.MethodGet:    tools.Chain(routes.GET_Users_Me_Security_MFA_Setup, rateClientWrite, session),
-		http.MethodPost:   tools.Chain(routes.POST_Users_Me_Security_MFA_Setup, rateClientWrite, limitJSON, session),
-		http.MethodDelete: tools.Chain(routes.DELETE_Users_Me_Security_MFA_Setup, rateClientWrite, session),
+		http.MethodGet:    tools.Chain(routes.GET_Users_Me_Security_MFA_Setup, rateClientWrite, session, usersOnly),
+		http.MethodPost:   tools.Chain(routes.POST_Users_Me_Security_MFA_Setup, rateClientWrite, limitJSON, session, usersOnly),
+		http.MethodDelete: tools.Chain(routes.DELETE_Users_Me_Security_MFA_Setup, rateClientWrite, session, usersOnly),
 	})
 	mux.Handle("/users/@me/security/mfa/codes", tools.MethodHandler{
-		http.MethodGet:    tools.Chain(routes.GET_Users_Me_Security_MFA_Codes, rateClientRead, session),
-		http.MethodDelete: tools.Chain(routes.DELETE_Users_Me_Security_MFA_Codes, rateClientWrite, session),
+		http.MethodGet:    tools.Chain(routes.GET_Users_Me_Security_MFA_Codes, rateClientRead, session, usersOnly),
+		http.MethodDelete: tools.Chain(routes.DELETE_Users_Me_Security_MFA_Codes, rateClientWrite, session, usersOnly),
+	})
+
+	// User Passkeys
+	mux.Handle("/users/@me/security/passkeys", tools.MethodHandler{
+		http.MethodGet: tools.Chain(routes.GET_Users_Me_Security_Passkeys, rateClientRead, session, usersOnly),
+	})
+	mux.Handle("/users/@me/security/passkeys/setup", tools.MethodHandler{
+		http.MethodGet:  tools.Chain(routes.GET_Users_Me_Security_Passkeys_Setup, rateClientWrite, session, usersOnly),
+		http.MethodPost: tools.Chain(routes.POST_Users_Me_Security_Passkeys_Setup, rateClientWrite, limitJSON, session, usersOnly),
+	})
+	mux.Handle("/users/@me/security/passkeys/{id}", tools.MethodHandler{
+		http.MethodDelete: tools.Chain(routes.DELETE_Users_Me_Security_Passkeys_ID, rateClientWrite, session, usersOnly),
 	})
 
 	// User Security
 	mux.Handle("/users/@me/security/escalate", tools.MethodHandler{
-		http.MethodPost: tools.Chain(routes.POST_Users_Me_Security_Escalate, rateClientWrite, limitJSON, session),
+		http.MethodPost: tools.Chain(routes.POST_Users_Me_Security_Escalate, rateClientWrite, limitJSON, session, usersOnly),
 	})
 	mux.Handle("/users/@me/security/password", tools.MethodHandler{
-		http.MethodPatch: tools.Chain(routes.PATCH_Users_Me_Security_Password, rateClientWrite, limitJSON, session),
+		http.MethodPatch: tools.Chain(routes.PATCH_Users_Me_Security_Password, rateClientWrite, limitJSON, session, usersOnly),
 	})
 	mux.Handle("/users/@me/security/email", tools.MethodHandler{
-		http.MethodPost:  tools.Chain(routes.POST_Users_Me_Security_Email, rateClientWrite, session),
-		http.MethodPatch: tools.Chain(routes.PATCH_Users_Me_Security_Email, rateClientWrite, limitJSON, session),
+		http.MethodPost:  tools.Chain(routes.POST_Users_Me_Security_Email, rateClientWrite, session, usersOnly),
+		http.MethodPatch: tools.Chain(routes.PATCH_Users_Me_Security_Email, rateClientWrite, limitJSON, session, usersOnly),
+	})
+
+	// SCIM Provisioning
+	mux.Handle("/scim/v2/ServiceProviderConfig", tools.MethodHandler{
+		http.MethodGet: tools.Chain(routes.GET_SCIM_ServiceProviderConfig, rateClientRead),
+	})
+	mux.Handle("/scim/v2/Users", tools.MethodHandler{
+		http.MethodGet:  tools.Chain(routes.GET_SCIM_Users, rateServerWrite, session, tools.UseApplicationsOnly, tools.NewScopes(tools.SCOPE_SCIM)),
+		http.MethodPost: tools.Chain(routes.POST_SCIM_Users, rateServerWrite, limitJSON, session, tools.UseApplicationsOnly, tools.NewScopes(tools.SCOPE_SCIM)),
+	})
+	mux.Handle("/scim/v2/Users/{id}", tools.MethodHandler{
+		http.MethodGet:    tools.Chain(routes.GET_SCIM_Users_ID, rateServerWrite, session, tools.UseApplicationsOnly, tools.NewScopes(tools.SCOPE_SCIM)),
+		http.MethodPut:    tools.Chain(routes.PUT_SCIM_Users_ID, rateServerWrite, limitJSON, session, tools.UseApplicationsOnly, tools.NewScopes(tools.SCOPE_SCIM)),
+		http.MethodPatch:  tools.Chain(routes.PATCH_SCIM_Users_ID, rateServerWrite, limitJSON, session, tools.UseApplicationsOnly, tools.NewScopes(tools.SCOPE_SCIM)),
+		http.MethodDelete: tools.Chain(routes.DELETE_SCIM_Users_ID, rateServerWrite, session, tools.UseApplicationsOnly, tools.NewScopes(tools.SCOPE_SCIM)),
 	})
 
 	// Default 404 Handler
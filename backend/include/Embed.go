@@ -5,7 +5,7 @@ import "embed"
 //go:embed archives/geolocation.kani.gz
 var ArchiveGeolocation []byte
 
-//go:embed templates/*.html
+//go:embed templates/*.html templates/*.txt
 var EmailTemplates embed.FS
 
 //go:embed schema.sql
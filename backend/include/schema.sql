@@ -153,6 +153,504 @@ BEGIN
         GRANT SELECT ON auth.profiles TO user_profiles;
     END IF;
 
+    /*
+     * Version:     1.1.0
+     * Name:        PKCE
+     * Description: Proof Key for Code Exchange (RFC 7636) and Public Clients
+     */
+    IF (SELECT _VERSION < 2) THEN
+        _VERSION := 2;
+        RAISE NOTICE 'Upgrading to Version %', _VERSION;
+
+        ALTER TABLE auth.applications
+            ADD COLUMN auth_public           BOOLEAN     NOT NULL DEFAULT FALSE;         -- oAuth2 Public Client?
+
+        ALTER TABLE auth.grants
+            ADD COLUMN code_challenge        TEXT,                                       -- PKCE Code Challenge
+            ADD COLUMN code_challenge_method TEXT;                                       -- PKCE Code Challenge Method
+    END IF;
+
+    /*
+     * Version:     1.2.0
+     * Name:        OpenID Connect
+     * Description: Nonce for ID Tokens issued through the Authorization Code flow
+     */
+    IF (SELECT _VERSION < 3) THEN
+        _VERSION := 3;
+        RAISE NOTICE 'Upgrading to Version %', _VERSION;
+
+        ALTER TABLE auth.grants
+            ADD COLUMN nonce                 TEXT;                                       -- OpenID Connect Nonce
+    END IF;
+
+    /*
+     * Version:     1.3.0
+     * Name:        Keystore
+     * Description: Versioned Signing Keys shared between instances
+     */
+    IF (SELECT _VERSION < 4) THEN
+        _VERSION := 4;
+        RAISE NOTICE 'Upgrading to Version %', _VERSION;
+
+        CREATE TABLE auth.keys (
+            id                  TEXT            NOT NULL PRIMARY KEY,                       -- Key ID
+            usage               TEXT            NOT NULL,                                   -- Key Usage
+            algorithm           TEXT            NOT NULL,                                   -- Key Algorithm
+            created             TIMESTAMP       NOT NULL DEFAULT CURRENT_TIMESTAMP,         -- Created At
+            activates           TIMESTAMP       NOT NULL,                                   -- Used for Signing At
+            expires             TIMESTAMP,                                                  -- Retired Key Expires At
+            material            BYTEA           NOT NULL                                    -- Key Material
+        );
+        GRANT SELECT, INSERT, UPDATE, DELETE ON auth.keys           TO user_backend;
+    END IF;
+
+    /*
+     * Version:     1.4.0
+     * Name:        Client Credentials
+     * Description: Connections for Applications acting as themselves without a User
+     */
+    IF (SELECT _VERSION < 5) THEN
+        _VERSION := 5;
+        RAISE NOTICE 'Upgrading to Version %', _VERSION;
+
+        ALTER TABLE auth.applications
+            ADD COLUMN auth_scopes           INT         NOT NULL DEFAULT 0;             -- oAuth2 Client Credentials Scopes
+
+        ALTER TABLE auth.connections
+            ALTER COLUMN user_id             DROP NOT NULL;                              -- Empty for Client Credentials
+    END IF;
+
+    /*
+     * Version:     1.5.0
+     * Name:        Device Authorization
+     * Description: Device Authorization Grant (RFC 8628) for devices without a browser
+     */
+    IF (SELECT _VERSION < 6) THEN
+        _VERSION := 6;
+        RAISE NOTICE 'Upgrading to Version %', _VERSION;
+
+        ALTER TABLE auth.grants
+            ALTER COLUMN user_id             DROP NOT NULL,                              -- Empty until Device is Approved
+            ALTER COLUMN redirect_uri        DROP NOT NULL,                              -- Empty for Device Grants
+            ADD COLUMN user_code             TEXT        UNIQUE,                         -- Device User Code
+            ADD COLUMN polled                TIMESTAMP,                                  -- Device Last Polled At
+            ADD COLUMN poll_interval         INT,                                        -- Device Polling Interval (Seconds)
+            ADD COLUMN denied                BOOLEAN     NOT NULL DEFAULT FALSE;         -- Device Denied by User?
+    END IF;
+
+    /*
+     * Version:     1.6.0
+     * Name:        Consents
+     * Description: Scopes approved by Users for each Application
+     */
+    IF (SELECT _VERSION < 7) THEN
+        _VERSION := 7;
+        RAISE NOTICE 'Upgrading to Version %', _VERSION;
+
+        CREATE TABLE auth.consents (
+            user_id             BIGINT          NOT NULL,                                   -- Relevant User ID
+            application_id      BIGINT          NOT NULL,                                   -- Relevant Application ID
+            created             TIMESTAMP       NOT NULL DEFAULT CURRENT_TIMESTAMP,         -- First Approved At
+            updated             TIMESTAMP       NOT NULL DEFAULT CURRENT_TIMESTAMP,         -- Last Approved At
+            scopes              INT             NOT NULL DEFAULT 0,                         -- Approved Scopes
+            PRIMARY KEY (user_id, application_id),
+            FOREIGN KEY (user_id)        REFERENCES auth.users(id)        ON DELETE CASCADE,
+            FOREIGN KEY (application_id) REFERENCES auth.applications(id) ON DELETE CASCADE
+        );
+        GRANT SELECT, INSERT, UPDATE, DELETE ON auth.consents       TO user_backend;
+
+        -- Existing Connections were approved by their User
+        INSERT INTO auth.consents (user_id, application_id, created, updated, scopes)
+        SELECT user_id, application_id, created, updated, scopes
+        FROM auth.connections
+        WHERE user_id IS NOT NULL AND revoked = FALSE;
+    END IF;
+
+    /*
+     * Version:     1.7.0
+     * Name:        Refresh Token Families
+     * Description: Track rotated Refresh Tokens to detect their reuse
+     */
+    IF (SELECT _VERSION < 8) THEN
+        _VERSION := 8;
+        RAISE NOTICE 'Upgrading to Version %', _VERSION;
+
+        CREATE TABLE auth.refresh_tokens (
+            token               TEXT            NOT NULL PRIMARY KEY,                       -- Refresh Token
+            connection_id       BIGINT          NOT NULL,                                   -- Relevant Connection ID
+            created             TIMESTAMP       NOT NULL DEFAULT CURRENT_TIMESTAMP,         -- Issued At
+            parent              TEXT,                                                       -- Token this one Replaced
+            rotated             TIMESTAMP,                                                  -- Exchanged At
+            FOREIGN KEY (connection_id) REFERENCES auth.connections(id) ON DELETE CASCADE
+        );
+        CREATE INDEX ON auth.refresh_tokens (connection_id);
+        GRANT SELECT, INSERT, UPDATE, DELETE ON auth.refresh_tokens TO user_backend;
+
+        -- Existing Refresh Tokens begin their own Family
+        INSERT INTO auth.refresh_tokens (token, connection_id)
+        SELECT token_refresh, id
+        FROM auth.connections
+        WHERE token_refresh IS NOT NULL AND revoked = FALSE;
+    END IF;
+
+    /*
+     * Version:     1.8.0
+     * Name:        Passkeys
+     * Description: WebAuthn Credentials and their pending Ceremony Challenges
+     */
+    IF (SELECT _VERSION < 9) THEN
+        _VERSION := 9;
+        RAISE NOTICE 'Upgrading to Version %', _VERSION;
+
+        CREATE TABLE auth.passkeys (
+            id                  BIGINT          NOT NULL PRIMARY KEY,                       -- Passkey ID
+            created             TIMESTAMP       NOT NULL DEFAULT CURRENT_TIMESTAMP,         -- Created At
+            used                TIMESTAMP,                                                  -- Last Used At
+            user_id             BIGINT          NOT NULL,                                   -- Relevant User ID
+            name                TEXT            NOT NULL,                                   -- Nickname
+            credential_id       TEXT            NOT NULL UNIQUE,                            -- WebAuthn Credential ID (base64url)
+            public_key          BYTEA           NOT NULL,                                   -- COSE Encoded Public Key
+            sign_count          BIGINT          NOT NULL DEFAULT 0,                         -- Last Seen Signature Counter
+            aaguid              TEXT            NOT NULL,                                   -- Authenticator Model (hex)
+            FOREIGN KEY (user_id) REFERENCES auth.users(id) ON DELETE CASCADE
+        );
+        CREATE INDEX ON auth.passkeys (user_id);
+
+        CREATE TABLE auth.passkey_challenges (
+            challenge           TEXT            NOT NULL PRIMARY KEY,                       -- Challenge (base64url)
+            expires             TIMESTAMP       NOT NULL,                                   -- Expires At
+            user_id             BIGINT,                                                     -- Registering User ID (NULL for Assertions)
+            FOREIGN KEY (user_id) REFERENCES auth.users(id) ON DELETE CASCADE
+        );
+        GRANT SELECT, INSERT, UPDATE, DELETE ON auth.passkeys           TO user_backend;
+        GRANT SELECT, INSERT, UPDATE, DELETE ON auth.passkey_challenges TO user_backend;
+    END IF;
+
+    /*
+     * Version:     1.9.0
+     * Name:        Devices
+     * Description: Remember Devices an Account has logged in from, replacing the
+     *              single IP Address used to detect logins from new locations
+     */
+    IF (SELECT _VERSION < 10) THEN
+        _VERSION := 10;
+        RAISE NOTICE 'Upgrading to Version %', _VERSION;
+
+        CREATE TABLE auth.devices (
+            id                  BIGINT          NOT NULL PRIMARY KEY,                       -- Device ID
+            created             TIMESTAMP       NOT NULL DEFAULT CURRENT_TIMESTAMP,         -- Created At
+            used                TIMESTAMP       NOT NULL DEFAULT CURRENT_TIMESTAMP,         -- Last Used At
+            user_id             BIGINT          NOT NULL,                                   -- Relevant User ID
+            token               TEXT,                                                       -- Device Cookie (Shared by Accounts on a Browser)
+            verified            BOOLEAN         NOT NULL DEFAULT FALSE,                     -- Login Allowed by Owner?
+            trusted_until       TIMESTAMP,                                                  -- Skip MFA Until
+            ip_address          TEXT            NOT NULL,                                   -- Last Seen IP Address
+            user_agent          TEXT            NOT NULL,                                   -- Last Seen User Agent
+            UNIQUE (user_id, token),
+            FOREIGN KEY (user_id) REFERENCES auth.users(id) ON DELETE CASCADE
+        );
+        GRANT SELECT, INSERT, UPDATE, DELETE ON auth.devices TO user_backend;
+
+        -- Existing Login Locations become Devices without a Cookie
+        INSERT INTO auth.devices (id, user_id, verified, ip_address, user_agent)
+        SELECT id, id, TRUE, ip_address, ''
+        FROM auth.users
+        WHERE ip_address != '';
+        ALTER TABLE auth.users DROP COLUMN ip_address;
+    END IF;
+
+    /*
+     * Version:     1.10.0
+     * Name:        Passwordless Login
+     * Description: Magic Links and Guess Limits for emailed Passcodes
+     */
+    IF (SELECT _VERSION < 11) THEN
+        _VERSION := 11;
+        RAISE NOTICE 'Upgrading to Version %', _VERSION;
+
+        ALTER TABLE auth.users
+            ADD COLUMN token_magic              TEXT        UNIQUE,                         -- Magic Login Link Token (Expires with Passcode)
+            ADD COLUMN token_passcode_attempts  INT         NOT NULL DEFAULT 0;             -- Incorrect Passcode Guesses
+    END IF;
+
+    /*
+     * Version:     1.11.0
+     * Name:        Federated Login
+     * Description: Identities from External OpenID Connect or OAuth2 Providers
+     */
+    IF (SELECT _VERSION < 12) THEN
+        _VERSION := 12;
+        RAISE NOTICE 'Upgrading to Version %', _VERSION;
+
+        CREATE TABLE auth.identities (
+            id                  BIGINT          NOT NULL PRIMARY KEY,                       -- Identity ID
+            created             TIMESTAMP       NOT NULL DEFAULT CURRENT_TIMESTAMP,         -- Created At
+            used                TIMESTAMP       NOT NULL DEFAULT CURRENT_TIMESTAMP,         -- Last Used At
+            user_id             BIGINT          NOT NULL,                                   -- Relevant User ID
+            provider            TEXT            NOT NULL,                                   -- Provider Name
+            subject             TEXT            NOT NULL,                                   -- Subject Identifier at Provider
+            email_address       TEXT,                                                       -- Email Address at Provider
+            UNIQUE (provider, subject),
+            UNIQUE (user_id, provider),
+            FOREIGN KEY (user_id) REFERENCES auth.users(id) ON DELETE CASCADE
+        );
+        CREATE TABLE auth.federation_states (
+            state               TEXT            NOT NULL PRIMARY KEY,                       -- State Parameter
+            expires             TIMESTAMP       NOT NULL,                                   -- Expires At
+            provider            TEXT            NOT NULL,                                   -- Provider Name
+            nonce               TEXT            NOT NULL,                                   -- ID Token Nonce
+            verifier            TEXT            NOT NULL,                                   -- PKCE Code Verifier
+            link_user_id        BIGINT,                                                     -- Linking User ID (NULL for Logins)
+            user_id             BIGINT,                                                     -- Resolved User ID (Awaiting MFA)
+            FOREIGN KEY (link_user_id) REFERENCES auth.users(id) ON DELETE CASCADE,
+            FOREIGN KEY (user_id) REFERENCES auth.users(id) ON DELETE CASCADE
+        );
+        GRANT SELECT, INSERT, UPDATE, DELETE ON auth.identities         TO user_backend;
+        GRANT SELECT, INSERT, UPDATE, DELETE ON auth.federation_states  TO user_backend;
+    END IF;
+
+    /*
+     * Version:     1.12.0
+     * Name:        SAML
+     * Description: Outstanding AuthnRequests and consumed Assertions for SAML 2.0 Connections
+     */
+    IF (SELECT _VERSION < 13) THEN
+        _VERSION := 13;
+        RAISE NOTICE 'Upgrading to Version %', _VERSION;
+
+        CREATE TABLE auth.saml_requests (
+            id                  TEXT            NOT NULL PRIMARY KEY,                       -- AuthnRequest ID
+            expires             TIMESTAMP       NOT NULL,                                   -- Expires At
+            connection          TEXT            NOT NULL                                    -- Connection Name
+        );
+        CREATE TABLE auth.saml_assertions (
+            id                  TEXT            NOT NULL,                                   -- Assertion ID
+            expires             TIMESTAMP       NOT NULL,                                   -- Expires At (Forgotten afterwards)
+            connection          TEXT            NOT NULL,                                   -- Connection Name
+            PRIMARY KEY (connection, id)
+        );
+        GRANT SELECT, INSERT, UPDATE, DELETE ON auth.saml_requests      TO user_backend;
+        GRANT SELECT, INSERT, UPDATE, DELETE ON auth.saml_assertions    TO user_backend;
+    END IF;
+
+    /*
+     * Version:     1.13.0
+     * Name:        SCIM Provisioning
+     * Description: Accounts managed by Applications using SCIM 2.0
+     */
+    IF (SELECT _VERSION < 14) THEN
+        _VERSION := 14;
+        RAISE NOTICE 'Upgrading to Version %', _VERSION;
+
+        CREATE TABLE auth.scim_users (
+            user_id             BIGINT          NOT NULL PRIMARY KEY,                       -- Relevant User ID
+            created             TIMESTAMP       NOT NULL DEFAULT CURRENT_TIMESTAMP,         -- Provisioned At
+            updated             TIMESTAMP       NOT NULL DEFAULT CURRENT_TIMESTAMP,         -- Updated At
+            application_id      BIGINT          NOT NULL,                                   -- Managing Application ID
+            external_id         TEXT,                                                       -- Identifier at Provider
+            user_name           TEXT            NOT NULL,                                   -- userName at Provider
+            active              BOOLEAN         NOT NULL DEFAULT TRUE,                      -- Account may Login?
+            UNIQUE (application_id, external_id),
+            FOREIGN KEY (user_id) REFERENCES auth.users(id) ON DELETE CASCADE,
+            FOREIGN KEY (application_id) REFERENCES auth.applications(id) ON DELETE CASCADE
+        );
+        CREATE UNIQUE INDEX ON auth.scim_users (application_id, LOWER(user_name));      -- userName is not Case Exact
+        GRANT SELECT, INSERT, UPDATE, DELETE ON auth.scim_users         TO user_backend;
+    END IF;
+
+    /*
+     * Version:     1.14.0
+     * Name:        Breached Passwords
+     * Description: Accounts whose Password was found in a Data Breach
+     */
+    IF (SELECT _VERSION < 15) THEN
+        _VERSION := 15;
+        RAISE NOTICE 'Upgrading to Version %', _VERSION;
+
+        ALTER TABLE auth.users
+            ADD COLUMN password_breached     TIMESTAMP;                                  -- Password found in Breach At (Until Reset)
+    END IF;
+
+    /*
+     * Version:     1.15.0
+     * Name:        Account Lockout
+     * Description: Failed Guesses per Factor and Temporary Lockouts with Exponential Backoff
+     */
+    IF (SELECT _VERSION < 16) THEN
+        _VERSION := 16;
+        RAISE NOTICE 'Upgrading to Version %', _VERSION;
+
+        ALTER TABLE auth.users
+            ADD COLUMN lockout_until         TIMESTAMP,                                  -- Locked Until
+            ADD COLUMN lockout_count         INT             NOT NULL DEFAULT 0,         -- Lockouts since last Login (Backoff Exponent)
+            ADD COLUMN token_unlock          TEXT            UNIQUE,                     -- Unlock Token
+            ADD COLUMN token_unlock_eat      TIMESTAMP;                                  -- Unlock Token Expires At
+        CREATE TABLE auth.login_failures (
+            user_id             BIGINT          NOT NULL,                                   -- Relevant User ID
+            factor              TEXT            NOT NULL,                                   -- Guessed Factor (password, passcode, recovery, email)
+            failures            INT             NOT NULL DEFAULT 0,                         -- Failed Guesses within Window
+            updated             TIMESTAMP       NOT NULL DEFAULT CURRENT_TIMESTAMP,         -- Last Failed Guess At
+            PRIMARY KEY (user_id, factor),
+            FOREIGN KEY (user_id) REFERENCES auth.users(id) ON DELETE CASCADE
+        );
+        GRANT SELECT, INSERT, UPDATE, DELETE ON auth.login_failures     TO user_backend;
+    END IF;
+
+    /*
+     * Version:     1.16.0
+     * Name:        Login Risk Assessment
+     * Description: Audit Trail of scored Login Attempts and the Decision taken
+     */
+    IF (SELECT _VERSION < 17) THEN
+        _VERSION := 17;
+        RAISE NOTICE 'Upgrading to Version %', _VERSION;
+
+        CREATE TABLE auth.login_assessments (
+            id                  BIGINT          NOT NULL PRIMARY KEY,                       -- Assessment ID
+            created             TIMESTAMP       NOT NULL DEFAULT CURRENT_TIMESTAMP,         -- Assessed At
+            user_id             BIGINT          NOT NULL,                                   -- Relevant User ID
+            device_id           BIGINT,                                                     -- Relevant Device ID (If Known)
+            ip_address          TEXT            NOT NULL,                                   -- IP Address of Attempt
+            user_agent          TEXT            NOT NULL,                                   -- User Agent of Attempt
+            score               INT             NOT NULL,                                   -- Total Score
+            signals             TEXT[]          NOT NULL,                                   -- Signals Raised
+            decision            TEXT            NOT NULL,                                   -- Decision (allow, approve, mfa, deny)
+            approved            BOOLEAN         NOT NULL DEFAULT FALSE,                     -- Approved by Owner via Email?
+            FOREIGN KEY (user_id) REFERENCES auth.users(id) ON DELETE CASCADE,
+            FOREIGN KEY (device_id) REFERENCES auth.devices(id) ON DELETE SET NULL
+        );
+        CREATE INDEX ON auth.login_assessments (user_id, created);
+        GRANT SELECT, INSERT, UPDATE, DELETE ON auth.login_assessments  TO user_backend;
+    END IF;
+
+    /*
+     * Version:     1.17.0
+     * Name:        Session Expiry
+     * Description: Absolute Lifetime and Idle Timeout for Sessions
+     */
+    IF (SELECT _VERSION < 18) THEN
+        _VERSION := 18;
+        RAISE NOTICE 'Upgrading to Version %', _VERSION;
+
+        -- The Lifetime is configured by the Server, which backfills Expiry at Startup
+        ALTER TABLE auth.sessions
+            ADD COLUMN expires               TIMESTAMP,                                  -- Expires At (Absolute)
+            ADD COLUMN used                  TIMESTAMP;                                  -- Last Used At (Idle Timeout)
+        UPDATE auth.sessions SET used = updated;
+        ALTER TABLE auth.sessions
+            ALTER COLUMN used    SET DEFAULT CURRENT_TIMESTAMP,
+            ALTER COLUMN used    SET NOT NULL;
+        CREATE INDEX ON auth.sessions (expires);
+        CREATE INDEX ON auth.sessions (used);
+    END IF;
+
+    /*
+     * Version:     1.18.0
+     * Name:        Field Encryption
+     * Description: Blind Index for Encrypted Email Addresses
+     */
+    IF (SELECT _VERSION < 19) THEN
+        _VERSION := 19;
+        RAISE NOTICE 'Upgrading to Version %', _VERSION;
+
+        -- Existing Rows are Encrypted and Indexed by the Backend on Startup
+        ALTER TABLE auth.users
+            ADD COLUMN email_index           TEXT            UNIQUE;                     -- Blind Index of Email Address
+    END IF;
+
+    /*
+     * Version:     1.19.0
+     * Name:        Email Outbox
+     * Description: Durable Queue of Outgoing Emails with Retries and Dead-Lettering
+     */
+    IF (SELECT _VERSION < 20) THEN
+        _VERSION := 20;
+        RAISE NOTICE 'Upgrading to Version %', _VERSION;
+
+        CREATE TABLE auth.email_outbox (
+            id                  BIGINT          NOT NULL PRIMARY KEY,                       -- Email ID
+            created             TIMESTAMP       NOT NULL DEFAULT CURRENT_TIMESTAMP,         -- Enqueued At
+            idempotency_key     TEXT            NOT NULL UNIQUE,                            -- Duplicate Enqueues are ignored
+            template            TEXT            NOT NULL,                                   -- Template Name
+            address             TEXT            NOT NULL,                                   -- Recipient Address (Encrypted)
+            subject             TEXT            NOT NULL,                                   -- Subject Line
+            body                TEXT            NOT NULL,                                   -- Rendered Body (Encrypted)
+            attempts            INT             NOT NULL DEFAULT 0,                         -- Delivery Attempts
+            attempt_after       TIMESTAMP       NOT NULL DEFAULT CURRENT_TIMESTAMP,         -- Next Attempt At (or Lease Expiry)
+            last_error          TEXT,                                                       -- Error of Last Attempt
+            sent                TIMESTAMP,                                                  -- Delivered At
+            failed              TIMESTAMP                                                   -- Dead-Lettered At
+        );
+        CREATE INDEX ON auth.email_outbox (attempt_after) WHERE sent IS NULL AND failed IS NULL;
+        GRANT SELECT, INSERT, UPDATE, DELETE ON auth.email_outbox       TO user_backend;
+    END IF;
+
+    /*
+     * Version:     1.20.0
+     * Name:        Email Alternatives
+     * Description: Plain-Text Bodies and Headers for Outbox Emails
+     */
+    IF (SELECT _VERSION < 21) THEN
+        _VERSION := 21;
+        RAISE NOTICE 'Upgrading to Version %', _VERSION;
+
+        -- Existing Rows are Encrypted by the Backend on Startup
+        ALTER TABLE auth.email_outbox
+            ADD COLUMN text                  TEXT            NOT NULL DEFAULT '',        -- Plain-Text Body (Encrypted)
+            ADD COLUMN headers               JSONB           NOT NULL DEFAULT '{}';      -- Additional Headers, e.g. Message-ID
+    END IF;
+
+    /*
+     * Version:     1.21.0
+     * Name:        Field Indexes
+     * Description: Blind Indexes for Encrypted Columns used in Lookups
+     */
+    IF (SELECT _VERSION < 22) THEN
+        _VERSION := 22;
+        RAISE NOTICE 'Upgrading to Version %', _VERSION;
+
+        -- Existing Rows are Encrypted and Indexed by the Backend on Startup
+        ALTER TABLE auth.devices
+            ADD COLUMN ip_index              TEXT;                                       -- Blind Index of IP Address
+        CREATE INDEX ON auth.devices (user_id, ip_index);
+        ALTER TABLE auth.scim_users
+            ADD COLUMN user_name_index       TEXT;                                       -- Blind Index of userName
+        DROP INDEX IF EXISTS auth.scim_users_application_id_lower_idx;
+        CREATE UNIQUE INDEX ON auth.scim_users (application_id, user_name_index);
+    END IF;
+
+    /*
+     * Version:     1.22.0
+     * Name:        Key Wrapping
+     * Description: Keystore Material Encrypted with a Key from the Environment
+     */
+    IF (SELECT _VERSION < 23) THEN
+        _VERSION := 23;
+        RAISE NOTICE 'Upgrading to Version %', _VERSION;
+
+        -- Existing Keys are Wrapped by the Backend on Startup
+        ALTER TABLE auth.keys
+            ADD COLUMN wrapped               BOOLEAN         NOT NULL DEFAULT FALSE;     -- Material Wrapped with KEYSTORE_WRAP_KEY?
+    END IF;
+
+    /*
+     * Version:     1.23.0
+     * Name:        Login Passcodes
+     * Description: Emailed Login Codes kept apart from Escalation Codes
+     */
+    IF (SELECT _VERSION < 24) THEN
+        _VERSION := 24;
+        RAISE NOTICE 'Upgrading to Version %', _VERSION;
+
+        ALTER TABLE auth.users
+            ADD COLUMN token_magic_passcode     TEXT,                                       -- Emailed Login Code
+            ADD COLUMN token_magic_eat          TIMESTAMP,                                  -- Magic Login Link and Code Expires At
+            ADD COLUMN token_magic_attempts     INT         NOT NULL DEFAULT 0;             -- Incorrect Login Code Guesses
+
+        -- Pending Links shared their Code and Expiry with Escalation, so they're discarded
+        UPDATE auth.users SET token_magic = NULL WHERE token_magic IS NOT NULL;
+    END IF;
+
     /*
      * HOUSEKEEPING
      *  Uses the "pg_cron" extension to enable automated maintenance without
@@ -182,6 +680,15 @@ BEGIN
         $$;
         CALL pgx_reschedule('0 4 * * *',   'Delete Revoked Sessions', $$ DELETE FROM auth.sessions WHERE revoked = TRUE $$);
         CALL pgx_reschedule('0 4 * * *',   'Cleanup Grants',          $$ TRUNCATE auth.grants                           $$);
+        CALL pgx_reschedule('0 * * * *',   'Cleanup Client Tokens',   $$ DELETE FROM auth.connections WHERE user_id IS NULL AND token_expires < NOW() $$);
+        CALL pgx_reschedule('0 * * * *',   'Cleanup Passkey Challenges', $$ DELETE FROM auth.passkey_challenges WHERE expires < NOW() $$);
+        CALL pgx_reschedule('0 4 * * *',   'Forget Stale Devices',    $$ DELETE FROM auth.devices WHERE used < NOW() - INTERVAL '1 year' $$);
+        CALL pgx_reschedule('0 * * * *',   'Cleanup Federation States', $$ DELETE FROM auth.federation_states WHERE expires < NOW() $$);
+        CALL pgx_reschedule('0 * * * *',   'Cleanup SAML Requests',   $$ DELETE FROM auth.saml_requests WHERE expires < NOW() $$);
+        CALL pgx_reschedule('0 * * * *',   'Cleanup SAML Assertions', $$ DELETE FROM auth.saml_assertions WHERE expires < NOW() $$);
+        CALL pgx_reschedule('0 4 * * *',   'Forget Login Assessments', $$ DELETE FROM auth.login_assessments WHERE created < NOW() - INTERVAL '1 year' $$);
+        CALL pgx_reschedule('0 4 * * *',   'Forget Login Failures',   $$ DELETE FROM auth.login_failures WHERE updated < NOW() - INTERVAL '1 day' $$);
+        CALL pgx_reschedule('0 4 * * *',   'Cleanup Email Outbox',    $$ DELETE FROM auth.email_outbox WHERE sent < NOW() - INTERVAL '7 days' OR failed < NOW() - INTERVAL '30 days' $$);
     END IF;
 
     /*
@@ -16,6 +16,20 @@ import (
 func main() {
 	time.Local = time.UTC
 
+	// Commands override the default startup flow and exit once complete
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "debug_database_apply_schema":
+			core.DebugDatabaseApplySchema()
+		case "debug_email_render_template":
+			core.DebugEmailRenderTemplates()
+		case "admin_reencrypt_fields":
+			core.AdminReencryptFields(os.Args[2:])
+		default:
+			tools.LoggerMain.Fatal("Unknown Command", os.Args[1])
+		}
+	}
+
 	// Startup Services
 	// 	Logger are unique and must be started specifically,
 	// 	everything else can be started at the same time
@@ -31,6 +45,12 @@ func main() {
 		tools.SetupEmailProvider,
 		tools.SetupRatelimitProvider,
 		tools.SetupStorageProvider,
+		tools.SetupFederation,
+		tools.SetupSAML,
+		tools.SetupSCIM,
+		tools.SetupPassword,
+		tools.SetupBreaches,
+		tools.SetupRisk,
 	} {
 		syncWg.Add(1)
 		go func() {
@@ -39,6 +59,11 @@ func main() {
 		}()
 	}
 	syncWg.Wait()
+	tools.SetupKeystore(stopCtx, &stopWg) // Depends on Database
+	tools.SetupSessions(stopCtx, &stopWg) // Depends on Database
+	tools.SetupTokens(stopCtx, &stopWg)   // Depends on Database
+	tools.SetupFields(stopCtx, &stopWg)   // Depends on Keystore
+	tools.SetupOutbox(stopCtx, &stopWg)   // Depends on Keystore
 	go StartupHTTP(stopCtx, &stopWg)
 
 	// Await Shutdown Signal
@@ -0,0 +1,45 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+func DELETE_OAuth2_Device(w http.ResponseWriter, r *http.Request) {
+
+	var Body struct {
+		UserCode string `query:"user_code" validate:"required"`
+	}
+	if !tools.ValidateQuery(w, r, &Body) {
+		return
+	}
+	ok, userCode := tools.OAuth2NormalizeUserCode(Body.UserCode)
+	if !ok {
+		tools.SendClientError(w, r, tools.ERROR_OAUTH2_FORM_INVALID_USER_CODE)
+		return
+	}
+	ctx, cancel := tools.NewContext()
+	defer cancel()
+
+	// Deny Pending Grant, the Device will be told on its next poll
+	tag, err := tools.Database.Exec(ctx,
+		`UPDATE auth.grants SET
+			denied = TRUE
+		WHERE user_code = $1
+		AND user_id IS NULL
+		AND denied = FALSE
+		AND expires > NOW()`,
+		userCode,
+	)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		tools.SendClientError(w, r, tools.ERROR_OAUTH2_FORM_INVALID_USER_CODE)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
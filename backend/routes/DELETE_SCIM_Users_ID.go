@@ -0,0 +1,33 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bakonpancakz/template-auth/tools"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func DELETE_SCIM_Users_ID(w http.ResponseWriter, r *http.Request) {
+
+	session := tools.GetSession(r)
+	ctx, cancel := tools.NewContext()
+	defer cancel()
+
+	user, ok := fetchSCIMUser(ctx, w, r, session.ApplicationID)
+	if !ok {
+		return
+	}
+
+	// Deprovision Account
+	if err := deleteAccount(ctx, user.ID, "Deprovisioned by your Organization"); errors.Is(err, pgx.ErrNoRows) {
+		tools.SendSCIMError(w, r, tools.ERROR_UNKNOWN_USER)
+		return
+	} else if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
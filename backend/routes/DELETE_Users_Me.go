@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"context"
 	"errors"
 	"net/http"
 
@@ -12,10 +13,6 @@ import (
 func DELETE_Users_Me(w http.ResponseWriter, r *http.Request) {
 
 	session := tools.GetSession(r)
-	if session.ApplicationID != tools.SESSION_NO_APPLICATION_ID {
-		tools.SendClientError(w, r, tools.ERROR_OAUTH2_USERS_ONLY)
-		return
-	}
 	if !session.Elevated {
 		tools.SendClientError(w, r, tools.ERROR_MFA_ESCALATION_REQUIRED)
 		return
@@ -23,6 +20,33 @@ func DELETE_Users_Me(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := tools.NewContext()
 	defer cancel()
 
+	// Delete Account and its Images
+	if err := deleteAccount(ctx, session.UserID, "User Request"); errors.Is(err, pgx.ErrNoRows) {
+		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_USER)
+		return
+	} else if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
+	// Clear Session
+	http.SetCookie(w, &http.Cookie{
+		Name:     tools.HTTP_COOKIE_NAME,
+		Value:    "DELETED",
+		Path:     "/",
+		Domain:   tools.HTTP_COOKIE_DOMAIN,
+		MaxAge:   -1,
+		Secure:   tools.HTTP_COOKIE_SECURE,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Delete Account, its Images and notify its Owner. Everything else is
+// expected to cascade. Returns pgx.ErrNoRows if the Account does not exist.
+func deleteAccount(ctx context.Context, userID int64, reason string) error {
+
 	// Fetch User Account and Profile
 	var imagePaths = make([]string, 0, 3)
 	var profile tools.DatabaseProfile
@@ -34,22 +58,17 @@ func DELETE_Users_Me(w http.ResponseWriter, r *http.Request) {
 		FROM auth.users u
 		JOIN auth.profiles p ON u.id = p.id
 		WHERE u.id = $1`,
-		session.UserID,
+		userID,
 	).Scan(
 		&user.ID,
-		&user.EmailAddress,
+		tools.Decrypted(&user.EmailAddress),
 		&user.EmailVerified,
 		&profile.Displayname,
 		&profile.AvatarHash,
 		&profile.BannerHash,
 	)
-	if errors.Is(err, pgx.ErrNoRows) {
-		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_USER)
-		return
-	}
 	if err != nil {
-		tools.SendServerError(w, r, err)
-		return
+		return err
 	}
 
 	// Fetch User Applications
@@ -58,8 +77,7 @@ func DELETE_Users_Me(w http.ResponseWriter, r *http.Request) {
 		user.ID,
 	)
 	if err != nil {
-		tools.SendServerError(w, r, err)
-		return
+		return err
 	}
 	defer rows.Close()
 
@@ -68,8 +86,7 @@ func DELETE_Users_Me(w http.ResponseWriter, r *http.Request) {
 		var applicationID int64
 		var applicationIcon *string
 		if err := rows.Scan(&applicationID, &applicationIcon); err != nil {
-			tools.SendServerError(w, r, err)
-			return
+			return err
 		}
 		if applicationIcon != nil {
 			imagePaths = append(imagePaths,
@@ -91,36 +108,24 @@ func DELETE_Users_Me(w http.ResponseWriter, r *http.Request) {
 	// Delete Account (Assuming this cascades properly)
 	tag, err := tools.Database.Exec(ctx, "DELETE FROM auth.users WHERE id = $1", user.ID)
 	if err != nil {
-		tools.SendServerError(w, r, err)
-		return
+		return err
 	}
 	if tag.RowsAffected() == 0 {
-		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_USER)
-		return
+		return pgx.ErrNoRows
 	}
 
 	// Background Tasks
 	// 	Delete Account Images
 	//	Notify Account Owner of Deletion
 	go tools.Storage.Delete(imagePaths...)
-	go tools.TemplateNotifyUserDeleted(
-		user.EmailAddress,
-		tools.LocalsNotifyUserDeleted{
-			Displayname: profile.Displayname,
-			Reason:      "User Request",
-		},
-	)
-
-	// Clear Session
-	http.SetCookie(w, &http.Cookie{
-		Name:     tools.HTTP_COOKIE_NAME,
-		Value:    "DELETED",
-		Path:     "/",
-		Domain:   tools.HTTP_COOKIE_DOMAIN,
-		MaxAge:   -1,
-		Secure:   tools.HTTP_COOKIE_SECURE,
-		HttpOnly: true,
-		SameSite: http.SameSiteLaxMode,
+	tools.EmailAsync(func() {
+		tools.TemplateNotifyUserDeleted(
+			user.EmailAddress,
+			tools.LocalsNotifyUserDeleted{
+				Displayname: profile.Displayname,
+				Reason:      reason,
+			},
+		)
 	})
-	w.WriteHeader(http.StatusNoContent)
+	return nil
 }
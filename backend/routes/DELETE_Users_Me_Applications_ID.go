@@ -10,10 +10,6 @@ import (
 func DELETE_Users_Me_Applications_ID(w http.ResponseWriter, r *http.Request) {
 
 	session := tools.GetSession(r)
-	if session.ApplicationID != tools.SESSION_NO_APPLICATION_ID {
-		tools.SendClientError(w, r, tools.ERROR_OAUTH2_USERS_ONLY)
-		return
-	}
 	if !session.Elevated {
 		tools.SendClientError(w, r, tools.ERROR_MFA_ESCALATION_REQUIRED)
 		return
@@ -12,10 +12,6 @@ import (
 func DELETE_Users_Me_Applications_ID_Icon(w http.ResponseWriter, r *http.Request) {
 
 	session := tools.GetSession(r)
-	if session.ApplicationID != tools.SESSION_NO_APPLICATION_ID {
-		tools.SendClientError(w, r, tools.ERROR_OAUTH2_USERS_ONLY)
-		return
-	}
 
 	snowflake, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
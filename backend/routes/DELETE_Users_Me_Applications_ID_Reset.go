@@ -10,10 +10,6 @@ import (
 func DELETE_Users_Me_Applications_ID_Reset(w http.ResponseWriter, r *http.Request) {
 
 	session := tools.GetSession(r)
-	if session.ApplicationID != tools.SESSION_NO_APPLICATION_ID {
-		tools.SendClientError(w, r, tools.ERROR_OAUTH2_USERS_ONLY)
-		return
-	}
 	if !session.Elevated {
 		tools.SendClientError(w, r, tools.ERROR_MFA_ESCALATION_REQUIRED)
 		return
@@ -28,7 +24,11 @@ func DELETE_Users_Me_Applications_ID_Reset(w http.ResponseWriter, r *http.Reques
 	defer cancel()
 
 	// Generate New Secret Key for Application
-	secretPlain, secretHashed := tools.GenerateApplicationSecret()
+	secretPlain, secretHashed, err := tools.GenerateApplicationSecret()
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
 	tag, err := tools.Database.Exec(ctx,
 		`UPDATE auth.applications SET
 			updated = CURRENT_TIMESTAMP,
@@ -47,6 +47,19 @@ func DELETE_Users_Me_Applications_ID_Reset(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// Revoke Tokens issued using the Previous Secret
+	if _, err := tools.Database.Exec(ctx,
+		`UPDATE auth.connections SET
+			updated = CURRENT_TIMESTAMP,
+			revoked = TRUE
+		WHERE application_id = $1
+		AND user_id IS NULL`,
+		snowflake,
+	); err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
 	// Organize Application
 	tools.SendJSON(w, r, http.StatusOK, map[string]any{
 		"secret": secretPlain,
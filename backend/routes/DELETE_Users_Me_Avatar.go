@@ -11,10 +11,6 @@ import (
 func DELETE_Users_Me_Avatar(w http.ResponseWriter, r *http.Request) {
 
 	session := tools.GetSession(r)
-	if session.ApplicationID != tools.SESSION_NO_APPLICATION_ID {
-		tools.SendClientError(w, r, tools.ERROR_OAUTH2_USERS_ONLY)
-		return
-	}
 	ctx, cancel := tools.NewContext()
 	defer cancel()
 
@@ -1,19 +1,18 @@
 package routes
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/bakonpancakz/template-auth/tools"
+
+	"github.com/jackc/pgx/v5"
 )
 
 func DELETE_Users_Me_Connections_ID(w http.ResponseWriter, r *http.Request) {
 
 	session := tools.GetSession(r)
-	if session.ApplicationID != tools.SESSION_NO_APPLICATION_ID {
-		tools.SendClientError(w, r, tools.ERROR_OAUTH2_USERS_ONLY)
-		return
-	}
 	if !session.Elevated {
 		tools.SendClientError(w, r, tools.ERROR_MFA_ESCALATION_REQUIRED)
 		return
@@ -28,20 +27,32 @@ func DELETE_Users_Me_Connections_ID(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	// Revoke Relevant Connection
-	tag, err := tools.Database.Exec(ctx,
+	var connection tools.DatabaseConnection
+	err = tools.Database.QueryRow(ctx,
 		`UPDATE auth.connections SET
 			updated = CURRENT_TIMESTAMP,
 			revoked = TRUE
-		WHERE id = $1 AND user_id = $2`,
+		WHERE id = $1 AND user_id = $2
+		RETURNING application_id`,
 		snowflake,
 		session.UserID,
-	)
+	).Scan(&connection.ApplicationID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_CONNECTION)
+		return
+	}
 	if err != nil {
 		tools.SendServerError(w, r, err)
 		return
 	}
-	if tag.RowsAffected() == 0 {
-		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_CONNECTION)
+
+	// Forget Approval so the User is prompted again
+	if _, err := tools.Database.Exec(ctx,
+		"DELETE FROM auth.consents WHERE user_id = $1 AND application_id = $2",
+		session.UserID,
+		connection.ApplicationID,
+	); err != nil {
+		tools.SendServerError(w, r, err)
 		return
 	}
 
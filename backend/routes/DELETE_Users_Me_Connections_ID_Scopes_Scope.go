@@ -0,0 +1,88 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bakonpancakz/template-auth/tools"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func DELETE_Users_Me_Connections_ID_Scopes_Scope(w http.ResponseWriter, r *http.Request) {
+
+	session := tools.GetSession(r)
+	if !session.Elevated {
+		tools.SendClientError(w, r, tools.ERROR_MFA_ESCALATION_REQUIRED)
+		return
+	}
+
+	snowflake, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_CONNECTION)
+		return
+	}
+	scope, ok := tools.SCOPE_HASH[r.PathValue("scope")]
+	if !ok {
+		tools.SendClientError(w, r, tools.ERROR_OAUTH2_FORM_INVALID_SCOPE)
+		return
+	}
+	ctx, cancel := tools.NewContext()
+	defer cancel()
+
+	// Remove Scope from Relevant Connection, takes effect immediately
+	var connection tools.DatabaseConnection
+	err = tools.Database.QueryRow(ctx,
+		`UPDATE auth.connections SET
+			updated = CURRENT_TIMESTAMP,
+			scopes  = scopes & ~$1::INT
+		WHERE id = $2 AND user_id = $3 AND revoked = FALSE
+		RETURNING application_id, scopes`,
+		scope.Flag,
+		snowflake,
+		session.UserID,
+	).Scan(
+		&connection.ApplicationID,
+		&connection.Scopes,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_CONNECTION)
+		return
+	}
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
+	// Remove Scope from Approval so the User is prompted again
+	var consent tools.DatabaseConsent
+	var consentUpdated *time.Time
+	err = tools.Database.QueryRow(ctx,
+		`UPDATE auth.consents SET
+			scopes = scopes & ~$1::INT
+		WHERE user_id = $2 AND application_id = $3
+		RETURNING updated, scopes`,
+		scope.Flag,
+		session.UserID,
+		connection.ApplicationID,
+	).Scan(
+		&consentUpdated,
+		&consent.Scopes,
+	)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
+	// Organize Connection
+	tools.SendJSON(w, r, http.StatusOK, map[string]any{
+		"id":     snowflake,
+		"scopes": connection.Scopes,
+		"consent": map[string]any{
+			"scopes":   consent.Scopes,
+			"approved": consentUpdated,
+		},
+	})
+}
@@ -0,0 +1,35 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+func DELETE_Users_Me_Security_Identities_Provider(w http.ResponseWriter, r *http.Request) {
+
+	session := tools.GetSession(r)
+	if !session.Elevated {
+		tools.SendClientError(w, r, tools.ERROR_MFA_ESCALATION_REQUIRED)
+		return
+	}
+	ctx, cancel := tools.NewContext()
+	defer cancel()
+
+	// Unlink Relevant Identity, the Account remains reachable by Email Login
+	tag, err := tools.Database.Exec(ctx,
+		"DELETE FROM auth.identities WHERE user_id = $1 AND provider = $2",
+		session.UserID,
+		r.PathValue("provider"),
+	)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_IDENTITY)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,42 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+func DELETE_Users_Me_Security_Passkeys_ID(w http.ResponseWriter, r *http.Request) {
+
+	session := tools.GetSession(r)
+	if !session.Elevated {
+		tools.SendClientError(w, r, tools.ERROR_MFA_ESCALATION_REQUIRED)
+		return
+	}
+
+	snowflake, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_PASSKEY)
+		return
+	}
+	ctx, cancel := tools.NewContext()
+	defer cancel()
+
+	// Delete Relevant Passkey
+	tag, err := tools.Database.Exec(ctx,
+		"DELETE FROM auth.passkeys WHERE id = $1 AND user_id = $2",
+		snowflake,
+		session.UserID,
+	)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_PASSKEY)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
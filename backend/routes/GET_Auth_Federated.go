@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+func GET_Auth_Federated(w http.ResponseWriter, r *http.Request) {
+	tools.SendJSON(w, r, http.StatusOK, slices.Sorted(maps.Keys(tools.Federation)))
+}
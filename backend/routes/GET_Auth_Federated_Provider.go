@@ -0,0 +1,64 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+func GET_Auth_Federated_Provider(w http.ResponseWriter, r *http.Request) {
+
+	provider, ok := tools.Federation[r.PathValue("provider")]
+	if !ok {
+		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_PROVIDER)
+		return
+	}
+	ctx, cancel := tools.NewContext()
+	defer cancel()
+
+	// Generate Authorization URL for Login
+	url, err := beginFederation(ctx, w, provider, nil)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
+	tools.SendJSON(w, r, http.StatusOK, map[string]any{
+		"url": url,
+	})
+}
+
+// Store a New Federation State and bind it to this Browser, returns the URL
+// the User should be sent to. Set linkUserID to attach the Identity to an Account.
+func beginFederation(ctx context.Context, w http.ResponseWriter, provider *tools.FederationProvider, linkUserID *int64) (string, error) {
+	state := tools.DatabaseFederationState{
+		State:      tools.GenerateRandomToken(),
+		Expires:    time.Now().Add(tools.LIFETIME_FEDERATION_STATE),
+		Provider:   provider.Name,
+		Nonce:      tools.GenerateRandomToken(),
+		Verifier:   tools.GenerateRandomToken(),
+		LinkUserID: linkUserID,
+	}
+	url, err := provider.AuthorizationURL(ctx, state.State, state.Nonce, state.Verifier)
+	if err != nil {
+		return "", err
+	}
+	if _, err := tools.Database.Exec(ctx,
+		`INSERT INTO auth.federation_states (
+			state, expires, provider, nonce, verifier, link_user_id
+		) VALUES ($1, $2, $3, $4, $5, $6)`,
+		state.State,
+		state.Expires,
+		state.Provider,
+		state.Nonce,
+		state.Verifier,
+		state.LinkUserID,
+	); err != nil {
+		return "", err
+	}
+
+	setFederationCookie(w, state.State)
+	return url, nil
+}
@@ -0,0 +1,43 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+func GET_Auth_Login_Passkey(w http.ResponseWriter, r *http.Request) {
+
+	ctx, cancel := tools.NewContext()
+	defer cancel()
+
+	// Generate Assertion Challenge, any Passkey may answer it
+	challenge, err := issuePasskeyChallenge(ctx, nil)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
+	// Organize Request Options (PublicKeyCredentialRequestOptionsJSON)
+	tools.SendJSON(w, r, http.StatusOK, map[string]any{
+		"challenge":        challenge,
+		"rpId":             tools.WEBAUTHN_RP_ID,
+		"timeout":          tools.LIFETIME_WEBAUTHN_CHALLENGE.Milliseconds(),
+		"userVerification": "required",
+		"allowCredentials": []any{},
+	})
+}
+
+// Store a New Passkey Challenge, Registrations are bound to their User
+func issuePasskeyChallenge(ctx context.Context, userID *int64) (string, error) {
+	challenge := tools.GeneratePasskeyChallenge()
+	_, err := tools.Database.Exec(ctx,
+		"INSERT INTO auth.passkey_challenges (challenge, expires, user_id) VALUES ($1, $2, $3)",
+		challenge,
+		time.Now().Add(tools.LIFETIME_WEBAUTHN_CHALLENGE),
+		userID,
+	)
+	return challenge, err
+}
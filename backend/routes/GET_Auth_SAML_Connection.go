@@ -0,0 +1,80 @@
+package routes
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+func GET_Auth_SAML_Connection(w http.ResponseWriter, r *http.Request) {
+
+	connection, ok := tools.SAML[r.PathValue("connection")]
+	if !ok {
+		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_PROVIDER)
+		return
+	}
+	ctx, cancel := tools.NewContext()
+	defer cancel()
+
+	// Generate Signed AuthnRequest
+	requestID := tools.GenerateSAMLID()
+	url, err := connection.AuthnRequestURL(ctx, requestID)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
+	// The Response must answer an Outstanding Request
+	if _, err := tools.Database.Exec(ctx,
+		`INSERT INTO auth.saml_requests (
+			id, expires, connection
+		) VALUES ($1, $2, $3)`,
+		requestID,
+		time.Now().Add(tools.LIFETIME_SAML_REQUEST),
+		connection.Name,
+	); err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
+	binding, err := tools.SAMLRequestBinding(requestID)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+	setSAMLCookie(w, binding)
+
+	tools.SendJSON(w, r, http.StatusOK, map[string]any{
+		"url": url,
+	})
+}
+
+// Bind the Request to this Browser, the Identity Provider posts its Response
+// cross-site so the Cookie must be SameSite=None which in turn requires Secure
+func setSAMLCookie(w http.ResponseWriter, binding string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     tools.HTTP_SAML_COOKIE_NAME,
+		Value:    binding,
+		Path:     "/auth/saml/",
+		Domain:   tools.HTTP_COOKIE_DOMAIN,
+		MaxAge:   int(tools.LIFETIME_SAML_REQUEST.Seconds()),
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteNoneMode,
+	})
+}
+
+// Remove the SAML Cookie once its Request is Consumed
+func clearSAMLCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     tools.HTTP_SAML_COOKIE_NAME,
+		Value:    "",
+		Path:     "/auth/saml/",
+		Domain:   tools.HTTP_COOKIE_DOMAIN,
+		MaxAge:   -1,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteNoneMode,
+	})
+}
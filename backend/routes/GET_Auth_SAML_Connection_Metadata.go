@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+func GET_Auth_SAML_Connection_Metadata(w http.ResponseWriter, r *http.Request) {
+
+	connection, ok := tools.SAML[r.PathValue("connection")]
+	if !ok {
+		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_PROVIDER)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/samlmetadata+xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(connection.Metadata())
+}
@@ -12,16 +12,16 @@ import (
 func GET_OAuth2_Authorize(w http.ResponseWriter, r *http.Request) {
 
 	session := tools.GetSession(r)
-	if session.ApplicationID != tools.SESSION_NO_APPLICATION_ID {
-		tools.SendClientError(w, r, tools.ERROR_OAUTH2_USERS_ONLY)
-		return
-	}
 	var Body struct {
 		State        *string `query:"state"`
 		ClientID     int64   `query:"client_id" validate:"required"`
 		ResponseType string  `query:"response_type" validate:"required"`
 		RedirectURI  string  `query:"redirect_uri" validate:"required,uri"`
 		ScopesString string  `query:"scope" validate:"required"`
+		Challenge    *string `query:"code_challenge"`
+		Method       string  `query:"code_challenge_method"`
+		Nonce        *string `query:"nonce"`
+		Prompt       string  `query:"prompt"`
 	}
 	if !tools.ValidateQuery(w, r, &Body) {
 		return
@@ -31,12 +31,20 @@ func GET_OAuth2_Authorize(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse Scopes
+	// Parse Scopes, Users cannot grant those meant for Applications
 	ok, requestedScopes := tools.OAuth2StringToScopes(Body.ScopesString)
-	if !ok {
+	if !ok || tools.OAuth2ScopesMachine(requestedScopes) != 0 {
 		tools.SendClientError(w, r, tools.ERROR_OAUTH2_FORM_INVALID_SCOPE)
 		return
 	}
+
+	// Parse Code Challenge
+	if Body.Challenge != nil {
+		if ok, _ := tools.OAuth2ValidateCodeChallenge(*Body.Challenge, Body.Method); !ok {
+			tools.SendClientError(w, r, tools.ERROR_OAUTH2_FORM_INVALID_CHALLENGE)
+			return
+		}
+	}
 	ctx, cancel := tools.NewContext()
 	defer cancel()
 
@@ -44,7 +52,7 @@ func GET_OAuth2_Authorize(w http.ResponseWriter, r *http.Request) {
 	var application tools.DatabaseApplication
 	err := tools.Database.QueryRow(ctx,
 		`SELECT
-			id, created, name, icon_hash, auth_redirects
+			id, created, name, icon_hash, auth_redirects, auth_public
 		FROM auth.applications
 		WHERE id = $1`,
 		Body.ClientID,
@@ -54,6 +62,7 @@ func GET_OAuth2_Authorize(w http.ResponseWriter, r *http.Request) {
 		&application.Name,
 		&application.IconHash,
 		&application.AuthRedirects,
+		&application.AuthPublic,
 	)
 	if errors.Is(err, pgx.ErrNoRows) {
 		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_APPLICATION)
@@ -71,6 +80,39 @@ func GET_OAuth2_Authorize(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Public Clients cannot keep a secret and must use PKCE instead
+	if application.AuthPublic && Body.Challenge == nil {
+		tools.SendClientError(w, r, tools.ERROR_OAUTH2_FORM_INVALID_CHALLENGE)
+		return
+	}
+
+	// Fetch Scopes previously Approved by User
+	var consent tools.DatabaseConsent
+	err = tools.Database.QueryRow(ctx,
+		`SELECT
+			scopes
+		FROM auth.consents
+		WHERE user_id = $1
+		AND application_id = $2`,
+		session.UserID,
+		application.ID,
+	).Scan(&consent.Scopes)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		tools.SendServerError(w, r, err)
+		return
+	}
+	consented := err == nil && (requestedScopes & ^consent.Scopes) == 0
+
+	// Prompt can be skipped if the User already approved these Scopes,
+	// the Grant itself is only issued by the POST Endpoint
+	if Body.Prompt == "none" && !consented {
+		tools.SendClientError(w, r, tools.ERROR_OAUTH2_CONSENT_REQUIRED)
+		return
+	}
+	if Body.Prompt == "consent" {
+		consented = false
+	}
+
 	// Fetch Profile for Account
 	var profile tools.DatabaseProfile
 	err = tools.Database.QueryRow(ctx,
@@ -95,9 +137,12 @@ func GET_OAuth2_Authorize(w http.ResponseWriter, r *http.Request) {
 
 	// Organize Application and Profile
 	tools.SendJSON(w, r, http.StatusOK, map[string]any{
-		"redirect": requestedRedirect,
-		"scopes":   requestedScopes,
-		"state":    Body.State,
+		"redirect":    requestedRedirect,
+		"scopes":      requestedScopes,
+		"permissions": tools.OAuth2ScopesDescribe(requestedScopes),
+		"state":       Body.State,
+		"nonce":       Body.Nonce,
+		"consented":   consented,
 		"application": map[string]any{
 			"id":      application.ID,
 			"created": application.Created,
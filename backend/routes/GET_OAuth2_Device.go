@@ -0,0 +1,97 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bakonpancakz/template-auth/tools"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func GET_OAuth2_Device(w http.ResponseWriter, r *http.Request) {
+
+	session := tools.GetSession(r)
+	var Body struct {
+		UserCode string `query:"user_code" validate:"required"`
+	}
+	if !tools.ValidateQuery(w, r, &Body) {
+		return
+	}
+	ok, userCode := tools.OAuth2NormalizeUserCode(Body.UserCode)
+	if !ok {
+		tools.SendClientError(w, r, tools.ERROR_OAUTH2_FORM_INVALID_USER_CODE)
+		return
+	}
+	ctx, cancel := tools.NewContext()
+	defer cancel()
+
+	// Fetch Pending Grant and Relevant Application
+	var grant tools.DatabaseGrant
+	var application tools.DatabaseApplication
+	err := tools.Database.QueryRow(ctx,
+		`SELECT
+			g.scopes, a.id, a.created, a.name, a.icon_hash
+		FROM auth.grants g
+		JOIN auth.applications a ON a.id = g.application_id
+		WHERE g.user_code = $1
+		AND g.user_id IS NULL
+		AND g.denied = FALSE
+		AND g.expires > NOW()`,
+		userCode,
+	).Scan(
+		&grant.Scopes,
+		&application.ID,
+		&application.Created,
+		&application.Name,
+		&application.IconHash,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		tools.SendClientError(w, r, tools.ERROR_OAUTH2_FORM_INVALID_USER_CODE)
+		return
+	}
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
+	// Fetch Profile for Account
+	var profile tools.DatabaseProfile
+	err = tools.Database.QueryRow(ctx,
+		`SELECT
+			id, displayname, avatar_hash
+		FROM auth.profiles
+		WHERE id = $1`,
+		session.UserID,
+	).Scan(
+		&profile.ID,
+		&profile.Displayname,
+		&profile.AvatarHash,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_USER)
+		return
+	}
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
+	// Organize Application and Profile
+	tools.SendJSON(w, r, http.StatusOK, map[string]any{
+		"user_code":   userCode,
+		"scopes":      grant.Scopes,
+		"permissions": tools.OAuth2ScopesDescribe(grant.Scopes),
+		"application": map[string]any{
+			"id":      application.ID,
+			"created": application.Created,
+			"name":    application.Name,
+			"icon":    application.IconHash,
+		},
+		"user": map[string]any{
+			"id":          profile.ID,
+			"displayname": profile.Displayname,
+			"avatar":      profile.AvatarHash,
+		},
+	})
+}
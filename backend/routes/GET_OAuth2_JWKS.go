@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+func GET_OAuth2_JWKS(w http.ResponseWriter, r *http.Request) {
+
+	// Export Public Signing Keys, including retired and upcoming keys
+	published := tools.KeystorePublished(tools.KEY_USAGE_SIGNATURE)
+	keys := make([]tools.JWK, 0, len(published))
+	for _, k := range published {
+		jwk, err := tools.GenerateJWK(k.Signer().Public(), k.ID)
+		if err != nil {
+			tools.SendServerError(w, r, err)
+			return
+		}
+		keys = append(keys, jwk)
+	}
+
+	// Organize Key Set
+	tools.SendJSON(w, r, http.StatusOK, map[string]any{
+		"keys": keys,
+	})
+}
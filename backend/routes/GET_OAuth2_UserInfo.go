@@ -0,0 +1,40 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/bakonpancakz/template-auth/tools"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func GET_OAuth2_UserInfo(w http.ResponseWriter, r *http.Request) {
+
+	session := tools.GetSession(r)
+	ctx, cancel := tools.NewContext()
+	defer cancel()
+
+	// Users have full access to their own account
+	scopes := session.ConnectionScopes
+	if session.ApplicationID == tools.SESSION_NO_APPLICATION_ID {
+		scopes = tools.SCOPE_READ_IDENTIFY.Flag | tools.SCOPE_READ_EMAIL.Flag
+	}
+
+	// Collect Claims permitted by Scopes
+	claims := map[string]any{
+		"sub": strconv.FormatInt(session.UserID, 10),
+	}
+	err := tools.OpenIDCollectClaims(ctx, session.UserID, scopes, claims)
+	if errors.Is(err, pgx.ErrNoRows) {
+		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_USER)
+		return
+	}
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
+	tools.SendJSON(w, r, http.StatusOK, claims)
+}
@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+func GET_SCIM_ServiceProviderConfig(w http.ResponseWriter, r *http.Request) {
+	tools.SendSCIM(w, r, http.StatusOK, map[string]any{
+		"schemas":        []string{tools.SCIM_SCHEMA_CONFIG},
+		"patch":          map[string]any{"supported": true},
+		"bulk":           map[string]any{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]any{"supported": true, "maxResults": tools.SCIM_PAGE_LIMIT},
+		"changePassword": map[string]any{"supported": false},
+		"sort":           map[string]any{"supported": false},
+		"etag":           map[string]any{"supported": false},
+		"authenticationSchemes": []map[string]any{{
+			"type":        "oauthbearertoken",
+			"name":        "OAuth Bearer Token",
+			"description": "Client Credentials Access Token with the '" + tools.SCOPE_SCIM.Name + "' scope",
+			"primary":     true,
+		}},
+	})
+}
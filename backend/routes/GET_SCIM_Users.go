@@ -0,0 +1,95 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+func GET_SCIM_Users(w http.ResponseWriter, r *http.Request) {
+
+	session := tools.GetSession(r)
+	var Query struct {
+		Filter     string `query:"filter"`
+		StartIndex int    `query:"startIndex"`
+		Count      *int   `query:"count"`
+	}
+	if !tools.ValidateQuery(w, r, &Query) {
+		return
+	}
+
+	// Pagination is 1-indexed and out of range values are clamped (RFC 7644 Section 3.4.2.4)
+	startIndex := max(Query.StartIndex, 1)
+	count := tools.SCIM_PAGE_LIMIT
+	if Query.Count != nil {
+		count = min(max(*Query.Count, 0), tools.SCIM_PAGE_LIMIT)
+	}
+
+	// Generate Conditions
+	args := []any{session.ApplicationID}
+	conditions := "s.application_id = $1"
+	if Query.Filter != "" {
+		filter, err := tools.SCIMFilterSQL(Query.Filter, scimUserAttributes, &args)
+		if errors.Is(err, tools.ErrSCIMFilter) {
+			tools.SendSCIMError(w, r, tools.ERROR_SCIM_INVALID_FILTER)
+			return
+		}
+		if err != nil {
+			tools.SendServerError(w, r, err)
+			return
+		}
+		conditions += " AND " + filter
+	}
+	ctx, cancel := tools.NewContext()
+	defer cancel()
+
+	// Count Matching Accounts
+	var total int
+	if err := tools.Database.QueryRow(ctx,
+		`SELECT COUNT(*)
+		FROM auth.scim_users s
+		JOIN auth.users u ON u.id = s.user_id
+		JOIN auth.profiles p ON p.id = s.user_id
+		WHERE `+conditions,
+		args...,
+	).Scan(&total); err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
+	// Fetch Requested Page
+	resources := make([]tools.SCIMUserResource, 0, count)
+	if count > 0 {
+		rows, err := tools.Database.Query(ctx,
+			fmt.Sprintf("%s WHERE %s ORDER BY s.user_id LIMIT %d OFFSET %d", scimUserQuery, conditions, count, startIndex-1),
+			args...,
+		)
+		if err != nil {
+			tools.SendServerError(w, r, err)
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			user, err := scanSCIMUser(rows)
+			if err != nil {
+				tools.SendServerError(w, r, err)
+				return
+			}
+			resources = append(resources, user.Resource())
+		}
+		if err := rows.Err(); err != nil {
+			tools.SendServerError(w, r, err)
+			return
+		}
+	}
+
+	tools.SendSCIM(w, r, http.StatusOK, map[string]any{
+		"schemas":      []string{tools.SCIM_SCHEMA_LIST_RESPONSE},
+		"totalResults": total,
+		"startIndex":   startIndex,
+		"itemsPerPage": len(resources),
+		"Resources":    resources,
+	})
+}
@@ -0,0 +1,256 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bakonpancakz/template-auth/tools"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func GET_SCIM_Users_ID(w http.ResponseWriter, r *http.Request) {
+
+	session := tools.GetSession(r)
+	ctx, cancel := tools.NewContext()
+	defer cancel()
+
+	user, ok := fetchSCIMUser(ctx, w, r, session.ApplicationID)
+	if !ok {
+		return
+	}
+
+	tools.SendSCIM(w, r, http.StatusOK, user.Resource())
+}
+
+// Attributes which may be used in Filters, mapped onto the Columns of scimUserQuery
+var scimUserAttributes = map[string]tools.SCIMAttribute{
+	"id":                {Column: "u.id::TEXT", CaseExact: true},
+	"externalid":        {Column: "s.external_id", CaseExact: true},
+	"username":          {Column: "s.user_name_index", Index: tools.UserNameIndex},
+	"displayname":       {Column: "p.displayname"},
+	"name.formatted":    {Column: "p.displayname"},
+	"emails":            {Column: "u.email_index", Index: tools.EmailIndex},
+	"emails.value":      {Column: "u.email_index", Index: tools.EmailIndex},
+	"emails.type":       {Column: "'work'"},
+	"emails.primary":    {Column: "TRUE", Kind: tools.SCIM_KIND_BOOLEAN},
+	"active":            {Column: "s.active", Kind: tools.SCIM_KIND_BOOLEAN},
+	"meta.created":      {Column: "s.created", Kind: tools.SCIM_KIND_DATETIME},
+	"meta.lastmodified": {Column: "s.updated", Kind: tools.SCIM_KIND_DATETIME},
+}
+
+const scimUserQuery = `SELECT
+		u.id, s.created, s.updated, s.external_id, s.user_name,
+		p.displayname, u.email_address, s.active
+	FROM auth.scim_users s
+	JOIN auth.users u ON u.id = s.user_id
+	JOIN auth.profiles p ON p.id = s.user_id`
+
+func scanSCIMUser(row pgx.Row) (tools.SCIMUser, error) {
+	var user tools.SCIMUser
+	err := row.Scan(
+		&user.ID,
+		&user.Created,
+		&user.Updated,
+		&user.ExternalID,
+		tools.Decrypted(&user.UserName),
+		&user.Displayname,
+		tools.Decrypted(&user.EmailAddress),
+		&user.Active,
+	)
+	return user, err
+}
+
+// Fetch Account from Path, Applications may only see Accounts they provisioned
+func fetchSCIMUser(ctx context.Context, w http.ResponseWriter, r *http.Request, applicationID int64) (tools.SCIMUser, bool) {
+	userID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		tools.SendSCIMError(w, r, tools.ERROR_UNKNOWN_USER)
+		return tools.SCIMUser{}, false
+	}
+	user, err := scanSCIMUser(tools.Database.QueryRow(ctx,
+		scimUserQuery+" WHERE s.user_id = $1 AND s.application_id = $2",
+		userID,
+		applicationID,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		tools.SendSCIMError(w, r, tools.ERROR_UNKNOWN_USER)
+		return tools.SCIMUser{}, false
+	}
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return tools.SCIMUser{}, false
+	}
+	return user, true
+}
+
+// Normalize and Validate Account Attributes before they are Saved, the
+// Displayname falls back to the userName like it does for Federated Logins
+func validateSCIMUser(ctx context.Context, w http.ResponseWriter, r *http.Request, applicationID int64, user *tools.SCIMUser) bool {
+	user.UserName = strings.TrimSpace(user.UserName)
+	user.EmailAddress = strings.ToLower(strings.TrimSpace(user.EmailAddress))
+	_, user.Displayname = federatedProfile(tools.FederationIdentity{
+		Username:     user.UserName,
+		EmailAddress: user.EmailAddress,
+		Displayname:  user.Displayname,
+	})
+	verrs, err := tools.ValidateStruct(struct {
+		EmailAddress string `validate:"email"`
+		Displayname  string `validate:"displayname"`
+	}{
+		EmailAddress: user.EmailAddress,
+		Displayname:  user.Displayname,
+	})
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return false
+	}
+	if len(verrs) > 0 || user.UserName == "" || len(user.UserName) > tools.SCIM_USERNAME_LENGTH_MAX {
+		tools.SendSCIMError(w, r, tools.ERROR_SCIM_INVALID_VALUE)
+		return false
+	}
+
+	// Accounts may only be provisioned within Domains the Application owns
+	if !tools.SCIMEmailAllowed(applicationID, user.EmailAddress) {
+		tools.SendSCIMError(w, r, tools.ERROR_SCIM_INVALID_VALUE)
+		return false
+	}
+
+	// Check for Duplicate Attributes
+	var duplicates int
+	if err := tools.Database.QueryRow(ctx,
+		`SELECT
+			(SELECT COUNT(*) FROM auth.users WHERE email_index = $1 AND id <> $2) +
+			(SELECT COUNT(*) FROM auth.scim_users WHERE application_id = $3 AND user_id <> $2 AND (
+				user_name_index = $4 OR external_id = $5
+			))`,
+		tools.EmailIndex(user.EmailAddress),
+		user.ID,
+		applicationID,
+		tools.UserNameIndex(user.UserName),
+		user.ExternalID,
+	).Scan(&duplicates); err != nil {
+		tools.SendServerError(w, r, err)
+		return false
+	}
+	if duplicates > 0 {
+		tools.SendSCIMError(w, r, tools.ERROR_SCIM_UNIQUENESS)
+		return false
+	}
+	return true
+}
+
+// Save the Attributes of a provisioned Account, a Deactivated Account is
+// signed out of every Session and Application
+func saveSCIMUser(ctx context.Context, w http.ResponseWriter, r *http.Request, applicationID int64, previous tools.SCIMUser, user *tools.SCIMUser) bool {
+	if !validateSCIMUser(ctx, w, r, applicationID, user) {
+		return false
+	}
+
+	userName, err := tools.EncryptField(user.UserName)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return false
+	}
+
+	// [TX] Begin Transaction
+	tx, err := tools.Database.Begin(ctx)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return false
+	}
+	defer tx.Rollback(ctx)
+
+	// [TX] Update Provisioning Attributes
+	if err := tx.QueryRow(ctx,
+		`UPDATE auth.scim_users SET
+			updated         = CURRENT_TIMESTAMP,
+			external_id     = $1,
+			user_name       = $2,
+			user_name_index = $3,
+			active          = $4
+		WHERE user_id = $5 AND application_id = $6
+		RETURNING updated`,
+		user.ExternalID,
+		userName,
+		tools.UserNameIndex(user.UserName),
+		user.Active,
+		user.ID,
+		applicationID,
+	).Scan(&user.Updated); errors.Is(err, pgx.ErrNoRows) {
+		tools.SendSCIMError(w, r, tools.ERROR_UNKNOWN_USER)
+		return false
+	} else if err != nil {
+		tools.SendServerError(w, r, err)
+		return false
+	}
+
+	// [TX] Update Profile
+	if user.Displayname != previous.Displayname {
+		if _, err := tx.Exec(ctx,
+			`UPDATE auth.profiles SET
+				updated     = CURRENT_TIMESTAMP,
+				displayname = $1
+			WHERE id = $2`,
+			user.Displayname,
+			user.ID,
+		); err != nil {
+			tools.SendServerError(w, r, err)
+			return false
+		}
+	}
+
+	// [TX] Update Email Address, the New Address has yet to be Verified
+	if user.EmailAddress != previous.EmailAddress {
+		email, err := tools.EncryptField(user.EmailAddress)
+		if err != nil {
+			tools.SendServerError(w, r, err)
+			return false
+		}
+		if _, err := tx.Exec(ctx,
+			`UPDATE auth.users SET
+				updated        = CURRENT_TIMESTAMP,
+				email_address  = $1,
+				email_index    = $2,
+				email_verified = FALSE
+			WHERE id = $3`,
+			email,
+			tools.EmailIndex(user.EmailAddress),
+			user.ID,
+		); err != nil {
+			tools.SendServerError(w, r, err)
+			return false
+		}
+	}
+
+	// [TX] Revoke Access of Deactivated Account
+	if previous.Active && !user.Active {
+		if _, err := tx.Exec(ctx,
+			"UPDATE auth.sessions SET revoked = TRUE WHERE user_id = $1",
+			user.ID,
+		); err != nil {
+			tools.SendServerError(w, r, err)
+			return false
+		}
+		if _, err := tx.Exec(ctx,
+			`UPDATE auth.connections SET
+				updated = CURRENT_TIMESTAMP,
+				revoked = TRUE
+			WHERE user_id = $1`,
+			user.ID,
+		); err != nil {
+			tools.SendServerError(w, r, err)
+			return false
+		}
+	}
+
+	// [TX] Complete Transaction
+	if err := tx.Commit(ctx); err != nil {
+		tools.SendServerError(w, r, err)
+		return false
+	}
+	return true
+}
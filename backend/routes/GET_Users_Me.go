@@ -12,10 +12,6 @@ import (
 func GET_Users_Me(w http.ResponseWriter, r *http.Request) {
 
 	session := tools.GetSession(r)
-	if !tools.OAuth2ScopesContains(session, tools.SCOPE_READ_IDENTIFY) {
-		tools.SendClientError(w, r, tools.ERROR_OAUTH2_SCOPE_REQUIRED)
-		return
-	}
 	ctx, cancel := tools.NewContext()
 	defer cancel()
 
@@ -32,7 +28,7 @@ func GET_Users_Me(w http.ResponseWriter, r *http.Request) {
 		WHERE u.id = $1`,
 		session.UserID,
 	).Scan(
-		&user.ID, &user.Created, &user.EmailAddress, &user.EmailVerified, &user.MFAEnabled,
+		&user.ID, &user.Created, tools.Decrypted(&user.EmailAddress), &user.EmailVerified, &user.MFAEnabled,
 		&profile.Username, &profile.Displayname, &profile.Biography, &profile.Subtitle, &profile.AvatarHash,
 		&profile.BannerHash, &profile.AccentBanner, &profile.AccentBorder, &profile.AccentBackground,
 	)
@@ -9,17 +9,14 @@ import (
 func GET_Users_Me_Applications(w http.ResponseWriter, r *http.Request) {
 
 	session := tools.GetSession(r)
-	if session.ApplicationID != tools.SESSION_NO_APPLICATION_ID {
-		tools.SendClientError(w, r, tools.ERROR_OAUTH2_USERS_ONLY)
-		return
-	}
 	ctx, cancel := tools.NewContext()
 	defer cancel()
 
 	// Fetch Applications for Account
 	rows, err := tools.Database.Query(ctx,
 		`SELECT
-			id, created, name, description, icon_hash, redirects
+			id, created, name, description, icon_hash, auth_redirects, auth_public,
+			auth_scopes
 		FROM auth.applications
 		WHERE user_id = $1`,
 		session.UserID,
@@ -41,6 +38,8 @@ func GET_Users_Me_Applications(w http.ResponseWriter, r *http.Request) {
 			&app.Description,
 			&app.IconHash,
 			&app.AuthRedirects,
+			&app.AuthPublic,
+			&app.AuthScopes,
 		)
 		if err != nil {
 			tools.SendServerError(w, r, err)
@@ -53,6 +52,8 @@ func GET_Users_Me_Applications(w http.ResponseWriter, r *http.Request) {
 			"description": app.Description,
 			"icon":        app.IconHash,
 			"redirects":   app.AuthRedirects,
+			"public":      app.AuthPublic,
+			"scopes":      tools.OAuth2ScopesToString(app.AuthScopes),
 		})
 	}
 
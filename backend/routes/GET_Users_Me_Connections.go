@@ -2,6 +2,7 @@ package routes
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/bakonpancakz/template-auth/tools"
 )
@@ -9,10 +10,6 @@ import (
 func GET_Users_Me_Connections(w http.ResponseWriter, r *http.Request) {
 
 	session := tools.GetSession(r)
-	if session.ApplicationID != tools.SESSION_NO_APPLICATION_ID {
-		tools.SendClientError(w, r, tools.ERROR_OAUTH2_USERS_ONLY)
-		return
-	}
 	ctx, cancel := tools.NewContext()
 	defer cancel()
 
@@ -20,9 +17,11 @@ func GET_Users_Me_Connections(w http.ResponseWriter, r *http.Request) {
 	rows, err := tools.Database.Query(ctx,
 		`SELECT
 			c.id, c.created, c.scopes,
-			a.id, a.created, a.name, a.description, a.icon_hash
+			a.id, a.created, a.name, a.description, a.icon_hash,
+			COALESCE(s.scopes, 0), s.updated
 		FROM auth.connections c
 		INNER JOIN auth.applications a ON c.application_id = a.id
+		LEFT JOIN auth.consents s ON s.user_id = c.user_id AND s.application_id = c.application_id
 		WHERE c.user_id = $1 AND c.revoked = FALSE`,
 		session.UserID,
 	)
@@ -35,6 +34,8 @@ func GET_Users_Me_Connections(w http.ResponseWriter, r *http.Request) {
 	// Organize Connections
 	var connection tools.DatabaseConnection
 	var application tools.DatabaseApplication
+	var consent tools.DatabaseConsent
+	var consentUpdated *time.Time
 	results := make([]map[string]any, 0, 1)
 	for rows.Next() {
 		if err := rows.Scan(
@@ -46,6 +47,8 @@ func GET_Users_Me_Connections(w http.ResponseWriter, r *http.Request) {
 			&application.Name,
 			&application.Description,
 			&application.IconHash,
+			&consent.Scopes,
+			&consentUpdated,
 		); err != nil {
 			tools.SendServerError(w, r, err)
 			return
@@ -54,6 +57,10 @@ func GET_Users_Me_Connections(w http.ResponseWriter, r *http.Request) {
 			"id":      connection.ID,
 			"created": connection.Created,
 			"scopes":  connection.Scopes,
+			"consent": map[string]any{
+				"scopes":   consent.Scopes,
+				"approved": consentUpdated,
+			},
 			"application": map[string]any{
 				"id":          application.ID,
 				"created":     application.Created,
@@ -0,0 +1,68 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+func GET_Users_Me_Security_Devices(w http.ResponseWriter, r *http.Request) {
+
+	session := tools.GetSession(r)
+	ctx, cancel := tools.NewContext()
+	defer cancel()
+
+	// Fetch Devices for Account
+	rows, err := tools.Database.Query(ctx,
+		`SELECT
+			id, created, used, token, verified, trusted_until, ip_address, user_agent
+		FROM auth.devices
+		WHERE user_id = $1
+		ORDER BY used DESC`,
+		session.UserID,
+	)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+	defer rows.Close()
+
+	// Organize Devices
+	var current *int64
+	var results = make([]map[string]any, 0, 1)
+	var device tools.DatabaseDevice
+	token := deviceCookie(r)
+	for rows.Next() {
+		if err := rows.Scan(
+			&device.ID,
+			&device.Created,
+			&device.Used,
+			&device.Token,
+			&device.Verified,
+			&device.TrustedUntil,
+			tools.Decrypted(&device.IPAddress),
+			tools.Decrypted(&device.UserAgent),
+		); err != nil {
+			tools.SendServerError(w, r, err)
+			return
+		}
+		if token != "" && device.Token != nil && tools.CompareToken(token, *device.Token) {
+			id := device.ID
+			current = &id
+		}
+		results = append(results, map[string]any{
+			"id":            device.ID,
+			"created":       device.Created,
+			"used":          device.Used,
+			"verified":      device.Verified,
+			"trusted_until": device.TrustedUntil,
+			"location":      tools.LookupLocation(device.IPAddress),
+			"browser":       tools.LookupBrowser(device.UserAgent),
+		})
+	}
+
+	tools.SendJSON(w, r, http.StatusOK, map[string]any{
+		"current": current,
+		"devices": results,
+	})
+}
@@ -0,0 +1,54 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+func GET_Users_Me_Security_Identities(w http.ResponseWriter, r *http.Request) {
+
+	session := tools.GetSession(r)
+	ctx, cancel := tools.NewContext()
+	defer cancel()
+
+	// Fetch Identities for Account
+	rows, err := tools.Database.Query(ctx,
+		`SELECT
+			id, created, used, provider, email_address
+		FROM auth.identities
+		WHERE user_id = $1
+		ORDER BY created ASC`,
+		session.UserID,
+	)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+	defer rows.Close()
+
+	// Organize Identities
+	var results = make([]map[string]any, 0, 1)
+	var identity tools.DatabaseIdentity
+	for rows.Next() {
+		if err := rows.Scan(
+			&identity.ID,
+			&identity.Created,
+			&identity.Used,
+			&identity.Provider,
+			tools.Decrypted(&identity.EmailAddress),
+		); err != nil {
+			tools.SendServerError(w, r, err)
+			return
+		}
+		results = append(results, map[string]any{
+			"id":            identity.ID,
+			"created":       identity.Created,
+			"used":          identity.Used,
+			"provider":      identity.Provider,
+			"email_address": identity.EmailAddress,
+		})
+	}
+
+	tools.SendJSON(w, r, http.StatusOK, results)
+}
@@ -10,10 +10,6 @@ import (
 func GET_Users_Me_Security_MFA_Setup(w http.ResponseWriter, r *http.Request) {
 
 	session := tools.GetSession(r)
-	if session.ApplicationID != tools.SESSION_NO_APPLICATION_ID {
-		tools.SendClientError(w, r, tools.ERROR_OAUTH2_USERS_ONLY)
-		return
-	}
 	if !session.Elevated {
 		tools.SendClientError(w, r, tools.ERROR_MFA_ESCALATION_REQUIRED)
 		return
@@ -32,7 +28,7 @@ func GET_Users_Me_Security_MFA_Setup(w http.ResponseWriter, r *http.Request) {
 		WHERE u.id = $1`,
 		session.UserID,
 	).Scan(
-		&user.EmailAddress,
+		tools.Decrypted(&user.EmailAddress),
 		&user.MFAEnabled,
 		&profile.Username,
 	)
@@ -52,6 +48,11 @@ func GET_Users_Me_Security_MFA_Setup(w http.ResponseWriter, r *http.Request) {
 		"Auth", fmt.Sprintf("%s (%s)", profile.Username, user.EmailAddress),
 		setupSecret,
 	)
+	setupSecretSealed, err := tools.EncryptField(setupSecret)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
 
 	// Update State for Current User
 	if _, err = tools.Database.Exec(ctx,
@@ -63,7 +64,7 @@ func GET_Users_Me_Security_MFA_Setup(w http.ResponseWriter, r *http.Request) {
 			mfa_codes_used 	= 0
 		WHERE id = $1`,
 		session.UserID,
-		setupSecret,
+		setupSecretSealed,
 		setupCodes,
 	); err != nil {
 		tools.SendServerError(w, r, err)
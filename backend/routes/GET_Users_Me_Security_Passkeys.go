@@ -0,0 +1,54 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+func GET_Users_Me_Security_Passkeys(w http.ResponseWriter, r *http.Request) {
+
+	session := tools.GetSession(r)
+	ctx, cancel := tools.NewContext()
+	defer cancel()
+
+	// Fetch Passkeys for Account
+	rows, err := tools.Database.Query(ctx,
+		`SELECT
+			id, created, used, name, aaguid
+		FROM auth.passkeys
+		WHERE user_id = $1
+		ORDER BY created`,
+		session.UserID,
+	)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+	defer rows.Close()
+
+	// Organize Passkeys
+	var results = make([]map[string]any, 0, 1)
+	var passkey tools.DatabasePasskey
+	for rows.Next() {
+		if err := rows.Scan(
+			&passkey.ID,
+			&passkey.Created,
+			&passkey.Used,
+			&passkey.Name,
+			&passkey.AAGUID,
+		); err != nil {
+			tools.SendServerError(w, r, err)
+			return
+		}
+		results = append(results, map[string]any{
+			"id":      passkey.ID,
+			"created": passkey.Created,
+			"used":    passkey.Used,
+			"name":    passkey.Name,
+			"aaguid":  passkey.AAGUID,
+		})
+	}
+
+	tools.SendJSON(w, r, http.StatusOK, results)
+}
@@ -0,0 +1,93 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+func GET_Users_Me_Security_Passkeys_Setup(w http.ResponseWriter, r *http.Request) {
+
+	session := tools.GetSession(r)
+	if !session.Elevated {
+		tools.SendClientError(w, r, tools.ERROR_MFA_ESCALATION_REQUIRED)
+		return
+	}
+	ctx, cancel := tools.NewContext()
+	defer cancel()
+
+	// Fetch Profile for Current User
+	var profile tools.DatabaseProfile
+	err := tools.Database.QueryRow(ctx,
+		"SELECT username, displayname FROM auth.profiles WHERE id = $1",
+		session.UserID,
+	).Scan(
+		&profile.Username,
+		&profile.Displayname,
+	)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
+	// Exclude Passkeys already Registered on this Authenticator
+	rows, err := tools.Database.Query(ctx,
+		"SELECT credential_id FROM auth.passkeys WHERE user_id = $1",
+		session.UserID,
+	)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+	defer rows.Close()
+	var excluded = make([]map[string]any, 0, 1)
+	for rows.Next() {
+		var credentialID string
+		if err := rows.Scan(&credentialID); err != nil {
+			tools.SendServerError(w, r, err)
+			return
+		}
+		excluded = append(excluded, map[string]any{
+			"type": "public-key",
+			"id":   credentialID,
+		})
+	}
+	if len(excluded) >= tools.PASSKEY_LIMIT {
+		tools.SendClientError(w, r, tools.ERROR_PASSKEY_LIMIT)
+		return
+	}
+
+	// Generate Registration Challenge
+	challenge, err := issuePasskeyChallenge(ctx, &session.UserID)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
+	// Organize Creation Options (PublicKeyCredentialCreationOptionsJSON)
+	params := make([]map[string]any, 0, len(tools.WEBAUTHN_ALGORITHMS))
+	for _, alg := range tools.WEBAUTHN_ALGORITHMS {
+		params = append(params, map[string]any{"type": "public-key", "alg": alg})
+	}
+	tools.SendJSON(w, r, http.StatusOK, map[string]any{
+		"challenge": challenge,
+		"rp": map[string]any{
+			"id":   tools.WEBAUTHN_RP_ID,
+			"name": tools.WEBAUTHN_RP_NAME,
+		},
+		"user": map[string]any{
+			"id":          tools.WebAuthnEncode([]byte(strconv.FormatInt(session.UserID, 10))),
+			"name":        profile.Username,
+			"displayName": profile.Displayname,
+		},
+		"pubKeyCredParams":   params,
+		"excludeCredentials": excluded,
+		"timeout":            tools.LIFETIME_WEBAUTHN_CHALLENGE.Milliseconds(),
+		"attestation":        "none",
+		"authenticatorSelection": map[string]any{
+			"residentKey":      "required",
+			"userVerification": "required",
+		},
+	})
+}
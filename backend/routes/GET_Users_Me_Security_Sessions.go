@@ -2,6 +2,7 @@ package routes
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/bakonpancakz/template-auth/tools"
 )
@@ -9,10 +10,6 @@ import (
 func GET_Users_Me_Security_Sessions(w http.ResponseWriter, r *http.Request) {
 
 	session := tools.GetSession(r)
-	if session.ApplicationID != tools.SESSION_NO_APPLICATION_ID {
-		tools.SendClientError(w, r, tools.ERROR_OAUTH2_USERS_ONLY)
-		return
-	}
 	ctx, cancel := tools.NewContext()
 	defer cancel()
 
@@ -21,8 +18,10 @@ func GET_Users_Me_Security_Sessions(w http.ResponseWriter, r *http.Request) {
 		`SELECT
 			id, device_ip_address, device_user_agent
 		FROM auth.sessions
-		WHERE user_id = $1`,
+		WHERE user_id = $1 AND expires > $2 AND used > $3`,
 		session.UserID,
+		time.Now(),
+		time.Now().Add(-tools.SESSION_IDLE_TIMEOUT),
 	)
 	if err != nil {
 		tools.SendServerError(w, r, err)
@@ -36,8 +35,8 @@ func GET_Users_Me_Security_Sessions(w http.ResponseWriter, r *http.Request) {
 	for rows.Next() {
 		if err := rows.Scan(
 			&login.ID,
-			&login.DeviceIPAddress,
-			&login.DeviceUserAgent,
+			tools.Decrypted(&login.DeviceIPAddress),
+			tools.Decrypted(&login.DeviceUserAgent),
 		); err != nil {
 			tools.SendServerError(w, r, err)
 			return
@@ -0,0 +1,49 @@
+package routes
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+func GET_WellKnown_OpenIDConfiguration(w http.ResponseWriter, r *http.Request) {
+
+	// Collect Supported Scopes
+	scopes := make([]string, 0, len(tools.SCOPE_LIST))
+	for _, sc := range tools.SCOPE_LIST {
+		scopes = append(scopes, sc.Name)
+	}
+
+	// Collect Supported Algorithms
+	algorithms := make([]string, 0, 1)
+	for _, k := range tools.KeystorePublished(tools.KEY_USAGE_SIGNATURE) {
+		if !slices.Contains(algorithms, k.Algorithm) {
+			algorithms = append(algorithms, k.Algorithm)
+		}
+	}
+
+	// Organize Discovery Document, the Authorization Endpoint is the consent page
+	// on the Frontend which then submits the request to this backend
+	tools.SendJSON(w, r, http.StatusOK, map[string]any{
+		"issuer":                                tools.OIDC_ISSUER,
+		"authorization_endpoint":                tools.EMAIL_DEFAULT_HOST + "/oauth2/authorize",
+		"token_endpoint":                        tools.OIDC_ISSUER + "/oauth2/token",
+		"revocation_endpoint":                   tools.OIDC_ISSUER + "/oauth2/token/revoke",
+		"introspection_endpoint":                tools.OIDC_ISSUER + "/oauth2/token/introspect",
+		"device_authorization_endpoint":         tools.OIDC_ISSUER + "/oauth2/device/code",
+		"userinfo_endpoint":                     tools.OIDC_ISSUER + "/oauth2/userinfo",
+		"jwks_uri":                              tools.OIDC_ISSUER + "/oauth2/jwks",
+		"scopes_supported":                      scopes,
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{GRANT_CODE, GRANT_REFRESH, GRANT_CLIENT_CREDENTIALS, GRANT_DEVICE_CODE},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": algorithms,
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "none"},
+		"code_challenge_methods_supported":      []string{tools.PKCE_METHOD_PLAIN, tools.PKCE_METHOD_S256},
+		"claims_supported": []string{
+			"iss", "sub", "aud", "exp", "iat", "nonce",
+			"preferred_username", "name", "updated_at", "email", "email_verified",
+		},
+	})
+}
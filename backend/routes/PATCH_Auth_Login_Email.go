@@ -0,0 +1,163 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bakonpancakz/template-auth/tools"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func PATCH_Auth_Login_Email(w http.ResponseWriter, r *http.Request) {
+
+	// Parse Request Body
+	// Either the Token from the Magic Link or the Email and Code are required
+	var Body struct {
+		Token    string `json:"token" validate:"omitempty,token"`
+		Email    string `json:"email" validate:"omitempty,email"`
+		Code     string `json:"code" validate:"omitempty,passcode"`
+		Passcode string `json:"passcode" validate:"omitempty,passcode"`
+		Remember bool   `json:"remember"`
+	}
+	if !tools.ValidateJSON(w, r, &Body) {
+		return
+	}
+	if Body.Token == "" && (Body.Email == "" || Body.Code == "") {
+		tools.SendClientError(w, r, tools.ERROR_LOGIN_CODE_INCORRECT)
+		return
+	}
+	ctx, cancel := tools.NewContext()
+	defer cancel()
+
+	// Find Relevant Account
+	// Guesses are counted before comparing so concurrent requests can't exceed the limit
+	var user tools.DatabaseUser
+	var err error
+	if Body.Token != "" {
+		err = tools.Database.QueryRow(ctx,
+			`SELECT
+				id, email_address, email_verified, mfa_enabled, mfa_secret, mfa_codes,
+				mfa_codes_used, token_magic, token_magic_passcode, token_magic_attempts,
+				lockout_until
+			FROM auth.users
+			WHERE token_magic = ANY($1) AND token_magic_eat > NOW()`,
+			tools.TokenCandidates(Body.Token),
+		).Scan(
+			&user.ID,
+			tools.Decrypted(&user.EmailAddress),
+			&user.EmailVerified,
+			&user.MFAEnabled,
+			tools.Decrypted(&user.MFASecret),
+			&user.MFACodes,
+			&user.MFACodesUsed,
+			&user.TokenMagic,
+			&user.TokenMagicPasscode,
+			&user.TokenMagicAttempts,
+			&user.LockoutUntil,
+		)
+	} else {
+		err = tools.Database.QueryRow(ctx,
+			`UPDATE auth.users SET
+				token_magic_attempts = token_magic_attempts + 1
+			WHERE email_index = $1
+			AND token_magic_passcode IS NOT NULL
+			AND token_magic_eat > NOW()
+			AND token_magic_attempts < $2
+			RETURNING
+				id, email_address, email_verified, mfa_enabled, mfa_secret, mfa_codes,
+				mfa_codes_used, token_magic, token_magic_passcode, token_magic_attempts,
+				lockout_until`,
+			tools.EmailIndex(Body.Email),
+			tools.PASSCODE_ATTEMPT_LIMIT,
+		).Scan(
+			&user.ID,
+			tools.Decrypted(&user.EmailAddress),
+			&user.EmailVerified,
+			&user.MFAEnabled,
+			tools.Decrypted(&user.MFASecret),
+			&user.MFACodes,
+			&user.MFACodesUsed,
+			&user.TokenMagic,
+			&user.TokenMagicPasscode,
+			&user.TokenMagicAttempts,
+			&user.LockoutUntil,
+		)
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		tools.SendClientError(w, r, tools.ERROR_LOGIN_CODE_INCORRECT)
+		return
+	}
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+	if accountLocked(w, r, user) {
+		return
+	}
+
+	// Compare Code
+	if Body.Token == "" && !tools.CompareToken(Body.Code, *user.TokenMagicPasscode) {
+		if user.TokenMagicAttempts < tools.PASSCODE_ATTEMPT_LIMIT {
+			failAttempt(ctx, w, r, user.ID, factorEmail, tools.ERROR_LOGIN_CODE_INCORRECT)
+			return
+		}
+
+		// Discard Exhausted Code, the Magic Link is sent alongside it
+		if _, err := tools.Database.Exec(ctx,
+			`UPDATE auth.users SET
+				token_magic 		 = NULL,
+				token_magic_passcode = NULL,
+				token_magic_eat 	 = NULL
+			WHERE id = $1`,
+			user.ID,
+		); err != nil {
+			tools.SendServerError(w, r, err)
+			return
+		}
+		failAttempt(ctx, w, r, user.ID, factorEmail, tools.ERROR_LOGIN_CODE_EXHAUSTED)
+		return
+	}
+
+	// Assess Login Risk, which may require MFA or an emailed Approval
+	// The Email replaces the Password, any further Checks still apply
+	device, deviceTrust, ok := assessLogin(ctx, w, r, user, Body.Passcode, Body.Remember)
+	if !ok {
+		return
+	}
+
+	// Consume Link and Code, proving ownership of the Email Address
+	tag, err := tools.Database.Exec(ctx,
+		`UPDATE auth.users SET
+			updated 			 = CURRENT_TIMESTAMP,
+			email_verified 		 = TRUE,
+			token_magic 		 = NULL,
+			token_magic_passcode = NULL,
+			token_magic_eat 	 = NULL,
+			token_magic_attempts = 0
+		WHERE id = $1 AND token_magic_passcode = $2`,
+		user.ID,
+		user.TokenMagicPasscode,
+	)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		tools.SendClientError(w, r, tools.ERROR_LOGIN_CODE_INCORRECT)
+		return
+	}
+
+	// Create New Session
+	if err := startSession(ctx, w, r, user, device, deviceTrust); errors.Is(err, pgx.ErrNoRows) {
+		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_USER)
+		return
+	} else if errors.Is(err, errAccountDisabled) {
+		tools.SendClientError(w, r, tools.ERROR_LOGIN_ACCOUNT_DISABLED)
+		return
+	} else if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -20,6 +20,10 @@ func PATCH_Auth_ResetPassword(w http.ResponseWriter, r *http.Request) {
 	if !tools.ValidateJSON(w, r, &Body) {
 		return
 	}
+	if tools.PasswordBreached(Body.NewPassword) {
+		tools.SendClientError(w, r, tools.ERROR_LOGIN_PASSWORD_BREACHED)
+		return
+	}
 	ctx, cancel := tools.NewContext()
 	defer cancel()
 
@@ -29,11 +33,11 @@ func PATCH_Auth_ResetPassword(w http.ResponseWriter, r *http.Request) {
 		`SELECT
 			id, email_address, password_history
 		FROM auth.users
-		WHERE token_reset = $1 AND token_reset_eat > NOW()`,
-		Body.Token,
+		WHERE token_reset = ANY($1) AND token_reset_eat > NOW()`,
+		tools.TokenCandidates(Body.Token),
 	).Scan(
 		&user.ID,
-		&user.EmailAddress,
+		tools.Decrypted(&user.EmailAddress),
 		&user.PasswordHistory,
 	)
 	if errors.Is(err, pgx.ErrNoRows) {
@@ -50,7 +54,7 @@ func PATCH_Auth_ResetPassword(w http.ResponseWriter, r *http.Request) {
 		if ok, err := tools.ComparePasswordHash(oldPassword, Body.NewPassword); err != nil {
 			tools.SendServerError(w, r, err)
 			return
-		} else if !ok {
+		} else if ok {
 			tools.SendClientError(w, r, tools.ERROR_LOGIN_PASSWORD_ALREADY_USED)
 			return
 		}
@@ -71,6 +75,7 @@ func PATCH_Auth_ResetPassword(w http.ResponseWriter, r *http.Request) {
 			updated 		 = CURRENT_TIMESTAMP,
 			token_reset 	 = NULL,
 			token_reset_eat	 = NULL,
+			password_breached = NULL,
 			password_hash 	 = $1,
 			password_history = $2
 		WHERE id = $3`,
@@ -88,7 +93,7 @@ func PATCH_Auth_ResetPassword(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Alert Account Owner
-	go func() {
+	tools.EmailAsync(func() {
 		subCtx, subCancel := tools.NewContext()
 		defer subCancel()
 
@@ -104,7 +109,7 @@ func PATCH_Auth_ResetPassword(w http.ResponseWriter, r *http.Request) {
 				Displayname: displayname,
 			},
 		)
-	}()
+	})
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,59 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bakonpancakz/template-auth/tools"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func PATCH_Auth_SAML_Connection(w http.ResponseWriter, r *http.Request) {
+
+	connection, ok := tools.SAML[r.PathValue("connection")]
+	if !ok {
+		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_PROVIDER)
+		return
+	}
+
+	// Parse Request Body
+	var Body struct {
+		State    string `json:"state" validate:"required"`
+		Passcode string `json:"passcode" validate:"omitempty,passcode"`
+		Remember bool   `json:"remember"`
+	}
+	if !tools.ValidateJSON(w, r, &Body) {
+		return
+	}
+	cookie, err := r.Cookie(tools.HTTP_FEDERATION_COOKIE_NAME)
+	if err != nil || !tools.CompareStringConstant(cookie.Value, Body.State) {
+		tools.SendClientError(w, r, tools.ERROR_FEDERATION_STATE_INVALID)
+		return
+	}
+	ctx, cancel := tools.NewContext()
+	defer cancel()
+
+	// Fetch Account resolved by the Assertion Consumer Service
+	var userID int64
+	err = tools.Database.QueryRow(ctx,
+		`SELECT user_id FROM auth.federation_states
+		WHERE state = $1 AND provider = $2 AND user_id IS NOT NULL AND expires > NOW()`,
+		Body.State,
+		"saml:"+connection.Name,
+	).Scan(&userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		tools.SendClientError(w, r, tools.ERROR_FEDERATION_STATE_INVALID)
+		return
+	}
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
+	// Sign into Relevant Account
+	if !completeFederation(ctx, w, r, Body.State, userID, Body.Passcode, Body.Remember) {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
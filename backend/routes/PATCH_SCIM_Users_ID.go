@@ -0,0 +1,46 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+func PATCH_SCIM_Users_ID(w http.ResponseWriter, r *http.Request) {
+
+	session := tools.GetSession(r)
+	var Body tools.SCIMPatchRequest
+	if !tools.ValidateSCIM(w, r, &Body) {
+		return
+	}
+	if !slices.Contains(Body.Schemas, tools.SCIM_SCHEMA_PATCH_OP) || len(Body.Operations) == 0 {
+		tools.SendSCIMError(w, r, tools.ERROR_SCIM_INVALID_SYNTAX)
+		return
+	}
+	ctx, cancel := tools.NewContext()
+	defer cancel()
+
+	previous, ok := fetchSCIMUser(ctx, w, r, session.ApplicationID)
+	if !ok {
+		return
+	}
+
+	// Apply Operations in Order, any Failure discards all of them
+	user := previous
+	for _, op := range Body.Operations {
+		if err := user.Patch(op); errors.Is(err, tools.ErrSCIMPath) {
+			tools.SendSCIMError(w, r, tools.ERROR_SCIM_INVALID_PATH)
+			return
+		} else if err != nil {
+			tools.SendSCIMError(w, r, tools.ERROR_SCIM_INVALID_VALUE)
+			return
+		}
+	}
+	if !saveSCIMUser(ctx, w, r, session.ApplicationID, previous, &user) {
+		return
+	}
+
+	tools.SendSCIM(w, r, http.StatusOK, user.Resource())
+}
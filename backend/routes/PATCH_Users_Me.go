@@ -12,10 +12,6 @@ import (
 func PATCH_Users_Me(w http.ResponseWriter, r *http.Request) {
 
 	session := tools.GetSession(r)
-	if session.ApplicationID != tools.SESSION_NO_APPLICATION_ID {
-		tools.SendClientError(w, r, tools.ERROR_OAUTH2_USERS_ONLY)
-		return
-	}
 
 	var Body struct {
 		Displayname      *string `json:"displayname" validate:"omitempty,displayname"`
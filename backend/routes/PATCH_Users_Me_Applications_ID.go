@@ -15,15 +15,13 @@ import (
 func PATCH_Users_Me_Applications_ID(w http.ResponseWriter, r *http.Request) {
 
 	session := tools.GetSession(r)
-	if session.ApplicationID != tools.SESSION_NO_APPLICATION_ID {
-		tools.SendClientError(w, r, tools.ERROR_OAUTH2_USERS_ONLY)
-		return
-	}
 
 	var Body struct {
 		Name        *string   `json:"name" validate:"omitempty,displayname"`
 		Description *string   `json:"description" validate:"omitempty,description"`
 		Redirects   *[]string `json:"redirects"`
+		Public      *bool     `json:"public"`
+		Scopes      *string   `json:"scopes"`
 	}
 	if !tools.ValidateJSON(w, r, &Body) {
 		return
@@ -41,7 +39,8 @@ func PATCH_Users_Me_Applications_ID(w http.ResponseWriter, r *http.Request) {
 	var application tools.DatabaseApplication
 	err = tools.Database.QueryRow(ctx,
 		`SELECT
-			id, created, name, description, icon_hash, redirects
+			id, created, name, description, icon_hash, auth_redirects, auth_public,
+			auth_scopes
 		FROM auth.applications
 		WHERE id = $1 AND user_id = $2`,
 		snowflake,
@@ -53,6 +52,8 @@ func PATCH_Users_Me_Applications_ID(w http.ResponseWriter, r *http.Request) {
 		&application.Description,
 		&application.IconHash,
 		&application.AuthRedirects,
+		&application.AuthPublic,
+		&application.AuthScopes,
 	)
 	if errors.Is(err, pgx.ErrNoRows) {
 		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_APPLICATION)
@@ -120,6 +121,22 @@ func PATCH_Users_Me_Applications_ID(w http.ResponseWriter, r *http.Request) {
 		application.AuthRedirects = normalized
 		edited = true
 	}
+	if Body.Public != nil {
+		application.AuthPublic = *Body.Public
+		edited = true
+	}
+	if Body.Scopes != nil {
+
+		// Applications may only act as themselves using Machine Scopes,
+		// Operator Scopes are granted through Configuration instead
+		ok, scopes := tools.OAuth2StringToScopes(*Body.Scopes)
+		if !ok || tools.OAuth2ScopesMachine(scopes) != scopes || tools.OAuth2ScopesOperator(scopes) != 0 {
+			tools.SendClientError(w, r, tools.ERROR_OAUTH2_FORM_INVALID_SCOPE)
+			return
+		}
+		application.AuthScopes = scopes
+		edited = true
+	}
 
 	if !edited {
 		tools.SendClientError(w, r, tools.ERROR_BODY_EMPTY)
@@ -132,11 +149,15 @@ func PATCH_Users_Me_Applications_ID(w http.ResponseWriter, r *http.Request) {
 			updated 	   = CURRENT_TIMESTAMP,
 			name		   = $1,
 			description    = $2,
-			auth_redirects = $3
-		WHERE id = $4 and user_id = $5`,
+			auth_redirects = $3,
+			auth_public    = $4,
+			auth_scopes    = $5
+		WHERE id = $6 and user_id = $7`,
 		application.Name,
 		application.Description,
 		application.AuthRedirects,
+		application.AuthPublic,
+		application.AuthScopes,
 		application.ID,
 		session.UserID,
 	)
@@ -157,5 +178,7 @@ func PATCH_Users_Me_Applications_ID(w http.ResponseWriter, r *http.Request) {
 		"description": application.Description,
 		"icon":        application.IconHash,
 		"redirects":   application.AuthRedirects,
+		"public":      application.AuthPublic,
+		"scopes":      tools.OAuth2ScopesToString(application.AuthScopes),
 	})
 }
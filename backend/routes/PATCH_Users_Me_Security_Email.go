@@ -3,6 +3,7 @@ package routes
 import (
 	"errors"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/bakonpancakz/template-auth/tools"
@@ -13,10 +14,6 @@ import (
 func PATCH_Users_Me_Security_Email(w http.ResponseWriter, r *http.Request) {
 
 	session := tools.GetSession(r)
-	if session.ApplicationID != tools.SESSION_NO_APPLICATION_ID {
-		tools.SendClientError(w, r, tools.ERROR_OAUTH2_USERS_ONLY)
-		return
-	}
 	if !session.Elevated {
 		tools.SendClientError(w, r, tools.ERROR_MFA_ESCALATION_REQUIRED)
 		return
@@ -34,7 +31,7 @@ func PATCH_Users_Me_Security_Email(w http.ResponseWriter, r *http.Request) {
 	// Check for Duplicate Email
 	var usageEmail int
 	err := tools.Database.
-		QueryRow(ctx, "SELECT COUNT(*) FROM auth.users WHERE email_address = LOWER($1)", Body.Email).
+		QueryRow(ctx, "SELECT COUNT(*) FROM auth.users WHERE email_index = $1", tools.EmailIndex(Body.Email)).
 		Scan(&usageEmail)
 	if err != nil {
 		tools.SendServerError(w, r, err)
@@ -46,22 +43,33 @@ func PATCH_Users_Me_Security_Email(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Update Account Email Fields
+	userEmail, err := tools.EncryptField(strings.ToLower(Body.Email))
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+	userVerifyToken, err := tools.GenerateSignedString()
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
 	var userEmailPrevious string
-	var userVerifyToken = tools.GenerateSignedString()
 	err = tools.Database.QueryRow(ctx,
 		`UPDATE auth.users SET
 			updated			 	= CURRENT_TIMESTAMP,
 			email_verified 		= FALSE,
-			email_address 	 	= LOWER($1),
-			token_verify 	 	= $2,
-			token_verify_eat 	= $3
-		WHERE id = $4
-		RETURNING (SELECT email_address FROM auth.users WHERE id = $4)`,
-		Body.Email,
-		userVerifyToken,
+			email_address 	 	= $1,
+			email_index 	 	= $2,
+			token_verify 	 	= $3,
+			token_verify_eat 	= $4
+		WHERE id = $5
+		RETURNING (SELECT email_address FROM auth.users WHERE id = $5)`,
+		userEmail,
+		tools.EmailIndex(Body.Email),
+		tools.HashToken(userVerifyToken),
 		time.Now().Add(tools.LIFETIME_TOKEN_EMAIL_VERIFY),
 		session.UserID,
-	).Scan(&userEmailPrevious)
+	).Scan(tools.Decrypted(&userEmailPrevious))
 	if errors.Is(err, pgx.ErrNoRows) {
 		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_USER)
 		return
@@ -72,7 +80,7 @@ func PATCH_Users_Me_Security_Email(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Send Emails
-	go func() {
+	tools.EmailAsync(func() {
 		subCtx, subCancel := tools.NewContext()
 		defer subCancel()
 
@@ -97,7 +105,7 @@ func PATCH_Users_Me_Security_Email(w http.ResponseWriter, r *http.Request) {
 				Displayname: displayname,
 			},
 		)
-	}()
+	})
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -12,10 +12,6 @@ import (
 func PATCH_Users_Me_Security_Password(w http.ResponseWriter, r *http.Request) {
 
 	session := tools.GetSession(r)
-	if session.ApplicationID != tools.SESSION_NO_APPLICATION_ID {
-		tools.SendClientError(w, r, tools.ERROR_OAUTH2_USERS_ONLY)
-		return
-	}
 
 	var Body struct {
 		OldPassword string `json:"old_password" validate:"required,password"`
@@ -24,6 +20,10 @@ func PATCH_Users_Me_Security_Password(w http.ResponseWriter, r *http.Request) {
 	if !tools.ValidateJSON(w, r, &Body) {
 		return
 	}
+	if tools.PasswordBreached(Body.NewPassword) {
+		tools.SendClientError(w, r, tools.ERROR_LOGIN_PASSWORD_BREACHED)
+		return
+	}
 	ctx, cancel := tools.NewContext()
 	defer cancel()
 
@@ -36,7 +36,7 @@ func PATCH_Users_Me_Security_Password(w http.ResponseWriter, r *http.Request) {
 		WHERE id = $1`,
 		session.UserID,
 	).Scan(
-		&user.EmailAddress,
+		tools.Decrypted(&user.EmailAddress),
 		&user.PasswordHash,
 		&user.PasswordHistory,
 	)
@@ -67,7 +67,7 @@ func PATCH_Users_Me_Security_Password(w http.ResponseWriter, r *http.Request) {
 		if ok, err := tools.ComparePasswordHash(oldPassword, Body.NewPassword); err != nil {
 			tools.SendServerError(w, r, err)
 			return
-		} else if !ok {
+		} else if ok {
 			tools.SendClientError(w, r, tools.ERROR_LOGIN_PASSWORD_ALREADY_USED)
 			return
 		}
@@ -103,24 +103,24 @@ func PATCH_Users_Me_Security_Password(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Notify Account Owner
-	go func() {
+	tools.EmailAsync(func() {
 		subCtx, subCancel := tools.NewContext()
 		defer subCancel()
 
 		// Fetch Displayname
 		displayname := tools.EMAIL_DEFAULT_DISPLAYNAME
 		tools.Database.
-			QueryRow(subCtx, "SELECT displayname FROM auth.profiles WHERE user_id = $1", user.ID).
+			QueryRow(subCtx, "SELECT displayname FROM auth.profiles WHERE id = $1", user.ID).
 			Scan(&displayname)
 
-			// Send Email
+		// Send Email
 		tools.TemplateNotifyUserPasswordModified(
 			user.EmailAddress,
 			tools.LocalsNotifyUserPasswordModified{
 				Displayname: displayname,
 			},
 		)
-	}()
+	})
 
 	// Revoke All Account Sessions
 	if _, err := tools.Database.Exec(ctx,
@@ -132,5 +132,14 @@ func PATCH_Users_Me_Security_Password(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Forget Trusted Devices
+	if _, err := tools.Database.Exec(ctx,
+		"UPDATE auth.devices SET trusted_until = NULL WHERE user_id = $1",
+		session.UserID,
+	); err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,499 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bakonpancakz/template-auth/tools"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func POST_Auth_Federated_Provider(w http.ResponseWriter, r *http.Request) {
+
+	provider, ok := tools.Federation[r.PathValue("provider")]
+	if !ok {
+		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_PROVIDER)
+		return
+	}
+
+	// Parse Request Body
+	// The Code may be omitted when answering the MFA Prompt of a previous Callback
+	var Body struct {
+		State    string `json:"state" validate:"required"`
+		Code     string `json:"code"`
+		Passcode string `json:"passcode" validate:"omitempty,passcode"`
+		Remember bool   `json:"remember"`
+	}
+	if !tools.ValidateJSON(w, r, &Body) {
+		return
+	}
+	cookie, err := r.Cookie(tools.HTTP_FEDERATION_COOKIE_NAME)
+	if err != nil || !tools.CompareStringConstant(cookie.Value, Body.State) {
+		tools.SendClientError(w, r, tools.ERROR_FEDERATION_STATE_INVALID)
+		return
+	}
+	ctx, cancel := tools.NewContext()
+	defer cancel()
+
+	// Fetch Relevant State
+	var state tools.DatabaseFederationState
+	err = tools.Database.QueryRow(ctx,
+		`SELECT
+			state, nonce, verifier, link_user_id, user_id
+		FROM auth.federation_states
+		WHERE state = $1 AND provider = $2 AND expires > NOW()`,
+		Body.State,
+		provider.Name,
+	).Scan(
+		&state.State,
+		&state.Nonce,
+		&state.Verifier,
+		&state.LinkUserID,
+		&state.UserID,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		tools.SendClientError(w, r, tools.ERROR_FEDERATION_STATE_INVALID)
+		return
+	}
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
+	// Redeem Code at Provider, unless the Account was found by a previous Callback
+	if state.UserID == nil {
+		if Body.Code == "" {
+			tools.SendClientError(w, r, tools.ERROR_FEDERATION_FAILED)
+			return
+		}
+		identity, err := provider.Exchange(ctx, Body.Code, state.Verifier, state.Nonce)
+		if err != nil {
+			tools.LoggerFederation.Warn("Exchange Failed", map[string]any{
+				"provider": provider.Name,
+				"error":    err.Error(),
+			})
+			tools.SendClientError(w, r, tools.ERROR_FEDERATION_FAILED)
+			return
+		}
+
+		// Link Identity to the Account which started the Request
+		if state.LinkUserID != nil {
+			if !consumeFederationState(ctx, w, r, state.State) {
+				return
+			}
+			if !linkIdentity(ctx, w, r, *state.LinkUserID, provider.Name, identity) {
+				return
+			}
+			clearFederationCookie(w)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		// Find or Create Relevant Account
+		userID, ok := resolveIdentity(ctx, w, r, provider.Name, identity)
+		if !ok {
+			return
+		}
+		if _, err := tools.Database.Exec(ctx,
+			"UPDATE auth.federation_states SET user_id = $1 WHERE state = $2",
+			userID,
+			state.State,
+		); err != nil {
+			tools.SendServerError(w, r, err)
+			return
+		}
+		state.UserID = &userID
+	}
+
+	// Sign into Relevant Account
+	if !completeFederation(ctx, w, r, state.State, *state.UserID, Body.Passcode, Body.Remember) {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Sign into the Account an Identity resolved to and Consume its State
+func completeFederation(ctx context.Context, w http.ResponseWriter, r *http.Request, state string, userID int64, passcode string, remember bool) bool {
+
+	// Fetch Relevant Account
+	var user tools.DatabaseUser
+	err := tools.Database.QueryRow(ctx,
+		`SELECT
+			id, email_address, mfa_enabled, mfa_secret, mfa_codes, mfa_codes_used,
+			lockout_until
+		FROM auth.users
+		WHERE id = $1`,
+		userID,
+	).Scan(
+		&user.ID,
+		tools.Decrypted(&user.EmailAddress),
+		&user.MFAEnabled,
+		tools.Decrypted(&user.MFASecret),
+		&user.MFACodes,
+		&user.MFACodesUsed,
+		&user.LockoutUntil,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_USER)
+		return false
+	}
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return false
+	}
+	if accountLocked(w, r, user) {
+		return false
+	}
+
+	// Find Relevant Device
+	device, err := lookupDevice(ctx, r, user.ID)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return false
+	}
+
+	// Filter: Multi-Factor Authentication
+	// The Provider replaces the Password, the Second Factor is still required
+	deviceTrust := false
+	deviceTrusted := device != nil && device.TrustedUntil != nil && device.TrustedUntil.After(time.Now())
+	if user.MFAEnabled && user.MFASecret != nil && !deviceTrusted {
+		if !verifyLoginPasscode(ctx, w, r, user, passcode) {
+			return false
+		}
+		deviceTrust = remember
+	}
+
+	// Create New Session
+	if !consumeFederationState(ctx, w, r, state) {
+		return false
+	}
+	if err := startSession(ctx, w, r, user, device, deviceTrust); errors.Is(err, pgx.ErrNoRows) {
+		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_USER)
+		return false
+	} else if errors.Is(err, errAccountDisabled) {
+		tools.SendClientError(w, r, tools.ERROR_LOGIN_ACCOUNT_DISABLED)
+		return false
+	} else if err != nil {
+		tools.SendServerError(w, r, err)
+		return false
+	}
+	clearFederationCookie(w)
+	return true
+}
+
+// Delete Federation State so it can't be Replayed
+func consumeFederationState(ctx context.Context, w http.ResponseWriter, r *http.Request, state string) bool {
+	tag, err := tools.Database.Exec(ctx,
+		"DELETE FROM auth.federation_states WHERE state = $1",
+		state,
+	)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return false
+	}
+	if tag.RowsAffected() == 0 {
+		tools.SendClientError(w, r, tools.ERROR_FEDERATION_STATE_INVALID)
+		return false
+	}
+	return true
+}
+
+// Bind Federation State to this Browser, Callbacks must come from the Browser which started the Login
+func setFederationCookie(w http.ResponseWriter, state string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     tools.HTTP_FEDERATION_COOKIE_NAME,
+		Value:    state,
+		Path:     "/",
+		Domain:   tools.HTTP_COOKIE_DOMAIN,
+		MaxAge:   int(tools.LIFETIME_FEDERATION_STATE.Seconds()),
+		Secure:   tools.HTTP_COOKIE_SECURE,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// Remove the Federation Cookie once its State is Consumed
+func clearFederationCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     tools.HTTP_FEDERATION_COOKIE_NAME,
+		Value:    "",
+		Path:     "/",
+		Domain:   tools.HTTP_COOKIE_DOMAIN,
+		MaxAge:   -1,
+		Secure:   tools.HTTP_COOKIE_SECURE,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// Attach Identity to Account, each Account may link one Identity per Provider
+func linkIdentity(ctx context.Context, w http.ResponseWriter, r *http.Request, userID int64, provider string, identity tools.FederationIdentity) bool {
+	identityEmail, err := tools.EncryptFieldOptional(identity.EmailAddress)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return false
+	}
+	tag, err := tools.Database.Exec(ctx,
+		`INSERT INTO auth.identities (
+			id, user_id, provider, subject, email_address
+		) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT DO NOTHING`,
+		tools.GenerateSnowflake(),
+		userID,
+		provider,
+		identity.Subject,
+		identityEmail,
+	)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return false
+	}
+	if tag.RowsAffected() == 0 {
+		tools.SendClientError(w, r, tools.ERROR_FEDERATION_IDENTITY_LINKED)
+		return false
+	}
+	return true
+}
+
+// Find the Account belonging to an Identity, returns the Relevant User ID.
+// Unknown Identities are linked to the Account using their Email Address only
+// if both sides have verified it, otherwise a New Account is created.
+func resolveIdentity(ctx context.Context, w http.ResponseWriter, r *http.Request, provider string, identity tools.FederationIdentity) (int64, bool) {
+
+	// Fetch Linked Account
+	var userID int64
+	identityEmail, err := tools.EncryptFieldOptional(identity.EmailAddress)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return 0, false
+	}
+	err = tools.Database.QueryRow(ctx,
+		`UPDATE auth.identities SET
+			used          = CURRENT_TIMESTAMP,
+			email_address = COALESCE($3, email_address)
+		WHERE provider = $1 AND subject = $2
+		RETURNING user_id`,
+		provider,
+		identity.Subject,
+		identityEmail,
+	).Scan(&userID)
+	if err == nil {
+		return userID, true
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		tools.SendServerError(w, r, err)
+		return 0, false
+	}
+	if identity.EmailAddress == "" {
+		tools.SendClientError(w, r, tools.ERROR_FEDERATION_EMAIL_REQUIRED)
+		return 0, false
+	}
+
+	// Fetch Account with Matching Email
+	var emailVerified bool
+	err = tools.Database.QueryRow(ctx,
+		"SELECT id, email_verified FROM auth.users WHERE email_index = $1",
+		tools.EmailIndex(identity.EmailAddress),
+	).Scan(
+		&userID,
+		&emailVerified,
+	)
+	if err == nil {
+		// Either side could have been registered by someone who doesn't own the Address
+		if !identity.EmailVerified || !emailVerified {
+			tools.SendClientError(w, r, tools.ERROR_FEDERATION_EMAIL_CONFLICT)
+			return 0, false
+		}
+		if !linkIdentity(ctx, w, r, userID, provider, identity) {
+			return 0, false
+		}
+		return userID, true
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		tools.SendServerError(w, r, err)
+		return 0, false
+	}
+
+	// Generate Account Fields
+	userID = tools.GenerateSnowflake()
+	username, displayname := federatedProfile(identity)
+	username, err = uniqueUsername(ctx, username, userID)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return 0, false
+	}
+	var userVerifyEmail, userVerifyHash *string
+	var userVerifyExpires *time.Time
+	if !identity.EmailVerified {
+		token, err := tools.GenerateSignedString()
+		if err != nil {
+			tools.SendServerError(w, r, err)
+			return 0, false
+		}
+		hashed := tools.HashToken(token)
+		expires := time.Now().Add(tools.LIFETIME_TOKEN_EMAIL_VERIFY)
+		userVerifyEmail, userVerifyHash, userVerifyExpires = &token, &hashed, &expires
+	}
+	userEmail, err := tools.EncryptField(identity.EmailAddress)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return 0, false
+	}
+	userDevice := deviceCookie(r)
+	if userDevice == "" {
+		if userDevice, err = tools.GenerateSignedString(); err != nil {
+			tools.SendServerError(w, r, err)
+			return 0, false
+		}
+	}
+	userDeviceAddress, userDeviceAgent, err := sealDevice(tools.GetRemoteIP(r), r.UserAgent())
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return 0, false
+	}
+
+	// [TX] Begin Transaction
+	tx, err := tools.Database.Begin(ctx)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return 0, false
+	}
+	defer tx.Rollback(ctx)
+
+	// [TX] Create New Account without a Password
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO auth.users (
+			id, email_address, email_index, email_verified, token_verify, token_verify_eat
+		) VALUES ($1, $2, $3, $4, $5, $6);`,
+		userID,
+		userEmail,
+		tools.EmailIndex(identity.EmailAddress),
+		identity.EmailVerified,
+		userVerifyHash,
+		userVerifyExpires,
+	); err != nil {
+		tools.SendServerError(w, r, err)
+		return 0, false
+	}
+
+	// [TX] Create New Profile
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO auth.profiles (
+			id, username, displayname
+		) VALUES ($1, $2, $3);`,
+		userID,
+		username,
+		displayname,
+	); err != nil {
+		tools.SendServerError(w, r, err)
+		return 0, false
+	}
+
+	// [TX] Remember Signup Device
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO auth.devices (
+			id, user_id, token, verified, ip_address, ip_index, user_agent
+		) VALUES ($1, $2, $3, TRUE, $4, $5, $6);`,
+		tools.GenerateSnowflake(),
+		userID,
+		tools.HashToken(userDevice),
+		userDeviceAddress,
+		tools.AddressIndex(tools.GetRemoteIP(r)),
+		userDeviceAgent,
+	); err != nil {
+		tools.SendServerError(w, r, err)
+		return 0, false
+	}
+
+	// [TX] Link Identity
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO auth.identities (
+			id, user_id, provider, subject, email_address
+		) VALUES ($1, $2, $3, $4, $5);`,
+		tools.GenerateSnowflake(),
+		userID,
+		provider,
+		identity.Subject,
+		identityEmail,
+	); err != nil {
+		tools.SendServerError(w, r, err)
+		return 0, false
+	}
+
+	// [TX] Complete Transaction
+	if err := tx.Commit(ctx); err != nil {
+		tools.SendServerError(w, r, err)
+		return 0, false
+	}
+
+	// Send Verification Email
+	if userVerifyEmail != nil {
+		tools.EmailAsync(func() {
+			tools.TemplateEmailVerify(
+				identity.EmailAddress,
+				tools.LocalsEmailVerify{
+					Displayname: displayname,
+					Token:       *userVerifyEmail,
+				},
+			)
+		})
+	}
+
+	setDeviceCookie(w, userDevice)
+	return userID, true
+}
+
+// Suffix Username with the Account ID if it's already taken
+func uniqueUsername(ctx context.Context, username string, userID int64) (string, error) {
+	var taken bool
+	if err := tools.Database.QueryRow(ctx,
+		"SELECT EXISTS (SELECT 1 FROM auth.profiles WHERE username = $1)",
+		username,
+	).Scan(&taken); err != nil {
+		return "", err
+	}
+	if taken {
+		username = username[:min(len(username), 19)] + "_" + strconv.FormatInt(userID, 36)
+	}
+	return username, nil
+}
+
+// Derive Username and Displayname for a New Account from its Identity
+func federatedProfile(identity tools.FederationIdentity) (username, displayname string) {
+	base := identity.Username
+	if base == "" {
+		base, _, _ = strings.Cut(identity.EmailAddress, "@")
+	}
+	username = strings.Map(func(c rune) rune {
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '_':
+			return c
+		case c >= 'A' && c <= 'Z':
+			return c + ('a' - 'A')
+		case c == '.' || c == '-':
+			return '_'
+		}
+		return -1
+	}, base)
+	username = username[:min(len(username), tools.USERNAME_LENGTH_MAX)]
+	if username == "" {
+		username = "user"
+	}
+	for len(username) < tools.USERNAME_LENGTH_MIN {
+		username += "_"
+	}
+
+	displayname = strings.TrimSpace(identity.Displayname)
+	if displayname == "" {
+		displayname = base
+	}
+	if runes := []rune(displayname); len(runes) > tools.DISPLAYNAME_LENGTH_MAX {
+		displayname = string(runes[:tools.DISPLAYNAME_LENGTH_MAX])
+	}
+	return username, displayname
+}
@@ -1,8 +1,10 @@
 package routes
 
 import (
+	"context"
 	"errors"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/bakonpancakz/template-auth/tools"
@@ -17,6 +19,7 @@ func POST_Auth_Login(w http.ResponseWriter, r *http.Request) {
 		Email    string `json:"email" validate:"required,email"`
 		Password string `json:"password" validate:"required"`
 		Passcode string `json:"passcode" validate:"omitempty,passcode"`
+		Remember bool   `json:"remember"`
 	}
 	if !tools.ValidateJSON(w, r, &Body) {
 		return
@@ -28,21 +31,22 @@ func POST_Auth_Login(w http.ResponseWriter, r *http.Request) {
 	var user tools.DatabaseUser
 	err := tools.Database.QueryRow(ctx,
 		`SELECT
-			id, email_address, email_verified, ip_address, mfa_enabled,
-			mfa_secret, mfa_codes, mfa_codes_used, password_hash
+			id, email_address, email_verified, mfa_enabled,
+			mfa_secret, mfa_codes, mfa_codes_used, password_hash,
+			lockout_until
 		FROM auth.users
-		WHERE email_address = LOWER($1)`,
-		Body.Email,
+		WHERE email_index = $1`,
+		tools.EmailIndex(Body.Email),
 	).Scan(
 		&user.ID,
-		&user.EmailAddress,
+		tools.Decrypted(&user.EmailAddress),
 		&user.EmailVerified,
-		&user.IPAddress,
 		&user.MFAEnabled,
-		&user.MFASecret,
+		tools.Decrypted(&user.MFASecret),
 		&user.MFACodes,
 		&user.MFACodesUsed,
 		&user.PasswordHash,
+		&user.LockoutUntil,
 	)
 	if errors.Is(err, pgx.ErrNoRows) {
 		tools.SendClientError(w, r, tools.ERROR_LOGIN_INCORRECT)
@@ -52,11 +56,14 @@ func POST_Auth_Login(w http.ResponseWriter, r *http.Request) {
 		tools.SendServerError(w, r, err)
 		return
 	}
+	if accountLocked(w, r, user) {
+		return
+	}
 
 	// Password Validation
 	if user.PasswordHash == nil {
-		// Intended for a scrapped feature where passwords found in a data
-		// breach on another platform would be cleared to protect the user
+		// Cleared when the password was found in a data breach on another
+		// platform, the user was emailed a link to reset it
 		tools.SendClientError(w, r, tools.ERROR_LOGIN_PASSWORD_RESET)
 		return
 	}
@@ -64,76 +71,139 @@ func POST_Auth_Login(w http.ResponseWriter, r *http.Request) {
 		tools.SendServerError(w, r, err)
 		return
 	} else if !ok {
-		tools.SendClientError(w, r, tools.ERROR_LOGIN_INCORRECT)
+		failAttempt(ctx, w, r, user.ID, factorPassword, tools.ERROR_LOGIN_INCORRECT)
 		return
 	}
+	if tools.PasswordBreached(Body.Password) {
+		// Stored Hashes can only be screened while their Password is known
+		if err := flagBreachedPassword(ctx, user); err != nil {
+			tools.SendServerError(w, r, err)
+			return
+		}
+		tools.SendClientError(w, r, tools.ERROR_LOGIN_PASSWORD_RESET)
+		return
+	}
+	if tools.PasswordHashOutdated(*user.PasswordHash) {
+		rehashPassword(ctx, user.ID, *user.PasswordHash, Body.Password)
+	}
 
-	// Filter: Multi-Factor Authentication
+	// Assess Login Risk, which may require MFA or an emailed Approval
+	device, deviceTrust, ok := assessLogin(ctx, w, r, user, Body.Passcode, Body.Remember)
+	if !ok {
+		return
+	}
+
+	// Create New Session
+	if err := startSession(ctx, w, r, user, device, deviceTrust); errors.Is(err, pgx.ErrNoRows) {
+		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_USER)
+		return
+	} else if errors.Is(err, errAccountDisabled) {
+		tools.SendClientError(w, r, tools.ERROR_LOGIN_ACCOUNT_DISABLED)
+		return
+	} else if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+var errAccountDisabled = errors.New("account disabled")
+
+// Assess the Risk of a Login once its First Factor was proven, then require
+// MFA or an emailed Approval where needed. Returns the Device to start the
+// Session on and whether to trust it, or false if a Response was sent.
+func assessLogin(ctx context.Context, w http.ResponseWriter, r *http.Request, user tools.DatabaseUser, passcode string, remember bool) (*tools.DatabaseDevice, bool, bool) {
+
+	// Find Relevant Device
 	sessionAgent := r.UserAgent()
 	sessionAddress := tools.GetRemoteIP(r)
-	if user.MFAEnabled && user.MFASecret != nil {
+	device, err := lookupDevice(ctx, r, user.ID)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return nil, false, false
+	}
+	deviceID := int64(0)
+	deviceTrusted := false
+	if device != nil {
+		deviceID = device.ID
+		deviceTrusted = device.TrustedUntil != nil && device.TrustedUntil.After(time.Now())
+	}
 
-		// Method: TOTP Verification
-		// User must attempt to prove ownership by entering a code generated by
-		// their authenticator app or by entering a recovery code
+	// Logins are expected from a Device the Owner has allowed before, or
+	// failing that from an IP Address one of those Devices was last seen at
+	var deviceKnown bool
+	if err := tools.Database.QueryRow(ctx,
+		`SELECT EXISTS (
+			SELECT 1 FROM auth.devices
+			WHERE user_id = $1
+			AND verified = TRUE
+			AND (id = $2 OR ip_index = $3)
+		)`,
+		user.ID,
+		deviceID,
+		tools.AddressIndex(sessionAddress),
+	).Scan(&deviceKnown); err != nil {
+		tools.SendServerError(w, r, err)
+		return nil, false, false
+	}
 
-		if Body.Passcode == "" {
-			tools.SendClientError(w, r, tools.ERROR_MFA_PASSCODE_REQUIRED)
-			return
-		}
+	// Assess Login Risk
+	// Unknown Devices require approval by default, other Signals may escalate
+	// that to MFA or refuse the Login entirely
+	risk, err := tools.AssessLogin(ctx, user.ID, sessionAddress, sessionAgent, deviceKnown)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return nil, false, false
+	}
+	approvalRequired := risk.Decision >= tools.RISK_APPROVE &&
+		tools.EMAIL_PROVIDER != "none" &&
+		user.EmailVerified
+	mfaRequired := user.MFAEnabled && user.MFASecret != nil &&
+		(!deviceTrusted || risk.Decision >= tools.RISK_MFA)
 
-		switch len(Body.Passcode) {
-
-		// Use Recovery Code
-		case tools.MFA_RECOVERY_LENGTH:
-			found := false
-			for i, recoveryCode := range user.MFACodes {
-				if Body.Passcode == recoveryCode {
-					// Code Used?
-					if (user.MFACodesUsed & (1 << i)) != 0 {
-						tools.SendClientError(w, r, tools.ERROR_MFA_RECOVERY_CODE_USED)
-						return
-					}
-					// Mark Recovery Code as Used
-					if _, err := tools.Database.Exec(ctx,
-						"UPDATE auth.users SET mfa_codes_used = mfa_codes_used | $1 WHERE id = $2",
-						(1 << i),
-						user.ID,
-					); err != nil {
-						tools.SendServerError(w, r, err)
-						return
-					}
-					found = true
-					break
-				}
-			}
-			if !found {
-				tools.SendClientError(w, r, tools.ERROR_MFA_RECOVERY_CODE_INCORRECT)
-				return
-			}
+	// Remember Device, so an emailed Approval applies to it once allowed
+	if device == nil && approvalRequired && !mfaRequired {
+		if device, err = registerDevice(ctx, w, r, user.ID); err != nil {
+			tools.SendServerError(w, r, err)
+			return nil, false, false
+		}
+		deviceID = device.ID
+	}
+	if err := tools.RecordAssessment(ctx, user.ID, deviceID, sessionAddress, sessionAgent, risk); err != nil {
+		tools.SendServerError(w, r, err)
+		return nil, false, false
+	}
+	if risk.Decision == tools.RISK_DENY {
+		tools.SendClientError(w, r, tools.ERROR_LOGIN_RISK_DENIED)
+		return nil, false, false
+	}
 
-		// Using Passcode
-		case tools.MFA_PASSCODE_LENGTH:
-			if !tools.ValidateTOTPCode(Body.Passcode, *user.MFASecret) {
-				tools.SendClientError(w, r, tools.ERROR_MFA_PASSCODE_INCORRECT)
-				return
-			}
+	// Filter: Multi-Factor Authentication
+	// Devices the Owner chose to remember skip this step until their trust
+	// expires, unless the Login is considered risky
+	deviceTrust := false
+	if mfaRequired {
 
-		default:
-			// Should be caught by validator!
-			tools.SendClientError(w, r, tools.ERROR_MFA_PASSCODE_INCORRECT)
-			return
+		// Method: TOTP Verification
+		// User must attempt to prove ownership by entering a code generated by
+		// their authenticator app or by entering a recovery code
+		if !verifyLoginPasscode(ctx, w, r, user, passcode) {
+			return nil, false, false
 		}
+		deviceTrust = remember
 
-	} else if tools.EMAIL_PROVIDER != "none" && user.EmailVerified &&
-		!tools.CompareStringConstant(user.IPAddress, sessionAddress) {
+	} else if approvalRequired {
 
 		// Method: Allow Login
-		// User must allow this unknown new location access to their account by
-		// clicking on a button sent to their email address
+		// User must allow this unknown new device or location access to their
+		// account by clicking on a button sent to their email address
 
 		// Generate New Token
-		loginToken := tools.GenerateSignedString()
+		loginToken, err := tools.GenerateSignedString()
+		if err != nil {
+			tools.SendServerError(w, r, err)
+			return nil, false, false
+		}
 		tag, err := tools.Database.Exec(ctx,
 			`UPDATE auth.users SET
 				updated 		 = CURRENT_TIMESTAMP,
@@ -141,29 +211,29 @@ func POST_Auth_Login(w http.ResponseWriter, r *http.Request) {
 				token_login_data = $2,
 				token_login_eat  = $3
 			WHERE id = $4`,
-			loginToken,
-			sessionAddress,
+			tools.HashToken(loginToken),
+			strconv.FormatInt(device.ID, 10),
 			time.Now().Add(tools.LIFETIME_TOKEN_EMAIL_LOGIN),
 			user.ID,
 		)
 		if err != nil {
 			tools.SendServerError(w, r, err)
-			return
+			return nil, false, false
 		}
 		if tag.RowsAffected() == 0 {
 			tools.SendClientError(w, r, tools.ERROR_UNKNOWN_USER)
-			return
+			return nil, false, false
 		}
 
 		// Alert Account Owner
-		go func() {
+		tools.EmailAsync(func() {
 			subCtx, subCancel := tools.NewContext()
 			defer subCancel()
 
 			// Fetch Displayname
 			displayname := tools.EMAIL_DEFAULT_DISPLAYNAME
 			tools.Database.
-				QueryRow(subCtx, "SELECT displayname FROM auth.profiles WHERE user_id = $1", user.ID).
+				QueryRow(subCtx, "SELECT displayname FROM auth.profiles WHERE id = $1", user.ID).
 				Scan(&displayname)
 
 			// Send Email
@@ -178,83 +248,392 @@ func POST_Auth_Login(w http.ResponseWriter, r *http.Request) {
 					DeviceLocation: tools.LookupLocation(sessionAddress),
 				},
 			)
-		}()
+		})
 
 		tools.SendClientError(w, r, tools.ERROR_MFA_EMAIL_SENT)
-		return
+		return nil, false, false
 	}
+	return device, deviceTrust, true
+}
 
-	// Update Account
-	sessionCreated := time.Now()
-	sessionToken := tools.GenerateSignedString()
+// Create New Session for Account and Remember its Device, alerting the Owner
+// if the Device is new. Trusted Devices may skip MFA on their next login.
+func startSession(ctx context.Context, w http.ResponseWriter, r *http.Request, user tools.DatabaseUser, device *tools.DatabaseDevice, trust bool) error {
+
+	sessionAgent := r.UserAgent()
+	sessionAddress := tools.GetRemoteIP(r)
+
+	// Accounts deactivated by their Organization may not Login
+	var disabled bool
+	if err := tools.Database.QueryRow(ctx,
+		"SELECT EXISTS (SELECT 1 FROM auth.scim_users WHERE user_id = $1 AND active = FALSE)",
+		user.ID,
+	).Scan(&disabled); err != nil {
+		return err
+	}
+	if disabled {
+		return errAccountDisabled
+	}
+
+	// Remember Device
+	var err error
+	if device == nil {
+		if device, err = registerDevice(ctx, w, r, user.ID); err != nil {
+			return err
+		}
+	} else {
+		// Refresh the Cookie as presented, only its Hash is stored
+		setDeviceCookie(w, deviceCookie(r))
+	}
+	var trustedUntil *time.Time
+	if trust {
+		t := time.Now().Add(tools.DEVICE_TRUST_INTERVAL)
+		trustedUntil = &t
+	}
+	sessionAddressSealed, sessionAgentSealed, err := sealDevice(sessionAddress, sessionAgent)
+	if err != nil {
+		return err
+	}
 	tag, err := tools.Database.Exec(ctx,
-		`UPDATE auth.users SET
-			updated    = CURRENT_TIMESTAMP,
-			ip_address = $1
-		WHERE id = $2`,
-		sessionAddress,
+		`UPDATE auth.devices SET
+			used          = CURRENT_TIMESTAMP,
+			verified      = TRUE,
+			ip_address    = $1,
+			ip_index      = $2,
+			user_agent    = $3,
+			trusted_until = COALESCE($4, trusted_until)
+		WHERE id = $5 AND user_id = $6`,
+		sessionAddressSealed,
+		tools.AddressIndex(sessionAddress),
+		sessionAgentSealed,
+		trustedUntil,
+		device.ID,
 		user.ID,
 	)
 	if err != nil {
-		tools.SendServerError(w, r, err)
-		return
+		return err
 	}
 	if tag.RowsAffected() == 0 {
-		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_USER)
-		return
+		return pgx.ErrNoRows
 	}
 
 	// Create New Session
+	sessionCreated := time.Now()
+	sessionToken, err := tools.GenerateSignedString()
+	if err != nil {
+		return err
+	}
 	_, err = tools.Database.Exec(ctx,
 		`INSERT INTO auth.sessions (
-			id, created, user_id, token, device_ip_address, device_user_agent
-		) VALUES ($1, $2, $3, $4, $5, $6);`,
+			id, created, used, expires, user_id, token, device_ip_address, device_user_agent
+		) VALUES ($1, $2, $2, $3, $4, $5, $6, $7);`,
 		tools.GenerateSnowflake(),
 		sessionCreated,
+		sessionCreated.Add(tools.LIFETIME_TOKEN_USER_COOKIE),
 		user.ID,
-		sessionToken,
-		sessionAddress,
-		sessionAgent,
+		tools.HashToken(sessionToken),
+		sessionAddressSealed,
+		sessionAgentSealed,
 	)
 	if err != nil {
-		tools.SendServerError(w, r, err)
-		return
+		return err
+	}
+	if err := clearFailures(ctx, user.ID); err != nil {
+		return err
 	}
 
 	// Alert Account Owner
-	go func() {
+	if !device.Verified {
+		tools.EmailAsync(func() {
+			subCtx, subCancel := tools.NewContext()
+			defer subCancel()
+
+			// Fetch Displayname
+			displayname := tools.EMAIL_DEFAULT_DISPLAYNAME
+			tools.Database.
+				QueryRow(subCtx, "SELECT displayname FROM auth.profiles WHERE id = $1", user.ID).
+				Scan(&displayname)
+
+			// Send Email
+			tools.TemplateLoginNewDevice(
+				user.EmailAddress,
+				tools.LocalsLoginNewDevice{
+					Displayname:    displayname,
+					IpAddress:      sessionAddress,
+					Timestamp:      tools.LookupTimezone(time.Now(), sessionAddress),
+					DeviceBrowser:  tools.LookupBrowser(sessionAgent),
+					DeviceLocation: tools.LookupLocation(sessionAddress),
+				},
+			)
+		})
+	}
+
+	// Set Session
+	http.SetCookie(w, &http.Cookie{
+		Name:     tools.HTTP_COOKIE_NAME,
+		Value:    sessionToken,
+		Path:     "/",
+		Domain:   tools.HTTP_COOKIE_DOMAIN,
+		MaxAge:   int(tools.LIFETIME_TOKEN_USER_COOKIE.Seconds()),
+		Secure:   tools.HTTP_COOKIE_SECURE,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// Read the Device Cookie, empty if missing or not issued by the Server
+func deviceCookie(r *http.Request) string {
+	cookie, err := r.Cookie(tools.HTTP_DEVICE_COOKIE_NAME)
+	if err != nil || !tools.CompareSignedString(cookie.Value) {
+		return ""
+	}
+	return cookie.Value
+}
+
+// Set the Device Cookie, which outlives Sessions so the Device is recognized later
+func setDeviceCookie(w http.ResponseWriter, token string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     tools.HTTP_DEVICE_COOKIE_NAME,
+		Value:    token,
+		Path:     "/",
+		Domain:   tools.HTTP_COOKIE_DOMAIN,
+		MaxAge:   int(tools.LIFETIME_TOKEN_DEVICE_COOKIE.Seconds()),
+		Secure:   tools.HTTP_COOKIE_SECURE,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// Clear a Password found in a Breach, signing the Account out everywhere as
+// someone else may know it, and email its Owner a link to reset it
+func flagBreachedPassword(ctx context.Context, user tools.DatabaseUser) error {
+
+	// [TX] Begin Transaction
+	tx, err := tools.Database.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// [TX] Clear Password, unless it was changed in the meantime
+	resetToken, err := tools.GenerateSignedString()
+	if err != nil {
+		return err
+	}
+	tag, err := tx.Exec(ctx,
+		`UPDATE auth.users SET
+			updated 		  = CURRENT_TIMESTAMP,
+			password_hash 	  = NULL,
+			password_breached = CURRENT_TIMESTAMP,
+			token_reset 	  = $1,
+			token_reset_eat   = $2
+		WHERE id = $3 AND password_hash = $4`,
+		tools.HashToken(resetToken),
+		time.Now().Add(tools.LIFETIME_TOKEN_EMAIL_RESET),
+		user.ID,
+		user.PasswordHash,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil
+	}
+
+	// [TX] Revoke Sessions
+	if _, err := tx.Exec(ctx,
+		"UPDATE auth.sessions SET revoked = TRUE WHERE user_id = $1",
+		user.ID,
+	); err != nil {
+		return err
+	}
+
+	// [TX] Complete Transaction
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	tools.LoggerBreaches.Warn("Account Flagged", map[string]any{
+		"user_id": user.ID,
+	})
+
+	// Notify Account Owner
+	tools.EmailAsync(func() {
 		subCtx, subCancel := tools.NewContext()
 		defer subCancel()
 
 		// Fetch Displayname
 		displayname := tools.EMAIL_DEFAULT_DISPLAYNAME
 		tools.Database.
-			QueryRow(subCtx, "SELECT displayname FROM auth.profiles WHERE user_id = $1", user.ID).
+			QueryRow(subCtx, "SELECT displayname FROM auth.profiles WHERE id = $1", user.ID).
 			Scan(&displayname)
 
 		// Send Email
-		tools.TemplateLoginNewDevice(
+		tools.TemplateLoginPasswordBreached(
 			user.EmailAddress,
-			tools.LocalsLoginNewDevice{
-				Displayname:    displayname,
-				IpAddress:      sessionAddress,
-				Timestamp:      tools.LookupTimezone(time.Now(), sessionAddress),
-				DeviceBrowser:  tools.LookupBrowser(sessionAgent),
-				DeviceLocation: tools.LookupLocation(sessionAddress),
+			tools.LocalsLoginPasswordBreached{
+				Displayname: displayname,
+				Token:       resetToken,
 			},
 		)
-	}()
-
-	// Set Session
-	http.SetCookie(w, &http.Cookie{
-		Name:     tools.HTTP_COOKIE_NAME,
-		Value:    sessionToken,
-		Path:     "",
-		Domain:   tools.HTTP_COOKIE_DOMAIN,
-		MaxAge:   int(tools.LIFETIME_TOKEN_USER_COOKIE),
-		Secure:   tools.HTTP_COOKIE_SECURE,
-		HttpOnly: true,
-		SameSite: http.SameSiteLaxMode,
 	})
-	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// Find Device of Account using the Device Cookie, nil if the Device is Unknown
+func lookupDevice(ctx context.Context, r *http.Request, userID int64) (*tools.DatabaseDevice, error) {
+	token := deviceCookie(r)
+	if token == "" {
+		return nil, nil
+	}
+	var device tools.DatabaseDevice
+	err := tools.Database.QueryRow(ctx,
+		`SELECT
+			id, user_id, token, verified, trusted_until
+		FROM auth.devices
+		WHERE user_id = $1 AND token = ANY($2)`,
+		userID,
+		tools.TokenCandidates(token),
+	).Scan(
+		&device.ID,
+		&device.UserID,
+		&device.Token,
+		&device.Verified,
+		&device.TrustedUntil,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+// Register New Device for Account, Browsers keep their Device Cookie between
+// Accounts so each of them can recognize it
+func registerDevice(ctx context.Context, w http.ResponseWriter, r *http.Request, userID int64) (*tools.DatabaseDevice, error) {
+	token := deviceCookie(r)
+	if token == "" {
+		var err error
+		if token, err = tools.GenerateSignedString(); err != nil {
+			return nil, err
+		}
+	}
+	hashed := tools.HashToken(token)
+	device := tools.DatabaseDevice{
+		ID:        tools.GenerateSnowflake(),
+		UserID:    userID,
+		Token:     &hashed,
+		IPAddress: tools.GetRemoteIP(r),
+		UserAgent: r.UserAgent(),
+	}
+	address, agent, err := sealDevice(device.IPAddress, device.UserAgent)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tools.Database.Exec(ctx,
+		`INSERT INTO auth.devices (
+			id, user_id, token, ip_address, ip_index, user_agent
+		) VALUES ($1, $2, $3, $4, $5, $6)`,
+		device.ID,
+		device.UserID,
+		device.Token,
+		address,
+		tools.AddressIndex(device.IPAddress),
+		agent,
+	); err != nil {
+		return nil, err
+	}
+	setDeviceCookie(w, token)
+	return &device, nil
+}
+
+// Encrypt the IP Address and User Agent a Device or Session was seen with
+func sealDevice(address, agent string) (string, string, error) {
+	address, err := tools.EncryptField(address)
+	if err != nil {
+		return "", "", err
+	}
+	agent, err = tools.EncryptField(agent)
+	if err != nil {
+		return "", "", err
+	}
+	return address, agent, nil
+}
+
+// Verify TOTP Passcode or Recovery Code for an Account with MFA Enabled,
+// Incorrect Guesses count towards locking the Account
+func verifyLoginPasscode(ctx context.Context, w http.ResponseWriter, r *http.Request, user tools.DatabaseUser, passcode string) bool {
+
+	if passcode == "" {
+		tools.SendClientError(w, r, tools.ERROR_MFA_PASSCODE_REQUIRED)
+		return false
+	}
+	if accountLocked(w, r, user) {
+		return false
+	}
+
+	switch len(passcode) {
+
+	// Use Recovery Code
+	case tools.MFA_RECOVERY_LENGTH:
+		for i, recoveryCode := range user.MFACodes {
+			if passcode == recoveryCode {
+				// Code Used?
+				if (user.MFACodesUsed & (1 << i)) != 0 {
+					failAttempt(ctx, w, r, user.ID, factorRecovery, tools.ERROR_MFA_RECOVERY_CODE_USED)
+					return false
+				}
+				// Mark Recovery Code as Used
+				if _, err := tools.Database.Exec(ctx,
+					"UPDATE auth.users SET mfa_codes_used = mfa_codes_used | $1 WHERE id = $2",
+					(1 << i),
+					user.ID,
+				); err != nil {
+					tools.SendServerError(w, r, err)
+					return false
+				}
+				return true
+			}
+		}
+		failAttempt(ctx, w, r, user.ID, factorRecovery, tools.ERROR_MFA_RECOVERY_CODE_INCORRECT)
+		return false
+
+	// Using Passcode
+	case tools.MFA_PASSCODE_LENGTH:
+		if !tools.ValidateTOTPCode(passcode, *user.MFASecret) {
+			failAttempt(ctx, w, r, user.ID, factorPasscode, tools.ERROR_MFA_PASSCODE_INCORRECT)
+			return false
+		}
+		return true
+
+	default:
+		// Should be caught by validator!
+		tools.SendClientError(w, r, tools.ERROR_MFA_PASSCODE_INCORRECT)
+		return false
+	}
+}
+
+// Upgrade Hash to the Configured Algorithm and Parameters, failures are only
+// logged as the old Hash remains valid and is tried again on the next Login
+func rehashPassword(ctx context.Context, userID int64, oldHash, password string) {
+	newHash, err := tools.GeneratePasswordHash(password)
+	if err == nil {
+		_, err = tools.Database.Exec(ctx,
+			`UPDATE auth.users SET
+				password_hash 	 = $1,
+				password_history = array_replace(password_history, $2, $1)
+			WHERE id = $3 AND password_hash = $2`,
+			newHash,
+			oldHash,
+			userID,
+		)
+	}
+	if err != nil {
+		tools.LoggerPassword.Error("Rehash Failed", map[string]any{
+			"user_id": userID,
+			"error":   err.Error(),
+		})
+	}
 }
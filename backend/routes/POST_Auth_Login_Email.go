@@ -0,0 +1,84 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bakonpancakz/template-auth/tools"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func POST_Auth_Login_Email(w http.ResponseWriter, r *http.Request) {
+
+	var Body struct {
+		Email string `json:"email" validate:"required,email"`
+	}
+	if !tools.ValidateJSON(w, r, &Body) {
+		return
+	}
+	ctx, cancel := tools.NewContext()
+	defer cancel()
+
+	// Update Account matching Given Email
+	// Login Codes are kept apart from Escalation Codes, so neither completes the other
+	loginToken, err := tools.GenerateSignedString()
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+	var (
+		loginExpires  = time.Now().Add(tools.LIFETIME_TOKEN_EMAIL_PASSCODE)
+		loginPasscode = tools.GeneratePasscode()
+		user          tools.DatabaseUser
+	)
+	err = tools.Database.QueryRow(ctx,
+		`UPDATE auth.users SET
+			updated 			 = CURRENT_TIMESTAMP,
+			token_magic 		 = $1,
+			token_magic_passcode = $2,
+			token_magic_eat 	 = $3,
+			token_magic_attempts = 0
+		WHERE email_index = $4
+		RETURNING id, email_address`,
+		tools.HashToken(loginToken),
+		tools.HashToken(loginPasscode),
+		loginExpires,
+		tools.EmailIndex(Body.Email),
+	).Scan(&user.ID, tools.Decrypted(&user.EmailAddress))
+	if errors.Is(err, pgx.ErrNoRows) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
+	// Send Login Link to Account Owner
+	tools.EmailAsync(func() {
+		subCtx, subCancel := tools.NewContext()
+		defer subCancel()
+
+		// Fetch Displayname
+		displayname := tools.EMAIL_DEFAULT_DISPLAYNAME
+		tools.Database.
+			QueryRow(subCtx, "SELECT displayname FROM auth.profiles WHERE id = $1", user.ID).
+			Scan(&displayname)
+
+		// Send Email
+		tools.TemplateLoginEmail(
+			user.EmailAddress,
+			tools.LocalsLoginEmail{
+				Displayname: displayname,
+				Token:       loginToken,
+				Code:        loginPasscode,
+				Lifetime:    fmt.Sprint(tools.LIFETIME_TOKEN_EMAIL_PASSCODE.Minutes()),
+			},
+		)
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
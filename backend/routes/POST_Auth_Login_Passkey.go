@@ -0,0 +1,193 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/bakonpancakz/template-auth/tools"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Fields returned by navigator.credentials.get(), encoded as base64url
+type passkeyAssertion struct {
+	CredentialID      string
+	ClientData        string
+	AuthenticatorData string
+	Signature         string
+	UserHandle        string
+}
+
+func POST_Auth_Login_Passkey(w http.ResponseWriter, r *http.Request) {
+
+	var Body struct {
+		CredentialID      string `json:"credential_id" validate:"required"`
+		ClientData        string `json:"client_data" validate:"required"`
+		AuthenticatorData string `json:"authenticator_data" validate:"required"`
+		Signature         string `json:"signature" validate:"required"`
+		UserHandle        string `json:"user_handle"`
+	}
+	if !tools.ValidateJSON(w, r, &Body) {
+		return
+	}
+	ctx, cancel := tools.NewContext()
+	defer cancel()
+
+	// Passkeys replace both the Password and any Second Factor
+	userID, ok := verifyPasskeyAssertion(ctx, w, r, tools.SESSION_NO_USER_ID, passkeyAssertion{
+		CredentialID:      Body.CredentialID,
+		ClientData:        Body.ClientData,
+		AuthenticatorData: Body.AuthenticatorData,
+		Signature:         Body.Signature,
+		UserHandle:        Body.UserHandle,
+	})
+	if !ok {
+		return
+	}
+
+	// Fetch Relevant Account
+	var user tools.DatabaseUser
+	err := tools.Database.QueryRow(ctx,
+		"SELECT id, email_address, lockout_until FROM auth.users WHERE id = $1",
+		userID,
+	).Scan(
+		&user.ID,
+		tools.Decrypted(&user.EmailAddress),
+		&user.LockoutUntil,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_USER)
+		return
+	}
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+	if accountLocked(w, r, user) {
+		return
+	}
+
+	// Find Relevant Device
+	device, err := lookupDevice(ctx, r, user.ID)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
+	// Create New Session
+	if err := startSession(ctx, w, r, user, device, false); errors.Is(err, pgx.ErrNoRows) {
+		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_USER)
+		return
+	} else if errors.Is(err, errAccountDisabled) {
+		tools.SendClientError(w, r, tools.ERROR_LOGIN_ACCOUNT_DISABLED)
+		return
+	} else if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Verify Passkey Assertion and Consume its Challenge, returns the Relevant User ID.
+// Use SESSION_NO_USER_ID to accept Passkeys belonging to any User.
+func verifyPasskeyAssertion(ctx context.Context, w http.ResponseWriter, r *http.Request, userID int64, a passkeyAssertion) (int64, bool) {
+
+	// Decode Assertion
+	clientData, err1 := tools.WebAuthnDecode(a.ClientData)
+	authData, err2 := tools.WebAuthnDecode(a.AuthenticatorData)
+	signature, err3 := tools.WebAuthnDecode(a.Signature)
+	if err1 != nil || err2 != nil || err3 != nil {
+		tools.SendClientError(w, r, tools.ERROR_PASSKEY_INVALID)
+		return 0, false
+	}
+	challenge, err := tools.WebAuthnParseClientData(clientData, tools.WEBAUTHN_CEREMONY_GET)
+	if err != nil {
+		tools.SendClientError(w, r, tools.ERROR_PASSKEY_INVALID)
+		return 0, false
+	}
+
+	// Consume Challenge
+	tag, err := tools.Database.Exec(ctx,
+		`DELETE FROM auth.passkey_challenges
+		WHERE challenge = $1
+		AND user_id IS NULL
+		AND expires > NOW()`,
+		challenge,
+	)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return 0, false
+	}
+	if tag.RowsAffected() == 0 {
+		tools.SendClientError(w, r, tools.ERROR_PASSKEY_CHALLENGE_INVALID)
+		return 0, false
+	}
+
+	// Fetch Relevant Passkey
+	var passkey tools.DatabasePasskey
+	err = tools.Database.QueryRow(ctx,
+		`SELECT
+			id, user_id, public_key, sign_count
+		FROM auth.passkeys
+		WHERE credential_id = $1
+		AND ($2::BIGINT = 0 OR user_id = $2)`,
+		a.CredentialID,
+		userID,
+	).Scan(
+		&passkey.ID,
+		&passkey.UserID,
+		&passkey.PublicKey,
+		&passkey.SignCount,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		tools.SendClientError(w, r, tools.ERROR_PASSKEY_INVALID)
+		return 0, false
+	}
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return 0, false
+	}
+	if a.UserHandle != "" {
+		handle, err := tools.WebAuthnDecode(a.UserHandle)
+		if err != nil || string(handle) != strconv.FormatInt(passkey.UserID, 10) {
+			tools.SendClientError(w, r, tools.ERROR_PASSKEY_INVALID)
+			return 0, false
+		}
+	}
+
+	// Verify Signature
+	data, err := tools.WebAuthnParseAuthenticatorData(authData)
+	if err != nil {
+		tools.SendClientError(w, r, tools.ERROR_PASSKEY_INVALID)
+		return 0, false
+	}
+	if err := tools.WebAuthnVerifySignature(passkey.PublicKey, authData, clientData, signature); err != nil {
+		tools.SendClientError(w, r, tools.ERROR_PASSKEY_INVALID)
+		return 0, false
+	}
+
+	// Authenticators without a Counter always report zero, otherwise it must
+	// increase or the Credential may have been cloned
+	if (data.SignCount != 0 || passkey.SignCount != 0) && int64(data.SignCount) <= passkey.SignCount {
+		tools.LoggerHttp.Warn("Passkey Counter Mismatch", map[string]any{
+			"passkey_id": passkey.ID,
+			"user_id":    passkey.UserID,
+			"stored":     passkey.SignCount,
+			"given":      data.SignCount,
+		})
+		tools.SendClientError(w, r, tools.ERROR_PASSKEY_CLONED)
+		return 0, false
+	}
+	if _, err := tools.Database.Exec(ctx,
+		"UPDATE auth.passkeys SET used = CURRENT_TIMESTAMP, sign_count = $1 WHERE id = $2",
+		int64(data.SignCount),
+		passkey.ID,
+	); err != nil {
+		tools.SendServerError(w, r, err)
+		return 0, false
+	}
+
+	return passkey.UserID, true
+}
@@ -22,22 +22,26 @@ func POST_Auth_ResetPassword(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	// Update Account matching Given Email
+	resetToken, err := tools.GenerateSignedString()
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
 	var (
 		resetTokenExpires = time.Now().Add(tools.LIFETIME_TOKEN_EMAIL_RESET)
-		resetToken        = tools.GenerateSignedString()
 		user              tools.DatabaseUser
 	)
-	err := tools.Database.QueryRow(ctx,
+	err = tools.Database.QueryRow(ctx,
 		`UPDATE auth.users SET
 			updated 		= CURRENT_TIMESTAMP,
 			token_reset_eat = $1,
 			token_reset 	= $2
-		WHERE email_address = LOWER($3)
+		WHERE email_index = $3
 		RETURNING id, email_address`,
 		resetTokenExpires,
-		resetToken,
-		Body.Email,
-	).Scan(&user.ID, &user.EmailAddress)
+		tools.HashToken(resetToken),
+		tools.EmailIndex(Body.Email),
+	).Scan(&user.ID, tools.Decrypted(&user.EmailAddress))
 	if errors.Is(err, pgx.ErrNoRows) {
 		w.WriteHeader(http.StatusNoContent)
 		return
@@ -48,7 +52,7 @@ func POST_Auth_ResetPassword(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Notify Account Owner
-	go func() {
+	tools.EmailAsync(func() {
 		subCtx, subCancel := tools.NewContext()
 		defer subCancel()
 
@@ -66,7 +70,7 @@ func POST_Auth_ResetPassword(w http.ResponseWriter, r *http.Request) {
 				Token:       resetToken,
 			},
 		)
-	}()
+	})
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,121 @@
+package routes
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+func POST_Auth_SAML_Connection_ACS(w http.ResponseWriter, r *http.Request) {
+
+	connection, ok := tools.SAML[r.PathValue("connection")]
+	if !ok {
+		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_PROVIDER)
+		return
+	}
+
+	// Parse Request Body
+	// Posted by the Browser on behalf of the Identity Provider (HTTP-POST Binding)
+	var Body struct {
+		SAMLResponse string `query:"SAMLResponse" validate:"required"`
+		RelayState   string `query:"RelayState"`
+	}
+	if !tools.ValidateQuery(w, r, &Body) {
+		return
+	}
+	ctx, cancel := tools.NewContext()
+	defer cancel()
+
+	// Verify Response
+	assertion, err := connection.ParseResponse(ctx, Body.SAMLResponse)
+	if err != nil {
+		tools.LoggerSAML.Warn("Response Rejected", map[string]any{
+			"connection": connection.Name,
+			"error":      err.Error(),
+		})
+		tools.SendClientError(w, r, tools.ERROR_SAML_RESPONSE_INVALID)
+		return
+	}
+
+	// Consume Outstanding Request, Unsolicited Responses must be allowed explicitly.
+	// Solicited Responses must arrive in the Browser which started the Request.
+	if assertion.InResponseTo != "" {
+		cookie, err := r.Cookie(tools.HTTP_SAML_COOKIE_NAME)
+		if err != nil || !tools.SAMLCompareRequestBinding(cookie.Value, assertion.InResponseTo) {
+			tools.SendClientError(w, r, tools.ERROR_SAML_UNSOLICITED)
+			return
+		}
+		clearSAMLCookie(w)
+		tag, err := tools.Database.Exec(ctx,
+			`DELETE FROM auth.saml_requests
+			WHERE id = $1 AND connection = $2 AND expires > NOW()`,
+			assertion.InResponseTo,
+			connection.Name,
+		)
+		if err != nil {
+			tools.SendServerError(w, r, err)
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			tools.SendClientError(w, r, tools.ERROR_SAML_UNSOLICITED)
+			return
+		}
+	} else if !connection.IDPInitiated {
+		tools.SendClientError(w, r, tools.ERROR_SAML_UNSOLICITED)
+		return
+	}
+
+	// Remember Assertion until it Expires so it can't be Replayed
+	tag, err := tools.Database.Exec(ctx,
+		`INSERT INTO auth.saml_assertions (
+			id, expires, connection
+		) VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING`,
+		assertion.ID,
+		assertion.Expires.Add(tools.SAML_CLOCK_SKEW),
+		connection.Name,
+	)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		tools.SendClientError(w, r, tools.ERROR_SAML_RESPONSE_INVALID)
+		return
+	}
+
+	// Find or Create Relevant Account
+	provider := "saml:" + connection.Name
+	userID, ok := resolveIdentity(ctx, w, r, provider, connection.Identity(assertion))
+	if !ok {
+		return
+	}
+
+	// Hand the Login over to the Frontend, which completes it like a Federated Login
+	state := tools.DatabaseFederationState{
+		State:    tools.GenerateRandomToken(),
+		Expires:  time.Now().Add(tools.LIFETIME_FEDERATION_STATE),
+		Provider: provider,
+		UserID:   &userID,
+	}
+	if _, err := tools.Database.Exec(ctx,
+		`INSERT INTO auth.federation_states (
+			state, expires, provider, nonce, verifier, user_id
+		) VALUES ($1, $2, $3, '', '', $4)`,
+		state.State,
+		state.Expires,
+		state.Provider,
+		state.UserID,
+	); err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+	setFederationCookie(w, state.State)
+
+	http.Redirect(w, r,
+		tools.SAML_REDIRECT_URL+"/"+connection.Name+"?state="+url.QueryEscape(state.State),
+		http.StatusSeeOther,
+	)
+}
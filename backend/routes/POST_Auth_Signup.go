@@ -2,6 +2,7 @@ package routes
 
 import (
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/bakonpancakz/template-auth/tools"
@@ -17,6 +18,10 @@ func POST_Auth_Signup(w http.ResponseWriter, r *http.Request) {
 	if !tools.ValidateJSON(w, r, &Body) {
 		return
 	}
+	if tools.PasswordBreached(Body.Password) {
+		tools.SendClientError(w, r, tools.ERROR_LOGIN_PASSWORD_BREACHED)
+		return
+	}
 	ctx, cancel := tools.NewContext()
 	defer cancel()
 
@@ -26,9 +31,9 @@ func POST_Auth_Signup(w http.ResponseWriter, r *http.Request) {
 	if err := tools.Database.QueryRow(ctx,
 		`SELECT
 			(SELECT COUNT(*) FROM auth.profiles WHERE username = LOWER($1)),
-			(SELECT COUNT(*) FROM auth.users WHERE email_address = LOWER($2))`,
+			(SELECT COUNT(*) FROM auth.users WHERE email_index = $2)`,
 		Body.Username,
-		Body.Email,
+		tools.EmailIndex(Body.Email),
 	).Scan(
 		&usageUsername,
 		&usageEmail,
@@ -47,12 +52,33 @@ func POST_Auth_Signup(w http.ResponseWriter, r *http.Request) {
 
 	// Generate Account Fields
 	userID := tools.GenerateSnowflake()
-	userVerifyEmail := tools.GenerateSignedString()
+	userVerifyEmail, err := tools.GenerateSignedString()
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
 	userPasswordHash, err := tools.GeneratePasswordHash(Body.Password)
 	if err != nil {
 		tools.SendServerError(w, r, err)
 		return
 	}
+	userEmail, err := tools.EncryptField(strings.ToLower(Body.Email))
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+	userDevice := deviceCookie(r)
+	if userDevice == "" {
+		if userDevice, err = tools.GenerateSignedString(); err != nil {
+			tools.SendServerError(w, r, err)
+			return
+		}
+	}
+	userDeviceAddress, userDeviceAgent, err := sealDevice(tools.GetRemoteIP(r), r.UserAgent())
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
 
 	// [TX] Begin Transaction
 	tx, err := tools.Database.Begin(ctx)
@@ -65,16 +91,32 @@ func POST_Auth_Signup(w http.ResponseWriter, r *http.Request) {
 	// [TX] Create New Account
 	if _, err := tx.Exec(ctx,
 		`INSERT INTO auth.users (
-			id, email_address, token_verify, token_verify_eat,
-			password_hash, password_history, ip_address
-		) VALUES ($1, LOWER($2), $3, $4, $5, $6, $7);`,
+			id, email_address, email_index, token_verify, token_verify_eat,
+			password_hash, password_history
+		) VALUES ($1, $2, $3, $4, $5, $6, $7);`,
 		userID,
-		Body.Email,
-		userVerifyEmail,
+		userEmail,
+		tools.EmailIndex(Body.Email),
+		tools.HashToken(userVerifyEmail),
 		time.Now().Add(tools.LIFETIME_TOKEN_EMAIL_VERIFY),
 		userPasswordHash,
 		[]string{userPasswordHash},
-		tools.GetRemoteIP(r),
+	); err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
+	// [TX] Remember Signup Device
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO auth.devices (
+			id, user_id, token, verified, ip_address, ip_index, user_agent
+		) VALUES ($1, $2, $3, TRUE, $4, $5, $6);`,
+		tools.GenerateSnowflake(),
+		userID,
+		tools.HashToken(userDevice),
+		userDeviceAddress,
+		tools.AddressIndex(tools.GetRemoteIP(r)),
+		userDeviceAgent,
 	); err != nil {
 		tools.SendServerError(w, r, err)
 		return
@@ -99,7 +141,7 @@ func POST_Auth_Signup(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Send Verification Email
-	go func() {
+	tools.EmailAsync(func() {
 		tools.TemplateEmailVerify(
 			Body.Email,
 			tools.LocalsEmailVerify{
@@ -107,7 +149,8 @@ func POST_Auth_Signup(w http.ResponseWriter, r *http.Request) {
 				Token:       userVerifyEmail,
 			},
 		)
-	}()
+	})
 
+	setDeviceCookie(w, userDevice)
 	w.WriteHeader(http.StatusNoContent)
 }
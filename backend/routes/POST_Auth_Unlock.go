@@ -0,0 +1,245 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bakonpancakz/template-auth/tools"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Factors counted separately, so Guesses of one don't lock out another sooner
+const (
+	factorPassword = "password"
+	factorPasscode = "passcode"
+	factorRecovery = "recovery"
+	factorEmail    = "email"
+)
+
+func POST_Auth_Unlock(w http.ResponseWriter, r *http.Request) {
+
+	var Body struct {
+		Token string `query:"token" validate:"required,token"`
+	}
+	if !tools.ValidateQuery(w, r, &Body) {
+		return
+	}
+	ctx, cancel := tools.NewContext()
+	defer cancel()
+
+	// [TX] Begin Transaction
+	tx, err := tools.Database.Begin(ctx)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	// [TX] Consume Token and Lift Lockout
+	// The Backoff is kept until the next Login, so a persistent Attacker still
+	// has to wait longer each time they lock the Account again
+	var userID int64
+	err = tx.QueryRow(ctx,
+		`UPDATE auth.users SET
+			updated 		 = CURRENT_TIMESTAMP,
+			lockout_until 	 = NULL,
+			token_unlock 	 = NULL,
+			token_unlock_eat = NULL
+		WHERE token_unlock = ANY($1) AND token_unlock_eat > NOW()
+		RETURNING id`,
+		tools.TokenCandidates(Body.Token),
+	).Scan(&userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_TOKEN)
+		return
+	}
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
+	// [TX] Forget Failed Guesses
+	if _, err := tx.Exec(ctx,
+		"DELETE FROM auth.login_failures WHERE user_id = $1",
+		userID,
+	); err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
+	// [TX] Complete Transaction
+	if err := tx.Commit(ctx); err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Refuse Guesses while the Account is Locked, responding with the time remaining
+func accountLocked(w http.ResponseWriter, r *http.Request, user tools.DatabaseUser) bool {
+	if user.LockoutUntil == nil {
+		return false
+	}
+	remaining := time.Until(*user.LockoutUntil)
+	if remaining <= 0 {
+		return false
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(remaining.Seconds())+1))
+	tools.SendClientError(w, r, tools.ERROR_LOGIN_ACCOUNT_LOCKED)
+	return true
+}
+
+// Count an Incorrect Guess against the Account and respond with the given Error,
+// too many Guesses of the same Factor lock the Account and alert its Owner
+func failAttempt(ctx context.Context, w http.ResponseWriter, r *http.Request, userID int64, factor string, reason tools.APIError) {
+	if err := recordFailure(ctx, r, userID, factor); err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+	tools.SendClientError(w, r, reason)
+}
+
+// Count an Incorrect Guess against the Account, locking it for twice as long
+// as the previous Lockout once the Limit for the Factor is reached
+func recordFailure(ctx context.Context, r *http.Request, userID int64, factor string) error {
+
+	limit := tools.LOCKOUT_PASSWORD_LIMIT
+	switch factor {
+	case factorPasscode, factorEmail:
+		limit = tools.LOCKOUT_PASSCODE_LIMIT
+	case factorRecovery:
+		limit = tools.LOCKOUT_RECOVERY_LIMIT
+	}
+
+	// Count Guess, older Guesses outside the Window are forgotten
+	var failures int
+	if err := tools.Database.QueryRow(ctx,
+		`INSERT INTO auth.login_failures (user_id, factor, failures)
+		VALUES ($1, $2, 1)
+		ON CONFLICT (user_id, factor) DO UPDATE SET
+			failures = CASE
+				WHEN auth.login_failures.updated > CURRENT_TIMESTAMP - make_interval(secs => $3)
+				THEN auth.login_failures.failures + 1
+				ELSE 1
+			END,
+			updated = CURRENT_TIMESTAMP
+		RETURNING failures`,
+		userID,
+		factor,
+		tools.LOCKOUT_WINDOW.Seconds(),
+	).Scan(&failures); err != nil {
+		return err
+	}
+	if failures < limit {
+		return nil
+	}
+
+	// [TX] Begin Transaction
+	tx, err := tools.Database.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// [TX] Lock Account
+	// Concurrent Guesses may all reach the Limit, only the first one locks it
+	var user tools.DatabaseUser
+	unlockToken, err := tools.GenerateSignedString()
+	if err != nil {
+		return err
+	}
+	err = tx.QueryRow(ctx,
+		`UPDATE auth.users SET
+			lockout_count 	 = lockout_count + 1,
+			lockout_until 	 = CURRENT_TIMESTAMP + LEAST(
+				make_interval(secs => $2::FLOAT8 * POWER(2, LEAST(lockout_count, 16))),
+				make_interval(secs => $3::FLOAT8)
+			),
+			token_unlock 	 = $4,
+			token_unlock_eat = $5
+		WHERE id = $1 AND (lockout_until IS NULL OR lockout_until <= CURRENT_TIMESTAMP)
+		RETURNING email_address, email_verified, lockout_until`,
+		userID,
+		tools.LOCKOUT_DURATION.Seconds(),
+		tools.LOCKOUT_DURATION_LIMIT.Seconds(),
+		tools.HashToken(unlockToken),
+		time.Now().Add(tools.LIFETIME_TOKEN_EMAIL_UNLOCK),
+	).Scan(
+		tools.Decrypted(&user.EmailAddress),
+		&user.EmailVerified,
+		&user.LockoutUntil,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// [TX] Forget Failed Guesses, the Account gets a fresh Limit once unlocked
+	if _, err := tx.Exec(ctx,
+		"DELETE FROM auth.login_failures WHERE user_id = $1",
+		userID,
+	); err != nil {
+		return err
+	}
+
+	// [TX] Complete Transaction
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	// Alert Account Owner
+	// Unverified Addresses may not belong to the Owner, so they aren't sent a Token
+	if !user.EmailVerified {
+		return nil
+	}
+	sessionAgent := r.UserAgent()
+	sessionAddress := tools.GetRemoteIP(r)
+	tools.EmailAsync(func() {
+		subCtx, subCancel := tools.NewContext()
+		defer subCancel()
+
+		// Fetch Displayname
+		displayname := tools.EMAIL_DEFAULT_DISPLAYNAME
+		tools.Database.
+			QueryRow(subCtx, "SELECT displayname FROM auth.profiles WHERE id = $1", userID).
+			Scan(&displayname)
+
+		// Send Email
+		tools.TemplateLoginAccountLocked(
+			user.EmailAddress,
+			tools.LocalsLoginAccountLocked{
+				Displayname:    displayname,
+				Token:          unlockToken,
+				LockedUntil:    tools.LookupTimezone(*user.LockoutUntil, sessionAddress),
+				IpAddress:      sessionAddress,
+				Timestamp:      tools.LookupTimezone(time.Now(), sessionAddress),
+				DeviceBrowser:  tools.LookupBrowser(sessionAgent),
+				DeviceLocation: tools.LookupLocation(sessionAddress),
+			},
+		)
+	})
+
+	return nil
+}
+
+// Forget Failed Guesses and reset the Backoff after the Owner proved themselves
+func clearFailures(ctx context.Context, userID int64) error {
+	if _, err := tools.Database.Exec(ctx,
+		"DELETE FROM auth.login_failures WHERE user_id = $1",
+		userID,
+	); err != nil {
+		return err
+	}
+	_, err := tools.Database.Exec(ctx,
+		"UPDATE auth.users SET lockout_count = 0 WHERE id = $1 AND lockout_count > 0",
+		userID,
+	)
+	return err
+}
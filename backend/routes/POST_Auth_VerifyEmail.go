@@ -24,8 +24,8 @@ func POST_Auth_VerifyEmail(w http.ResponseWriter, r *http.Request) {
 			email_verified   = TRUE,
 			token_verify 	 = NULL,
 			token_verify_eat = NULL
-		WHERE token_verify = $1 AND token_verify_eat > NOW()`,
-		Body.Token,
+		WHERE token_verify = ANY($1) AND token_verify_eat > NOW()`,
+		tools.TokenCandidates(Body.Token),
 	)
 	if err != nil {
 		tools.SendServerError(w, r, err)
@@ -1,9 +1,13 @@
 package routes
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/bakonpancakz/template-auth/tools"
+
+	"github.com/jackc/pgx/v5"
 )
 
 func POST_Auth_VerifyLogin(w http.ResponseWriter, r *http.Request) {
@@ -17,23 +21,78 @@ func POST_Auth_VerifyLogin(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := tools.NewContext()
 	defer cancel()
 
-	// Update Account matching Given Token
-	tag, err := tools.Database.Exec(ctx,
+	// [TX] Begin Transaction
+	tx, err := tools.Database.Begin(ctx)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	// [TX] Fetch Account matching Given Token
+	var user tools.DatabaseUser
+	err = tx.QueryRow(ctx,
+		`SELECT id, token_login_data FROM auth.users
+		WHERE token_login = ANY($1) AND token_login_eat > NOW()
+		FOR UPDATE`,
+		tools.TokenCandidates(Body.Token),
+	).Scan(
+		&user.ID,
+		&user.TokenLoginData,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_TOKEN)
+		return
+	}
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
+	// [TX] Consume Token
+	if _, err := tx.Exec(ctx,
 		`UPDATE auth.users SET
 			updated 		 = CURRENT_TIMESTAMP,
-			ip_address 		 = token_login_data,
 			token_login 	 = NULL,
 			token_login_data = NULL,
 			token_login_eat  = NULL
-		WHERE token_login = $1 AND token_login_eat > NOW()`,
-		Body.Token,
-	)
-	if err != nil {
+		WHERE id = $1`,
+		user.ID,
+	); err != nil {
 		tools.SendServerError(w, r, err)
 		return
 	}
-	if tag.RowsAffected() == 0 {
-		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_TOKEN)
+
+	// [TX] Allow Logins from Device
+	// Tokens issued before Devices existed contain an IP Address instead
+	if user.TokenLoginData != nil {
+		if deviceID, err := strconv.ParseInt(*user.TokenLoginData, 10, 64); err == nil {
+			if _, err := tx.Exec(ctx,
+				"UPDATE auth.devices SET verified = TRUE WHERE id = $1 AND user_id = $2",
+				deviceID,
+				user.ID,
+			); err != nil {
+				tools.SendServerError(w, r, err)
+				return
+			}
+
+			// Location is no longer new to the Risk Assessment
+			if _, err := tx.Exec(ctx,
+				`UPDATE auth.login_assessments SET approved = TRUE
+				WHERE user_id = $1 AND device_id = $2 AND decision = $3`,
+				user.ID,
+				deviceID,
+				tools.RISK_APPROVE.String(),
+			); err != nil {
+				tools.SendServerError(w, r, err)
+				return
+			}
+		}
+	}
+
+	// [TX] Complete Transaction
+	if err := tx.Commit(ctx); err != nil {
+		tools.SendServerError(w, r, err)
 		return
 	}
 
@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -15,16 +16,15 @@ import (
 func POST_OAuth2_Authorize(w http.ResponseWriter, r *http.Request) {
 
 	session := tools.GetSession(r)
-	if session.ApplicationID != tools.SESSION_NO_APPLICATION_ID {
-		tools.SendClientError(w, r, tools.ERROR_OAUTH2_USERS_ONLY)
-		return
-	}
 	var Body struct {
 		State        *string `query:"state"`
 		ClientID     int64   `query:"client_id" validate:"required"`
 		ResponseType string  `query:"response_type" validate:"required"`
 		RedirectURI  string  `query:"redirect_uri" validate:"required,uri"`
 		ScopesString string  `query:"scope" validate:"required"`
+		Challenge    *string `query:"code_challenge"`
+		Method       string  `query:"code_challenge_method"`
+		Nonce        *string `query:"nonce"`
 	}
 	if !tools.ValidateQuery(w, r, &Body) {
 		return
@@ -34,23 +34,35 @@ func POST_OAuth2_Authorize(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse Scopes
+	// Parse Scopes, Users cannot grant those meant for Applications
 	ok, requestedScopes := tools.OAuth2StringToScopes(Body.ScopesString)
-	if !ok {
+	if !ok || tools.OAuth2ScopesMachine(requestedScopes) != 0 {
 		tools.SendClientError(w, r, tools.ERROR_OAUTH2_FORM_INVALID_SCOPE)
 		return
 	}
+
+	// Parse Code Challenge
+	var requestedMethod *string
+	if Body.Challenge != nil {
+		ok, method := tools.OAuth2ValidateCodeChallenge(*Body.Challenge, Body.Method)
+		if !ok {
+			tools.SendClientError(w, r, tools.ERROR_OAUTH2_FORM_INVALID_CHALLENGE)
+			return
+		}
+		requestedMethod = &method
+	}
 	ctx, cancel := tools.NewContext()
 	defer cancel()
 
 	// Fetch State for Requested Application
 	var application tools.DatabaseApplication
 	err := tools.Database.QueryRow(ctx,
-		"SELECT id, auth_redirects FROM auth.applications WHERE id = $1",
+		"SELECT id, auth_redirects, auth_public FROM auth.applications WHERE id = $1",
 		Body.ClientID,
 	).Scan(
 		&application.ID,
 		&application.AuthRedirects,
+		&application.AuthPublic,
 	)
 	if errors.Is(err, pgx.ErrNoRows) {
 		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_APPLICATION)
@@ -68,29 +80,79 @@ func POST_OAuth2_Authorize(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Generate Temporary Grant Session
-	grantCode := tools.GenerateSignedString()
+	// Public Clients cannot keep a secret and must use PKCE instead
+	if application.AuthPublic && Body.Challenge == nil {
+		tools.SendClientError(w, r, tools.ERROR_OAUTH2_FORM_INVALID_CHALLENGE)
+		return
+	}
+
+	// Remember Approval for future Authorizations
+	if err := recordConsent(ctx, session.UserID, application.ID, requestedScopes); err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
+	// Redirect User to Requested URI with Grant
+	location, err := issueGrant(ctx, tools.DatabaseGrant{
+		UserID:              session.UserID,
+		ApplicationID:       application.ID,
+		RedirectURI:         requestedRedirect,
+		Scopes:              requestedScopes,
+		CodeChallenge:       Body.Challenge,
+		CodeChallengeMethod: requestedMethod,
+		Nonce:               Body.Nonce,
+	}, Body.State)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, location, http.StatusFound)
+}
+
+// Record Scopes approved by the User, adding to those previously approved
+func recordConsent(ctx context.Context, userID, applicationID int64, scopes int) error {
+	_, err := tools.Database.Exec(ctx,
+		`INSERT INTO auth.consents (
+			user_id, application_id, scopes
+		) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, application_id) DO UPDATE SET
+			updated = CURRENT_TIMESTAMP,
+			scopes  = auth.consents.scopes | EXCLUDED.scopes`,
+		userID,
+		applicationID,
+		scopes,
+	)
+	return err
+}
+
+// Generate Temporary Grant Session, returning the Redirect Location
+func issueGrant(ctx context.Context, grant tools.DatabaseGrant, state *string) (string, error) {
+	grantCode, err := tools.GenerateSignedString()
+	if err != nil {
+		return "", err
+	}
 	if _, err := tools.Database.Exec(ctx,
 		`INSERT INTO auth.grants (
-			id, expires, user_id, application_id, redirect_uri, scopes, code
-		) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			id, expires, user_id, application_id, redirect_uri, scopes, code,
+			code_challenge, code_challenge_method, nonce
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
 		tools.GenerateSnowflake(),
 		time.Now().Add(tools.LIFETIME_OAUTH2_GRANT_TOKEN),
-		session.UserID,
-		application.ID,
-		requestedRedirect,
-		requestedScopes,
-		grantCode,
+		grant.UserID,
+		grant.ApplicationID,
+		grant.RedirectURI,
+		grant.Scopes,
+		tools.HashToken(grantCode),
+		grant.CodeChallenge,
+		grant.CodeChallengeMethod,
+		grant.Nonce,
 	); err != nil {
-		tools.SendServerError(w, r, err)
-		return
+		return "", err
 	}
-
-	// Redirect User to Requested URI with Grant
 	q := url.Values{}
 	q.Add("code", grantCode)
-	if Body.State != nil {
-		q.Add("state", *Body.State)
+	if state != nil {
+		q.Add("state", *state)
 	}
-	http.Redirect(w, r, fmt.Sprint(requestedRedirect, "?", q.Encode()), http.StatusFound)
+	return fmt.Sprint(grant.RedirectURI, "?", q.Encode()), nil
 }
@@ -0,0 +1,61 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bakonpancakz/template-auth/tools"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func POST_OAuth2_Device(w http.ResponseWriter, r *http.Request) {
+
+	session := tools.GetSession(r)
+	var Body struct {
+		UserCode string `query:"user_code" validate:"required"`
+	}
+	if !tools.ValidateQuery(w, r, &Body) {
+		return
+	}
+	ok, userCode := tools.OAuth2NormalizeUserCode(Body.UserCode)
+	if !ok {
+		tools.SendClientError(w, r, tools.ERROR_OAUTH2_FORM_INVALID_USER_CODE)
+		return
+	}
+	ctx, cancel := tools.NewContext()
+	defer cancel()
+
+	// Approve Pending Grant, the Device will collect it on its next poll
+	var grant tools.DatabaseGrant
+	err := tools.Database.QueryRow(ctx,
+		`UPDATE auth.grants SET
+			user_id = $1
+		WHERE user_code = $2
+		AND user_id IS NULL
+		AND denied = FALSE
+		AND expires > NOW()
+		RETURNING application_id, scopes`,
+		session.UserID,
+		userCode,
+	).Scan(
+		&grant.ApplicationID,
+		&grant.Scopes,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		tools.SendClientError(w, r, tools.ERROR_OAUTH2_FORM_INVALID_USER_CODE)
+		return
+	}
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
+	// Remember Approval for future Authorizations
+	if err := recordConsent(ctx, session.UserID, grant.ApplicationID, grant.Scopes); err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
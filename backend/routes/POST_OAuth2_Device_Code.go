@@ -0,0 +1,113 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/bakonpancakz/template-auth/tools"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func POST_OAuth2_Device_Code(w http.ResponseWriter, r *http.Request) {
+
+	var Body struct {
+		ClientID     string `query:"client_id"`
+		ScopesString string `query:"scope" validate:"required"`
+	}
+	if !tools.ValidateQuery(w, r, &Body) {
+		return
+	}
+
+	// Public Clients identify themselves in the body without a secret
+	var clientID int64
+	var clientSecret string
+	user, pass, hasSecret := r.BasicAuth()
+	if !hasSecret {
+		user = Body.ClientID
+	}
+	if user == "" {
+		tools.SendClientError(w, r, tools.ERROR_GENERIC_UNAUTHORIZED)
+		return
+	} else if id, err := strconv.ParseInt(user, 10, 64); err != nil {
+		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_APPLICATION)
+		return
+	} else {
+		clientID = id
+		clientSecret = pass
+	}
+
+	// Parse Scopes, Users cannot grant those meant for Applications
+	ok, requestedScopes := tools.OAuth2StringToScopes(Body.ScopesString)
+	if !ok || tools.OAuth2ScopesMachine(requestedScopes) != 0 {
+		tools.SendClientError(w, r, tools.ERROR_OAUTH2_FORM_INVALID_SCOPE)
+		return
+	}
+	ctx, cancel := tools.NewContext()
+	defer cancel()
+
+	// Validate Application Secret
+	var application tools.DatabaseApplication
+	err := tools.Database.QueryRow(ctx,
+		"SELECT id, auth_secret, auth_public FROM auth.applications WHERE id = $1",
+		clientID,
+	).Scan(
+		&application.ID,
+		&application.AuthSecret,
+		&application.AuthPublic,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_APPLICATION)
+		return
+	}
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+	if hasSecret {
+		if !tools.CompareApplicationSecret(clientSecret, application.AuthSecret) {
+			tools.SendClientError(w, r, tools.ERROR_GENERIC_UNAUTHORIZED)
+			return
+		}
+	} else if !application.AuthPublic {
+		tools.SendClientError(w, r, tools.ERROR_GENERIC_UNAUTHORIZED)
+		return
+	}
+
+	// Generate Pending Device Grant
+	deviceCode, err := tools.GenerateSignedString()
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+	userCode := tools.GenerateUserCode()
+	if _, err := tools.Database.Exec(ctx,
+		`INSERT INTO auth.grants (
+			id, expires, application_id, scopes, code, user_code, poll_interval
+		) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		tools.GenerateSnowflake(),
+		time.Now().Add(tools.LIFETIME_OAUTH2_DEVICE_CODE),
+		application.ID,
+		requestedScopes,
+		tools.HashToken(deviceCode),
+		userCode,
+		int(tools.OAUTH2_DEVICE_INTERVAL.Seconds()),
+	); err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
+	// Organize Device Grant
+	verificationURI := tools.EMAIL_DEFAULT_HOST + "/oauth2/device"
+	tools.SendJSON(w, r, http.StatusOK, map[string]any{
+		"device_code":               deviceCode,
+		"user_code":                 userCode,
+		"verification_uri":          verificationURI,
+		"verification_uri_complete": verificationURI + "?" + url.Values{"user_code": {userCode}}.Encode(),
+		"expires_in":                tools.LIFETIME_OAUTH2_DEVICE_CODE.Seconds(),
+		"interval":                  tools.OAUTH2_DEVICE_INTERVAL.Seconds(),
+	})
+}
@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"strconv"
@@ -12,30 +13,23 @@ import (
 )
 
 const (
-	GRANT_CODE    = "authorization_code"
-	GRANT_REFRESH = "refresh_token"
+	GRANT_CODE               = "authorization_code"
+	GRANT_REFRESH            = "refresh_token"
+	GRANT_CLIENT_CREDENTIALS = "client_credentials"
+	GRANT_DEVICE_CODE        = "urn:ietf:params:oauth:grant-type:device_code"
 )
 
 func POST_OAuth2_Token(w http.ResponseWriter, r *http.Request) {
 
-	var clientID int64
-	var clientSecret string
-	if user, pass, ok := r.BasicAuth(); !ok {
-		tools.SendClientError(w, r, tools.ERROR_GENERIC_UNAUTHORIZED)
-		return
-	} else if id, err := strconv.ParseInt(user, 10, 64); err != nil {
-		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_APPLICATION)
-		return
-	} else {
-		clientID = id
-		clientSecret = pass
-	}
-
 	var Body struct {
 		GrantType    string `query:"grant_type" validate:"required"`
+		ClientID     string `query:"client_id"`
 		RedirectURI  string `query:"redirect_uri"`
 		Code         string `query:"code"`
+		CodeVerifier string `query:"code_verifier"`
 		RefreshToken string `query:"refresh_token"`
+		ScopesString string `query:"scope"`
+		DeviceCode   string `query:"device_code"`
 	}
 	if !tools.ValidateQuery(w, r, &Body) {
 		return
@@ -43,7 +37,26 @@ func POST_OAuth2_Token(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := tools.NewContext()
 	defer cancel()
 
+	// Public Clients identify themselves in the body without a secret
+	var clientID int64
+	var clientSecret string
+	user, pass, hasSecret := r.BasicAuth()
+	if !hasSecret {
+		user = Body.ClientID
+	}
+	if user == "" {
+		tools.SendClientError(w, r, tools.ERROR_GENERIC_UNAUTHORIZED)
+		return
+	} else if id, err := strconv.ParseInt(user, 10, 64); err != nil {
+		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_APPLICATION)
+		return
+	} else {
+		clientID = id
+		clientSecret = pass
+	}
+
 	// Validate Parameters
+	var requestedScopes int
 	switch Body.GrantType {
 	case GRANT_CODE:
 		if Body.RedirectURI == "" {
@@ -59,19 +72,36 @@ func POST_OAuth2_Token(w http.ResponseWriter, r *http.Request) {
 			tools.SendClientError(w, r, tools.ERROR_OAUTH2_FORM_INVALID_REFRESH_TOKEN)
 			return
 		}
+	case GRANT_CLIENT_CREDENTIALS:
+		ok, scopes := tools.OAuth2StringToScopes(Body.ScopesString)
+		if !ok || tools.OAuth2ScopesMachine(scopes) != scopes {
+			tools.SendClientError(w, r, tools.ERROR_OAUTH2_FORM_INVALID_SCOPE)
+			return
+		}
+		requestedScopes = scopes
+	case GRANT_DEVICE_CODE:
+		if !tools.CompareSignedString(Body.DeviceCode) {
+			tools.SendClientError(w, r, tools.ERROR_OAUTH2_FORM_INVALID_DEVICE_CODE)
+			return
+		}
+	default:
+		tools.SendClientError(w, r, tools.ERROR_OAUTH2_FORM_INVALID_GRANT_TYPE)
+		return
 	}
 
 	// Validate Application Secret
 	var application tools.DatabaseApplication
 	err := tools.Database.QueryRow(ctx,
 		`SELECT
-			id, auth_secret
+			id, auth_secret, auth_public, auth_scopes
 		FROM auth.applications
 		WHERE id = $1`,
 		clientID,
 	).Scan(
 		&application.ID,
 		&application.AuthSecret,
+		&application.AuthPublic,
+		&application.AuthScopes,
 	)
 	if errors.Is(err, pgx.ErrNoRows) {
 		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_APPLICATION)
@@ -81,7 +111,13 @@ func POST_OAuth2_Token(w http.ResponseWriter, r *http.Request) {
 		tools.SendServerError(w, r, err)
 		return
 	}
-	if !tools.CompareApplicationSecret(clientSecret, application.AuthSecret) {
+	if hasSecret {
+		if !tools.CompareApplicationSecret(clientSecret, application.AuthSecret) {
+			tools.SendClientError(w, r, tools.ERROR_GENERIC_UNAUTHORIZED)
+			return
+		}
+	} else if !application.AuthPublic || Body.GrantType == GRANT_CLIENT_CREDENTIALS {
+		// Applications may only act as themselves using their secret
 		tools.SendClientError(w, r, tools.ERROR_GENERIC_UNAUTHORIZED)
 		return
 	}
@@ -94,14 +130,18 @@ func POST_OAuth2_Token(w http.ResponseWriter, r *http.Request) {
 		var grant tools.DatabaseGrant
 		err := tools.Database.QueryRow(ctx,
 			`DELETE FROM auth.grants
-			WHERE code = $1 AND expires > NOW()
-			RETURNING user_id, application_id, redirect_uri, scopes`,
-			Body.Code,
+			WHERE code = ANY($1) AND expires > NOW() AND user_code IS NULL
+			RETURNING user_id, application_id, redirect_uri, scopes,
+				code_challenge, code_challenge_method, nonce`,
+			tools.TokenCandidates(Body.Code),
 		).Scan(
 			&grant.UserID,
 			&grant.ApplicationID,
 			&grant.RedirectURI,
 			&grant.Scopes,
+			&grant.CodeChallenge,
+			&grant.CodeChallengeMethod,
+			&grant.Nonce,
 		)
 		if errors.Is(err, pgx.ErrNoRows) {
 			tools.SendClientError(w, r, tools.ERROR_UNKNOWN_APPLICATION)
@@ -117,101 +157,50 @@ func POST_OAuth2_Token(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		// Fetch Relevant Connection
-		var tokenAccess, tokenRefresh string
-		var connection tools.DatabaseConnection
-		err = tools.Database.QueryRow(ctx,
-			`SELECT
-				token_expires
-			FROM auth.connections
-			WHERE application_id = $1
-			AND user_id = $2`,
-			grant.ApplicationID,
-			grant.UserID,
-		).Scan(&connection.TokenExpires)
-
-		switch {
-		// Create New Connection
-		case errors.Is(err, pgx.ErrNoRows):
-			tokenAccess = tools.GenerateSignedString()
-			tokenRefresh = tools.GenerateSignedString()
-			_, err := tools.Database.Exec(ctx,
-				`INSERT INTO auth.connections (
-					id, user_id, application_id, scopes, token_access,
-					token_expires, token_refresh
-				) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-				tools.GenerateSnowflake(),
-				grant.UserID,
-				grant.ApplicationID,
-				grant.Scopes,
-				tokenAccess,
-				time.Now().Add(tools.LIFETIME_OAUTH2_ACCESS_TOKEN),
-				tokenRefresh,
-			)
-			if err != nil {
-				tools.SendServerError(w, r, err)
-				return
-			}
-
-		// Reset Existing Connection
-		case err == nil:
-			tag, err := tools.Database.Exec(ctx,
-				`UPDATE auth.connections SET
-					updated			= CURRENT_TIMESTAMP,
-					revoked 		= FALSE,
-					scopes  		= $1,
-					token_access 	= $2,
-					token_refresh   = $3,
-					token_expires	= $4
-				WHERE user_id = $5
-				AND application_id = $6`,
-				grant.Scopes,
-				tokenAccess,
-				tokenRefresh,
-				time.Now().Add(tools.LIFETIME_OAUTH2_ACCESS_TOKEN),
-				grant.UserID,
-				grant.ApplicationID,
-			)
-			if err != nil {
-				tools.SendServerError(w, r, err)
-				return
-			}
-			if tag.RowsAffected() == 0 {
-				tools.SendClientError(w, r, tools.ERROR_UNKNOWN_CONNECTION)
+		// Validate Code Verifier
+		if grant.CodeChallenge == nil {
+			if Body.CodeVerifier != "" || application.AuthPublic {
+				tools.SendClientError(w, r, tools.ERROR_OAUTH2_FORM_INVALID_VERIFIER)
 				return
 			}
+		} else if grant.CodeChallengeMethod == nil || !tools.OAuth2CompareCodeVerifier(
+			Body.CodeVerifier,
+			*grant.CodeChallenge,
+			*grant.CodeChallengeMethod,
+		) {
+			tools.SendClientError(w, r, tools.ERROR_OAUTH2_FORM_INVALID_VERIFIER)
+			return
+		}
 
-		// Unknown Error
-		default:
+		// Issue Tokens for Approved Grant
+		response, err := grantConnection(ctx, grant)
+		if err != nil {
 			tools.SendServerError(w, r, err)
 			return
 		}
-
-		// Organize Grant
-		tools.SendJSON(w, r, http.StatusOK, map[string]any{
-			"token_type":    tools.TOKEN_PREFIX_BEARER,
-			"access_token":  tokenAccess,
-			"refresh_token": tokenRefresh,
-			"expires_in":    tools.LIFETIME_OAUTH2_ACCESS_TOKEN.Seconds(),
-			"scopes":        tools.OAuth2ScopesToString(grant.Scopes),
-		})
+		tools.SendJSON(w, r, http.StatusOK, response)
 		return
 
 	case GRANT_REFRESH:
-		// Search for Relevant Connection
+		// Search for Relevant Connection by any Token in its Family
 		var connection tools.DatabaseConnection
+		var refresh tools.DatabaseRefreshToken
 		err = tools.Database.QueryRow(ctx,
 			`SELECT
-				id, revoked, scopes
-			FROM auth.connections
-			WHERE token_refresh = $1
-			AND application_id 	= $2`,
-			Body.RefreshToken,
+				c.id, c.user_id, c.revoked, c.scopes, t.token, t.rotated
+			FROM auth.refresh_tokens t
+			JOIN auth.connections c ON c.id = t.connection_id
+			WHERE t.token = ANY($1)
+			AND c.application_id = $2`,
+			tools.TokenCandidates(Body.RefreshToken),
 			application.ID,
 		).Scan(
 			&connection.ID,
-			&connection.Scopes,
+			&connection.UserID,
 			&connection.Revoked,
+			&connection.Scopes,
+			&refresh.Token,
+			&refresh.Rotated,
 		)
 		if errors.Is(err, pgx.ErrNoRows) {
 			tools.SendClientError(w, r, tools.ERROR_GENERIC_UNAUTHORIZED)
@@ -225,19 +214,74 @@ func POST_OAuth2_Token(w http.ResponseWriter, r *http.Request) {
 			tools.SendClientError(w, r, tools.ERROR_ACCESS_REVOKED)
 			return
 		}
+		if refresh.Rotated != nil {
+			// Token was already exchanged, assume the Family was stolen
+			if err := revokeRefreshFamily(ctx, connection.ID); err != nil {
+				tools.SendServerError(w, r, err)
+				return
+			}
+			tools.SendClientError(w, r, tools.ERROR_OAUTH2_REFRESH_TOKEN_REUSED)
+			return
+		}
+
+		// [TX] Begin Transaction
+		tokenAccess, tokenRefresh, err := generateTokenPair()
+		if err != nil {
+			tools.SendServerError(w, r, err)
+			return
+		}
+		tx, err := tools.Database.Begin(ctx)
+		if err != nil {
+			tools.SendServerError(w, r, err)
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		// [TX] Rotate Presented Token, losing a race here is also a reuse
+		tag, err := tx.Exec(ctx,
+			`UPDATE auth.refresh_tokens SET
+				rotated = CURRENT_TIMESTAMP
+			WHERE token = $1
+			AND rotated IS NULL`,
+			refresh.Token,
+		)
+		if err != nil {
+			tools.SendServerError(w, r, err)
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			tx.Rollback(ctx)
+			if err := revokeRefreshFamily(ctx, connection.ID); err != nil {
+				tools.SendServerError(w, r, err)
+				return
+			}
+			tools.SendClientError(w, r, tools.ERROR_OAUTH2_REFRESH_TOKEN_REUSED)
+			return
+		}
+
+		// [TX] Record Child Token
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO auth.refresh_tokens (
+				token, connection_id, parent
+			) VALUES ($1, $2, $3)`,
+			tools.HashToken(tokenRefresh),
+			connection.ID,
+			refresh.Token,
+		); err != nil {
+			tools.SendServerError(w, r, err)
+			return
+		}
 
-		// Update Connection Tokens
-		var tokenAccess = tools.GenerateSignedString()
-		var tokenRefresh = tools.GenerateSignedString()
-		tag, err := tools.Database.Exec(ctx,
+		// [TX] Update Connection Tokens
+		tag, err = tx.Exec(ctx,
 			`UPDATE auth.connections SET
 				updated 	  = CURRENT_TIMESTAMP,
 				token_access  = $1,
 				token_refresh = $2,
 				token_expires = $3
 			WHERE id = $4`,
-			tokenAccess,
-			tokenRefresh,
+			tools.HashToken(tokenAccess),
+			tools.HashToken(tokenRefresh),
 			time.Now().Add(tools.LIFETIME_OAUTH2_ACCESS_TOKEN),
 			connection.ID,
 		)
@@ -250,18 +294,358 @@ func POST_OAuth2_Token(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		// [TX] Complete Transaction
+		if err := tx.Commit(ctx); err != nil {
+			tools.SendServerError(w, r, err)
+			return
+		}
+
 		// Organize Connection
-		tools.SendJSON(w, r, http.StatusOK, map[string]any{
+		response := map[string]any{
 			"token_type":    tools.TOKEN_PREFIX_BEARER,
 			"access_token":  tokenAccess,
 			"refresh_token": tokenRefresh,
 			"expires_in":    tools.LIFETIME_OAUTH2_ACCESS_TOKEN.Seconds(),
 			"scopes":        tools.OAuth2ScopesToString(connection.Scopes),
+		}
+		if (connection.Scopes & tools.SCOPE_OPENID.Flag) != 0 {
+			idToken, err := tools.OpenIDGenerateToken(ctx, connection.UserID, application.ID, connection.Scopes, nil)
+			if err != nil {
+				tools.SendServerError(w, r, err)
+				return
+			}
+			response["id_token"] = idToken
+		}
+		tools.SendJSON(w, r, http.StatusOK, response)
+		return
+
+	case GRANT_DEVICE_CODE:
+
+		// Fetch Device Grant
+		var grant tools.DatabaseGrant
+		var approvedBy *int64
+		var isExpired, isEarly bool
+		err := tools.Database.QueryRow(ctx,
+			`SELECT
+				id, user_id, application_id, scopes, denied,
+				expires <= NOW(),
+				COALESCE(polled > NOW() - make_interval(secs => poll_interval), FALSE)
+			FROM auth.grants
+			WHERE code = ANY($1)
+			AND user_code IS NOT NULL`,
+			tools.TokenCandidates(Body.DeviceCode),
+		).Scan(
+			&grant.ID,
+			&approvedBy,
+			&grant.ApplicationID,
+			&grant.Scopes,
+			&grant.Denied,
+			&isExpired,
+			&isEarly,
+		)
+		if errors.Is(err, pgx.ErrNoRows) {
+			tools.SendClientError(w, r, tools.ERROR_OAUTH2_FORM_INVALID_DEVICE_CODE)
+			return
+		}
+		if err != nil {
+			tools.SendServerError(w, r, err)
+			return
+		}
+		if grant.ApplicationID != clientID {
+			tools.SendClientError(w, r, tools.ERROR_GENERIC_UNAUTHORIZED)
+			return
+		}
+
+		// Sanity Checks
+		switch {
+		case isExpired:
+			tools.SendClientError(w, r, tools.ERROR_OAUTH2_DEVICE_EXPIRED)
+			return
+		case grant.Denied:
+			if _, err := tools.Database.Exec(ctx, "DELETE FROM auth.grants WHERE id = $1", grant.ID); err != nil {
+				tools.SendServerError(w, r, err)
+				return
+			}
+			tools.SendClientError(w, r, tools.ERROR_OAUTH2_DEVICE_DENIED)
+			return
+		case isEarly:
+			// Device must wait an additional interval per RFC 8628 Section 3.5
+			if _, err := tools.Database.Exec(ctx,
+				`UPDATE auth.grants SET
+					polled 		  = NOW(),
+					poll_interval = poll_interval + $1
+				WHERE id = $2`,
+				int(tools.OAUTH2_DEVICE_INTERVAL.Seconds()),
+				grant.ID,
+			); err != nil {
+				tools.SendServerError(w, r, err)
+				return
+			}
+			tools.SendClientError(w, r, tools.ERROR_OAUTH2_DEVICE_SLOW_DOWN)
+			return
+		case approvedBy == nil:
+			if _, err := tools.Database.Exec(ctx, "UPDATE auth.grants SET polled = NOW() WHERE id = $1", grant.ID); err != nil {
+				tools.SendServerError(w, r, err)
+				return
+			}
+			tools.SendClientError(w, r, tools.ERROR_OAUTH2_DEVICE_PENDING)
+			return
+		}
+
+		// Consume Device Grant
+		tag, err := tools.Database.Exec(ctx, "DELETE FROM auth.grants WHERE id = $1", grant.ID)
+		if err != nil {
+			tools.SendServerError(w, r, err)
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			// Grant was collected by a concurrent request
+			tools.SendClientError(w, r, tools.ERROR_OAUTH2_FORM_INVALID_DEVICE_CODE)
+			return
+		}
+
+		// Issue Tokens for Approved Grant
+		grant.UserID = *approvedBy
+		response, err := grantConnection(ctx, grant)
+		if err != nil {
+			tools.SendServerError(w, r, err)
+			return
+		}
+		tools.SendJSON(w, r, http.StatusOK, response)
+		return
+
+	case GRANT_CLIENT_CREDENTIALS:
+		// Default to all Scopes allowed for the Application, Operator
+		// Scopes are only taken from Configuration
+		allowedScopes := application.AuthScopes &^ tools.OAuth2ScopesOperator(application.AuthScopes)
+		allowedScopes = allowedScopes | tools.OAuth2ScopesGranted(application.ID)
+		if Body.ScopesString == "" {
+			requestedScopes = allowedScopes
+		}
+		if (requestedScopes & ^allowedScopes) != 0 {
+			tools.SendClientError(w, r, tools.ERROR_OAUTH2_FORM_INVALID_SCOPE)
+			return
+		}
+
+		// Create Connection without a User, these are never refreshed
+		// and are instead removed once they expire
+		tokenAccess, err := tools.GenerateSignedString()
+		if err != nil {
+			tools.SendServerError(w, r, err)
+			return
+		}
+		if _, err := tools.Database.Exec(ctx,
+			`INSERT INTO auth.connections (
+				id, application_id, scopes, token_access, token_expires
+			) VALUES ($1, $2, $3, $4, $5)`,
+			tools.GenerateSnowflake(),
+			application.ID,
+			requestedScopes,
+			tools.HashToken(tokenAccess),
+			time.Now().Add(tools.LIFETIME_OAUTH2_CLIENT_TOKEN),
+		); err != nil {
+			tools.SendServerError(w, r, err)
+			return
+		}
+
+		// Organize Connection
+		tools.SendJSON(w, r, http.StatusOK, map[string]any{
+			"token_type":   tools.TOKEN_PREFIX_BEARER,
+			"access_token": tokenAccess,
+			"expires_in":   tools.LIFETIME_OAUTH2_CLIENT_TOKEN.Seconds(),
+			"scopes":       tools.OAuth2ScopesToString(requestedScopes),
 		})
 		return
+	}
+}
+
+// Generate an Access and Refresh Token for a Connection
+func generateTokenPair() (access, refresh string, err error) {
+	if access, err = tools.GenerateSignedString(); err != nil {
+		return "", "", err
+	}
+	if refresh, err = tools.GenerateSignedString(); err != nil {
+		return "", "", err
+	}
+	return access, refresh, nil
+}
+
+// Create or Reset the Connection for an Approved Grant, returning the Token Response
+func grantConnection(ctx context.Context, grant tools.DatabaseGrant) (map[string]any, error) {
 
-	// Unknown Grant Type
+	// [TX] Begin Transaction
+	tx, err := tools.Database.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// [TX] Fetch Relevant Connection
+	tokenAccess, tokenRefresh, err := generateTokenPair()
+	if err != nil {
+		return nil, err
+	}
+	var connection tools.DatabaseConnection
+	err = tx.QueryRow(ctx,
+		`SELECT
+			id, token_expires
+		FROM auth.connections
+		WHERE application_id = $1
+		AND user_id = $2
+		FOR UPDATE`,
+		grant.ApplicationID,
+		grant.UserID,
+	).Scan(
+		&connection.ID,
+		&connection.TokenExpires,
+	)
+
+	switch {
+	// [TX] Create New Connection
+	case errors.Is(err, pgx.ErrNoRows):
+		connection.ID = tools.GenerateSnowflake()
+		_, err := tx.Exec(ctx,
+			`INSERT INTO auth.connections (
+				id, user_id, application_id, scopes, token_access,
+				token_expires, token_refresh
+			) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			connection.ID,
+			grant.UserID,
+			grant.ApplicationID,
+			grant.Scopes,
+			tools.HashToken(tokenAccess),
+			time.Now().Add(tools.LIFETIME_OAUTH2_ACCESS_TOKEN),
+			tools.HashToken(tokenRefresh),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+	// [TX] Reset Existing Connection
+	case err == nil:
+		_, err := tx.Exec(ctx,
+			`UPDATE auth.connections SET
+				updated			= CURRENT_TIMESTAMP,
+				revoked 		= FALSE,
+				scopes  		= $1,
+				token_access 	= $2,
+				token_refresh   = $3,
+				token_expires	= $4
+			WHERE id = $5`,
+			grant.Scopes,
+			tools.HashToken(tokenAccess),
+			tools.HashToken(tokenRefresh),
+			time.Now().Add(tools.LIFETIME_OAUTH2_ACCESS_TOKEN),
+			connection.ID,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+	// Unknown Error
 	default:
-		tools.SendClientError(w, r, tools.ERROR_OAUTH2_FORM_INVALID_GRANT_TYPE)
+		return nil, err
 	}
+
+	// [TX] Start a New Token Family, previous ones are no longer relevant
+	if _, err := tx.Exec(ctx,
+		"DELETE FROM auth.refresh_tokens WHERE connection_id = $1",
+		connection.ID,
+	); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx,
+		"INSERT INTO auth.refresh_tokens (token, connection_id) VALUES ($1, $2)",
+		tools.HashToken(tokenRefresh),
+		connection.ID,
+	); err != nil {
+		return nil, err
+	}
+
+	// [TX] Complete Transaction
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	// Organize Grant
+	response := map[string]any{
+		"token_type":    tools.TOKEN_PREFIX_BEARER,
+		"access_token":  tokenAccess,
+		"refresh_token": tokenRefresh,
+		"expires_in":    tools.LIFETIME_OAUTH2_ACCESS_TOKEN.Seconds(),
+		"scopes":        tools.OAuth2ScopesToString(grant.Scopes),
+	}
+	if (grant.Scopes & tools.SCOPE_OPENID.Flag) != 0 {
+		idToken, err := tools.OpenIDGenerateToken(ctx, grant.UserID, grant.ApplicationID, grant.Scopes, grant.Nonce)
+		if err != nil {
+			return nil, err
+		}
+		response["id_token"] = idToken
+	}
+	return response, nil
+}
+
+// Revoke Connection after a Refresh Token was Reused and notify its Owner
+func revokeRefreshFamily(ctx context.Context, connectionID int64) error {
+
+	// Revoke Relevant Connection
+	var connection tools.DatabaseConnection
+	err := tools.Database.QueryRow(ctx,
+		`UPDATE auth.connections SET
+			updated = CURRENT_TIMESTAMP,
+			revoked = TRUE
+		WHERE id = $1
+		RETURNING user_id, application_id`,
+		connectionID,
+	).Scan(
+		&connection.UserID,
+		&connection.ApplicationID,
+	)
+	if err != nil {
+		return err
+	}
+	tools.LoggerHttp.Warn("Refresh Token Reused", map[string]any{
+		"connection_id":  connectionID,
+		"user_id":        connection.UserID,
+		"application_id": connection.ApplicationID,
+	})
+
+	// Notify Account Owner
+	tools.EmailAsync(func() {
+		subCtx, subCancel := tools.NewContext()
+		defer subCancel()
+
+		// Fetch Relevant Details
+		var emailAddress, applicationName string
+		displayname := tools.EMAIL_DEFAULT_DISPLAYNAME
+		err := tools.Database.QueryRow(subCtx,
+			`SELECT
+				u.email_address, p.displayname, a.name
+			FROM auth.users u
+			JOIN auth.profiles p     ON p.id = u.id
+			JOIN auth.applications a ON a.id = $2
+			WHERE u.id = $1`,
+			connection.UserID,
+			connection.ApplicationID,
+		).Scan(
+			tools.Decrypted(&emailAddress),
+			&displayname,
+			&applicationName,
+		)
+		if err != nil {
+			tools.LoggerEmail.Error("Lookup Failed", err.Error())
+			return
+		}
+
+		// Send Email
+		tools.TemplateNotifyConnectionRevoked(
+			emailAddress,
+			tools.LocalsNotifyConnectionRevoked{
+				Displayname: displayname,
+				Application: applicationName,
+			},
+		)
+	})
+
+	return nil
 }
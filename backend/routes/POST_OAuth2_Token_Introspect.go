@@ -0,0 +1,122 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/bakonpancakz/template-auth/tools"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func POST_OAuth2_Token_Introspect(w http.ResponseWriter, r *http.Request) {
+
+	var clientID int64
+	var clientSecret string
+	if user, pass, ok := r.BasicAuth(); !ok {
+		tools.SendClientError(w, r, tools.ERROR_GENERIC_UNAUTHORIZED)
+		return
+	} else if id, err := strconv.ParseInt(user, 10, 64); err != nil {
+		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_APPLICATION)
+		return
+	} else {
+		clientID = id
+		clientSecret = pass
+	}
+
+	var Body struct {
+		Token string `query:"token" validate:"required"`
+	}
+	if !tools.ValidateQuery(w, r, &Body) {
+		return
+	}
+	ctx, cancel := tools.NewContext()
+	defer cancel()
+
+	// Validate Application Secret
+	var application tools.DatabaseApplication
+	err := tools.Database.QueryRow(ctx,
+		"SELECT id, auth_secret FROM auth.applications WHERE id = $1",
+		clientID,
+	).Scan(
+		&application.ID,
+		&application.AuthSecret,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_APPLICATION)
+		return
+	}
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
+	// Compare Application Secret
+	if !tools.CompareApplicationSecret(clientSecret, application.AuthSecret) {
+		tools.SendClientError(w, r, tools.ERROR_GENERIC_UNAUTHORIZED)
+		return
+	}
+
+	// Tokens that are malformed, unknown or belong to another
+	// application are simply reported as inactive (RFC 7662)
+	inactive := map[string]any{"active": false}
+	if !tools.CompareSignedString(Body.Token) {
+		tools.SendJSON(w, r, http.StatusOK, inactive)
+		return
+	}
+
+	// Search for Relevant Connection
+	var connection tools.DatabaseConnection
+	var isAccess, isExpired bool
+	err = tools.Database.QueryRow(ctx,
+		`SELECT
+			COALESCE(user_id, $3), updated, revoked, scopes, token_expires,
+			COALESCE(token_access = ANY($1), FALSE), token_expires <= NOW()
+		FROM auth.connections
+		WHERE (token_access = ANY($1) OR token_refresh = ANY($1))
+		AND application_id = $2`,
+		tools.TokenCandidates(Body.Token),
+		application.ID,
+		tools.SESSION_NO_USER_ID,
+	).Scan(
+		&connection.UserID,
+		&connection.Updated,
+		&connection.Revoked,
+		&connection.Scopes,
+		&connection.TokenExpires,
+		&isAccess,
+		&isExpired,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		tools.SendJSON(w, r, http.StatusOK, inactive)
+		return
+	}
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+	if connection.Revoked || (isAccess && isExpired) {
+		tools.SendJSON(w, r, http.StatusOK, inactive)
+		return
+	}
+
+	// Organize Token Information
+	response := map[string]any{
+		"active":    true,
+		"scope":     tools.OAuth2ScopesToString(connection.Scopes),
+		"client_id": strconv.FormatInt(application.ID, 10),
+		"sub":       strconv.FormatInt(connection.UserID, 10),
+		"iat":       connection.Updated.Unix(),
+	}
+	if connection.UserID == tools.SESSION_NO_USER_ID {
+		// Application is acting as itself
+		response["sub"] = response["client_id"]
+	}
+	if isAccess {
+		// Refresh Tokens do not expire until they are used or revoked
+		response["token_type"] = tools.TOKEN_PREFIX_BEARER
+		response["exp"] = connection.TokenExpires.Unix()
+	}
+	tools.SendJSON(w, r, http.StatusOK, response)
+}
@@ -64,10 +64,10 @@ func POST_OAuth2_Token_Revoke(w http.ResponseWriter, r *http.Request) {
 			updated = CURRENT_TIMESTAMP,
 			revoked = TRUE,
 			scopes	= 0
-		WHERE (token_access = $1 OR token_refresh = $1)
+		WHERE (token_access = ANY($1) OR token_refresh = ANY($1))
 		AND application_id = $2
 		AND revoked = false`,
-		Body.Token,
+		tools.TokenCandidates(Body.Token),
 		application.ID,
 	)
 	if err != nil {
@@ -0,0 +1,112 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+func POST_SCIM_Users(w http.ResponseWriter, r *http.Request) {
+
+	session := tools.GetSession(r)
+	var Body tools.SCIMUserResource
+	if !tools.ValidateSCIM(w, r, &Body) {
+		return
+	}
+	ctx, cancel := tools.NewContext()
+	defer cancel()
+
+	// Generate Account Fields
+	user := tools.SCIMUser{ID: tools.GenerateSnowflake()}
+	user.Replace(Body)
+	if !validateSCIMUser(ctx, w, r, session.ApplicationID, &user) {
+		return
+	}
+	username, _ := federatedProfile(tools.FederationIdentity{
+		Username:     user.UserName,
+		EmailAddress: user.EmailAddress,
+	})
+	username, err := uniqueUsername(ctx, username, user.ID)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+	email, err := tools.EncryptField(user.EmailAddress)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+	userName, err := tools.EncryptField(user.UserName)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+	user.Created = time.Now()
+	user.Updated = user.Created
+
+	// [TX] Begin Transaction
+	tx, err := tools.Database.Begin(ctx)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	// [TX] Create New Account without a Password
+	// The Address lies within a Domain the Application owns, but is still
+	// verified by the Owner on their first Login
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO auth.users (
+			id, created, updated, email_address, email_index
+		) VALUES ($1, $2, $2, $3, $4);`,
+		user.ID,
+		user.Created,
+		email,
+		tools.EmailIndex(user.EmailAddress),
+	); err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
+	// [TX] Create New Profile
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO auth.profiles (
+			id, created, updated, username, displayname
+		) VALUES ($1, $2, $2, $3, $4);`,
+		user.ID,
+		user.Created,
+		username,
+		user.Displayname,
+	); err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
+	// [TX] Hand Account over to Application
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO auth.scim_users (
+			user_id, created, updated, application_id, external_id, user_name, user_name_index, active
+		) VALUES ($1, $2, $2, $3, $4, $5, $6, $7);`,
+		user.ID,
+		user.Created,
+		session.ApplicationID,
+		user.ExternalID,
+		userName,
+		tools.UserNameIndex(user.UserName),
+		user.Active,
+	); err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
+	// [TX] Complete Transaction
+	if err := tx.Commit(ctx); err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", tools.OIDC_ISSUER+"/scim/v2/Users/"+strconv.FormatInt(user.ID, 10))
+	tools.SendSCIM(w, r, http.StatusCreated, user.Resource())
+}
@@ -11,10 +11,6 @@ import (
 func POST_Users_Me_Applications(w http.ResponseWriter, r *http.Request) {
 
 	session := tools.GetSession(r)
-	if session.ApplicationID != tools.SESSION_NO_APPLICATION_ID {
-		tools.SendClientError(w, r, tools.ERROR_OAUTH2_USERS_ONLY)
-		return
-	}
 	var Body struct {
 		Name string `json:"name" validate:"required,displayname"`
 	}
@@ -27,9 +23,13 @@ func POST_Users_Me_Applications(w http.ResponseWriter, r *http.Request) {
 	// Create New Application for Account
 	var applicationID = tools.GenerateSnowflake()
 	var applicationCreated = time.Now()
-	var applicationSecret, _ = tools.GenerateApplicationSecret()
+	var applicationSecret, _, err = tools.GenerateApplicationSecret()
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
 
-	_, err := tools.Database.Exec(ctx,
+	_, err = tools.Database.Exec(ctx,
 		`INSERT INTO auth.applications (
 			id, created, updated, user_id, name, auth_secret
 		) VALUES ($1, $2, $2, $3, $4, $5)`,
@@ -52,5 +52,7 @@ func POST_Users_Me_Applications(w http.ResponseWriter, r *http.Request) {
 		"description": nil,
 		"icon":        nil,
 		"redirects":   make([]string, 0),
+		"public":      false,
+		"scopes":      "",
 	})
 }
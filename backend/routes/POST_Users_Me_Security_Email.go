@@ -13,30 +13,30 @@ import (
 func POST_Users_Me_Security_Email(w http.ResponseWriter, r *http.Request) {
 
 	session := tools.GetSession(r)
-	if session.ApplicationID != tools.SESSION_NO_APPLICATION_ID {
-		tools.SendClientError(w, r, tools.ERROR_OAUTH2_USERS_ONLY)
-		return
-	}
 	ctx, cancel := tools.NewContext()
 	defer cancel()
 
 	// Update Email Verification Fields for Account
+	verifyToken, err := tools.GenerateSignedString()
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
 	var (
 		user               tools.DatabaseUser
-		verifyToken        = tools.GenerateSignedString()
 		verifyTokenExpires = time.Now().Add(tools.LIFETIME_TOKEN_EMAIL_VERIFY)
 	)
-	err := tools.Database.QueryRow(ctx,
+	err = tools.Database.QueryRow(ctx,
 		`UPDATE auth.users SET
 			updated 		 = CURRENT_TIMESTAMP,
 			token_verify 	 = $1,
 			token_verify_eat = $2
 		WHERE id = $3 AND email_verified = FALSE
-		RETURNING email_address`,
-		verifyToken,
+		RETURNING id, email_address`,
+		tools.HashToken(verifyToken),
 		verifyTokenExpires,
 		session.UserID,
-	).Scan(&user)
+	).Scan(&user.ID, tools.Decrypted(&user.EmailAddress))
 	if errors.Is(err, pgx.ErrNoRows) {
 		tools.SendClientError(w, r, tools.ERROR_MFA_EMAIL_ALREADY_VERIFIED)
 		return
@@ -47,7 +47,7 @@ func POST_Users_Me_Security_Email(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Send Email to Account Owner
-	go func() {
+	tools.EmailAsync(func() {
 		subCtx, subCancel := tools.NewContext()
 		defer subCancel()
 
@@ -65,7 +65,7 @@ func POST_Users_Me_Security_Email(w http.ResponseWriter, r *http.Request) {
 				Token:       verifyToken,
 			},
 		)
-	}()
+	})
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -14,12 +14,13 @@ import (
 func POST_Users_Me_Security_Escalate(w http.ResponseWriter, r *http.Request) {
 
 	session := tools.GetSession(r)
-	if session.ApplicationID != tools.SESSION_NO_APPLICATION_ID {
-		tools.SendClientError(w, r, tools.ERROR_OAUTH2_USERS_ONLY)
-		return
-	}
 	var Body struct {
-		Passcode string `json:"passcode" validate:"omitempty,passcode"`
+		Passcode          string `json:"passcode" validate:"omitempty,passcode"`
+		CredentialID      string `json:"credential_id"`
+		ClientData        string `json:"client_data"`
+		AuthenticatorData string `json:"authenticator_data"`
+		Signature         string `json:"signature"`
+		UserHandle        string `json:"user_handle"`
 	}
 	if !tools.ValidateJSON(w, r, &Body) {
 		return
@@ -31,21 +32,25 @@ func POST_Users_Me_Security_Escalate(w http.ResponseWriter, r *http.Request) {
 	var user tools.DatabaseUser
 	err := tools.Database.QueryRow(ctx,
 		`SELECT
-			email_address, email_verified, mfa_enabled,
-			mfa_secret, mfa_codes, mfa_codes_used,
-			password_hash, token_passcode, token_passcode_eat
+			id, email_address, email_verified, mfa_enabled,
+			mfa_secret, mfa_codes, mfa_codes_used, password_hash,
+			token_passcode, token_passcode_eat, token_passcode_attempts,
+			lockout_until
 		FROM auth.users WHERE id = $1`,
 		session.UserID,
 	).Scan(
-		&user.EmailAddress,
+		&user.ID,
+		tools.Decrypted(&user.EmailAddress),
 		&user.EmailVerified,
 		&user.MFAEnabled,
-		&user.MFASecret,
+		tools.Decrypted(&user.MFASecret),
 		&user.MFACodes,
 		&user.MFACodesUsed,
 		&user.PasswordHash,
 		&user.TokenPasscode,
 		&user.TokenPasscodeEAT,
+		&user.TokenPasscodeAttempts,
+		&user.LockoutUntil,
 	)
 	if errors.Is(err, pgx.ErrNoRows) {
 		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_USER)
@@ -57,57 +62,27 @@ func POST_Users_Me_Security_Escalate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Attempt Multi-Factor Authentication
-	if user.MFAEnabled && user.MFASecret != nil {
-
-		// Method: TOTP Verification
-		// User must attempt to prove ownership by entering a code generated by
-		// their authenticator app or by entering a recovery code
-
-		if Body.Passcode == "" {
-			tools.SendClientError(w, r, tools.ERROR_MFA_PASSCODE_REQUIRED)
+	if Body.CredentialID != "" {
+
+		// Method: Passkey
+		// User must attempt to prove ownership by signing a challenge with a
+		// passkey registered to their account, this satisfies any other method
+		if _, ok := verifyPasskeyAssertion(ctx, w, r, session.UserID, passkeyAssertion{
+			CredentialID:      Body.CredentialID,
+			ClientData:        Body.ClientData,
+			AuthenticatorData: Body.AuthenticatorData,
+			Signature:         Body.Signature,
+			UserHandle:        Body.UserHandle,
+		}); !ok {
 			return
 		}
 
-		switch len(Body.Passcode) {
-
-		// Using Recovery Code
-		case tools.MFA_RECOVERY_LENGTH:
-			found := false
-			for i, recoveryCode := range user.MFACodes {
-				if Body.Passcode == recoveryCode {
-					// Code Used?
-					if (user.MFACodesUsed & (1 << i)) != 0 {
-						tools.SendClientError(w, r, tools.ERROR_MFA_RECOVERY_CODE_USED)
-						return
-					}
-					// Mark Recovery Code as Used
-					if _, err := tools.Database.Exec(ctx,
-						"UPDATE auth.users SET mfa_codes_used = mfa_codes_used | $1 WHERE id = $2",
-						(1 << i),
-						session.UserID,
-					); err != nil {
-						tools.SendServerError(w, r, err)
-						return
-					}
-					found = true
-					break
-				}
-			}
-			if !found {
-				tools.SendClientError(w, r, tools.ERROR_MFA_RECOVERY_CODE_INCORRECT)
-				return
-			}
+	} else if user.MFAEnabled && user.MFASecret != nil {
 
-		// Using Passcode
-		case tools.MFA_PASSCODE_LENGTH:
-			if !tools.ValidateTOTPCode(Body.Passcode, *user.MFASecret) {
-				tools.SendClientError(w, r, tools.ERROR_MFA_PASSCODE_INCORRECT)
-				return
-			}
-
-		default:
-			// Should be caught by validator!
-			tools.SendClientError(w, r, tools.ERROR_MFA_PASSCODE_INCORRECT)
+		// Method: TOTP Verification
+		// User must attempt to prove ownership by entering a code generated by
+		// their authenticator app or by entering a recovery code
+		if !verifyLoginPasscode(ctx, w, r, user, Body.Passcode) {
 			return
 		}
 
@@ -119,6 +94,7 @@ func POST_Users_Me_Security_Escalate(w http.ResponseWriter, r *http.Request) {
 		if Body.Passcode == "" ||
 			user.TokenPasscode == nil ||
 			user.TokenPasscodeEAT == nil ||
+			user.TokenPasscodeAttempts >= tools.PASSCODE_ATTEMPT_LIMIT ||
 			time.Now().After(*user.TokenPasscodeEAT) {
 
 			// The user did not provide a passcode or it has expired
@@ -129,11 +105,12 @@ func POST_Users_Me_Security_Escalate(w http.ResponseWriter, r *http.Request) {
 			passcodeExpiration := time.Now().Add(tools.LIFETIME_TOKEN_EMAIL_PASSCODE)
 			if _, err = tools.Database.Exec(ctx,
 				`UPDATE auth.users SET
-					updated 		   = CURRENT_TIMESTAMP,
-					token_passcode 	   = $1,
-					token_passcode_eat = $2
+					updated 				= CURRENT_TIMESTAMP,
+					token_passcode 			= $1,
+					token_passcode_eat 		= $2,
+					token_passcode_attempts = 0
 				WHERE id = $3`,
-				passcode,
+				tools.HashToken(passcode),
 				passcodeExpiration,
 				session.UserID,
 			); err != nil {
@@ -142,7 +119,7 @@ func POST_Users_Me_Security_Escalate(w http.ResponseWriter, r *http.Request) {
 			}
 
 			// Send Passcode to Account Owner
-			go func() {
+			tools.EmailAsync(func() {
 				subCtx, subCancel := tools.NewContext()
 				defer subCancel()
 
@@ -161,7 +138,7 @@ func POST_Users_Me_Security_Escalate(w http.ResponseWriter, r *http.Request) {
 						Lifetime:    fmt.Sprint(tools.LIFETIME_TOKEN_EMAIL_PASSCODE.Minutes()),
 					},
 				)
-			}()
+			})
 
 			tools.SendClientError(w, r, tools.ERROR_MFA_EMAIL_SENT)
 			return
@@ -169,8 +146,30 @@ func POST_Users_Me_Security_Escalate(w http.ResponseWriter, r *http.Request) {
 		} else {
 
 			// User is attempting to prove ownership by entering a passcode
-			if Body.Passcode != *user.TokenPasscode {
-				tools.SendClientError(w, r, tools.ERROR_MFA_PASSCODE_INCORRECT)
+			// Guesses are counted before comparing so concurrent requests can't exceed the limit
+			if accountLocked(w, r, user) {
+				return
+			}
+			tag, err := tools.Database.Exec(ctx,
+				`UPDATE auth.users SET
+					token_passcode_attempts = token_passcode_attempts + 1
+				WHERE id = $1
+				AND token_passcode = $2
+				AND token_passcode_attempts < $3`,
+				user.ID,
+				user.TokenPasscode,
+				tools.PASSCODE_ATTEMPT_LIMIT,
+			)
+			if err != nil {
+				tools.SendServerError(w, r, err)
+				return
+			}
+			if tag.RowsAffected() == 0 {
+				tools.SendClientError(w, r, tools.ERROR_LOGIN_CODE_EXHAUSTED)
+				return
+			}
+			if !tools.CompareToken(Body.Passcode, *user.TokenPasscode) {
+				failAttempt(ctx, w, r, user.ID, factorPasscode, tools.ERROR_MFA_PASSCODE_INCORRECT)
 				return
 			}
 		}
@@ -179,11 +178,14 @@ func POST_Users_Me_Security_Escalate(w http.ResponseWriter, r *http.Request) {
 
 		// Method: Password
 		// User must attempt to prove ownership by re-entering their password
+		if accountLocked(w, r, user) {
+			return
+		}
 		if match, err := tools.ComparePasswordHash(*user.PasswordHash, Body.Passcode); err != nil {
 			tools.SendServerError(w, r, err)
 			return
 		} else if !match {
-			tools.SendClientError(w, r, tools.ERROR_MFA_PASSWORD_INCORRECT)
+			failAttempt(ctx, w, r, user.ID, factorPassword, tools.ERROR_MFA_PASSWORD_INCORRECT)
 			return
 		}
 
@@ -195,10 +197,14 @@ func POST_Users_Me_Security_Escalate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Mark Current Session as Elevated
+	if err := clearFailures(ctx, user.ID); err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
 	elevatedUntil := time.Now().Add(tools.LIFETIME_TOKEN_USER_ELEVATION)
 	if _, err := tools.Database.Exec(ctx,
 		"UPDATE auth.sessions SET elevated_until = $1 WHERE id = $2",
-		elevatedUntil,
+		elevatedUntil.Unix(),
 		session.SessionID,
 	); err != nil {
 		tools.SendServerError(w, r, err)
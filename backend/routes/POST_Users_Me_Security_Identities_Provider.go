@@ -0,0 +1,35 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+func POST_Users_Me_Security_Identities_Provider(w http.ResponseWriter, r *http.Request) {
+
+	session := tools.GetSession(r)
+	if !session.Elevated {
+		tools.SendClientError(w, r, tools.ERROR_MFA_ESCALATION_REQUIRED)
+		return
+	}
+
+	provider, ok := tools.Federation[r.PathValue("provider")]
+	if !ok {
+		tools.SendClientError(w, r, tools.ERROR_UNKNOWN_PROVIDER)
+		return
+	}
+	ctx, cancel := tools.NewContext()
+	defer cancel()
+
+	// Generate Authorization URL for Linking, completed by the Login Callback
+	url, err := beginFederation(ctx, w, provider, &session.UserID)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
+	tools.SendJSON(w, r, http.StatusOK, map[string]any{
+		"url": url,
+	})
+}
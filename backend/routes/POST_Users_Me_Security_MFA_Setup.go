@@ -9,10 +9,6 @@ import (
 func POST_Users_Me_Security_MFA_Setup(w http.ResponseWriter, r *http.Request) {
 
 	session := tools.GetSession(r)
-	if session.ApplicationID != tools.SESSION_NO_APPLICATION_ID {
-		tools.SendClientError(w, r, tools.ERROR_OAUTH2_USERS_ONLY)
-		return
-	}
 	var Body struct {
 		Passcode string `json:"passcode" validate:"required,passcode"`
 	}
@@ -29,7 +25,7 @@ func POST_Users_Me_Security_MFA_Setup(w http.ResponseWriter, r *http.Request) {
 		session.UserID,
 	).Scan(
 		&user.MFAEnabled,
-		&user.MFASecret,
+		tools.Decrypted(&user.MFASecret),
 	); err != nil {
 		tools.SendServerError(w, r, err)
 		return
@@ -0,0 +1,121 @@
+package routes
+
+import (
+	"encoding/hex"
+	"errors"
+	"net/http"
+
+	"github.com/bakonpancakz/template-auth/tools"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func POST_Users_Me_Security_Passkeys_Setup(w http.ResponseWriter, r *http.Request) {
+
+	session := tools.GetSession(r)
+	if !session.Elevated {
+		tools.SendClientError(w, r, tools.ERROR_MFA_ESCALATION_REQUIRED)
+		return
+	}
+	var Body struct {
+		Name              string `json:"name" validate:"required,displayname"`
+		ClientData        string `json:"client_data" validate:"required"`
+		AttestationObject string `json:"attestation_object" validate:"required"`
+	}
+	if !tools.ValidateJSON(w, r, &Body) {
+		return
+	}
+
+	// Decode Attestation
+	clientData, err1 := tools.WebAuthnDecode(Body.ClientData)
+	attestation, err2 := tools.WebAuthnDecode(Body.AttestationObject)
+	if err1 != nil || err2 != nil {
+		tools.SendClientError(w, r, tools.ERROR_PASSKEY_INVALID)
+		return
+	}
+	challenge, err := tools.WebAuthnParseClientData(clientData, tools.WEBAUTHN_CEREMONY_CREATE)
+	if err != nil {
+		tools.SendClientError(w, r, tools.ERROR_PASSKEY_INVALID)
+		return
+	}
+	data, err := tools.WebAuthnParseAttestation(attestation)
+	if err != nil {
+		tools.SendClientError(w, r, tools.ERROR_PASSKEY_INVALID)
+		return
+	}
+	ctx, cancel := tools.NewContext()
+	defer cancel()
+
+	// Consume Challenge
+	tag, err := tools.Database.Exec(ctx,
+		`DELETE FROM auth.passkey_challenges
+		WHERE challenge = $1
+		AND user_id = $2
+		AND expires > NOW()`,
+		challenge,
+		session.UserID,
+	)
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		tools.SendClientError(w, r, tools.ERROR_PASSKEY_CHALLENGE_INVALID)
+		return
+	}
+
+	// Enforce Passkey Limit
+	var passkeyCount int
+	if err := tools.Database.QueryRow(ctx,
+		"SELECT COUNT(*) FROM auth.passkeys WHERE user_id = $1",
+		session.UserID,
+	).Scan(&passkeyCount); err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+	if passkeyCount >= tools.PASSKEY_LIMIT {
+		tools.SendClientError(w, r, tools.ERROR_PASSKEY_LIMIT)
+		return
+	}
+
+	// Store Credential, the same Credential cannot be Registered twice
+	passkey := tools.DatabasePasskey{
+		ID:           tools.GenerateSnowflake(),
+		Name:         Body.Name,
+		CredentialID: tools.WebAuthnEncode(data.CredentialID),
+		PublicKey:    data.PublicKey,
+		SignCount:    int64(data.SignCount),
+		AAGUID:       hex.EncodeToString(data.AAGUID),
+	}
+	err = tools.Database.QueryRow(ctx,
+		`INSERT INTO auth.passkeys (
+			id, user_id, name, credential_id, public_key, sign_count, aaguid
+		) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (credential_id) DO NOTHING
+		RETURNING created`,
+		passkey.ID,
+		session.UserID,
+		passkey.Name,
+		passkey.CredentialID,
+		passkey.PublicKey,
+		passkey.SignCount,
+		passkey.AAGUID,
+	).Scan(&passkey.Created)
+	if errors.Is(err, pgx.ErrNoRows) {
+		tools.SendClientError(w, r, tools.ERROR_PASSKEY_DUPLICATE)
+		return
+	}
+	if err != nil {
+		tools.SendServerError(w, r, err)
+		return
+	}
+
+	// Organize Passkey
+	tools.SendJSON(w, r, http.StatusOK, map[string]any{
+		"id":      passkey.ID,
+		"created": passkey.Created,
+		"used":    nil,
+		"name":    passkey.Name,
+		"aaguid":  passkey.AAGUID,
+	})
+}
@@ -0,0 +1,32 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+func PUT_SCIM_Users_ID(w http.ResponseWriter, r *http.Request) {
+
+	session := tools.GetSession(r)
+	var Body tools.SCIMUserResource
+	if !tools.ValidateSCIM(w, r, &Body) {
+		return
+	}
+	ctx, cancel := tools.NewContext()
+	defer cancel()
+
+	previous, ok := fetchSCIMUser(ctx, w, r, session.ApplicationID)
+	if !ok {
+		return
+	}
+
+	// Replace Attributes
+	user := previous
+	user.Replace(Body)
+	if !saveSCIMUser(ctx, w, r, session.ApplicationID, previous, &user) {
+		return
+	}
+
+	tools.SendSCIM(w, r, http.StatusOK, user.Resource())
+}
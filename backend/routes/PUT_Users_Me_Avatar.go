@@ -13,10 +13,6 @@ import (
 func PUT_Users_Me_Avatar(w http.ResponseWriter, r *http.Request) {
 
 	session := tools.GetSession(r)
-	if session.ApplicationID != tools.SESSION_NO_APPLICATION_ID {
-		tools.SendClientError(w, r, tools.ERROR_OAUTH2_USERS_ONLY)
-		return
-	}
 	if err := r.ParseMultipartForm(math.MaxInt64); err != nil {
 		tools.SendClientError(w, r, tools.ERROR_BODY_INVALID_TYPE)
 		return
@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+func Test_Commands(t *testing.T) {
+
+	t.Run("admin_reencrypt_fields", func(t *testing.T) {
+		ResetDatabase(t, RESET_BASE, RESET_ACCOUNT, RESET_PROFILE, RESET_SESSION)
+		if _, err := tools.MigrateFields(t.Context()); err != nil {
+			t.Fatalf("migration failed: %s", err)
+		}
+		previous, err := tools.KeystoreCurrent(tools.KEY_USAGE_ENCRYPTION)
+		if err != nil {
+			t.Fatalf("missing master key: %s", err)
+		}
+
+		// Rotate Master Key
+		key, err := tools.KeystoreGenerate(tools.KEY_USAGE_ENCRYPTION, tools.KEY_ALGORITHM_A256GCM, time.Now())
+		if err != nil {
+			t.Fatalf("cannot generate key: %s", err)
+		}
+		if err := tools.Keystore.Save(t.Context(), key); err != nil {
+			t.Fatalf("cannot save key: %s", err)
+		}
+		if err := tools.KeystoreRotate(t.Context(), time.Now()); err != nil {
+			t.Fatalf("cannot rotate keys: %s", err)
+		}
+		if _, err := tools.ReencryptFields(t.Context()); err != nil {
+			t.Fatalf("command failed: %s", err)
+		}
+
+		// Values are wrapped by the New Key and the Old Key is retired
+		var email string
+		QueryDatabaseRow(t, "SELECT email_address FROM auth.users WHERE id = $1",
+			[]any{TEST_ID_PRIMARY}, &email,
+		)
+		if !strings.HasPrefix(email, tools.FIELD_ENVELOPE_PREFIX+key.ID+".") {
+			t.Errorf("address was not re-wrapped: %s", email)
+		}
+		if _, ok := tools.KeystoreLookup(tools.KEY_USAGE_ENCRYPTION, previous.ID); ok {
+			t.Errorf("previous key was not retired")
+		}
+		NewTestRequest(t, "GET", "/users/@me").
+			WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+			Send().
+			ExpectStatus(http.StatusOK).
+			ExpectJSON().
+			ExpectString("email", TEST_EMAIL_PRIMARY)
+		NewTestRequest(t, "GET", "/users/@me/security/sessions").
+			WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+			Send().
+			ExpectStatus(http.StatusOK)
+	})
+}
@@ -0,0 +1,59 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+func Test_Device_Endpoints(t *testing.T) {
+	ResetDatabase(t,
+		RESET_BASE, RESET_ACCOUNT, RESET_PROFILE,
+		RESET_SESSION, RESET_DEVICE,
+	)
+
+	t.Run("/users/@me/security/devices", func(t *testing.T) {
+		t.Run("List Devices", func(t *testing.T) {
+			res := NewTestRequest(t, "GET", "/users/@me/security/devices").
+				WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+				WithCookie(tools.HTTP_DEVICE_COOKIE_NAME, TEST_TOKEN_SECONDARY).
+				Send().
+				ExpectStatus(http.StatusOK).
+				ExpectField("devices")
+			if res.responseJSON["current"] == nil {
+				t.Errorf("current device was not recognized")
+			}
+			if devices, _ := res.responseJSON["devices"].([]any); len(devices) != 1 {
+				t.Errorf("expected 1 device, got %d", len(devices))
+			}
+		})
+	})
+
+	t.Run("/users/@me/security/devices/{id}", func(t *testing.T) {
+		t.Run("Forget Device - Escalation Required", func(t *testing.T) {
+			NewTestRequest(t, "DELETE", "/users/@me/security/devices/%d", TEST_ID_PRIMARY).
+				WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+				Send().
+				ExpectStatus(tools.ERROR_MFA_ESCALATION_REQUIRED.Status).
+				ExpectInteger("code", int64(tools.ERROR_MFA_ESCALATION_REQUIRED.Code))
+		})
+
+		ExecDatabase(t, RESET_SESSION_ELEVATED.Query, RESET_SESSION_ELEVATED.Arguments...)
+
+		t.Run("Forget Device", func(t *testing.T) {
+			NewTestRequest(t, "DELETE", "/users/@me/security/devices/%d", TEST_ID_PRIMARY).
+				WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+				Send().
+				ExpectStatus(http.StatusNoContent)
+		})
+
+		t.Run("Forget Unknown Device", func(t *testing.T) {
+			NewTestRequest(t, "DELETE", "/users/@me/security/devices/%d", TEST_ID_PRIMARY).
+				WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+				Send().
+				ExpectStatus(tools.ERROR_UNKNOWN_DEVICE.Status).
+				ExpectInteger("code", int64(tools.ERROR_UNKNOWN_DEVICE.Code))
+		})
+	})
+}
@@ -0,0 +1,236 @@
+package tests
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+func Test_Federation_Endpoints(t *testing.T) {
+	ResetDatabase(t,
+		RESET_BASE, RESET_ACCOUNT, RESET_PROFILE,
+		RESET_SESSION, RESET_SESSION_ELEVATED,
+	)
+	idp := newTestIdentityProvider("test")
+
+	beginLogin := func(t *testing.T, claims map[string]any) (state, code string) {
+		res := NewTestRequest(t, "GET", "/auth/federated/test").
+			Send().
+			ExpectStatus(http.StatusOK).
+			ExpectCookie(tools.HTTP_FEDERATION_COOKIE_NAME).
+			ExpectField("url")
+		return idp.Authorize(t, res.responseJSON["url"].(string), claims)
+	}
+	callback := func(t *testing.T, state string, body map[string]any) *testRequest {
+		body["state"] = state
+		return NewTestRequest(t, "POST", "/auth/federated/test").
+			WithCookie(tools.HTTP_FEDERATION_COOKIE_NAME, state).
+			WithJSON(body).
+			Send()
+	}
+
+	t.Run("/auth/federated", func(t *testing.T) {
+		res := NewTestRequest(t, "GET", "/auth/federated").
+			Send().
+			ExpectStatus(http.StatusOK).
+			ExpectBody()
+		if strings.TrimSpace(string(res.responseBody)) != `["test"]` {
+			t.Fatalf("expected provider list, got %s", res.responseBody)
+		}
+		NewTestRequest(t, "GET", "/auth/federated/unknown").
+			Send().
+			ExpectStatus(tools.ERROR_UNKNOWN_PROVIDER.Status).
+			ExpectInteger("code", int64(tools.ERROR_UNKNOWN_PROVIDER.Code))
+	})
+
+	t.Run("/auth/federated/{provider}", func(t *testing.T) {
+		newcomer := map[string]any{
+			"sub":                "newcomer",
+			"email":              TEST_EMAIL_SECONDARY,
+			"email_verified":     true,
+			"name":               TEST_DISPLAYNAME_SECONDARY,
+			"preferred_username": TEST_USERNAME_SECONDARY,
+		}
+
+		t.Run("State Mismatch", func(t *testing.T) {
+			_, code := beginLogin(t, newcomer)
+			callback(t, TEST_TOKEN_INVALID, map[string]any{"code": code}).
+				ExpectStatus(tools.ERROR_FEDERATION_STATE_INVALID.Status).
+				ExpectInteger("code", int64(tools.ERROR_FEDERATION_STATE_INVALID.Code))
+		})
+
+		t.Run("Incorrect Nonce", func(t *testing.T) {
+			state, code := beginLogin(t, map[string]any{"sub": "newcomer", "nonce": "nonce"})
+			callback(t, state, map[string]any{"code": code}).
+				ExpectStatus(tools.ERROR_FEDERATION_FAILED.Status).
+				ExpectInteger("code", int64(tools.ERROR_FEDERATION_FAILED.Code))
+		})
+
+		t.Run("Create Account", func(t *testing.T) {
+			state, code := beginLogin(t, newcomer)
+			callback(t, state, map[string]any{"code": code}).
+				ExpectStatus(http.StatusNoContent).
+				ExpectCookie(tools.HTTP_COOKIE_NAME)
+
+			var username string
+			var verified bool
+			QueryDatabaseRow(t,
+				`SELECT p.username, u.email_verified
+				FROM auth.identities i
+				JOIN auth.users u ON u.id = i.user_id
+				JOIN auth.profiles p ON p.id = i.user_id
+				WHERE i.provider = 'test' AND i.subject = 'newcomer'`,
+				nil,
+				&username,
+				&verified,
+			)
+			if username != TEST_USERNAME_SECONDARY || !verified {
+				t.Fatalf("unexpected account: username=%s verified=%t", username, verified)
+			}
+
+			// State is consumed by the first Callback
+			callback(t, state, map[string]any{"code": code}).
+				ExpectStatus(tools.ERROR_FEDERATION_STATE_INVALID.Status)
+		})
+
+		t.Run("Existing Identity", func(t *testing.T) {
+			state, code := beginLogin(t, newcomer)
+			callback(t, state, map[string]any{"code": code}).
+				ExpectStatus(http.StatusNoContent).
+				ExpectCookie(tools.HTTP_COOKIE_NAME)
+
+			var accounts int
+			QueryDatabaseRow(t,
+				"SELECT COUNT(*) FROM auth.users WHERE email_index = $1",
+				[]any{tools.EmailIndex(TEST_EMAIL_SECONDARY)},
+				&accounts,
+			)
+			if accounts != 1 {
+				t.Fatalf("expected one account, got %d", accounts)
+			}
+		})
+
+		t.Run("Account Locked", func(t *testing.T) {
+			// The Provider replaces the Password, not the Lockout
+			ExecDatabase(t,
+				"UPDATE auth.users SET lockout_until = CURRENT_TIMESTAMP + INTERVAL '1 hour' WHERE email_index = $1",
+				tools.EmailIndex(TEST_EMAIL_SECONDARY),
+			)
+			defer ExecDatabase(t,
+				"UPDATE auth.users SET lockout_until = NULL WHERE email_index = $1",
+				tools.EmailIndex(TEST_EMAIL_SECONDARY),
+			)
+			state, code := beginLogin(t, newcomer)
+			callback(t, state, map[string]any{"code": code}).
+				ExpectStatus(tools.ERROR_LOGIN_ACCOUNT_LOCKED.Status).
+				ExpectInteger("code", int64(tools.ERROR_LOGIN_ACCOUNT_LOCKED.Code)).
+				ExpectHeader("Retry-After", nil)
+		})
+
+		t.Run("Email Conflict (Unverified)", func(t *testing.T) {
+			state, code := beginLogin(t, map[string]any{
+				"sub":            "impostor",
+				"email":          TEST_EMAIL_PRIMARY,
+				"email_verified": true,
+			})
+			callback(t, state, map[string]any{"code": code}).
+				ExpectStatus(tools.ERROR_FEDERATION_EMAIL_CONFLICT.Status).
+				ExpectInteger("code", int64(tools.ERROR_FEDERATION_EMAIL_CONFLICT.Code))
+		})
+
+		t.Run("Email Required", func(t *testing.T) {
+			state, code := beginLogin(t, map[string]any{"sub": "anonymous"})
+			callback(t, state, map[string]any{"code": code}).
+				ExpectStatus(tools.ERROR_FEDERATION_EMAIL_REQUIRED.Status).
+				ExpectInteger("code", int64(tools.ERROR_FEDERATION_EMAIL_REQUIRED.Code))
+		})
+
+		t.Run("Link by Verified Email", func(t *testing.T) {
+			ExecDatabase(t, "UPDATE auth.users SET email_verified = TRUE WHERE id = $1", TEST_ID_PRIMARY)
+			ResetDatabase(t, RESET_ACCOUNT_MFA)
+			state, code := beginLogin(t, map[string]any{
+				"sub":            "owner",
+				"email":          TEST_EMAIL_PRIMARY,
+				"email_verified": true,
+			})
+
+			// The Provider replaces the Password, not the Second Factor
+			callback(t, state, map[string]any{"code": code}).
+				ExpectStatus(tools.ERROR_MFA_PASSCODE_REQUIRED.Status).
+				ExpectInteger("code", int64(tools.ERROR_MFA_PASSCODE_REQUIRED.Code))
+			callback(t, state, map[string]any{
+				"passcode": tools.GenerateTOTPCode(TEST_TOTP_SECRET, time.Now()),
+			}).
+				ExpectStatus(http.StatusNoContent).
+				ExpectCookie(tools.HTTP_COOKIE_NAME)
+
+			var userID int64
+			QueryDatabaseRow(t,
+				"SELECT user_id FROM auth.identities WHERE provider = 'test' AND subject = 'owner'",
+				nil,
+				&userID,
+			)
+			if userID != TEST_ID_PRIMARY {
+				t.Fatalf("identity linked to wrong account")
+			}
+		})
+	})
+
+	t.Run("/users/@me/security/identities", func(t *testing.T) {
+		ExecDatabase(t, "DELETE FROM auth.identities WHERE user_id = $1", TEST_ID_PRIMARY)
+
+		t.Run("Link Identity", func(t *testing.T) {
+			res := NewTestRequest(t, "POST", "/users/@me/security/identities/test").
+				WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+				Send().
+				ExpectStatus(http.StatusOK).
+				ExpectField("url")
+			state, code := idp.Authorize(t, res.responseJSON["url"].(string), map[string]any{
+				"sub":   "linked",
+				"email": "linked@email.org",
+			})
+			callback(t, state, map[string]any{"code": code}).
+				ExpectStatus(http.StatusNoContent)
+		})
+
+		t.Run("Link Duplicate", func(t *testing.T) {
+			res := NewTestRequest(t, "POST", "/users/@me/security/identities/test").
+				WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+				Send().
+				ExpectStatus(http.StatusOK).
+				ExpectField("url")
+			state, code := idp.Authorize(t, res.responseJSON["url"].(string), map[string]any{
+				"sub": "newcomer",
+			})
+			callback(t, state, map[string]any{"code": code}).
+				ExpectStatus(tools.ERROR_FEDERATION_IDENTITY_LINKED.Status).
+				ExpectInteger("code", int64(tools.ERROR_FEDERATION_IDENTITY_LINKED.Code))
+		})
+
+		t.Run("List Identities", func(t *testing.T) {
+			res := NewTestRequest(t, "GET", "/users/@me/security/identities").
+				WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+				Send().
+				ExpectStatus(http.StatusOK).
+				ExpectBody()
+			if !strings.Contains(string(res.responseBody), `"email_address":"linked@email.org"`) {
+				t.Fatalf("expected linked identity, got %s", res.responseBody)
+			}
+		})
+
+		t.Run("Unlink Identity", func(t *testing.T) {
+			NewTestRequest(t, "DELETE", "/users/@me/security/identities/test").
+				WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+				Send().
+				ExpectStatus(http.StatusNoContent)
+			NewTestRequest(t, "DELETE", "/users/@me/security/identities/test").
+				WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+				Send().
+				ExpectStatus(tools.ERROR_UNKNOWN_IDENTITY.Status).
+				ExpectInteger("code", int64(tools.ERROR_UNKNOWN_IDENTITY.Code))
+		})
+	})
+}
@@ -2,11 +2,14 @@ package tests
 
 import (
 	"net/http"
+	"net/netip"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/bakonpancakz/template-auth/tools"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 func Test_Login_Endpoints(t *testing.T) {
@@ -47,6 +50,22 @@ func Test_Login_Endpoints(t *testing.T) {
 				ExpectStatus(http.StatusBadRequest)
 		})
 
+		t.Run("Breached Password", func(t *testing.T) {
+			tools.Breaches = tools.NewBreachFilter(1, 0.001)
+			tools.Breaches.Insert(TEST_PASSWORD_SECONDARY)
+			defer func() { tools.Breaches = nil }()
+
+			NewTestRequest(t, "POST", "/auth/signup").
+				WithJSON(map[string]any{
+					"username": TEST_USERNAME_SECONDARY,
+					"email":    TEST_EMAIL_SECONDARY,
+					"password": TEST_PASSWORD_SECONDARY,
+				}).
+				Send().
+				ExpectStatus(tools.ERROR_LOGIN_PASSWORD_BREACHED.Status).
+				ExpectInteger("code", int64(tools.ERROR_LOGIN_PASSWORD_BREACHED.Code))
+		})
+
 		t.Run("Signup - Duplicate Email", func(t *testing.T) {
 			NewTestRequest(t, "POST", "/auth/signup").
 				WithJSON(map[string]any{
@@ -87,7 +106,7 @@ func Test_Login_Endpoints(t *testing.T) {
 
 		t.Run("MFA Challenge - New Location", func(t *testing.T) {
 			ExecDatabase(t,
-				`UPDATE auth.users SET ip_address = '', email_verified = TRUE WHERE id = $1`,
+				`UPDATE auth.users SET email_verified = TRUE WHERE id = $1`,
 				TEST_ID_PRIMARY,
 			)
 			NewTestRequest(t, "POST", "/auth/login").
@@ -97,7 +116,8 @@ func Test_Login_Endpoints(t *testing.T) {
 				}).
 				Send().
 				ExpectStatus(tools.ERROR_MFA_EMAIL_SENT.Status).
-				ExpectInteger("code", int64(tools.ERROR_MFA_EMAIL_SENT.Code))
+				ExpectInteger("code", int64(tools.ERROR_MFA_EMAIL_SENT.Code)).
+				ExpectCookie(tools.HTTP_DEVICE_COOKIE_NAME)
 
 			// Ensure Fields are Present
 			var stateToken, stateData *string
@@ -112,7 +132,23 @@ func Test_Login_Endpoints(t *testing.T) {
 			}
 		})
 
-		ResetDatabase(t, RESET_BASE, RESET_ACCOUNT, RESET_ACCOUNT_MFA)
+		t.Run("MFA Challenge - Allowed Location", func(t *testing.T) {
+			// Devices allowed by the Owner vouch for the IP Address they were last seen at
+			ExecDatabase(t,
+				`UPDATE auth.devices SET verified = TRUE WHERE user_id = $1`,
+				TEST_ID_PRIMARY,
+			)
+			NewTestRequest(t, "POST", "/auth/login").
+				WithJSON(map[string]any{
+					"email":    TEST_EMAIL_PRIMARY,
+					"password": TEST_PASSWORD_PRIMARY,
+				}).
+				Send().
+				ExpectStatus(http.StatusNoContent).
+				ExpectCookie(tools.HTTP_COOKIE_NAME)
+		})
+
+		ResetDatabase(t, RESET_BASE, RESET_ACCOUNT, RESET_ACCOUNT_MFA, RESET_DEVICE)
 
 		t.Run("MFA Challenge - TOTP: Prompt Passcode", func(t *testing.T) {
 			NewTestRequest(t, "POST", "/auth/login").
@@ -173,6 +209,91 @@ func Test_Login_Endpoints(t *testing.T) {
 				ExpectCookie(tools.HTTP_COOKIE_NAME)
 		})
 
+		t.Run("MFA Challenge - TOTP: Remember Device", func(t *testing.T) {
+			res := NewTestRequest(t, "POST", "/auth/login").
+				WithCookie(tools.HTTP_DEVICE_COOKIE_NAME, TEST_TOKEN_SECONDARY).
+				WithJSON(map[string]any{
+					"email":    TEST_EMAIL_PRIMARY,
+					"password": TEST_PASSWORD_PRIMARY,
+					"passcode": tools.GenerateTOTPCode(TEST_TOTP_SECRET, time.Now()),
+					"remember": true,
+				}).
+				Send().
+				ExpectStatus(http.StatusNoContent).
+				ExpectCookie(tools.HTTP_COOKIE_NAME).
+				ExpectCookie(tools.HTTP_DEVICE_COOKIE_NAME)
+
+			// The Cookie keeps its Token, only the Hash is stored
+			for _, header := range res.response.Header.Values("Set-Cookie") {
+				cookie, err := http.ParseSetCookie(header)
+				if err == nil && cookie.Name == tools.HTTP_DEVICE_COOKIE_NAME && cookie.Value != TEST_TOKEN_SECONDARY {
+					t.Errorf("unexpected device cookie: %s", cookie.Value)
+				}
+			}
+		})
+
+		t.Run("MFA Challenge - TOTP: Trusted Device", func(t *testing.T) {
+			NewTestRequest(t, "POST", "/auth/login").
+				WithCookie(tools.HTTP_DEVICE_COOKIE_NAME, TEST_TOKEN_SECONDARY).
+				WithJSON(map[string]any{
+					"email":    TEST_EMAIL_PRIMARY,
+					"password": TEST_PASSWORD_PRIMARY,
+				}).
+				Send().
+				ExpectStatus(http.StatusNoContent).
+				ExpectCookie(tools.HTTP_COOKIE_NAME)
+		})
+
+		t.Run("Risk Assessment - Anonymous Network", func(t *testing.T) {
+			tools.RiskNetworks = tools.NewRiskNetworkTable()
+			tools.RiskNetworks.Insert(netip.MustParsePrefix("127.0.0.0/8"), tools.RiskNetwork{ASN: "AS64500", Anonymous: true})
+			tools.RiskNetworks.Insert(netip.MustParsePrefix("::1/128"), tools.RiskNetwork{ASN: "AS64500", Anonymous: true})
+			defer func() { tools.RiskNetworks = nil }()
+			ExecDatabase(t,
+				`INSERT INTO auth.login_failures (user_id, factor, failures) VALUES ($1, $2, $3)`,
+				TEST_ID_PRIMARY, "password", tools.RISK_FAILURE_VELOCITY,
+			)
+
+			// Trusted Devices must complete MFA again
+			NewTestRequest(t, "POST", "/auth/login").
+				WithCookie(tools.HTTP_DEVICE_COOKIE_NAME, TEST_TOKEN_SECONDARY).
+				WithJSON(map[string]any{
+					"email":    TEST_EMAIL_PRIMARY,
+					"password": TEST_PASSWORD_PRIMARY,
+				}).
+				Send().
+				ExpectStatus(tools.ERROR_MFA_PASSCODE_REQUIRED.Status).
+				ExpectInteger("code", int64(tools.ERROR_MFA_PASSCODE_REQUIRED.Code))
+
+			// Decision is recorded for Audit
+			var decision string
+			var signals []string
+			QueryDatabaseRow(t,
+				"SELECT decision, signals FROM auth.login_assessments WHERE user_id = $1 ORDER BY id DESC LIMIT 1",
+				[]any{TEST_ID_PRIMARY},
+				&decision, &signals,
+			)
+			if decision != tools.RISK_MFA.String() {
+				t.Errorf("expected decision %q, got %q (%v)", tools.RISK_MFA, decision, signals)
+			}
+		})
+
+		t.Run("MFA Challenge - TOTP: Trust Expired", func(t *testing.T) {
+			ExecDatabase(t,
+				`UPDATE auth.devices SET trusted_until = NOW() - INTERVAL '1 day' WHERE id = $1`,
+				TEST_ID_PRIMARY,
+			)
+			NewTestRequest(t, "POST", "/auth/login").
+				WithCookie(tools.HTTP_DEVICE_COOKIE_NAME, TEST_TOKEN_SECONDARY).
+				WithJSON(map[string]any{
+					"email":    TEST_EMAIL_PRIMARY,
+					"password": TEST_PASSWORD_PRIMARY,
+				}).
+				Send().
+				ExpectStatus(tools.ERROR_MFA_PASSCODE_REQUIRED.Status).
+				ExpectInteger("code", int64(tools.ERROR_MFA_PASSCODE_REQUIRED.Code))
+		})
+
 		ResetDatabase(t, RESET_BASE, RESET_ACCOUNT)
 
 		t.Run("Incorrect Login - NULL Password", func(t *testing.T) {
@@ -190,6 +311,40 @@ func Test_Login_Endpoints(t *testing.T) {
 				ExpectInteger("code", int64(tools.ERROR_LOGIN_PASSWORD_RESET.Code))
 		})
 
+		ResetDatabase(t, RESET_BASE, RESET_ACCOUNT, RESET_SESSION)
+
+		t.Run("Incorrect Login - Breached Password", func(t *testing.T) {
+			tools.Breaches = tools.NewBreachFilter(1, 0.001)
+			tools.Breaches.Insert(TEST_PASSWORD_PRIMARY)
+			defer func() { tools.Breaches = nil }()
+
+			NewTestRequest(t, "POST", "/auth/login").
+				WithJSON(map[string]any{
+					"email":    TEST_EMAIL_PRIMARY,
+					"password": TEST_PASSWORD_PRIMARY,
+				}).
+				Send().
+				ExpectStatus(tools.ERROR_LOGIN_PASSWORD_RESET.Status).
+				ExpectInteger("code", int64(tools.ERROR_LOGIN_PASSWORD_RESET.Code))
+
+			// Password is cleared and the Owner sent a Reset Link
+			var flagged bool
+			QueryDatabaseRow(t,
+				`SELECT password_hash IS NULL AND password_breached IS NOT NULL AND token_reset IS NOT NULL
+				FROM auth.users WHERE id = $1`,
+				[]any{TEST_ID_PRIMARY}, &flagged,
+			)
+			if !flagged {
+				t.Fatalf("expected account to be flagged")
+			}
+
+			// Sessions are Revoked as someone else may know the Password
+			NewTestRequest(t, "GET", "/users/@me").
+				WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+				Send().
+				ExpectStatus(tools.ERROR_GENERIC_UNAUTHORIZED.Status)
+		})
+
 		ResetDatabase(t, RESET_BASE, RESET_ACCOUNT)
 
 		t.Run("Incorrect Login - Incorrect Password", func(t *testing.T) {
@@ -225,6 +380,232 @@ func Test_Login_Endpoints(t *testing.T) {
 				ExpectCookie(tools.HTTP_COOKIE_NAME)
 		})
 
+		t.Run("Login Normally - Outdated Hash is Upgraded", func(t *testing.T) {
+			legacy, err := bcrypt.GenerateFromPassword([]byte(TEST_PASSWORD_PRIMARY), bcrypt.MinCost)
+			if err != nil {
+				t.Fatalf("cannot hash pass: %s", err)
+			}
+			ExecDatabase(t, "UPDATE auth.users SET password_hash = $1 WHERE id = $2", string(legacy), TEST_ID_PRIMARY)
+
+			NewTestRequest(t, "POST", "/auth/login").
+				WithJSON(map[string]any{
+					"email":    TEST_EMAIL_PRIMARY,
+					"password": TEST_PASSWORD_PRIMARY,
+				}).
+				Send().
+				ExpectStatus(http.StatusNoContent).
+				ExpectCookie(tools.HTTP_COOKIE_NAME)
+
+			var hash string
+			QueryDatabaseRow(t, "SELECT password_hash FROM auth.users WHERE id = $1",
+				[]any{TEST_ID_PRIMARY},
+				&hash,
+			)
+			if tools.PasswordHashOutdated(hash) {
+				t.Errorf("password hash was not upgraded: %s", hash)
+			}
+		})
+
+	})
+
+	t.Run("/auth/login/email", func(t *testing.T) {
+		ResetDatabase(t, RESET_BASE, RESET_ACCOUNT)
+		ExecDatabase(t,
+			`UPDATE auth.users SET password_hash = NULL WHERE id = $1`,
+			TEST_ID_PRIMARY,
+		)
+
+		var loginToken, loginCode = TEST_TOKEN_PRIMARY, "123456"
+		requestEmail := func(t *testing.T) {
+			NewTestRequest(t, "POST", "/auth/login/email").
+				WithJSON(map[string]any{
+					"email": TEST_EMAIL_PRIMARY,
+				}).
+				Send().
+				ExpectStatus(http.StatusNoContent)
+
+			// Only Hashes are stored, so they're swapped for Values the Test knows
+			var storedToken, storedCode string
+			QueryDatabaseRow(t,
+				"SELECT token_magic, token_magic_passcode FROM auth.users WHERE id = $1",
+				[]any{TEST_ID_PRIMARY},
+				&storedToken, &storedCode,
+			)
+			if !strings.HasPrefix(storedToken, tools.TOKEN_HASH_PREFIX) || !strings.HasPrefix(storedCode, tools.TOKEN_HASH_PREFIX) {
+				t.Errorf("login tokens were stored in plaintext")
+			}
+			ExecDatabase(t,
+				"UPDATE auth.users SET token_magic = $1, token_magic_passcode = $2 WHERE id = $3",
+				tools.HashToken(loginToken), tools.HashToken(loginCode), TEST_ID_PRIMARY,
+			)
+		}
+		incorrectCode := func() string {
+			return "000000"
+		}
+
+		t.Run("Request Email - Unknown Account", func(t *testing.T) {
+			NewTestRequest(t, "POST", "/auth/login/email").
+				WithJSON(map[string]any{
+					"email": TEST_EMAIL_SECONDARY,
+				}).
+				Send().
+				ExpectStatus(http.StatusNoContent)
+		})
+
+		t.Run("Use Code (Incorrect)", func(t *testing.T) {
+			requestEmail(t)
+			NewTestRequest(t, "PATCH", "/auth/login/email").
+				WithJSON(map[string]any{
+					"email": TEST_EMAIL_PRIMARY,
+					"code":  incorrectCode(),
+				}).
+				Send().
+				ExpectStatus(tools.ERROR_LOGIN_CODE_INCORRECT.Status).
+				ExpectInteger("code", int64(tools.ERROR_LOGIN_CODE_INCORRECT.Code))
+		})
+
+		t.Run("Use Code", func(t *testing.T) {
+			NewTestRequest(t, "PATCH", "/auth/login/email").
+				WithJSON(map[string]any{
+					"email": TEST_EMAIL_PRIMARY,
+					"code":  loginCode,
+				}).
+				Send().
+				ExpectStatus(http.StatusNoContent).
+				ExpectCookie(tools.HTTP_COOKIE_NAME)
+		})
+
+		t.Run("Replay Code", func(t *testing.T) {
+			NewTestRequest(t, "PATCH", "/auth/login/email").
+				WithJSON(map[string]any{
+					"email": TEST_EMAIL_PRIMARY,
+					"code":  loginCode,
+				}).
+				Send().
+				ExpectStatus(tools.ERROR_LOGIN_CODE_INCORRECT.Status)
+		})
+
+		t.Run("Use Magic Link", func(t *testing.T) {
+			requestEmail(t)
+			NewTestRequest(t, "PATCH", "/auth/login/email").
+				WithJSON(map[string]any{
+					"token": loginToken,
+				}).
+				Send().
+				ExpectStatus(http.StatusNoContent).
+				ExpectCookie(tools.HTTP_COOKIE_NAME)
+		})
+
+		t.Run("Use Code - Escalation Code", func(t *testing.T) {
+			// Codes sent to escalate a Session can't complete a Login
+			ExecDatabase(t,
+				"UPDATE auth.users SET token_passcode = $1, token_passcode_eat = NOW() + INTERVAL '5 minutes' WHERE id = $2",
+				tools.HashToken(loginCode), TEST_ID_PRIMARY,
+			)
+			NewTestRequest(t, "PATCH", "/auth/login/email").
+				WithJSON(map[string]any{
+					"email": TEST_EMAIL_PRIMARY,
+					"code":  loginCode,
+				}).
+				Send().
+				ExpectStatus(tools.ERROR_LOGIN_CODE_INCORRECT.Status).
+				ExpectInteger("code", int64(tools.ERROR_LOGIN_CODE_INCORRECT.Code))
+		})
+
+		t.Run("Use Magic Link - New Location", func(t *testing.T) {
+			// Logins by Email are assessed like any other
+			ExecDatabase(t, "DELETE FROM auth.devices WHERE user_id = $1", TEST_ID_PRIMARY)
+			requestEmail(t)
+			NewTestRequest(t, "PATCH", "/auth/login/email").
+				WithJSON(map[string]any{
+					"token": loginToken,
+				}).
+				Send().
+				ExpectStatus(tools.ERROR_MFA_EMAIL_SENT.Status).
+				ExpectInteger("code", int64(tools.ERROR_MFA_EMAIL_SENT.Code))
+
+			var assessments int
+			QueryDatabaseRow(t, "SELECT COUNT(*) FROM auth.login_assessments WHERE user_id = $1",
+				[]any{TEST_ID_PRIMARY},
+				&assessments,
+			)
+			if assessments == 0 {
+				t.Errorf("expected login to be assessed")
+			}
+		})
+
+		t.Run("Exhaust Code", func(t *testing.T) {
+			requestEmail(t)
+			for i := 1; i < tools.PASSCODE_ATTEMPT_LIMIT; i++ {
+				NewTestRequest(t, "PATCH", "/auth/login/email").
+					WithJSON(map[string]any{
+						"email": TEST_EMAIL_PRIMARY,
+						"code":  incorrectCode(),
+					}).
+					Send().
+					ExpectStatus(tools.ERROR_LOGIN_CODE_INCORRECT.Status)
+			}
+			NewTestRequest(t, "PATCH", "/auth/login/email").
+				WithJSON(map[string]any{
+					"email": TEST_EMAIL_PRIMARY,
+					"code":  incorrectCode(),
+				}).
+				Send().
+				ExpectStatus(tools.ERROR_LOGIN_CODE_EXHAUSTED.Status).
+				ExpectInteger("code", int64(tools.ERROR_LOGIN_CODE_EXHAUSTED.Code))
+			NewTestRequest(t, "PATCH", "/auth/login/email").
+				WithJSON(map[string]any{
+					"token": loginToken,
+				}).
+				Send().
+				ExpectStatus(tools.ERROR_LOGIN_CODE_INCORRECT.Status)
+
+			// Incorrect Codes count towards a Lockout
+			var lockouts int
+			QueryDatabaseRow(t, "SELECT lockout_count FROM auth.users WHERE id = $1",
+				[]any{TEST_ID_PRIMARY},
+				&lockouts,
+			)
+			if lockouts != 1 {
+				t.Errorf("expected account to be locked, got %d lockouts", lockouts)
+			}
+		})
+
+		t.Run("Use Magic Link - Account Locked", func(t *testing.T) {
+			ExecDatabase(t, "UPDATE auth.users SET lockout_until = NOW() + INTERVAL '1 hour' WHERE id = $1", TEST_ID_PRIMARY)
+			requestEmail(t)
+			NewTestRequest(t, "PATCH", "/auth/login/email").
+				WithJSON(map[string]any{
+					"token": loginToken,
+				}).
+				Send().
+				ExpectStatus(tools.ERROR_LOGIN_ACCOUNT_LOCKED.Status).
+				ExpectInteger("code", int64(tools.ERROR_LOGIN_ACCOUNT_LOCKED.Code))
+		})
+
+		ResetDatabase(t, RESET_BASE, RESET_ACCOUNT, RESET_ACCOUNT_MFA)
+
+		t.Run("MFA Challenge - TOTP: Prompt Passcode", func(t *testing.T) {
+			requestEmail(t)
+			NewTestRequest(t, "PATCH", "/auth/login/email").
+				WithJSON(map[string]any{
+					"token": loginToken,
+				}).
+				Send().
+				ExpectStatus(tools.ERROR_MFA_PASSCODE_REQUIRED.Status).
+				ExpectInteger("code", int64(tools.ERROR_MFA_PASSCODE_REQUIRED.Code))
+		})
+
+		t.Run("MFA Challenge - TOTP: Use Passcode", func(t *testing.T) {
+			NewTestRequest(t, "PATCH", "/auth/login/email").
+				WithJSON(map[string]any{
+					"token":    loginToken,
+					"passcode": tools.GenerateTOTPCode(TEST_TOTP_SECRET, time.Now()),
+				}).
+				Send().
+				ExpectStatus(http.StatusNoContent).
+				ExpectCookie(tools.HTTP_COOKIE_NAME)
+		})
 	})
 
 	t.Run("/auth/logout", func(t *testing.T) {
@@ -266,9 +647,30 @@ func Test_Login_Endpoints(t *testing.T) {
 				[]any{TEST_ID_PRIMARY},
 				&stateToken,
 			)
-			if stateToken == nil {
-				t.Errorf("reset token was not set")
+			if stateToken == nil || !strings.HasPrefix(*stateToken, tools.TOKEN_HASH_PREFIX) {
+				t.Fatalf("reset token was not set")
 			}
+
+			// Only the Hash is stored, so it's swapped for a Token the Test knows
+			ExecDatabase(t, "UPDATE auth.users SET token_reset = $1 WHERE id = $2", TEST_TOKEN_PRIMARY_HASH, TEST_ID_PRIMARY)
+			stateToken = &TEST_TOKEN_PRIMARY
+		})
+
+		t.Run("PATCH: Reuse Password from History", func(t *testing.T) {
+			previous, err := tools.PasswordHashers["bcrypt"].Hash(TEST_PASSWORD_SECONDARY)
+			if err != nil {
+				t.Fatalf("cannot hash pass: %s", err)
+			}
+			ExecDatabase(t, "UPDATE auth.users SET password_history = $1 WHERE id = $2", []string{previous}, TEST_ID_PRIMARY)
+
+			NewTestRequest(t, "PATCH", "/auth/password-reset").
+				WithJSON(map[string]any{
+					"password": TEST_PASSWORD_SECONDARY,
+					"token":    stateToken,
+				}).
+				Send().
+				ExpectStatus(tools.ERROR_LOGIN_PASSWORD_ALREADY_USED.Status).
+				ExpectInteger("code", int64(tools.ERROR_LOGIN_PASSWORD_ALREADY_USED.Code))
 		})
 
 		t.Run("PATCH: Update Password using Token", func(t *testing.T) {
@@ -291,7 +693,7 @@ func Test_Login_Endpoints(t *testing.T) {
 	})
 
 	t.Run("/auth/verify-login", func(t *testing.T) {
-		ResetDatabase(t, RESET_BASE, RESET_ACCOUNT, RESET_ACCOUNT_TOKENS)
+		ResetDatabase(t, RESET_BASE, RESET_ACCOUNT, RESET_ACCOUNT_TOKENS, RESET_DEVICE)
 
 		t.Run("Use Invalid Token", func(t *testing.T) {
 			NewTestRequest(t, "POST", "/auth/verify-login").
@@ -319,13 +721,107 @@ func Test_Login_Endpoints(t *testing.T) {
 				Send().
 				ExpectStatus(http.StatusNoContent)
 
-			var stateAddress string
-			QueryDatabaseRow(t, "SELECT ip_address FROM auth.users WHERE id = $1",
+			var stateVerified bool
+			QueryDatabaseRow(t, "SELECT verified FROM auth.devices WHERE id = $1",
+				[]any{TEST_ID_PRIMARY},
+				&stateVerified,
+			)
+			if !stateVerified {
+				t.Errorf("device was not allowed")
+			}
+		})
+	})
+
+	t.Run("/auth/unlock", func(t *testing.T) {
+		ResetDatabase(t, RESET_BASE, RESET_ACCOUNT)
+
+		t.Run("Lock Account - Incorrect Password", func(t *testing.T) {
+			for range tools.LOCKOUT_PASSWORD_LIMIT {
+				NewTestRequest(t, "POST", "/auth/login").
+					WithJSON(map[string]any{
+						"email":    TEST_EMAIL_PRIMARY,
+						"password": TEST_PASSWORD_SECONDARY,
+					}).
+					Send().
+					ExpectStatus(tools.ERROR_LOGIN_INCORRECT.Status).
+					ExpectInteger("code", int64(tools.ERROR_LOGIN_INCORRECT.Code))
+			}
+
+			// Correct Password is refused while Locked
+			var retryAfter string
+			NewTestRequest(t, "POST", "/auth/login").
+				WithJSON(map[string]any{
+					"email":    TEST_EMAIL_PRIMARY,
+					"password": TEST_PASSWORD_PRIMARY,
+				}).
+				Send().
+				ExpectStatus(tools.ERROR_LOGIN_ACCOUNT_LOCKED.Status).
+				ExpectInteger("code", int64(tools.ERROR_LOGIN_ACCOUNT_LOCKED.Code)).
+				ExpectHeader("Retry-After", &retryAfter)
+		})
+
+		t.Run("Use Invalid Token", func(t *testing.T) {
+			NewTestRequest(t, "POST", "/auth/unlock").
+				WithQuery(map[string]any{"token": TEST_TOKEN_INVALID}).
+				Send().
+				ExpectStatus(http.StatusBadRequest)
+		})
+
+		t.Run("Use Incorrect Token", func(t *testing.T) {
+			NewTestRequest(t, "POST", "/auth/unlock").
+				WithQuery(map[string]any{"token": TEST_TOKEN_SECONDARY}).
+				Send().
+				ExpectStatus(http.StatusNotFound)
+		})
+
+		t.Run("Use Correct Token", func(t *testing.T) {
+			ExecDatabase(t, "UPDATE auth.users SET token_unlock = $1 WHERE id = $2", TEST_TOKEN_PRIMARY_HASH, TEST_ID_PRIMARY)
+			NewTestRequest(t, "POST", "/auth/unlock").
+				WithQuery(map[string]any{"token": TEST_TOKEN_PRIMARY}).
+				Send().
+				ExpectStatus(http.StatusNoContent)
+			NewTestRequest(t, "POST", "/auth/login").
+				WithJSON(map[string]any{
+					"email":    TEST_EMAIL_PRIMARY,
+					"password": TEST_PASSWORD_PRIMARY,
+				}).
+				Send().
+				ExpectStatus(http.StatusNoContent).
+				ExpectCookie(tools.HTTP_COOKIE_NAME)
+
+			// Backoff is reset by a successful Login
+			var lockouts int
+			QueryDatabaseRow(t, "SELECT lockout_count FROM auth.users WHERE id = $1",
+				[]any{TEST_ID_PRIMARY},
+				&lockouts,
+			)
+			if lockouts != 0 {
+				t.Errorf("expected backoff to be reset, got %d lockouts", lockouts)
+			}
+		})
+
+		t.Run("Lock Account - Backoff Doubles", func(t *testing.T) {
+			ExecDatabase(t,
+				"UPDATE auth.users SET lockout_count = 2 WHERE id = $1",
+				TEST_ID_PRIMARY,
+			)
+			for range tools.LOCKOUT_PASSWORD_LIMIT {
+				NewTestRequest(t, "POST", "/auth/login").
+					WithJSON(map[string]any{
+						"email":    TEST_EMAIL_PRIMARY,
+						"password": TEST_PASSWORD_SECONDARY,
+					}).
+					Send()
+			}
+			var seconds float64
+			QueryDatabaseRow(t,
+				"SELECT EXTRACT(EPOCH FROM lockout_until - CURRENT_TIMESTAMP) FROM auth.users WHERE id = $1",
 				[]any{TEST_ID_PRIMARY},
-				&stateAddress,
+				&seconds,
 			)
-			if !strings.EqualFold(stateAddress, TEST_IP_ADDRESS) {
-				t.Errorf("ip address is incorrect: got %s, want %s", stateAddress, TEST_IP_ADDRESS)
+			expected := 4 * tools.LOCKOUT_DURATION.Seconds()
+			if seconds > expected || seconds < expected-60 {
+				t.Errorf("expected lockout of %.0fs, got %.0fs", expected, seconds)
 			}
 		})
 	})
@@ -0,0 +1,670 @@
+package tests
+
+import (
+	"crypto"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+func Test_OAuth2_Endpoints(t *testing.T) {
+
+	t.Run("/oauth2/token (PKCE)", func(t *testing.T) {
+		ResetDatabase(t,
+			RESET_BASE, RESET_ACCOUNT, RESET_SESSION,
+			RESET_APPLICATION, RESET_APPLICATION_CUSTOMIZED, RESET_APPLICATION_PUBLIC,
+		)
+
+		// Authorize Application and return Grant Code
+		authorize := func(t *testing.T, challenge string) string {
+			var location string
+			NewTestRequest(t, "POST", "/oauth2/authorize").
+				WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+				WithQuery(map[string]any{
+					"client_id":             TEST_ID_PRIMARY,
+					"response_type":         "code",
+					"redirect_uri":          TEST_REDIRECT_URI_PRIMARY,
+					"scope":                 tools.SCOPE_READ_IDENTIFY.Name,
+					"code_challenge":        challenge,
+					"code_challenge_method": tools.PKCE_METHOD_S256,
+				}).
+				Send().
+				ExpectStatus(http.StatusFound).
+				ExpectHeader("Location", &location)
+
+			parsed, err := url.Parse(location)
+			if err != nil {
+				t.Fatalf("invalid redirect location: %s", err)
+			}
+			code := parsed.Query().Get("code")
+			if code == "" {
+				t.Fatalf("redirect location is missing code")
+			}
+			return code
+		}
+
+		t.Run("Authorize - Public Client without Challenge", func(t *testing.T) {
+			NewTestRequest(t, "GET", "/oauth2/authorize").
+				WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+				WithQuery(map[string]any{
+					"client_id":     TEST_ID_PRIMARY,
+					"response_type": "code",
+					"redirect_uri":  TEST_REDIRECT_URI_PRIMARY,
+					"scope":         tools.SCOPE_READ_IDENTIFY.Name,
+				}).
+				Send().
+				ExpectStatus(tools.ERROR_OAUTH2_FORM_INVALID_CHALLENGE.Status).
+				ExpectInteger("code", int64(tools.ERROR_OAUTH2_FORM_INVALID_CHALLENGE.Code))
+		})
+
+		t.Run("Authorize - Invalid Challenge", func(t *testing.T) {
+			NewTestRequest(t, "GET", "/oauth2/authorize").
+				WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+				WithQuery(map[string]any{
+					"client_id":      TEST_ID_PRIMARY,
+					"response_type":  "code",
+					"redirect_uri":   TEST_REDIRECT_URI_PRIMARY,
+					"scope":          tools.SCOPE_READ_IDENTIFY.Name,
+					"code_challenge": TEST_PKCE_VERIFIER_INVALID,
+				}).
+				Send().
+				ExpectStatus(tools.ERROR_OAUTH2_FORM_INVALID_CHALLENGE.Status).
+				ExpectInteger("code", int64(tools.ERROR_OAUTH2_FORM_INVALID_CHALLENGE.Code))
+		})
+
+		t.Run("Exchange - Incorrect Verifier", func(t *testing.T) {
+			NewTestRequest(t, "POST", "/oauth2/token").
+				WithQuery(map[string]any{
+					"grant_type":    "authorization_code",
+					"client_id":     TEST_ID_PRIMARY,
+					"redirect_uri":  TEST_REDIRECT_URI_PRIMARY,
+					"code":          authorize(t, TEST_PKCE_CHALLENGE_PRIMARY),
+					"code_verifier": TEST_PKCE_VERIFIER_SECONDARY,
+				}).
+				Send().
+				ExpectStatus(tools.ERROR_OAUTH2_FORM_INVALID_VERIFIER.Status).
+				ExpectInteger("code", int64(tools.ERROR_OAUTH2_FORM_INVALID_VERIFIER.Code))
+		})
+
+		t.Run("Exchange - Missing Verifier", func(t *testing.T) {
+			NewTestRequest(t, "POST", "/oauth2/token").
+				WithQuery(map[string]any{
+					"grant_type":   "authorization_code",
+					"client_id":    TEST_ID_PRIMARY,
+					"redirect_uri": TEST_REDIRECT_URI_PRIMARY,
+					"code":         authorize(t, TEST_PKCE_CHALLENGE_PRIMARY),
+				}).
+				Send().
+				ExpectStatus(tools.ERROR_OAUTH2_FORM_INVALID_VERIFIER.Status).
+				ExpectInteger("code", int64(tools.ERROR_OAUTH2_FORM_INVALID_VERIFIER.Code))
+		})
+
+		t.Run("Exchange Normally", func(t *testing.T) {
+			NewTestRequest(t, "POST", "/oauth2/token").
+				WithQuery(map[string]any{
+					"grant_type":    "authorization_code",
+					"client_id":     TEST_ID_PRIMARY,
+					"redirect_uri":  TEST_REDIRECT_URI_PRIMARY,
+					"code":          authorize(t, TEST_PKCE_CHALLENGE_PRIMARY),
+					"code_verifier": TEST_PKCE_VERIFIER_PRIMARY,
+				}).
+				Send().
+				ExpectStatus(http.StatusOK).
+				ExpectField("access_token").
+				ExpectField("refresh_token").
+				ExpectString("scopes", tools.SCOPE_READ_IDENTIFY.Name)
+		})
+	})
+
+	t.Run("/oauth2/userinfo (OpenID)", func(t *testing.T) {
+		ResetDatabase(t,
+			RESET_BASE, RESET_ACCOUNT, RESET_PROFILE, RESET_SESSION,
+			RESET_APPLICATION, RESET_APPLICATION_CUSTOMIZED, RESET_APPLICATION_PUBLIC,
+		)
+		var accessToken, idToken string
+
+		t.Run("Discovery Document", func(t *testing.T) {
+			NewTestRequest(t, "GET", "/.well-known/openid-configuration").
+				Send().
+				ExpectStatus(http.StatusOK).
+				ExpectString("issuer", tools.OIDC_ISSUER).
+				ExpectString("authorization_endpoint", tools.EMAIL_DEFAULT_HOST+"/oauth2/authorize").
+				ExpectString("token_endpoint", tools.OIDC_ISSUER+"/oauth2/token").
+				ExpectString("jwks_uri", tools.OIDC_ISSUER+"/oauth2/jwks")
+		})
+
+		t.Run("Exchange with OpenID Scope", func(t *testing.T) {
+			var location string
+			NewTestRequest(t, "POST", "/oauth2/authorize").
+				WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+				WithQuery(map[string]any{
+					"client_id":      TEST_ID_PRIMARY,
+					"response_type":  "code",
+					"redirect_uri":   TEST_REDIRECT_URI_PRIMARY,
+					"scope":          "openid email",
+					"nonce":          TEST_TOKEN_SECONDARY,
+					"code_challenge": TEST_PKCE_VERIFIER_PRIMARY,
+				}).
+				Send().
+				ExpectStatus(http.StatusFound).
+				ExpectHeader("Location", &location)
+			parsed, _ := url.Parse(location)
+
+			res := NewTestRequest(t, "POST", "/oauth2/token").
+				WithQuery(map[string]any{
+					"grant_type":    "authorization_code",
+					"client_id":     TEST_ID_PRIMARY,
+					"redirect_uri":  TEST_REDIRECT_URI_PRIMARY,
+					"code":          parsed.Query().Get("code"),
+					"code_verifier": TEST_PKCE_VERIFIER_PRIMARY,
+				}).
+				Send().
+				ExpectStatus(http.StatusOK).
+				ExpectField("id_token")
+			accessToken, _ = res.responseJSON["access_token"].(string)
+			idToken, _ = res.responseJSON["id_token"].(string)
+		})
+
+		t.Run("Verify ID Token using JWKS", func(t *testing.T) {
+			res := NewTestRequest(t, "GET", "/oauth2/jwks").
+				Send().
+				ExpectStatus(http.StatusOK).
+				ExpectBody()
+			var keyset struct{ Keys []tools.JWK }
+			if err := json.Unmarshal(res.responseBody, &keyset); err != nil {
+				t.Fatalf("invalid key set: %s", err)
+			}
+			claims, err := tools.CompareJWT(idToken, func(kid string) crypto.PublicKey {
+				for _, jwk := range keyset.Keys {
+					if jwk.KeyID == kid {
+						key, _ := tools.ParseJWK(jwk)
+						return key
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("id token verification failed: %s", err)
+			}
+			if claims["sub"] != strconv.FormatInt(TEST_ID_PRIMARY, 10) ||
+				claims["aud"] != strconv.FormatInt(TEST_ID_PRIMARY, 10) ||
+				claims["nonce"] != TEST_TOKEN_SECONDARY ||
+				claims["email"] != TEST_EMAIL_PRIMARY {
+				t.Errorf("id token has unexpected claims: %v", claims)
+			}
+		})
+
+		t.Run("Fetch User Info", func(t *testing.T) {
+			NewTestRequest(t, "GET", "/oauth2/userinfo").
+				WithHeader("Authorization", tools.TOKEN_PREFIX_BEARER+" "+accessToken).
+				Send().
+				ExpectStatus(http.StatusOK).
+				ExpectString("sub", strconv.FormatInt(TEST_ID_PRIMARY, 10)).
+				ExpectString("email", TEST_EMAIL_PRIMARY)
+		})
+	})
+
+	t.Run("/oauth2/token/introspect", func(t *testing.T) {
+		ResetDatabase(t,
+			RESET_BASE, RESET_ACCOUNT, RESET_APPLICATION,
+			RESET_CONNECTION, RESET_CONNECTION_SCOPE_READ_IDENTIFY,
+		)
+		clientID := strconv.FormatInt(TEST_ID_PRIMARY, 10)
+
+		t.Run("Incorrect Secret", func(t *testing.T) {
+			NewTestRequest(t, "POST", "/oauth2/token/introspect").
+				WithBasicAuth(clientID, TEST_TOKEN_INVALID).
+				WithQuery(map[string]any{"token": TEST_TOKEN_PRIMARY}).
+				Send().
+				ExpectStatus(tools.ERROR_GENERIC_UNAUTHORIZED.Status)
+		})
+
+		t.Run("Unknown Token", func(t *testing.T) {
+			NewTestRequest(t, "POST", "/oauth2/token/introspect").
+				WithBasicAuth(clientID, TEST_SECRET_PRIMARY).
+				WithQuery(map[string]any{"token": TEST_TOKEN_INVALID}).
+				Send().
+				ExpectStatus(http.StatusOK).
+				ExpectBoolean("active", false)
+		})
+
+		t.Run("Access Token", func(t *testing.T) {
+			NewTestRequest(t, "POST", "/oauth2/token/introspect").
+				WithBasicAuth(clientID, TEST_SECRET_PRIMARY).
+				WithQuery(map[string]any{"token": TEST_TOKEN_PRIMARY}).
+				Send().
+				ExpectStatus(http.StatusOK).
+				ExpectString("scope", tools.SCOPE_READ_IDENTIFY.Name).
+				ExpectString("client_id", clientID).
+				ExpectString("sub", clientID).
+				ExpectField("exp").
+				ExpectField("iat").
+				ExpectBoolean("active", true)
+		})
+
+		t.Run("Refresh Token", func(t *testing.T) {
+			NewTestRequest(t, "POST", "/oauth2/token/introspect").
+				WithBasicAuth(clientID, TEST_SECRET_PRIMARY).
+				WithQuery(map[string]any{"token": TEST_TOKEN_SECONDARY}).
+				Send().
+				ExpectStatus(http.StatusOK).
+				ExpectString("sub", clientID).
+				ExpectField("iat").
+				ExpectBoolean("active", true)
+		})
+
+		t.Run("Revoked Token", func(t *testing.T) {
+			ResetDatabase(t, RESET_CONNECTION_REVOKED)
+			NewTestRequest(t, "POST", "/oauth2/token/introspect").
+				WithBasicAuth(clientID, TEST_SECRET_PRIMARY).
+				WithQuery(map[string]any{"token": TEST_TOKEN_PRIMARY}).
+				Send().
+				ExpectStatus(http.StatusOK).
+				ExpectBoolean("active", false)
+		})
+	})
+
+	t.Run("/oauth2/token (Client Credentials)", func(t *testing.T) {
+		ResetDatabase(t,
+			RESET_BASE, RESET_ACCOUNT, RESET_PROFILE, RESET_SESSION,
+			RESET_APPLICATION, RESET_APPLICATION_CUSTOMIZED, RESET_APPLICATION_MACHINE,
+		)
+		clientID := strconv.FormatInt(TEST_ID_PRIMARY, 10)
+		var accessToken string
+
+		t.Run("Authorize - User cannot grant Machine Scope", func(t *testing.T) {
+			NewTestRequest(t, "GET", "/oauth2/authorize").
+				WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+				WithQuery(map[string]any{
+					"client_id":     TEST_ID_PRIMARY,
+					"response_type": "code",
+					"redirect_uri":  TEST_REDIRECT_URI_PRIMARY,
+					"scope":         tools.SCOPE_READ_PROFILES.Name,
+				}).
+				Send().
+				ExpectStatus(tools.ERROR_OAUTH2_FORM_INVALID_SCOPE.Status).
+				ExpectInteger("code", int64(tools.ERROR_OAUTH2_FORM_INVALID_SCOPE.Code))
+		})
+
+		t.Run("Edit Application - Owner cannot grant Operator Scope", func(t *testing.T) {
+			NewTestRequest(t, "PATCH", "/users/@me/applications/%d", TEST_ID_PRIMARY).
+				WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+				WithJSON(map[string]any{
+					"scopes": tools.SCOPE_READ_PROFILES.Name + " " + tools.SCOPE_SCIM.Name,
+				}).
+				Send().
+				ExpectStatus(tools.ERROR_OAUTH2_FORM_INVALID_SCOPE.Status).
+				ExpectInteger("code", int64(tools.ERROR_OAUTH2_FORM_INVALID_SCOPE.Code))
+		})
+
+		t.Run("Exchange - Missing Secret", func(t *testing.T) {
+			NewTestRequest(t, "POST", "/oauth2/token").
+				WithQuery(map[string]any{
+					"grant_type": "client_credentials",
+					"client_id":  TEST_ID_PRIMARY,
+				}).
+				Send().
+				ExpectStatus(tools.ERROR_GENERIC_UNAUTHORIZED.Status)
+		})
+
+		t.Run("Exchange - User Scope", func(t *testing.T) {
+			NewTestRequest(t, "POST", "/oauth2/token").
+				WithBasicAuth(clientID, TEST_SECRET_PRIMARY).
+				WithQuery(map[string]any{
+					"grant_type": "client_credentials",
+					"scope":      tools.SCOPE_READ_IDENTIFY.Name,
+				}).
+				Send().
+				ExpectStatus(tools.ERROR_OAUTH2_FORM_INVALID_SCOPE.Status).
+				ExpectInteger("code", int64(tools.ERROR_OAUTH2_FORM_INVALID_SCOPE.Code))
+		})
+
+		t.Run("Exchange Normally", func(t *testing.T) {
+			res := NewTestRequest(t, "POST", "/oauth2/token").
+				WithBasicAuth(clientID, TEST_SECRET_PRIMARY).
+				WithQuery(map[string]any{
+					"grant_type": "client_credentials",
+					"scope":      tools.SCOPE_READ_PROFILES.Name,
+				}).
+				Send().
+				ExpectStatus(http.StatusOK).
+				ExpectField("access_token").
+				ExpectString("scopes", tools.SCOPE_READ_PROFILES.Name)
+			if _, ok := res.responseJSON["refresh_token"]; ok {
+				t.Errorf("unexpected refresh token for client credentials")
+			}
+			accessToken, _ = res.responseJSON["access_token"].(string)
+		})
+
+		t.Run("Fetch Profile as Application", func(t *testing.T) {
+			NewTestRequest(t, "GET", "/users/%d", TEST_ID_PRIMARY).
+				WithHeader("Authorization", tools.TOKEN_PREFIX_BEARER+" "+accessToken).
+				Send().
+				ExpectStatus(http.StatusOK).
+				ExpectString("username", TEST_USERNAME_PRIMARY)
+		})
+
+		t.Run("Fetch Account as Application", func(t *testing.T) {
+			NewTestRequest(t, "GET", "/users/@me").
+				WithHeader("Authorization", tools.TOKEN_PREFIX_BEARER+" "+accessToken).
+				Send().
+				ExpectStatus(tools.ERROR_OAUTH2_SCOPE_REQUIRED.Status).
+				ExpectInteger("code", int64(tools.ERROR_OAUTH2_SCOPE_REQUIRED.Code))
+		})
+
+		t.Run("Introspect Token", func(t *testing.T) {
+			NewTestRequest(t, "POST", "/oauth2/token/introspect").
+				WithBasicAuth(clientID, TEST_SECRET_PRIMARY).
+				WithQuery(map[string]any{"token": accessToken}).
+				Send().
+				ExpectStatus(http.StatusOK).
+				ExpectString("sub", clientID).
+				ExpectBoolean("active", true)
+		})
+	})
+
+	t.Run("/oauth2/device (Device Authorization)", func(t *testing.T) {
+		ResetDatabase(t,
+			RESET_BASE, RESET_ACCOUNT, RESET_PROFILE, RESET_SESSION,
+			RESET_APPLICATION, RESET_APPLICATION_PUBLIC,
+		)
+
+		// Request Device Code and return both Codes
+		request := func(t *testing.T) (string, string) {
+			res := NewTestRequest(t, "POST", "/oauth2/device/code").
+				WithQuery(map[string]any{
+					"client_id": TEST_ID_PRIMARY,
+					"scope":     tools.SCOPE_READ_IDENTIFY.Name,
+				}).
+				Send().
+				ExpectStatus(http.StatusOK).
+				ExpectField("device_code").
+				ExpectField("user_code").
+				ExpectField("verification_uri").
+				ExpectInteger("interval", int64(tools.OAUTH2_DEVICE_INTERVAL.Seconds()))
+			deviceCode, _ := res.responseJSON["device_code"].(string)
+			userCode, _ := res.responseJSON["user_code"].(string)
+			return deviceCode, userCode
+		}
+
+		// Poll Token Endpoint using Device Code
+		poll := func(t *testing.T, deviceCode string) *testRequest {
+			return NewTestRequest(t, "POST", "/oauth2/token").
+				WithQuery(map[string]any{
+					"grant_type":  "urn:ietf:params:oauth:grant-type:device_code",
+					"client_id":   TEST_ID_PRIMARY,
+					"device_code": deviceCode,
+				}).
+				Send()
+		}
+
+		t.Run("Approve Device", func(t *testing.T) {
+			deviceCode, userCode := request(t)
+
+			poll(t, deviceCode).
+				ExpectStatus(tools.ERROR_OAUTH2_DEVICE_PENDING.Status).
+				ExpectString("error", tools.ERROR_OAUTH2_DEVICE_PENDING.Reason)
+			poll(t, deviceCode).
+				ExpectStatus(tools.ERROR_OAUTH2_DEVICE_SLOW_DOWN.Status).
+				ExpectString("error", tools.ERROR_OAUTH2_DEVICE_SLOW_DOWN.Reason)
+
+			NewTestRequest(t, "GET", "/oauth2/device").
+				WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+				WithQuery(map[string]any{"user_code": strings.ToLower(userCode)}).
+				Send().
+				ExpectStatus(http.StatusOK).
+				ExpectString("user_code", userCode).
+				ExpectInteger("scopes", int64(tools.SCOPE_READ_IDENTIFY.Flag))
+			NewTestRequest(t, "POST", "/oauth2/device").
+				WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+				WithQuery(map[string]any{"user_code": userCode}).
+				Send().
+				ExpectStatus(http.StatusNoContent)
+
+			ExecDatabase(t, "UPDATE auth.grants SET polled = NULL WHERE user_code = $1", userCode)
+			poll(t, deviceCode).
+				ExpectStatus(http.StatusOK).
+				ExpectField("access_token").
+				ExpectField("refresh_token").
+				ExpectString("scopes", tools.SCOPE_READ_IDENTIFY.Name)
+			poll(t, deviceCode).
+				ExpectStatus(tools.ERROR_OAUTH2_FORM_INVALID_DEVICE_CODE.Status).
+				ExpectString("error", tools.ERROR_OAUTH2_FORM_INVALID_DEVICE_CODE.Reason)
+		})
+
+		t.Run("Deny Device", func(t *testing.T) {
+			deviceCode, userCode := request(t)
+			NewTestRequest(t, "DELETE", "/oauth2/device").
+				WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+				WithQuery(map[string]any{"user_code": userCode}).
+				Send().
+				ExpectStatus(http.StatusNoContent)
+			poll(t, deviceCode).
+				ExpectStatus(tools.ERROR_OAUTH2_DEVICE_DENIED.Status).
+				ExpectString("error", tools.ERROR_OAUTH2_DEVICE_DENIED.Reason)
+		})
+
+		t.Run("Expired Device Code", func(t *testing.T) {
+			deviceCode, userCode := request(t)
+			ExecDatabase(t, "UPDATE auth.grants SET expires = $1 WHERE user_code = $2", TEST_TOKEN_EXPIRES_PAST, userCode)
+			NewTestRequest(t, "POST", "/oauth2/device").
+				WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+				WithQuery(map[string]any{"user_code": userCode}).
+				Send().
+				ExpectStatus(tools.ERROR_OAUTH2_FORM_INVALID_USER_CODE.Status).
+				ExpectInteger("code", int64(tools.ERROR_OAUTH2_FORM_INVALID_USER_CODE.Code))
+			poll(t, deviceCode).
+				ExpectStatus(tools.ERROR_OAUTH2_DEVICE_EXPIRED.Status).
+				ExpectString("error", tools.ERROR_OAUTH2_DEVICE_EXPIRED.Reason)
+		})
+	})
+
+	t.Run("/oauth2/authorize (Consent)", func(t *testing.T) {
+		ResetDatabase(t,
+			RESET_BASE, RESET_ACCOUNT, RESET_PROFILE, RESET_SESSION, RESET_SESSION_ELEVATED,
+			RESET_APPLICATION, RESET_APPLICATION_CUSTOMIZED, RESET_APPLICATION_PUBLIC,
+		)
+
+		// Request Authorization for the given Scopes
+		authorize := func(t *testing.T, method, scopes, prompt string) *testRequest {
+			return NewTestRequest(t, method, "/oauth2/authorize").
+				WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+				WithQuery(map[string]any{
+					"client_id":      TEST_ID_PRIMARY,
+					"response_type":  "code",
+					"redirect_uri":   TEST_REDIRECT_URI_PRIMARY,
+					"scope":          scopes,
+					"prompt":         prompt,
+					"code_challenge": TEST_PKCE_VERIFIER_PRIMARY,
+				}).
+				Send()
+		}
+		bothScopes := tools.SCOPE_READ_IDENTIFY.Name + " " + tools.SCOPE_READ_EMAIL.Name
+
+		t.Run("Prompt before Approval", func(t *testing.T) {
+			authorize(t, "GET", bothScopes, "").
+				ExpectStatus(http.StatusOK).
+				ExpectBoolean("consented", false)
+			authorize(t, "GET", bothScopes, "none").
+				ExpectStatus(tools.ERROR_OAUTH2_CONSENT_REQUIRED.Status).
+				ExpectString("error", tools.ERROR_OAUTH2_CONSENT_REQUIRED.Reason)
+		})
+
+		t.Run("Prompt carries Nonce", func(t *testing.T) {
+			NewTestRequest(t, "GET", "/oauth2/authorize").
+				WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+				WithQuery(map[string]any{
+					"client_id":      TEST_ID_PRIMARY,
+					"response_type":  "code",
+					"redirect_uri":   TEST_REDIRECT_URI_PRIMARY,
+					"scope":          bothScopes,
+					"code_challenge": TEST_PKCE_VERIFIER_PRIMARY,
+					"nonce":          TEST_TOKEN_SECONDARY,
+				}).
+				Send().
+				ExpectStatus(http.StatusOK).
+				ExpectString("nonce", TEST_TOKEN_SECONDARY)
+		})
+
+		t.Run("Skip Prompt after Approval", func(t *testing.T) {
+			var location string
+			authorize(t, "POST", bothScopes, "").
+				ExpectStatus(http.StatusFound)
+			authorize(t, "GET", tools.SCOPE_READ_IDENTIFY.Name, "").
+				ExpectStatus(http.StatusOK).
+				ExpectBoolean("consented", true)
+			authorize(t, "GET", bothScopes, "none").
+				ExpectStatus(http.StatusOK).
+				ExpectBoolean("consented", true)
+			authorize(t, "GET", bothScopes, "consent").
+				ExpectStatus(http.StatusOK).
+				ExpectBoolean("consented", false)
+
+			// Codes are only issued by the POST Endpoint
+			var count int
+			QueryDatabaseRow(t, "SELECT COUNT(*) FROM auth.grants", []any{}, &count)
+			if count != 1 {
+				t.Fatalf("expected 1 grant, got %d", count)
+			}
+			authorize(t, "POST", tools.SCOPE_READ_IDENTIFY.Name, "").
+				ExpectStatus(http.StatusFound).
+				ExpectHeader("Location", &location)
+
+			// Exchange Grant so a Connection exists
+			parsed, _ := url.Parse(location)
+			NewTestRequest(t, "POST", "/oauth2/token").
+				WithQuery(map[string]any{
+					"grant_type":    "authorization_code",
+					"client_id":     TEST_ID_PRIMARY,
+					"redirect_uri":  TEST_REDIRECT_URI_PRIMARY,
+					"code":          parsed.Query().Get("code"),
+					"code_verifier": TEST_PKCE_VERIFIER_PRIMARY,
+				}).
+				Send().
+				ExpectStatus(http.StatusOK)
+		})
+
+		t.Run("Revoke Individual Scope", func(t *testing.T) {
+			var connectionID int64
+			QueryDatabaseRow(t,
+				"SELECT id FROM auth.connections WHERE user_id = $1",
+				[]any{TEST_ID_PRIMARY},
+				&connectionID,
+			)
+			NewTestRequest(t, "DELETE", "/users/@me/connections/%d/scopes/%s", connectionID, tools.SCOPE_READ_EMAIL.Name).
+				WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+				Send().
+				ExpectStatus(http.StatusOK).
+				ExpectInteger("scopes", int64(tools.SCOPE_READ_IDENTIFY.Flag))
+
+			authorize(t, "GET", tools.SCOPE_READ_IDENTIFY.Name, "").
+				ExpectStatus(http.StatusOK).
+				ExpectBoolean("consented", true)
+			authorize(t, "GET", bothScopes, "").
+				ExpectStatus(http.StatusOK).
+				ExpectBoolean("consented", false)
+		})
+	})
+	t.Run("/users/@me (Scopes)", func(t *testing.T) {
+		ResetDatabase(t,
+			RESET_BASE, RESET_ACCOUNT, RESET_APPLICATION,
+			RESET_CONNECTION, RESET_CONNECTION_SCOPE_READ_IDENTIFY,
+		)
+		bearer := tools.TOKEN_PREFIX_BEARER + " " + TEST_TOKEN_PRIMARY
+
+		t.Run("Read Account with Scope", func(t *testing.T) {
+			NewTestRequest(t, "GET", "/users/@me").
+				WithHeader("Authorization", bearer).
+				Send().
+				ExpectStatus(http.StatusOK).
+				ExpectString("username", TEST_USERNAME_PRIMARY)
+		})
+
+		t.Run("Edit Profile without Scope", func(t *testing.T) {
+			NewTestRequest(t, "PATCH", "/users/@me").
+				WithHeader("Authorization", bearer).
+				WithJSON(map[string]any{"displayname": TEST_DISPLAYNAME_SECONDARY}).
+				Send().
+				ExpectStatus(tools.ERROR_OAUTH2_SCOPE_REQUIRED.Status).
+				ExpectInteger("code", int64(tools.ERROR_OAUTH2_SCOPE_REQUIRED.Code))
+		})
+
+		t.Run("List Connections without Scope", func(t *testing.T) {
+			NewTestRequest(t, "GET", "/users/@me/connections").
+				WithHeader("Authorization", bearer).
+				Send().
+				ExpectStatus(tools.ERROR_OAUTH2_SCOPE_REQUIRED.Status).
+				ExpectInteger("code", int64(tools.ERROR_OAUTH2_SCOPE_REQUIRED.Code))
+		})
+
+		t.Run("Users Only Endpoint", func(t *testing.T) {
+			NewTestRequest(t, "GET", "/users/@me/security/sessions").
+				WithHeader("Authorization", bearer).
+				Send().
+				ExpectStatus(tools.ERROR_OAUTH2_USERS_ONLY.Status).
+				ExpectInteger("code", int64(tools.ERROR_OAUTH2_USERS_ONLY.Code))
+		})
+
+		t.Run("Edit Profile with Scope", func(t *testing.T) {
+			ExecDatabase(t, RESET_CONNECTION_SCOPE_WRITE_PROFILE.Query, RESET_CONNECTION_SCOPE_WRITE_PROFILE.Arguments...)
+			NewTestRequest(t, "PATCH", "/users/@me").
+				WithHeader("Authorization", bearer).
+				WithJSON(map[string]any{"displayname": TEST_DISPLAYNAME_SECONDARY}).
+				Send().
+				ExpectStatus(http.StatusOK).
+				ExpectString("displayname", TEST_DISPLAYNAME_SECONDARY)
+		})
+	})
+	t.Run("/oauth2/token (Refresh Rotation)", func(t *testing.T) {
+		ResetDatabase(t,
+			RESET_BASE, RESET_ACCOUNT, RESET_APPLICATION,
+			RESET_CONNECTION, RESET_CONNECTION_REFRESH, RESET_CONNECTION_SCOPE_READ_IDENTIFY,
+		)
+		clientID := strconv.FormatInt(TEST_ID_PRIMARY, 10)
+		refresh := func(t *testing.T, token string) *testRequest {
+			return NewTestRequest(t, "POST", "/oauth2/token").
+				WithBasicAuth(clientID, TEST_SECRET_PRIMARY).
+				WithQuery(map[string]any{
+					"grant_type":    "refresh_token",
+					"refresh_token": token,
+				}).
+				Send()
+		}
+		var rotatedToken string
+
+		t.Run("Rotate Refresh Token", func(t *testing.T) {
+			res := refresh(t, TEST_TOKEN_SECONDARY).
+				ExpectStatus(http.StatusOK).
+				ExpectField("refresh_token")
+			rotatedToken, _ = res.responseJSON["refresh_token"].(string)
+
+			var parent string
+			QueryDatabaseRow(t,
+				"SELECT parent FROM auth.refresh_tokens WHERE token = $1",
+				[]any{tools.HashToken(rotatedToken)},
+				&parent,
+			)
+			if parent != TEST_TOKEN_SECONDARY_HASH {
+				t.Errorf("rotated token has unexpected parent %q", parent)
+			}
+		})
+
+		t.Run("Reuse Rotated Token", func(t *testing.T) {
+			refresh(t, TEST_TOKEN_SECONDARY).
+				ExpectStatus(tools.ERROR_OAUTH2_REFRESH_TOKEN_REUSED.Status).
+				ExpectInteger("code", int64(tools.ERROR_OAUTH2_REFRESH_TOKEN_REUSED.Code)).
+				ExpectString("error", tools.ERROR_OAUTH2_REFRESH_TOKEN_REUSED.Reason)
+		})
+
+		t.Run("Family Revoked", func(t *testing.T) {
+			refresh(t, rotatedToken).
+				ExpectStatus(tools.ERROR_ACCESS_REVOKED.Status).
+				ExpectInteger("code", int64(tools.ERROR_ACCESS_REVOKED.Code))
+		})
+	})
+}
@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+func Test_Passkey_Endpoints(t *testing.T) {
+	ResetDatabase(t,
+		RESET_BASE, RESET_ACCOUNT, RESET_PROFILE,
+		RESET_SESSION, RESET_SESSION_ELEVATED,
+	)
+	authenticator := newTestAuthenticator(TEST_ID_PRIMARY)
+
+	setupChallenge := func(t *testing.T) string {
+		res := NewTestRequest(t, "GET", "/users/@me/security/passkeys/setup").
+			WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+			Send().
+			ExpectStatus(http.StatusOK).
+			ExpectField("challenge")
+		return res.responseJSON["challenge"].(string)
+	}
+	loginChallenge := func(t *testing.T) string {
+		res := NewTestRequest(t, "GET", "/auth/login/passkey").
+			Send().
+			ExpectStatus(http.StatusOK).
+			ExpectField("challenge")
+		return res.responseJSON["challenge"].(string)
+	}
+
+	t.Run("/users/@me/security/passkeys/setup", func(t *testing.T) {
+		var challenge string
+
+		t.Run("Register Passkey", func(t *testing.T) {
+			challenge = setupChallenge(t)
+			NewTestRequest(t, "POST", "/users/@me/security/passkeys/setup").
+				WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+				WithJSON(authenticator.Register(TEST_DISPLAYNAME_PRIMARY, challenge)).
+				Send().
+				ExpectStatus(http.StatusOK).
+				ExpectString("name", TEST_DISPLAYNAME_PRIMARY)
+		})
+
+		t.Run("Replay Challenge", func(t *testing.T) {
+			NewTestRequest(t, "POST", "/users/@me/security/passkeys/setup").
+				WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+				WithJSON(authenticator.Register(TEST_DISPLAYNAME_PRIMARY, challenge)).
+				Send().
+				ExpectStatus(tools.ERROR_PASSKEY_CHALLENGE_INVALID.Status).
+				ExpectInteger("code", int64(tools.ERROR_PASSKEY_CHALLENGE_INVALID.Code))
+		})
+
+		t.Run("Register Duplicate", func(t *testing.T) {
+			NewTestRequest(t, "POST", "/users/@me/security/passkeys/setup").
+				WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+				WithJSON(authenticator.Register(TEST_DISPLAYNAME_SECONDARY, setupChallenge(t))).
+				Send().
+				ExpectStatus(tools.ERROR_PASSKEY_DUPLICATE.Status).
+				ExpectInteger("code", int64(tools.ERROR_PASSKEY_DUPLICATE.Code))
+		})
+	})
+
+	t.Run("/auth/login/passkey", func(t *testing.T) {
+		var assertion map[string]any
+
+		t.Run("Login with Passkey", func(t *testing.T) {
+			assertion = authenticator.Assert(loginChallenge(t))
+			NewTestRequest(t, "POST", "/auth/login/passkey").
+				WithJSON(assertion).
+				Send().
+				ExpectStatus(http.StatusNoContent).
+				ExpectCookie(tools.HTTP_COOKIE_NAME)
+		})
+
+		t.Run("Replay Assertion", func(t *testing.T) {
+			NewTestRequest(t, "POST", "/auth/login/passkey").
+				WithJSON(assertion).
+				Send().
+				ExpectStatus(tools.ERROR_PASSKEY_CHALLENGE_INVALID.Status).
+				ExpectInteger("code", int64(tools.ERROR_PASSKEY_CHALLENGE_INVALID.Code))
+		})
+
+		t.Run("Cloned Passkey", func(t *testing.T) {
+			authenticator.signCount = 0
+			NewTestRequest(t, "POST", "/auth/login/passkey").
+				WithJSON(authenticator.Assert(loginChallenge(t))).
+				Send().
+				ExpectStatus(tools.ERROR_PASSKEY_CLONED.Status).
+				ExpectInteger("code", int64(tools.ERROR_PASSKEY_CLONED.Code))
+			authenticator.signCount = 1
+		})
+
+		t.Run("Unknown Passkey", func(t *testing.T) {
+			NewTestRequest(t, "POST", "/auth/login/passkey").
+				WithJSON(newTestAuthenticator(TEST_ID_PRIMARY).Assert(loginChallenge(t))).
+				Send().
+				ExpectStatus(tools.ERROR_PASSKEY_INVALID.Status).
+				ExpectInteger("code", int64(tools.ERROR_PASSKEY_INVALID.Code))
+		})
+
+		t.Run("Account Locked", func(t *testing.T) {
+			ExecDatabase(t, "UPDATE auth.users SET lockout_until = NOW() + INTERVAL '1 hour' WHERE id = $1", TEST_ID_PRIMARY)
+			t.Cleanup(func() {
+				ExecDatabase(t, "UPDATE auth.users SET lockout_until = NULL WHERE id = $1", TEST_ID_PRIMARY)
+			})
+			NewTestRequest(t, "POST", "/auth/login/passkey").
+				WithJSON(authenticator.Assert(loginChallenge(t))).
+				Send().
+				ExpectStatus(tools.ERROR_LOGIN_ACCOUNT_LOCKED.Status).
+				ExpectInteger("code", int64(tools.ERROR_LOGIN_ACCOUNT_LOCKED.Code))
+		})
+	})
+
+	t.Run("/users/@me/security/escalate (Passkey)", func(t *testing.T) {
+		ExecDatabase(t, "UPDATE auth.sessions SET elevated_until = 0 WHERE id = $1", TEST_ID_PRIMARY)
+		NewTestRequest(t, "POST", "/users/@me/security/escalate").
+			WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+			WithJSON(authenticator.Assert(loginChallenge(t))).
+			Send().
+			ExpectStatus(http.StatusOK).
+			ExpectField("elevate_until")
+	})
+
+	t.Run("/users/@me/security/passkeys/{id}", func(t *testing.T) {
+		var passkeyID int64
+		QueryDatabaseRow(t,
+			"SELECT id FROM auth.passkeys WHERE user_id = $1",
+			[]any{TEST_ID_PRIMARY},
+			&passkeyID,
+		)
+		NewTestRequest(t, "DELETE", "/users/@me/security/passkeys/%d", passkeyID).
+			WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+			Send().
+			ExpectStatus(http.StatusNoContent)
+		NewTestRequest(t, "POST", "/auth/login/passkey").
+			WithJSON(authenticator.Assert(loginChallenge(t))).
+			Send().
+			ExpectStatus(tools.ERROR_PASSKEY_INVALID.Status)
+	})
+}
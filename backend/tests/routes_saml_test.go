@@ -0,0 +1,203 @@
+package tests
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+func Test_SAML_Endpoints(t *testing.T) {
+	ResetDatabase(t,
+		RESET_BASE, RESET_ACCOUNT, RESET_PROFILE,
+	)
+	idp := newTestSAMLProvider("test")
+
+	// Cookie binding the latest Request to the Browser
+	binding := ""
+	beginLogin := func(t *testing.T) string {
+		res := NewTestRequest(t, "GET", "/auth/saml/test").
+			Send().
+			ExpectStatus(http.StatusOK).
+			ExpectCookie(tools.HTTP_SAML_COOKIE_NAME).
+			ExpectField("url")
+		for _, cookie := range res.response.Cookies() {
+			if cookie.Name == tools.HTTP_SAML_COOKIE_NAME {
+				binding = cookie.Value
+			}
+		}
+		return idp.Authorize(t, res.responseJSON["url"].(string))
+	}
+	consumeWith := func(t *testing.T, response, binding string) *testRequest {
+		return NewTestRequest(t, "POST", "/auth/saml/test/acs").
+			WithHeader("Origin", idp.server.URL).
+			WithCookie(tools.HTTP_SAML_COOKIE_NAME, binding).
+			WithQuery(map[string]any{"SAMLResponse": response}).
+			Send()
+	}
+	consume := func(t *testing.T, response string) *testRequest {
+		return consumeWith(t, response, binding)
+	}
+	redirectState := func(t *testing.T, res *testRequest) string {
+		var location string
+		res.ExpectStatus(http.StatusSeeOther).
+			ExpectCookie(tools.HTTP_FEDERATION_COOKIE_NAME).
+			ExpectHeader("Location", &location)
+		u, err := url.Parse(location)
+		if err != nil || !strings.HasPrefix(location, tools.SAML_REDIRECT_URL+"/test?") {
+			t.Fatalf("unexpected redirect: %s", location)
+		}
+		return u.Query().Get("state")
+	}
+	complete := func(t *testing.T, state string, body map[string]any) *testRequest {
+		body["state"] = state
+		return NewTestRequest(t, "PATCH", "/auth/saml/test").
+			WithCookie(tools.HTTP_FEDERATION_COOKIE_NAME, state).
+			WithJSON(body).
+			Send()
+	}
+
+	t.Run("/auth/saml/{connection}/metadata", func(t *testing.T) {
+		res := NewTestRequest(t, "GET", "/auth/saml/test/metadata").
+			Send().
+			ExpectStatus(http.StatusOK).
+			ExpectBody()
+		if !strings.Contains(string(res.responseBody), idp.connection.ConsumerURL()) {
+			t.Fatalf("expected consumer url in metadata, got %s", res.responseBody)
+		}
+		NewTestRequest(t, "GET", "/auth/saml/unknown/metadata").
+			Send().
+			ExpectStatus(tools.ERROR_UNKNOWN_PROVIDER.Status).
+			ExpectInteger("code", int64(tools.ERROR_UNKNOWN_PROVIDER.Code))
+	})
+
+	t.Run("/auth/saml/{connection}", func(t *testing.T) {
+		t.Run("ECDSA Key", func(t *testing.T) {
+			previousKey := tools.SAMLKey
+			key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			if err != nil {
+				t.Fatalf("cannot generate key: %s", err)
+			}
+			tools.SAMLKey = key
+			defer func() { tools.SAMLKey = previousKey }()
+			beginLogin(t)
+		})
+	})
+
+	t.Run("/auth/saml/{connection}/acs", func(t *testing.T) {
+		newcomer := testSAMLAssertion{
+			NameID: "newcomer",
+			Attributes: map[string]string{
+				"email":       TEST_EMAIL_SECONDARY,
+				"uid":         TEST_USERNAME_SECONDARY,
+				"displayName": TEST_DISPLAYNAME_SECONDARY,
+			},
+		}
+
+		t.Run("Create Account", func(t *testing.T) {
+			newcomer.InResponseTo = beginLogin(t)
+			complete(t, redirectState(t, consume(t, idp.Response(t, newcomer))), map[string]any{}).
+				ExpectStatus(http.StatusNoContent).
+				ExpectCookie(tools.HTTP_COOKIE_NAME)
+
+			var username, displayname string
+			QueryDatabaseRow(t,
+				`SELECT p.username, p.displayname
+				FROM auth.identities i
+				JOIN auth.profiles p ON p.id = i.user_id
+				WHERE i.provider = 'saml:test' AND i.subject = 'newcomer'`,
+				nil,
+				&username,
+				&displayname,
+			)
+			if username != TEST_USERNAME_SECONDARY || displayname != TEST_DISPLAYNAME_SECONDARY {
+				t.Fatalf("unexpected profile: username=%s displayname=%s", username, displayname)
+			}
+		})
+
+		t.Run("Request Consumed", func(t *testing.T) {
+			// The Request was answered by the previous Response
+			consume(t, idp.Response(t, newcomer)).
+				ExpectStatus(tools.ERROR_SAML_UNSOLICITED.Status).
+				ExpectInteger("code", int64(tools.ERROR_SAML_UNSOLICITED.Code))
+		})
+
+		t.Run("Different Browser", func(t *testing.T) {
+			// Responses must arrive in the Browser which started the Request
+			otherBinding := binding
+			newcomer.InResponseTo = beginLogin(t)
+			response := idp.Response(t, newcomer)
+			unrelatedBinding, err := tools.SAMLRequestBinding(tools.GenerateSAMLID())
+			if err != nil {
+				t.Fatalf("cannot bind request: %s", err)
+			}
+			for _, b := range []string{"", otherBinding, unrelatedBinding} {
+				consumeWith(t, response, b).
+					ExpectStatus(tools.ERROR_SAML_UNSOLICITED.Status).
+					ExpectInteger("code", int64(tools.ERROR_SAML_UNSOLICITED.Code))
+			}
+		})
+
+		t.Run("Tampered Assertion", func(t *testing.T) {
+			newcomer.InResponseTo = beginLogin(t)
+			consume(t, idp.TamperedResponse(t, newcomer, ">newcomer<", ">administrator<")).
+				ExpectStatus(tools.ERROR_SAML_RESPONSE_INVALID.Status).
+				ExpectInteger("code", int64(tools.ERROR_SAML_RESPONSE_INVALID.Code))
+		})
+
+		t.Run("Second Factor Required", func(t *testing.T) {
+			ExecDatabase(t, "UPDATE auth.users SET email_verified = TRUE WHERE id = $1", TEST_ID_PRIMARY)
+			ResetDatabase(t, RESET_ACCOUNT_MFA)
+			state := redirectState(t, consume(t, idp.Response(t, testSAMLAssertion{
+				InResponseTo: beginLogin(t),
+				NameID:       "owner",
+				Attributes:   map[string]string{"email": TEST_EMAIL_PRIMARY},
+			})))
+
+			// The Identity Provider replaces the Password, not the Second Factor
+			complete(t, state, map[string]any{}).
+				ExpectStatus(tools.ERROR_MFA_PASSCODE_REQUIRED.Status).
+				ExpectInteger("code", int64(tools.ERROR_MFA_PASSCODE_REQUIRED.Code))
+			complete(t, state, map[string]any{"passcode": tools.GenerateTOTPCode(TEST_TOTP_SECRET, time.Now())}).
+				ExpectStatus(http.StatusNoContent).
+				ExpectCookie(tools.HTTP_COOKIE_NAME)
+		})
+
+		t.Run("Untrusted Email Domain", func(t *testing.T) {
+			// Only Domains managed by the Identity Provider may be linked by Email
+			idp.connection.EmailDomains = []string{"example.org"}
+			defer func() { idp.connection.EmailDomains = []string{"email.org"} }()
+			consume(t, idp.Response(t, testSAMLAssertion{
+				InResponseTo: beginLogin(t),
+				NameID:       "intruder",
+				Attributes:   map[string]string{"email": TEST_EMAIL_PRIMARY},
+			})).
+				ExpectStatus(tools.ERROR_FEDERATION_EMAIL_CONFLICT.Status).
+				ExpectInteger("code", int64(tools.ERROR_FEDERATION_EMAIL_CONFLICT.Code))
+		})
+
+		t.Run("IdP-Initiated", func(t *testing.T) {
+			unsolicited := idp.Response(t, testSAMLAssertion{NameID: "newcomer"})
+			consume(t, unsolicited).
+				ExpectStatus(tools.ERROR_SAML_UNSOLICITED.Status).
+				ExpectInteger("code", int64(tools.ERROR_SAML_UNSOLICITED.Code))
+
+			idp.connection.IDPInitiated = true
+			defer func() { idp.connection.IDPInitiated = false }()
+			complete(t, redirectState(t, consume(t, unsolicited)), map[string]any{}).
+				ExpectStatus(http.StatusNoContent).
+				ExpectCookie(tools.HTTP_COOKIE_NAME)
+
+			// Each Assertion may only be used once
+			consume(t, unsolicited).
+				ExpectStatus(tools.ERROR_SAML_RESPONSE_INVALID.Status).
+				ExpectInteger("code", int64(tools.ERROR_SAML_RESPONSE_INVALID.Code))
+		})
+	})
+}
@@ -0,0 +1,259 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+func Test_SCIM_Endpoints(t *testing.T) {
+	ResetDatabase(t,
+		RESET_BASE, RESET_ACCOUNT, RESET_PROFILE, RESET_SESSION,
+		RESET_APPLICATION, RESET_CONNECTION_SCIM,
+	)
+	var userID string
+	bearer := tools.TOKEN_PREFIX_BEARER + " " + TEST_TOKEN_SECONDARY
+	newcomer := map[string]any{
+		"schemas":    []string{tools.SCIM_SCHEMA_USER},
+		"externalId": "00u1a2b3c4",
+		"userName":   "Newcomer@Corp.Example",
+		"name":       map[string]any{"formatted": TEST_DISPLAYNAME_SECONDARY},
+		"emails":     []map[string]any{{"value": TEST_EMAIL_SECONDARY, "type": "work", "primary": true}},
+		"active":     true,
+	}
+
+	t.Run("/scim/v2/ServiceProviderConfig", func(t *testing.T) {
+		NewTestRequest(t, "GET", "/scim/v2/ServiceProviderConfig").
+			Send().
+			ExpectStatus(http.StatusOK).
+			ExpectJSON().
+			ExpectField("filter").
+			ExpectField("authenticationSchemes")
+	})
+
+	t.Run("/scim/v2/Users", func(t *testing.T) {
+
+		t.Run("Users are Rejected", func(t *testing.T) {
+			NewTestRequest(t, "GET", "/scim/v2/Users").
+				WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+				Send().
+				ExpectStatus(tools.ERROR_OAUTH2_APPLICATIONS_ONLY.Status).
+				ExpectInteger("code", int64(tools.ERROR_OAUTH2_APPLICATIONS_ONLY.Code))
+		})
+
+		t.Run("Create Account", func(t *testing.T) {
+			var location string
+			res := NewTestRequest(t, "POST", "/scim/v2/Users").
+				WithHeader("Authorization", bearer).
+				WithJSON(newcomer).
+				Send().
+				ExpectStatus(http.StatusCreated).
+				ExpectHeader("Location", &location).
+				ExpectJSON().
+				ExpectString("userName", "Newcomer@Corp.Example").
+				ExpectString("displayName", TEST_DISPLAYNAME_SECONDARY).
+				ExpectBoolean("active", true).
+				ExpectField("id")
+			userID = res.responseJSON["id"].(string)
+			if location != tools.OIDC_ISSUER+"/scim/v2/Users/"+userID {
+				t.Fatalf("unexpected location: %s", location)
+			}
+
+			// Address must be verified by the Owner
+			var verified bool
+			QueryDatabaseRow(t,
+				"SELECT email_verified FROM auth.users WHERE email_index = $1",
+				[]any{tools.EmailIndex(TEST_EMAIL_SECONDARY)}, &verified,
+			)
+			if verified {
+				t.Fatalf("expected provisioned email to be unverified")
+			}
+		})
+
+		t.Run("Create Duplicate Account", func(t *testing.T) {
+			NewTestRequest(t, "POST", "/scim/v2/Users").
+				WithHeader("Authorization", bearer).
+				WithJSON(newcomer).
+				Send().
+				ExpectStatus(tools.ERROR_SCIM_UNIQUENESS.Status).
+				ExpectJSON().
+				ExpectString("scimType", tools.ERROR_SCIM_UNIQUENESS.Reason)
+		})
+
+		t.Run("Create Account - Unowned Domain", func(t *testing.T) {
+			outsider := map[string]any{}
+			for k, v := range newcomer {
+				outsider[k] = v
+			}
+			outsider["externalId"] = "00u5d6e7f8"
+			outsider["userName"] = "Outsider@Corp.Example"
+			outsider["emails"] = []map[string]any{{"value": "outsider@elsewhere.org", "primary": true}}
+			NewTestRequest(t, "POST", "/scim/v2/Users").
+				WithHeader("Authorization", bearer).
+				WithJSON(outsider).
+				Send().
+				ExpectStatus(tools.ERROR_SCIM_INVALID_VALUE.Status).
+				ExpectJSON().
+				ExpectString("scimType", tools.ERROR_SCIM_INVALID_VALUE.Reason)
+		})
+
+		t.Run("Application not Allowed", func(t *testing.T) {
+			domains := tools.SCIMApplications[TEST_ID_PRIMARY]
+			delete(tools.SCIMApplications, TEST_ID_PRIMARY)
+			t.Cleanup(func() { tools.SCIMApplications[TEST_ID_PRIMARY] = domains })
+
+			// Existing Tokens lose the Scope once the Operator withdraws it
+			NewTestRequest(t, "GET", "/scim/v2/Users").
+				WithHeader("Authorization", bearer).
+				Send().
+				ExpectStatus(tools.ERROR_OAUTH2_SCOPE_REQUIRED.Status).
+				ExpectInteger("code", int64(tools.ERROR_OAUTH2_SCOPE_REQUIRED.Code))
+		})
+
+		t.Run("Filter Accounts", func(t *testing.T) {
+			NewTestRequest(t, "GET", "/scim/v2/Users").
+				WithHeader("Authorization", bearer).
+				WithQuery(map[string]any{"filter": `userName eq "newcomer@corp.example"`}).
+				Send().
+				ExpectStatus(http.StatusOK).
+				ExpectJSON().
+				ExpectInteger("totalResults", 1).
+				ExpectInteger("itemsPerPage", 1)
+			NewTestRequest(t, "GET", "/scim/v2/Users").
+				WithHeader("Authorization", bearer).
+				WithQuery(map[string]any{"filter": `emails[type eq "work" and value eq "SECONDARY@email.org"] and not (active eq false)`}).
+				Send().
+				ExpectStatus(http.StatusOK).
+				ExpectJSON().
+				ExpectInteger("totalResults", 1)
+			NewTestRequest(t, "GET", "/scim/v2/Users").
+				WithHeader("Authorization", bearer).
+				WithQuery(map[string]any{"filter": `externalId eq "unknown"`}).
+				Send().
+				ExpectStatus(http.StatusOK).
+				ExpectJSON().
+				ExpectInteger("totalResults", 0)
+		})
+
+		t.Run("Filter Accounts - Invalid Filter", func(t *testing.T) {
+			NewTestRequest(t, "GET", "/scim/v2/Users").
+				WithHeader("Authorization", bearer).
+				WithQuery(map[string]any{"filter": `password eq "hunter2"`}).
+				Send().
+				ExpectStatus(tools.ERROR_SCIM_INVALID_FILTER.Status).
+				ExpectJSON().
+				ExpectString("scimType", tools.ERROR_SCIM_INVALID_FILTER.Reason)
+
+			// Encrypted Addresses can only be matched exactly
+			NewTestRequest(t, "GET", "/scim/v2/Users").
+				WithHeader("Authorization", bearer).
+				WithQuery(map[string]any{"filter": `emails.value co "secondary"`}).
+				Send().
+				ExpectStatus(tools.ERROR_SCIM_INVALID_FILTER.Status)
+		})
+
+		t.Run("Paginate Accounts", func(t *testing.T) {
+			NewTestRequest(t, "GET", "/scim/v2/Users").
+				WithHeader("Authorization", bearer).
+				WithQuery(map[string]any{"count": 0}).
+				Send().
+				ExpectStatus(http.StatusOK).
+				ExpectJSON().
+				ExpectInteger("totalResults", 1).
+				ExpectInteger("itemsPerPage", 0)
+			NewTestRequest(t, "GET", "/scim/v2/Users").
+				WithHeader("Authorization", bearer).
+				WithQuery(map[string]any{"startIndex": 2}).
+				Send().
+				ExpectStatus(http.StatusOK).
+				ExpectJSON().
+				ExpectInteger("startIndex", 2).
+				ExpectInteger("itemsPerPage", 0)
+		})
+	})
+
+	t.Run("/scim/v2/Users/{id}", func(t *testing.T) {
+
+		t.Run("Fetch Account", func(t *testing.T) {
+			NewTestRequest(t, "GET", "/scim/v2/Users/%s", userID).
+				WithHeader("Authorization", bearer).
+				Send().
+				ExpectStatus(http.StatusOK).
+				ExpectJSON().
+				ExpectString("externalId", "00u1a2b3c4")
+		})
+
+		t.Run("Fetch Account - Not Provisioned", func(t *testing.T) {
+			NewTestRequest(t, "GET", "/scim/v2/Users/%d", TEST_ID_PRIMARY).
+				WithHeader("Authorization", bearer).
+				Send().
+				ExpectStatus(tools.ERROR_UNKNOWN_USER.Status)
+		})
+
+		t.Run("Replace Account", func(t *testing.T) {
+			replaced := map[string]any{}
+			for k, v := range newcomer {
+				replaced[k] = v
+			}
+			replaced["displayName"] = "Renamed Newcomer"
+			NewTestRequest(t, "PUT", "/scim/v2/Users/%s", userID).
+				WithHeader("Authorization", bearer).
+				WithJSON(replaced).
+				Send().
+				ExpectStatus(http.StatusOK).
+				ExpectJSON().
+				ExpectString("displayName", "Renamed Newcomer")
+		})
+
+		t.Run("Patch Account - Invalid Path", func(t *testing.T) {
+			NewTestRequest(t, "PATCH", "/scim/v2/Users/%s", userID).
+				WithHeader("Authorization", bearer).
+				WithJSON(map[string]any{
+					"schemas":    []string{tools.SCIM_SCHEMA_PATCH_OP},
+					"Operations": []map[string]any{{"op": "replace", "path": "password", "value": "hunter2"}},
+				}).
+				Send().
+				ExpectStatus(tools.ERROR_SCIM_INVALID_PATH.Status).
+				ExpectJSON().
+				ExpectString("scimType", tools.ERROR_SCIM_INVALID_PATH.Reason)
+		})
+
+		t.Run("Patch Account - Deactivate", func(t *testing.T) {
+			var id int64
+			QueryDatabaseRow(t, "SELECT user_id FROM auth.scim_users WHERE user_name_index = $1", []any{tools.UserNameIndex("Newcomer@Corp.Example")}, &id)
+			token := tools.GenerateSignedStringWith(TEST_KEY_HMAC)
+			ExecDatabase(t,
+				"INSERT INTO auth.sessions (id, user_id, token, device_ip_address, device_user_agent, expires) VALUES ($1, $2, $3, $4, $5, $6)",
+				tools.GenerateSnowflake(), id, tools.HashToken(token), TEST_IP_ADDRESS, TEST_IP_AGENT, TEST_TOKEN_EXPIRES_FUTURE,
+			)
+			NewTestRequest(t, "PATCH", "/scim/v2/Users/%s", userID).
+				WithHeader("Authorization", bearer).
+				WithJSON(map[string]any{
+					"schemas":    []string{tools.SCIM_SCHEMA_PATCH_OP},
+					"Operations": []map[string]any{{"op": "replace", "value": map[string]any{"active": false}}},
+				}).
+				Send().
+				ExpectStatus(http.StatusOK).
+				ExpectJSON().
+				ExpectBoolean("active", false)
+
+			// Existing Sessions are Revoked
+			NewTestRequest(t, "GET", "/users/@me").
+				WithCookie(tools.HTTP_COOKIE_NAME, token).
+				Send().
+				ExpectStatus(tools.ERROR_GENERIC_UNAUTHORIZED.Status)
+		})
+
+		t.Run("Delete Account", func(t *testing.T) {
+			NewTestRequest(t, "DELETE", "/scim/v2/Users/%s", userID).
+				WithHeader("Authorization", bearer).
+				Send().
+				ExpectStatus(http.StatusNoContent)
+			NewTestRequest(t, "GET", "/scim/v2/Users/%s", userID).
+				WithHeader("Authorization", bearer).
+				Send().
+				ExpectStatus(tools.ERROR_UNKNOWN_USER.Status)
+		})
+	})
+}
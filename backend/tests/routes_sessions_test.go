@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+func Test_Session_Expiry(t *testing.T) {
+	ResetDatabase(t, RESET_BASE, RESET_ACCOUNT, RESET_SESSION)
+
+	t.Run("Usage Extends Idle Timeout", func(t *testing.T) {
+		ExecDatabase(t,
+			"UPDATE auth.sessions SET used = $1 WHERE id = $2",
+			time.Now().Add(-time.Hour), TEST_ID_PRIMARY,
+		)
+		NewTestRequest(t, "GET", "/users/@me/security/sessions").
+			WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+			Send().
+			ExpectStatus(http.StatusOK)
+
+		var used time.Time
+		QueryDatabaseRow(t, "SELECT used FROM auth.sessions WHERE id = $1",
+			[]any{TEST_ID_PRIMARY},
+			&used,
+		)
+		if time.Since(used) > tools.SESSION_TOUCH_INTERVAL {
+			t.Errorf("session usage was not recorded")
+		}
+	})
+
+	t.Run("Idle Session", func(t *testing.T) {
+		ExecDatabase(t,
+			"UPDATE auth.sessions SET used = $1 WHERE id = $2",
+			time.Now().Add(-tools.SESSION_IDLE_TIMEOUT-time.Hour), TEST_ID_PRIMARY,
+		)
+		NewTestRequest(t, "GET", "/users/@me/security/sessions").
+			WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+			Send().
+			ExpectStatus(tools.ERROR_ACCESS_EXPIRED.Status).
+			ExpectInteger("code", int64(tools.ERROR_ACCESS_EXPIRED.Code))
+	})
+
+	t.Run("Backfill Expiry", func(t *testing.T) {
+		ExecDatabase(t, "UPDATE auth.sessions SET expires = NULL WHERE id = $1", TEST_ID_PRIMARY)
+		backfilled, err := tools.BackfillSessions(t.Context())
+		if err != nil {
+			t.Fatalf("backfill failed: %s", err)
+		}
+		if backfilled != 1 {
+			t.Errorf("expected 1 backfilled session, got %d", backfilled)
+		}
+
+		// Sessions are given the configured Lifetime
+		var seconds float64
+		QueryDatabaseRow(t, "SELECT EXTRACT(EPOCH FROM expires - created) FROM auth.sessions WHERE id = $1",
+			[]any{TEST_ID_PRIMARY},
+			&seconds,
+		)
+		if seconds != tools.LIFETIME_TOKEN_USER_COOKIE.Seconds() {
+			t.Errorf("expected lifetime of %.0fs, got %.0fs", tools.LIFETIME_TOKEN_USER_COOKIE.Seconds(), seconds)
+		}
+	})
+
+	t.Run("Expired Session", func(t *testing.T) {
+		ExecDatabase(t,
+			"UPDATE auth.sessions SET used = $1, expires = $2 WHERE id = $3",
+			time.Now(), time.Now().Add(-time.Minute), TEST_ID_PRIMARY,
+		)
+		NewTestRequest(t, "GET", "/users/@me/security/sessions").
+			WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+			Send().
+			ExpectStatus(tools.ERROR_ACCESS_EXPIRED.Status).
+			ExpectInteger("code", int64(tools.ERROR_ACCESS_EXPIRED.Code))
+	})
+
+	t.Run("Purge Expired Sessions", func(t *testing.T) {
+		purged, err := tools.PurgeSessions(t.Context(), time.Now())
+		if err != nil {
+			t.Fatalf("purge failed: %s", err)
+		}
+		if purged != 1 {
+			t.Errorf("expected 1 purged session, got %d", purged)
+		}
+	})
+}
@@ -1,9 +1,12 @@
 package tests
 
 import (
+	"crypto/sha256"
 	"encoding/base64"
+	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/bakonpancakz/template-auth/tools"
@@ -14,8 +17,13 @@ var (
 	TEST_ID_PRIMARY                 = tools.GenerateSnowflake()
 	TEST_ID_SECONDARY               = tools.GenerateSnowflake()
 	TEST_TOKEN_INVALID              = "token"
-	TEST_TOKEN_PRIMARY              = tools.GenerateSignedString()
-	TEST_TOKEN_SECONDARY            = tools.GenerateSignedString()
+	TEST_KEY_HMAC                   = mustGenerateKey(tools.KEY_USAGE_HMAC, tools.KEY_ALGORITHM_HS256)
+	TEST_TOKEN_PRIMARY              = tools.GenerateSignedStringWith(TEST_KEY_HMAC)
+	TEST_TOKEN_SECONDARY            = tools.GenerateSignedStringWith(TEST_KEY_HMAC)
+	TEST_TOKEN_PRIMARY_HASH         = tools.HashToken(TEST_TOKEN_PRIMARY)
+	TEST_TOKEN_SECONDARY_HASH       = tools.HashToken(TEST_TOKEN_SECONDARY)
+	TEST_SECRET_PRIMARY             = tools.GenerateSignedStringWith(TEST_KEY_HMAC)
+	TEST_SECRET_PRIMARY_HASH        = mustHashSecret(TEST_SECRET_PRIMARY)
 	TEST_TOKEN_EXPIRES_FUTURE       = time.Now().AddDate(10, 0, 0)
 	TEST_TOKEN_EXPIRES_PAST         = time.Now().AddDate(-10, 0, 0)
 	TEST_EMAIL_INVALID              = "invalid@email..org"
@@ -47,6 +55,10 @@ var (
 	TEST_REDIRECT_INVALID           = make([]string, 16)
 	TEST_REDIRECT_PRIMARY           = []string{TEST_REDIRECT_URI_PRIMARY}
 	TEST_REDIRECT_SECONDARY         = []string{TEST_REDIRECT_URI_SECONDARY}
+	TEST_PKCE_VERIFIER_INVALID      = "verifier"
+	TEST_PKCE_VERIFIER_PRIMARY      = strings.Repeat("pancakes", 6)
+	TEST_PKCE_VERIFIER_SECONDARY    = strings.Repeat("waffles_", 6)
+	TEST_PKCE_CHALLENGE_PRIMARY     = mustChallengeVerifier(TEST_PKCE_VERIFIER_PRIMARY)
 	TEST_TOTP_PASSCODE_INVALID      = "000000" // sobbing
 	TEST_TOTP_SECRET                = tools.GenerateTOTPSecret()
 	TEST_TOTP_RECOVERY_CODE_INVALID = "AAAAAAAA" // screaming
@@ -76,3 +88,21 @@ func mustHashPassword(plaintext string) string {
 	}
 	return h
 }
+
+// Test Tokens are signed before the Keystore starts, their Key is saved to it during startup
+func mustGenerateKey(usage, algorithm string) tools.KeystoreKey {
+	k, err := tools.KeystoreGenerate(usage, algorithm, time.Now())
+	if err != nil {
+		log.Fatalln("cannot generate key", err)
+	}
+	return k
+}
+
+func mustHashSecret(plaintext string) string {
+	return fmt.Sprintf("%X", sha256.Sum256([]byte(plaintext)))
+}
+
+func mustChallengeVerifier(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
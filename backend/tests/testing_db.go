@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"strconv"
 	"testing"
 
 	"github.com/bakonpancakz/template-auth/include"
@@ -18,16 +19,17 @@ var RESET_BASE = DatabaseResetOption{
 	Arguments: []any{},
 }
 
-// Create Default Account with Default Profile
+// Create Default Account with Default Profile, the Address is left unencrypted
+// so Fixtures don't depend on the Master Keys currently held by the Keystore
 var RESET_ACCOUNT = DatabaseResetOption{
-	Query:     `INSERT INTO auth.users (id, email_address, password_hash) VALUES ($1, $2, $3);`,
-	Arguments: []any{TEST_ID_PRIMARY, TEST_EMAIL_PRIMARY, TEST_PASSWORD_PRIMARY_HASH},
+	Query:     `INSERT INTO auth.users (id, email_address, email_index, password_hash) VALUES ($1, $2, $3, $4);`,
+	Arguments: []any{TEST_ID_PRIMARY, TEST_EMAIL_PRIMARY, tools.EmailIndex(TEST_EMAIL_PRIMARY), TEST_PASSWORD_PRIMARY_HASH},
 }
 
 // With Verify Login, Verify Email, and Passcode Tokens
 var RESET_ACCOUNT_TOKENS = DatabaseResetOption{
 	Query:     `UPDATE auth.users SET token_verify = $1, token_verify_eat = $2, token_login = $3, token_login_data = $4, token_login_eat = $5, token_reset = $6, token_reset_eat = $7 WHERE id = $8`,
-	Arguments: []any{TEST_TOKEN_PRIMARY, TEST_TOKEN_EXPIRES_FUTURE, TEST_TOKEN_PRIMARY, TEST_IP_ADDRESS, TEST_TOKEN_EXPIRES_FUTURE, TEST_TOKEN_PRIMARY, TEST_TOKEN_EXPIRES_FUTURE, TEST_ID_PRIMARY},
+	Arguments: []any{TEST_TOKEN_PRIMARY_HASH, TEST_TOKEN_EXPIRES_FUTURE, TEST_TOKEN_PRIMARY_HASH, strconv.FormatInt(TEST_ID_PRIMARY, 10), TEST_TOKEN_EXPIRES_FUTURE, TEST_TOKEN_PRIMARY_HASH, TEST_TOKEN_EXPIRES_FUTURE, TEST_ID_PRIMARY},
 }
 
 // With MFA Fields
@@ -48,10 +50,22 @@ var RESET_PROFILE_CUSTOMIZED = DatabaseResetOption{
 	Arguments: []any{TEST_DISPLAYNAME_PRIMARY, TEST_SUBTITLE_PRIMARY, TEST_BIOGRAPHY_PRIMARY, TEST_HASH_PRIMARY, TEST_HASH_PRIMARY, TEST_COLOR_PRIMARY, TEST_COLOR_PRIMARY, TEST_COLOR_PRIMARY, TEST_ID_PRIMARY},
 }
 
+// Create Default Device, not yet allowed by its Owner
+var RESET_DEVICE = DatabaseResetOption{
+	Query:     `INSERT INTO auth.devices (id, user_id, token, ip_address, ip_index, user_agent) VALUES ($1, $2, $3, $4, $5, $6)`,
+	Arguments: []any{TEST_ID_PRIMARY, TEST_ID_PRIMARY, TEST_TOKEN_SECONDARY_HASH, TEST_IP_ADDRESS, tools.AddressIndex(TEST_IP_ADDRESS), TEST_IP_AGENT},
+}
+
 // Create Default Session
 var RESET_SESSION = DatabaseResetOption{
-	Query:     `INSERT INTO auth.sessions (id, user_id, token, device_ip_address, device_user_agent) VALUES ($1, $2, $3, $4, $5)`,
-	Arguments: []any{TEST_ID_PRIMARY, TEST_ID_PRIMARY, TEST_TOKEN_PRIMARY, TEST_IP_ADDRESS, TEST_IP_AGENT},
+	Query:     `INSERT INTO auth.sessions (id, user_id, token, device_ip_address, device_user_agent, expires) VALUES ($1, $2, $3, $4, $5, $6)`,
+	Arguments: []any{TEST_ID_PRIMARY, TEST_ID_PRIMARY, TEST_TOKEN_PRIMARY_HASH, TEST_IP_ADDRESS, TEST_IP_AGENT, TEST_TOKEN_EXPIRES_FUTURE},
+}
+
+// Update Default Session as Elevated
+var RESET_SESSION_ELEVATED = DatabaseResetOption{
+	Query:     `UPDATE auth.sessions SET elevated_until = $1 WHERE id = $2 AND user_id = $3`,
+	Arguments: []any{TEST_TOKEN_EXPIRES_FUTURE.Unix(), TEST_ID_PRIMARY, TEST_ID_PRIMARY},
 }
 
 // Update Default Session as Revoked
@@ -63,7 +77,7 @@ var RESET_SESSION_REVOKED = DatabaseResetOption{
 // Create Default Application
 var RESET_APPLICATION = DatabaseResetOption{
 	Query:     `INSERT INTO auth.applications (id, user_id, name, auth_secret) VALUES ($1, $2, $3, $4)`,
-	Arguments: []any{TEST_ID_PRIMARY, TEST_ID_PRIMARY, TEST_DISPLAYNAME_PRIMARY, TEST_TOKEN_PRIMARY},
+	Arguments: []any{TEST_ID_PRIMARY, TEST_ID_PRIMARY, TEST_DISPLAYNAME_PRIMARY, TEST_SECRET_PRIMARY_HASH},
 }
 
 // Personalize Default Application
@@ -72,16 +86,34 @@ var RESET_APPLICATION_CUSTOMIZED = DatabaseResetOption{
 	Arguments: []any{TEST_BIOGRAPHY_PRIMARY, TEST_HASH_PRIMARY, TEST_REDIRECT_PRIMARY, TEST_ID_PRIMARY, TEST_ID_PRIMARY},
 }
 
+// Update Default Application as a Public Client
+var RESET_APPLICATION_PUBLIC = DatabaseResetOption{
+	Query:     `UPDATE auth.applications SET auth_public = TRUE WHERE id = $1 AND user_id = $2`,
+	Arguments: []any{TEST_ID_PRIMARY, TEST_ID_PRIMARY},
+}
+
+// Allow Default Application to act as itself with Scope 'profiles.read'
+var RESET_APPLICATION_MACHINE = DatabaseResetOption{
+	Query:     `UPDATE auth.applications SET auth_scopes = $1 WHERE id = $2 AND user_id = $3`,
+	Arguments: []any{tools.SCOPE_READ_PROFILES.Flag, TEST_ID_PRIMARY, TEST_ID_PRIMARY},
+}
+
 // Create Default Connection for Default Application
 var RESET_CONNECTION = DatabaseResetOption{
-	Query:     `INSERT INTO auth.connections (user_id, application_id, scopes,token_access, token_expires, token_refresh) VALUES ($1, $2, $3, $4, $5, $6)`,
-	Arguments: []any{},
+	Query:     `INSERT INTO auth.connections (id, user_id, application_id, scopes, token_access, token_expires, token_refresh) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+	Arguments: []any{TEST_ID_PRIMARY, TEST_ID_PRIMARY, TEST_ID_PRIMARY, 0, TEST_TOKEN_PRIMARY_HASH, TEST_TOKEN_EXPIRES_FUTURE, TEST_TOKEN_SECONDARY_HASH},
+}
+
+// Record Refresh Token of Default Connection as its own Family
+var RESET_CONNECTION_REFRESH = DatabaseResetOption{
+	Query:     `INSERT INTO auth.refresh_tokens (token, connection_id) VALUES ($1, $2)`,
+	Arguments: []any{TEST_TOKEN_SECONDARY_HASH, TEST_ID_PRIMARY},
 }
 
 // Create Default Grant for Default Application
 var RESET_GRANT = DatabaseResetOption{
 	Query:     `INSERT INTO auth.grants (id, expires, user_id, application_id,redirect_uri, scopes, code) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-	Arguments: []any{TEST_ID_PRIMARY, TEST_TOKEN_EXPIRES_FUTURE, TEST_ID_PRIMARY, TEST_ID_PRIMARY, TEST_REDIRECT_URI_PRIMARY, 0, TEST_TOKEN_PRIMARY},
+	Arguments: []any{TEST_ID_PRIMARY, TEST_TOKEN_EXPIRES_FUTURE, TEST_ID_PRIMARY, TEST_ID_PRIMARY, TEST_REDIRECT_URI_PRIMARY, 0, TEST_TOKEN_PRIMARY_HASH},
 }
 
 // With OAuth2 Scope 'identify'
@@ -96,12 +128,24 @@ var RESET_CONNECTION_SCOPE_READ_EMAIL = DatabaseResetOption{
 	Arguments: []any{tools.SCOPE_READ_EMAIL.Flag, TEST_ID_PRIMARY},
 }
 
+// With OAuth2 Scope 'profile.write'
+var RESET_CONNECTION_SCOPE_WRITE_PROFILE = DatabaseResetOption{
+	Query:     "UPDATE auth.connections SET scopes = scopes | $1 WHERE id = $2",
+	Arguments: []any{tools.SCOPE_WRITE_PROFILE.Flag, TEST_ID_PRIMARY},
+}
+
 // Update Default Connection as Revoked
 var RESET_CONNECTION_REVOKED = DatabaseResetOption{
-	Query:     `UPDATE auth.connections SET revoked = FALSE, scopes  = 0 WHERE id = $1 AND user_id = $2`,
+	Query:     `UPDATE auth.connections SET revoked = TRUE, scopes = 0 WHERE id = $1 AND user_id = $2`,
 	Arguments: []any{TEST_ID_PRIMARY, TEST_ID_PRIMARY},
 }
 
+// Create Client Credentials Connection for Default Application with Scope 'scim'
+var RESET_CONNECTION_SCIM = DatabaseResetOption{
+	Query:     `INSERT INTO auth.connections (id, user_id, application_id, scopes, token_access, token_expires) VALUES ($1, NULL, $2, $3, $4, $5)`,
+	Arguments: []any{TEST_ID_SECONDARY, TEST_ID_PRIMARY, tools.SCOPE_SCIM.Flag, TEST_TOKEN_SECONDARY_HASH, TEST_TOKEN_EXPIRES_FUTURE},
+}
+
 // Reset the Database to the Default Schema, applys dummy data if flags specify
 func ResetDatabase(t *testing.T, options ...DatabaseResetOption) {
 	for _, o := range options {
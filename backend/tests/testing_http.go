@@ -3,9 +3,11 @@ package tests
 import (
 	"bytes"
 	"compress/gzip"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"strings"
@@ -42,6 +44,13 @@ func (t *testRequest) WithHeader(key, value string) *testRequest {
 	return t
 }
 
+// Include HTTP Basic Authentication with your Request
+func (t *testRequest) WithBasicAuth(username, password string) *testRequest {
+	credentials := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+	t.requestHeaders["Authorization"] = "Basic " + credentials
+	return t
+}
+
 func (t *testRequest) WithCookie(name, value string) *testRequest {
 	cookies := ""
 	if c, ok := t.requestHeaders["Cookie"]; ok {
@@ -139,8 +148,8 @@ func (t *testRequest) ExpectBody() *testRequest {
 
 // Expect and Parse the Response Body to be a JSON Object
 func (t *testRequest) ExpectJSON() *testRequest {
-	header := strings.ToLower(t.response.Header.Get("Content-Type"))
-	if !strings.Contains(header, "application/json") {
+	header, _, _ := mime.ParseMediaType(t.response.Header.Get("Content-Type"))
+	if header != "application/json" && !strings.HasSuffix(header, "+json") {
 		t.test.Fatalf("expected content type of 'application/json'")
 	}
 	t.ExpectBody()
@@ -170,6 +179,18 @@ func (t *testRequest) ExpectCookie(name string) *testRequest {
 	return t
 }
 
+// Expect a Header to be present, optionally storing its value
+func (t *testRequest) ExpectHeader(key string, dst *string) *testRequest {
+	value := t.response.Header.Get(key)
+	if value == "" {
+		t.test.Fatalf("expected header '%s' to be present", key)
+	}
+	if dst != nil {
+		*dst = value
+	}
+	return t
+}
+
 // Expect a JSON field to be present
 func (t *testRequest) ExpectField(key string) *testRequest {
 	t.ExpectJSON()
@@ -213,3 +234,20 @@ func (t *testRequest) ExpectInteger(key string, expected int64) *testRequest {
 	}
 	return t
 }
+
+// Expect a JSON field to contain the following boolean value
+func (t *testRequest) ExpectBoolean(key string, expected bool) *testRequest {
+	t.ExpectJSON()
+	v, ok := t.responseJSON[key]
+	if !ok {
+		t.test.Fatalf("expected boolean field '%s' not found", key)
+	}
+	b, ok := v.(bool)
+	if !ok {
+		t.test.Fatalf("field '%s' expected boolean, got %T\nBody: %s", key, v, t.responseBody)
+	}
+	if b != expected {
+		t.test.Fatalf("field '%s' expected %t, got %t\nBody: %s", key, expected, b, t.responseBody)
+	}
+	return t
+}
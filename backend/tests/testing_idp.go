@@ -0,0 +1,172 @@
+package tests
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+const (
+	TEST_IDP_CLIENT_ID     = "template-auth"
+	TEST_IDP_CLIENT_SECRET = "hunter2"
+	TEST_IDP_KEY_ID        = "idp"
+)
+
+// Local OpenID Provider which approves every Authorization Request, the
+// Claims returned are chosen by the test when it simulates the User consenting
+type testIdentityProvider struct {
+	server *httptest.Server
+	key    *ecdsa.PrivateKey
+	mutex  sync.Mutex
+	codes  map[string]testIdentityGrant
+	tokens map[string]map[string]any
+}
+
+type testIdentityGrant struct {
+	RedirectURI string
+	Challenge   string
+	Nonce       string
+	Claims      map[string]any
+}
+
+// Start Provider and register it for Federated Login under the given Name
+func newTestIdentityProvider(name string) *testIdentityProvider {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	p := &testIdentityProvider{
+		key:    key,
+		codes:  map[string]testIdentityGrant{},
+		tokens: map[string]map[string]any{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("GET /jwks", p.jwks)
+	mux.HandleFunc("POST /token", p.token)
+	mux.HandleFunc("GET /userinfo", p.userinfo)
+	p.server = httptest.NewServer(mux)
+
+	tools.Federation[name] = &tools.FederationProvider{
+		Name:         name,
+		Issuer:       p.server.URL,
+		ClientID:     TEST_IDP_CLIENT_ID,
+		ClientSecret: TEST_IDP_CLIENT_SECRET,
+		Scopes:       []string{"openid", "email", "profile"},
+	}
+	return p
+}
+
+// Simulate the User consenting at the Authorization URL, returns the State
+// and Code the Provider would redirect back with
+func (p *testIdentityProvider) Authorize(t *testing.T, authorizationURL string, claims map[string]any) (state, code string) {
+	u, err := url.Parse(authorizationURL)
+	if err != nil {
+		t.Fatalf("invalid authorization url: %s", err)
+	}
+	query := u.Query()
+	if query.Get("client_id") != TEST_IDP_CLIENT_ID || query.Get("code_challenge_method") != tools.PKCE_METHOD_S256 {
+		t.Fatalf("unexpected authorization request: %s", u.RawQuery)
+	}
+	code = tools.GenerateRandomToken()
+	p.mutex.Lock()
+	p.codes[code] = testIdentityGrant{
+		RedirectURI: query.Get("redirect_uri"),
+		Challenge:   query.Get("code_challenge"),
+		Nonce:       query.Get("nonce"),
+		Claims:      claims,
+	}
+	p.mutex.Unlock()
+	return query.Get("state"), code
+}
+
+func (p *testIdentityProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]any{
+		"issuer":                 p.server.URL,
+		"authorization_endpoint": p.server.URL + "/authorize",
+		"token_endpoint":         p.server.URL + "/token",
+		"userinfo_endpoint":      p.server.URL + "/userinfo",
+		"jwks_uri":               p.server.URL + "/jwks",
+	})
+}
+
+func (p *testIdentityProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	jwk, err := tools.GenerateJWK(p.key.Public(), TEST_IDP_KEY_ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"keys": []tools.JWK{jwk}})
+}
+
+func (p *testIdentityProvider) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	if !ok || id != TEST_IDP_CLIENT_ID || secret != TEST_IDP_CLIENT_SECRET {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+
+	// Redeem Code, each may only be used once
+	p.mutex.Lock()
+	grant, ok := p.codes[r.PostFormValue("code")]
+	delete(p.codes, r.PostFormValue("code"))
+	p.mutex.Unlock()
+	challenge := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok ||
+		r.PostFormValue("grant_type") != "authorization_code" ||
+		r.PostFormValue("redirect_uri") != grant.RedirectURI ||
+		base64.RawURLEncoding.EncodeToString(challenge[:]) != grant.Challenge {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	// Issue ID Token, Claims given by the test override the defaults
+	claims := map[string]any{
+		"iss":   p.server.URL,
+		"aud":   TEST_IDP_CLIENT_ID,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"nonce": grant.Nonce,
+	}
+	for k, v := range grant.Claims {
+		claims[k] = v
+	}
+	idToken, err := tools.GenerateJWT(p.key, TEST_IDP_KEY_ID, claims)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	accessToken := tools.GenerateRandomToken()
+	p.mutex.Lock()
+	p.tokens[accessToken] = claims
+	p.mutex.Unlock()
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+	})
+}
+
+func (p *testIdentityProvider) userinfo(w http.ResponseWriter, r *http.Request) {
+	p.mutex.Lock()
+	claims, ok := p.tokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+	p.mutex.Unlock()
+	if !ok {
+		http.Error(w, `{"error":"invalid_token"}`, http.StatusUnauthorized)
+		return
+	}
+	json.NewEncoder(w).Encode(claims)
+}
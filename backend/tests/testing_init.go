@@ -2,9 +2,13 @@ package tests
 
 import (
 	"context"
+	"log"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/bakonpancakz/template-auth/core"
 	"github.com/bakonpancakz/template-auth/tools"
@@ -21,6 +25,9 @@ func init() {
 	tools.STORAGE_PROVIDER = "test"
 	tools.RATELIMIT_PROVIDER = "test"
 	tools.LOGGER_PROVIDER = "test"
+	tools.KEYSTORE_PROVIDER = "test"
+	tools.SCIM_APPLICATIONS = []string{strconv.FormatInt(TEST_ID_PRIMARY, 10)}
+	os.Setenv("SCIM_"+tools.SCIM_APPLICATIONS[0]+"_EMAIL_DOMAINS", "email.org")
 
 	var stopCtx = context.TODO()
 	var stopWg sync.WaitGroup
@@ -32,6 +39,12 @@ func init() {
 		tools.SetupEmailProvider,
 		tools.SetupRatelimitProvider,
 		tools.SetupStorageProvider,
+		tools.SetupFederation,
+		tools.SetupSAML,
+		tools.SetupSCIM,
+		tools.SetupPassword,
+		tools.SetupBreaches,
+		tools.SetupRisk,
 	} {
 		syncWg.Add(1)
 		go func() {
@@ -40,6 +53,21 @@ func init() {
 		}()
 	}
 	syncWg.Wait()
+	tools.SetupKeystore(stopCtx, &stopWg) // Depends on Database
+	if err := tools.Keystore.Save(stopCtx, TEST_KEY_HMAC); err != nil {
+		log.Fatalln("cannot save test key", err)
+	}
+	if err := tools.KeystoreRotate(stopCtx, time.Now()); err != nil {
+		log.Fatalln("cannot load test key", err)
+	}
+	tools.SetupSessions(stopCtx, &stopWg) // Depends on Database
+	tools.SetupTokens(stopCtx, &stopWg)   // Depends on Database
+	tools.SetupFields(stopCtx, &stopWg)   // Depends on Keystore
+	// Outbox Workers aren't started, tests process the Outbox themselves
 	HTTP_SERVER = httptest.NewServer(core.SetupMux())
 	HTTP_CLIENT = HTTP_SERVER.Client()
+	HTTP_CLIENT.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		// Redirects are inspected by the tests themselves
+		return http.ErrUseLastResponse
+	}
 }
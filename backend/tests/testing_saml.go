@@ -0,0 +1,251 @@
+package tests
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+// Local SAML Identity Provider which signs whatever Assertion the test asks for
+type testSAMLProvider struct {
+	server     *httptest.Server
+	connection *tools.SAMLConnection
+	key        *rsa.PrivateKey
+	cert       *x509.Certificate
+}
+
+// Contents of an Assertion issued by the Provider
+type testSAMLAssertion struct {
+	InResponseTo string
+	NameID       string
+	Attributes   map[string]string
+}
+
+// Start Provider and register it as a SAML Connection under the given Name
+func newTestSAMLProvider(name string) *testSAMLProvider {
+	p := &testSAMLProvider{}
+	p.key, p.cert = mustSelfSignedCertificate("idp")
+	if tools.SAMLKey == nil {
+		tools.SAMLKey, tools.SAMLCertificate = mustSelfSignedCertificate("sp")
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metadata", p.metadata)
+	p.server = httptest.NewServer(mux)
+
+	p.connection = &tools.SAMLConnection{
+		Name:                 name,
+		MetadataURL:          p.server.URL + "/metadata",
+		TrustEmail:           true,
+		EmailDomains:         []string{"email.org"},
+		AttributeEmail:       tools.SAML_ATTRIBUTE_EMAIL,
+		AttributeUsername:    tools.SAML_ATTRIBUTE_USERNAME,
+		AttributeDisplayname: tools.SAML_ATTRIBUTE_DISPLAYNAME,
+	}
+	tools.SAML[name] = p.connection
+	return p
+}
+
+func mustSelfSignedCertificate(name string) (*rsa.PrivateKey, *x509.Certificate) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		panic(err)
+	}
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		panic(err)
+	}
+	return key, cert
+}
+
+// Simulate the Browser following the Redirect to the Provider, returns the
+// ID of the AuthnRequest once its Signature is Verified
+func (p *testSAMLProvider) Authorize(t *testing.T, requestURL string) string {
+	u, err := url.Parse(requestURL)
+	if err != nil {
+		t.Fatalf("invalid request url: %s", err)
+	}
+	query := u.Query()
+
+	// Verify Query Signature, computed over the Parameters as sent
+	signed, _, _ := strings.Cut(u.RawQuery, "&Signature=")
+	signature, err := base64.StdEncoding.DecodeString(query.Get("Signature"))
+	if err != nil {
+		t.Fatalf("unexpected request signature: %s", u.RawQuery)
+	}
+	digest := sha256.Sum256([]byte(signed))
+	switch key := tools.SAMLKey.Public().(type) {
+	case *rsa.PublicKey:
+		if query.Get("SigAlg") != tools.XML_ALG_RSA_SHA256 {
+			t.Fatalf("unexpected request algorithm: %s", query.Get("SigAlg"))
+		}
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+			t.Fatalf("invalid request signature: %s", err)
+		}
+	case *ecdsa.PublicKey:
+		if query.Get("SigAlg") != tools.XML_ALG_ECDSA_SHA256 || len(signature) != 64 {
+			t.Fatalf("unexpected request algorithm: %s", query.Get("SigAlg"))
+		}
+		r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(key, digest[:], r, s) {
+			t.Fatalf("invalid request signature")
+		}
+	}
+
+	// Read Request
+	compressed, err := base64.StdEncoding.DecodeString(query.Get("SAMLRequest"))
+	if err != nil {
+		t.Fatalf("invalid request encoding: %s", err)
+	}
+	data, err := io.ReadAll(flate.NewReader(bytes.NewReader(compressed)))
+	if err != nil {
+		t.Fatalf("invalid request compression: %s", err)
+	}
+	request, err := tools.ParseXML(data)
+	if err != nil {
+		t.Fatalf("invalid request: %s", err)
+	}
+	if request.Local != "AuthnRequest" || request.Attr("AssertionConsumerServiceURL") != p.connection.ConsumerURL() {
+		t.Fatalf("unexpected request: %s", data)
+	}
+	return request.Attr("ID")
+}
+
+// Generate an encoded Response carrying a Signed Assertion
+func (p *testSAMLProvider) Response(t *testing.T, a testSAMLAssertion) string {
+	return base64.StdEncoding.EncodeToString([]byte(p.sign(t, p.response(a))))
+}
+
+// Generate an encoded Response, then modify it after it was Signed
+func (p *testSAMLProvider) TamperedResponse(t *testing.T, a testSAMLAssertion, old, new string) string {
+	signed := p.sign(t, p.response(a))
+	return base64.StdEncoding.EncodeToString([]byte(strings.Replace(signed, old, new, 1)))
+}
+
+func (p *testSAMLProvider) response(a testSAMLAssertion) string {
+	now := time.Now().UTC()
+	expires := now.Add(5 * time.Minute).Format(time.RFC3339)
+	inResponseTo := ""
+	if a.InResponseTo != "" {
+		inResponseTo = fmt.Sprintf(` InResponseTo="%s"`, a.InResponseTo)
+	}
+	var attributes strings.Builder
+	for k, v := range a.Attributes {
+		fmt.Fprintf(&attributes, `<saml:Attribute Name="%s"><saml:AttributeValue>%s</saml:AttributeValue></saml:Attribute>`, k, v)
+	}
+	return fmt.Sprintf(
+		`<samlp:Response xmlns:samlp="%[1]s" xmlns:saml="%[2]s" ID="%[3]s" Version="2.0" IssueInstant="%[4]s" Destination="%[5]s"%[6]s>`+
+			`<saml:Issuer>%[7]s</saml:Issuer>`+
+			`<samlp:Status><samlp:StatusCode Value="%[8]s"/></samlp:Status>`+
+			`<saml:Assertion ID="%[9]s" Version="2.0" IssueInstant="%[4]s">`+
+			`<saml:Issuer>%[7]s</saml:Issuer><!--signature-->`+
+			`<saml:Subject><saml:NameID Format="%[10]s">%[11]s</saml:NameID>`+
+			`<saml:SubjectConfirmation Method="%[12]s"><saml:SubjectConfirmationData Recipient="%[5]s" NotOnOrAfter="%[13]s"%[6]s/></saml:SubjectConfirmation></saml:Subject>`+
+			`<saml:Conditions NotBefore="%[4]s" NotOnOrAfter="%[13]s"><saml:AudienceRestriction><saml:Audience>%[14]s</saml:Audience></saml:AudienceRestriction></saml:Conditions>`+
+			`<saml:AttributeStatement>%[15]s</saml:AttributeStatement>`+
+			`</saml:Assertion></samlp:Response>`,
+		tools.SAML_NS_PROTOCOL,
+		tools.SAML_NS_ASSERTION,
+		tools.GenerateSAMLID(),
+		now.Format(time.RFC3339),
+		p.connection.ConsumerURL(),
+		inResponseTo,
+		p.server.URL,
+		tools.SAML_STATUS_SUCCESS,
+		tools.GenerateSAMLID(),
+		tools.SAML_NAMEID_UNSPECIFIED,
+		a.NameID,
+		tools.SAML_CONFIRMATION,
+		expires,
+		p.connection.ServiceEntityID(),
+		attributes.String(),
+	)
+}
+
+// Replace the Signature Marker with an Enveloped Signature over the Assertion
+func (p *testSAMLProvider) sign(t *testing.T, response string) string {
+	document, err := tools.ParseXML([]byte(response))
+	if err != nil {
+		t.Fatalf("invalid response: %s", err)
+	}
+	assertion := document.Child(tools.SAML_NS_ASSERTION, "Assertion")
+	digest := sha256.Sum256(tools.XMLCanonicalize(assertion, nil, nil))
+
+	signedInfo := fmt.Sprintf(
+		`<ds:SignedInfo xmlns:ds="%s">`+
+			`<ds:CanonicalizationMethod Algorithm="%s"/>`+
+			`<ds:SignatureMethod Algorithm="%s"/>`+
+			`<ds:Reference URI="#%s"><ds:Transforms>`+
+			`<ds:Transform Algorithm="%s"/><ds:Transform Algorithm="%s"/>`+
+			`</ds:Transforms><ds:DigestMethod Algorithm="%s"/><ds:DigestValue>%s</ds:DigestValue></ds:Reference>`+
+			`</ds:SignedInfo>`,
+		tools.XML_NS_DSIG,
+		tools.XML_ALG_EXC_C14N,
+		tools.XML_ALG_RSA_SHA256,
+		assertion.Attr("ID"),
+		tools.XML_ALG_ENVELOPED,
+		tools.XML_ALG_EXC_C14N,
+		tools.XML_ALG_SHA256,
+		base64.StdEncoding.EncodeToString(digest[:]),
+	)
+	element, err := tools.ParseXML([]byte(signedInfo))
+	if err != nil {
+		t.Fatalf("invalid signed info: %s", err)
+	}
+	hashed := sha256.Sum256(tools.XMLCanonicalize(element, nil, nil))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, hashed[:])
+	if err != nil {
+		t.Fatalf("signing failed: %s", err)
+	}
+
+	return strings.Replace(response, "<!--signature-->", fmt.Sprintf(
+		`<ds:Signature xmlns:ds="%s">%s<ds:SignatureValue>%s</ds:SignatureValue></ds:Signature>`,
+		tools.XML_NS_DSIG,
+		strings.Replace(signedInfo, ` xmlns:ds="`+tools.XML_NS_DSIG+`"`, "", 1),
+		base64.StdEncoding.EncodeToString(signature),
+	), 1)
+}
+
+func (p *testSAMLProvider) metadata(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/samlmetadata+xml")
+	fmt.Fprintf(w,
+		`<md:EntityDescriptor xmlns:md="%s" xmlns:ds="%s" entityID="%s"><md:IDPSSODescriptor protocolSupportEnumeration="%s">`+
+			`<md:KeyDescriptor use="signing"><ds:KeyInfo><ds:X509Data><ds:X509Certificate>%s</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>`+
+			`<md:SingleSignOnService Binding="%s" Location="%s"/>`+
+			`</md:IDPSSODescriptor></md:EntityDescriptor>`,
+		tools.SAML_NS_METADATA,
+		tools.XML_NS_DSIG,
+		p.server.URL,
+		tools.SAML_NS_PROTOCOL,
+		base64.StdEncoding.EncodeToString(p.cert.Raw),
+		tools.SAML_BINDING_REDIRECT,
+		p.server.URL+"/sso",
+	)
+}
@@ -0,0 +1,296 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+const (
+	TEST_SMTP_USERNAME = "relay"
+	TEST_SMTP_PASSWORD = "hunter2"
+)
+
+// Local SMTP Relay which accepts every Message once authenticated, the
+// Security Mode is one of the values allowed for EMAIL_SMTP_SECURITY
+type testSMTPServer struct {
+	listener    net.Listener
+	tls         *tls.Config
+	implicit    bool
+	caFile      string
+	mutex       sync.Mutex
+	conns       []net.Conn
+	connections int
+	messages    []testSMTPMessage
+}
+
+type testSMTPMessage struct {
+	From      string
+	To        []string
+	Data      string
+	Mechanism string
+	Secure    bool
+}
+
+func newTestSMTPServer(t *testing.T, security string) *testSMTPServer {
+	s := &testSMTPServer{implicit: security == "tls"}
+
+	// Generate Certificate for the Loopback Address
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("cannot generate key: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:              []string{"localhost"},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("cannot create certificate: %s", err)
+	}
+	s.caFile = filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(s.caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("cannot write certificate: %s", err)
+	}
+	if security != "none" {
+		s.tls = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	}
+
+	// Start Listening
+	s.listener, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %s", err)
+	}
+	if s.implicit {
+		s.listener = tls.NewListener(s.listener, s.tls)
+	}
+	go func() {
+		for {
+			conn, err := s.listener.Accept()
+			if err != nil {
+				return
+			}
+			s.mutex.Lock()
+			s.conns = append(s.conns, conn)
+			s.connections++
+			s.mutex.Unlock()
+			go s.handle(conn)
+		}
+	}()
+	t.Cleanup(func() {
+		s.listener.Close()
+		s.drop()
+	})
+	return s
+}
+
+// Use the Server as the Email Provider for the remainder of the Test
+func (s *testSMTPServer) use(t *testing.T, security, auth string) {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+
+	previous := tools.Email
+	tools.EMAIL_PROVIDER = "smtp"
+	tools.EMAIL_SMTP_HOST = host
+	tools.EMAIL_SMTP_PORT = portNumber
+	tools.EMAIL_SMTP_SECURITY = security
+	tools.EMAIL_SMTP_AUTH = auth
+	tools.EMAIL_SMTP_USERNAME = TEST_SMTP_USERNAME
+	tools.EMAIL_SMTP_PASSWORD = TEST_SMTP_PASSWORD
+	tools.EMAIL_SMTP_TLS_CA = s.caFile
+
+	stopCtx, stop := context.WithCancel(context.Background())
+	var stopWg sync.WaitGroup
+	tools.SetupEmailProvider(stopCtx, &stopWg)
+	t.Cleanup(func() {
+		stop()
+		stopWg.Wait()
+		tools.Email = previous
+		tools.EMAIL_PROVIDER = "test"
+	})
+}
+
+// Messages received so far
+func (s *testSMTPServer) received() ([]testSMTPMessage, int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]testSMTPMessage{}, s.messages...), s.connections
+}
+
+// Close every open Connection, as Relays do with Idle Clients
+func (s *testSMTPServer) drop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, c := range s.conns {
+		c.Close()
+	}
+	s.conns = nil
+}
+
+func (s *testSMTPServer) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(lines ...string) {
+		for _, l := range lines {
+			conn.Write([]byte(l + "\r\n"))
+		}
+	}
+	read := func() (string, bool) {
+		line, err := r.ReadString('\n')
+		return strings.TrimRight(line, "\r\n"), err == nil
+	}
+
+	var message testSMTPMessage
+	secure := s.implicit
+	reply("220 127.0.0.1 ESMTP test")
+	for {
+		line, ok := read()
+		if !ok {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+
+		case "EHLO", "HELO":
+			reply("250-127.0.0.1")
+			if s.tls != nil && !secure {
+				reply("250-STARTTLS")
+			}
+			reply("250-AUTH PLAIN LOGIN CRAM-MD5", "250 8BITMIME")
+
+		case "STARTTLS":
+			if s.tls == nil || secure {
+				reply("502 not supported")
+				continue
+			}
+			reply("220 ready")
+			tc := tls.Server(conn, s.tls)
+			if err := tc.Handshake(); err != nil {
+				return
+			}
+			conn, r, secure = tc, bufio.NewReader(tc), true
+
+		case "AUTH":
+			mechanism, initial, _ := strings.Cut(arg, " ")
+			mechanism = strings.ToUpper(mechanism)
+			var username, password string
+			switch mechanism {
+			case "PLAIN":
+				if initial == "" {
+					reply("334 ")
+					initial, _ = read()
+				}
+				decoded, _ := base64.StdEncoding.DecodeString(initial)
+				if parts := strings.Split(string(decoded), "\x00"); len(parts) == 3 {
+					username, password = parts[1], parts[2]
+				}
+			case "LOGIN":
+				reply("334 " + base64.StdEncoding.EncodeToString([]byte("Username:")))
+				line, _ := read()
+				decoded, _ := base64.StdEncoding.DecodeString(line)
+				username = string(decoded)
+				reply("334 " + base64.StdEncoding.EncodeToString([]byte("Password:")))
+				line, _ = read()
+				decoded, _ = base64.StdEncoding.DecodeString(line)
+				password = string(decoded)
+			case "CRAM-MD5":
+				challenge := "<" + strconv.FormatInt(time.Now().UnixNano(), 10) + "@127.0.0.1>"
+				reply("334 " + base64.StdEncoding.EncodeToString([]byte(challenge)))
+				line, _ := read()
+				decoded, _ := base64.StdEncoding.DecodeString(line)
+				name, digest, _ := strings.Cut(string(decoded), " ")
+				h := hmac.New(md5.New, []byte(TEST_SMTP_PASSWORD))
+				h.Write([]byte(challenge))
+				if digest == hex.EncodeToString(h.Sum(nil)) {
+					username, password = name, TEST_SMTP_PASSWORD
+				}
+			}
+			if username != TEST_SMTP_USERNAME || password != TEST_SMTP_PASSWORD {
+				reply("535 invalid credentials")
+				continue
+			}
+			message.Mechanism = mechanism
+			reply("235 authenticated")
+
+		case "MAIL":
+			if message.Mechanism == "" {
+				reply("530 authentication required")
+				continue
+			}
+			message.From = smtpPath(arg)
+			reply("250 ok")
+
+		case "RCPT":
+			message.To = append(message.To, smtpPath(arg))
+			reply("250 ok")
+
+		case "DATA":
+			reply("354 end with <CRLF>.<CRLF>")
+			var data strings.Builder
+			for {
+				line, ok := read()
+				if !ok {
+					return
+				}
+				if line == "." {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(line, ".") + "\r\n")
+			}
+			message.Data, message.Secure = data.String(), secure
+			s.mutex.Lock()
+			s.messages = append(s.messages, message)
+			s.mutex.Unlock()
+			message.From, message.To = "", nil
+			reply("250 queued")
+
+		case "RSET":
+			message.From, message.To = "", nil
+			reply("250 ok")
+
+		case "NOOP":
+			reply("250 ok")
+
+		case "QUIT":
+			reply("221 bye")
+			return
+
+		default:
+			reply("502 unknown command")
+		}
+	}
+}
+
+// Extract the Address from a "FROM:<address> [params]" or "TO:<address>" Argument
+func smtpPath(arg string) string {
+	_, path, _ := strings.Cut(arg, "<")
+	path, _, _ = strings.Cut(path, ">")
+	return path
+}
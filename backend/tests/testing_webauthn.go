@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"strconv"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+// Software Authenticator which creates ES256 Passkeys bound to the configured Relying Party
+type testAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+}
+
+func newTestAuthenticator(userID int64) *testAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	credentialID := make([]byte, 16)
+	rand.Read(credentialID)
+	return &testAuthenticator{
+		key:          key,
+		credentialID: credentialID,
+		userHandle:   []byte(strconv.FormatInt(userID, 10)),
+	}
+}
+
+// Credential ID as sent to the Server
+func (a *testAuthenticator) ID() string {
+	return tools.WebAuthnEncode(a.credentialID)
+}
+
+// Respond to Creation Options, returns the Body for Passkey Setup
+func (a *testAuthenticator) Register(name, challenge string) map[string]any {
+	x, y := make([]byte, 32), make([]byte, 32)
+	a.key.X.FillBytes(x)
+	a.key.Y.FillBytes(y)
+	publicKey := encodeCBOR(map[any]any{
+		int64(1):  int64(2),
+		int64(3):  int64(tools.COSE_ALG_ES256),
+		int64(-1): int64(1),
+		int64(-2): x,
+		int64(-3): y,
+	})
+
+	// Attested Credential Data
+	attested := make([]byte, 18)
+	binary.BigEndian.PutUint16(attested[16:], uint16(len(a.credentialID)))
+	attested = append(append(attested, a.credentialID...), publicKey...)
+	authData := append(a.authenticatorData(tools.WEBAUTHN_FLAG_AT), attested...)
+
+	return map[string]any{
+		"name":        name,
+		"client_data": tools.WebAuthnEncode(a.clientData(tools.WEBAUTHN_CEREMONY_CREATE, challenge)),
+		"attestation_object": tools.WebAuthnEncode(encodeCBOR(map[any]any{
+			"fmt":      "none",
+			"attStmt":  map[any]any{},
+			"authData": authData,
+		})),
+	}
+}
+
+// Respond to Request Options, returns the Body for Passkey Login or Escalation
+func (a *testAuthenticator) Assert(challenge string) map[string]any {
+	a.signCount++
+	authData := a.authenticatorData(0)
+	clientData := a.clientData(tools.WEBAUTHN_CEREMONY_GET, challenge)
+	clientHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		panic(err)
+	}
+	return map[string]any{
+		"credential_id":      a.ID(),
+		"client_data":        tools.WebAuthnEncode(clientData),
+		"authenticator_data": tools.WebAuthnEncode(authData),
+		"signature":          tools.WebAuthnEncode(signature),
+		"user_handle":        tools.WebAuthnEncode(a.userHandle),
+	}
+}
+
+func (a *testAuthenticator) clientData(ceremony, challenge string) []byte {
+	b, _ := json.Marshal(map[string]any{
+		"type":      ceremony,
+		"challenge": challenge,
+		"origin":    tools.WEBAUTHN_ORIGINS[0],
+	})
+	return b
+}
+
+func (a *testAuthenticator) authenticatorData(flags byte) []byte {
+	rpHash := sha256.Sum256([]byte(tools.WEBAUTHN_RP_ID))
+	data := append(rpHash[:], flags|tools.WEBAUTHN_FLAG_UP|tools.WEBAUTHN_FLAG_UV, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(data[33:], a.signCount)
+	return data
+}
+
+// Encode the CBOR subset understood by tools.DecodeCBOR
+func encodeCBOR(v any) []byte {
+	head := func(major byte, n uint64) []byte {
+		switch {
+		case n < 24:
+			return []byte{major<<5 | byte(n)}
+		case n <= 0xFF:
+			return []byte{major<<5 | 24, byte(n)}
+		case n <= 0xFFFF:
+			return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+		case n <= 0xFFFFFFFF:
+			return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(n))
+		default:
+			return binary.BigEndian.AppendUint64([]byte{major<<5 | 27}, n)
+		}
+	}
+	switch v := v.(type) {
+	case int64:
+		if v < 0 {
+			return head(1, uint64(-1-v))
+		}
+		return head(0, uint64(v))
+	case []byte:
+		return append(head(2, uint64(len(v))), v...)
+	case string:
+		return append(head(3, uint64(len(v))), v...)
+	case map[any]any:
+		b := head(5, uint64(len(v)))
+		for key, value := range v {
+			b = append(b, encodeCBOR(key)...)
+			b = append(b, encodeCBOR(value)...)
+		}
+		return b
+	default:
+		panic("unsupported cbor type")
+	}
+}
@@ -0,0 +1,136 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+func encodeEmailMIME(t *testing.T, message tools.EmailMessage) *mail.Message {
+	data, err := tools.EncodeEmailMIME(mail.Address{Address: tools.EMAIL_SENDER_ADDRESS}, message)
+	if err != nil {
+		t.Fatalf("cannot encode message: %s", err)
+	}
+	parsed, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("cannot parse message: %s", err)
+	}
+	return parsed
+}
+
+func Test_Email_MIME(t *testing.T) {
+
+	t.Run("HTML Only", func(t *testing.T) {
+		parsed := encodeEmailMIME(t, tools.EmailMessage{
+			To:      TEST_EMAIL_PRIMARY,
+			Subject: "Hello",
+			HTML:    "<p>Hello</p>",
+		})
+		if !strings.HasPrefix(parsed.Header.Get("Content-Type"), "text/html") {
+			t.Errorf("unexpected content type: %s", parsed.Header.Get("Content-Type"))
+		}
+		if parsed.Header.Get("Message-ID") == "" {
+			t.Errorf("message id was not generated")
+		}
+	})
+
+	t.Run("Attachments", func(t *testing.T) {
+		attachment := bytes.Repeat([]byte{0x00, 0xFF, '\n'}, 100)
+		parsed := encodeEmailMIME(t, tools.EmailMessage{
+			To:      TEST_EMAIL_PRIMARY,
+			Subject: "Your Data Export",
+			HTML:    "<p>Attached</p>",
+			Text:    "Attached",
+			Attachments: []tools.EmailAttachment{
+				{Filename: "export.bin", ContentType: "application/octet-stream", Data: attachment},
+			},
+		})
+		mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+		if err != nil || mediaType != "multipart/mixed" {
+			t.Fatalf("unexpected content type: %s", parsed.Header.Get("Content-Type"))
+		}
+		parts := multipart.NewReader(parsed.Body, params["boundary"])
+
+		// Bodies are nested as Alternatives
+		part, err := parts.NextPart()
+		if err != nil {
+			t.Fatalf("cannot read body part: %s", err)
+		}
+		if mediaType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type")); mediaType != "multipart/alternative" {
+			t.Errorf("unexpected body part: %s", part.Header.Get("Content-Type"))
+		}
+
+		// Attachments are Base64 Encoded
+		part, err = parts.NextPart()
+		if err != nil {
+			t.Fatalf("cannot read attachment part: %s", err)
+		}
+		if part.FileName() != "export.bin" || part.Header.Get("Content-Transfer-Encoding") != "base64" {
+			t.Errorf("unexpected attachment headers: %v", part.Header)
+		}
+		encoded, _ := io.ReadAll(part)
+		decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(encoded), "\r\n", ""))
+		if err != nil || !bytes.Equal(decoded, attachment) {
+			t.Errorf("attachment was corrupted")
+		}
+		if _, err := parts.NextPart(); err != io.EOF {
+			t.Errorf("unexpected extra part")
+		}
+	})
+
+	t.Run("Headers", func(t *testing.T) {
+		previousReplyTo, previousUnsubscribe := tools.EMAIL_REPLY_TO, tools.EMAIL_LIST_UNSUBSCRIBE
+		tools.EMAIL_REPLY_TO = "support@example.org"
+		tools.EMAIL_LIST_UNSUBSCRIBE = "mailto:unsubscribe@example.org, https://example.org/unsubscribe"
+		t.Cleanup(func() {
+			tools.EMAIL_REPLY_TO, tools.EMAIL_LIST_UNSUBSCRIBE = previousReplyTo, previousUnsubscribe
+		})
+
+		// Defaults come from the Configuration
+		parsed := encodeEmailMIME(t, tools.EmailMessage{
+			To:      TEST_EMAIL_PRIMARY,
+			Subject: "Hello",
+			Text:    "Hello",
+			Headers: map[string]string{"Message-ID": "<fixed@example.org>"},
+		})
+		if parsed.Header.Get("Reply-To") != "<support@example.org>" ||
+			parsed.Header.Get("List-Unsubscribe") != "<mailto:unsubscribe@example.org>, <https://example.org/unsubscribe>" ||
+			parsed.Header.Get("Message-ID") != "<fixed@example.org>" {
+			t.Errorf("unexpected headers: %v", parsed.Header)
+		}
+
+		// Messages may override Defaults
+		parsed = encodeEmailMIME(t, tools.EmailMessage{
+			To:      TEST_EMAIL_PRIMARY,
+			Subject: "Hello",
+			Text:    "Hello",
+			Headers: map[string]string{"reply-to": "Security <security@example.org>"},
+		})
+		if parsed.Header.Get("Reply-To") != `"Security" <security@example.org>` {
+			t.Errorf("unexpected reply-to: %s", parsed.Header.Get("Reply-To"))
+		}
+
+		// Reserved and Injected Headers are refused
+		for _, headers := range []map[string]string{
+			{"Subject": "Overridden"},
+			{"X-Campaign": "value\r\nBcc: victim@example.org"},
+		} {
+			_, err := tools.EncodeEmailMIME(mail.Address{Address: tools.EMAIL_SENDER_ADDRESS}, tools.EmailMessage{
+				To:      TEST_EMAIL_PRIMARY,
+				Subject: "Hello",
+				Text:    "Hello",
+				Headers: headers,
+			})
+			if err == nil {
+				t.Errorf("expected headers to be refused: %v", headers)
+			}
+		}
+	})
+}
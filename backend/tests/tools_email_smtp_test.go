@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+func Test_Email_SMTP(t *testing.T) {
+
+	// Long Lines, Equal Signs and Leading Dots must survive Transport
+	message := tools.EmailMessage{
+		To:      TEST_EMAIL_PRIMARY,
+		Subject: "Verify your Email Address ✓",
+		HTML:    "<p style=\"color: red\">" + strings.Repeat("Welcome! ", 20) + "</p>\n.hidden\n",
+		Text:    strings.Repeat("Welcome! ", 20) + "\n.hidden\n",
+	}
+
+	for _, security := range []string{"none", "starttls", "tls"} {
+		for _, auth := range []string{"plain", "login", "cram-md5"} {
+			t.Run(security+" "+auth, func(t *testing.T) {
+				server := newTestSMTPServer(t, security)
+				server.use(t, security, auth)
+
+				for range 2 {
+					if err := tools.Email.Send(message); err != nil {
+						t.Fatalf("send failed: %s", err)
+					}
+				}
+				messages, connections := server.received()
+				if len(messages) != 2 {
+					t.Fatalf("expected 2 messages, got %d", len(messages))
+				}
+				if connections != 1 {
+					t.Errorf("expected connection to be reused, got %d connections", connections)
+				}
+
+				m := messages[0]
+				if m.Mechanism != strings.ToUpper(auth) {
+					t.Errorf("unexpected mechanism: %s", m.Mechanism)
+				}
+				if m.Secure != (security != "none") {
+					t.Errorf("unexpected security: %t", m.Secure)
+				}
+				if m.From != tools.EMAIL_SENDER_ADDRESS || len(m.To) != 1 || m.To[0] != TEST_EMAIL_PRIMARY {
+					t.Errorf("unexpected envelope: %s -> %v", m.From, m.To)
+				}
+
+				// Decode Message
+				parsed, err := mail.ReadMessage(strings.NewReader(m.Data))
+				if err != nil {
+					t.Fatalf("cannot parse message: %s", err)
+				}
+				decodedSubject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+				if err != nil || decodedSubject != message.Subject {
+					t.Errorf("unexpected subject: %s", decodedSubject)
+				}
+				mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+				if err != nil || mediaType != "multipart/alternative" ||
+					parsed.Header.Get("MIME-Version") != "1.0" ||
+					parsed.Header.Get("Message-ID") == "" {
+					t.Errorf("unexpected headers: %v", parsed.Header)
+				}
+				for _, line := range strings.Split(m.Data, "\r\n") {
+					if len(line) > 78 {
+						t.Errorf("line exceeds limit: %s", line)
+					}
+				}
+
+				// Parts are decoded by the Reader, Plain-Text comes first
+				parts := multipart.NewReader(parsed.Body, params["boundary"])
+				for _, expected := range []struct{ ContentType, Body string }{
+					{"text/plain", message.Text},
+					{"text/html", message.HTML},
+				} {
+					part, err := parts.NextPart()
+					if err != nil {
+						t.Fatalf("cannot read part: %s", err)
+					}
+					if !strings.HasPrefix(part.Header.Get("Content-Type"), expected.ContentType) {
+						t.Errorf("unexpected part: %s", part.Header.Get("Content-Type"))
+					}
+					body, err := io.ReadAll(part)
+					if err != nil {
+						t.Fatalf("cannot decode part: %s", err)
+					}
+					if strings.ReplaceAll(string(body), "\r\n", "\n") != expected.Body {
+						t.Errorf("unexpected body: %q", body)
+					}
+				}
+			})
+		}
+	}
+
+	t.Run("Reconnect", func(t *testing.T) {
+		server := newTestSMTPServer(t, "starttls")
+		server.use(t, "starttls", "plain")
+
+		if err := tools.Email.Send(tools.EmailMessage{To: TEST_EMAIL_PRIMARY, Subject: "First", HTML: "<p>First</p>"}); err != nil {
+			t.Fatalf("send failed: %s", err)
+		}
+		server.drop()
+		if err := tools.Email.Send(tools.EmailMessage{To: TEST_EMAIL_PRIMARY, Subject: "Second", HTML: "<p>Second</p>"}); err != nil {
+			t.Fatalf("send after disconnect failed: %s", err)
+		}
+		messages, connections := server.received()
+		if len(messages) != 2 || connections != 2 {
+			t.Fatalf("expected 2 messages over 2 connections, got %d over %d", len(messages), connections)
+		}
+	})
+}
@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+func Test_Field_Encryption(t *testing.T) {
+
+	t.Run("Encrypt Field", func(t *testing.T) {
+		sealed, err := tools.EncryptField(TEST_TOTP_SECRET)
+		if err != nil {
+			t.Fatalf("encryption failed: %s", err)
+		}
+		if !strings.HasPrefix(sealed, tools.FIELD_ENVELOPE_PREFIX) {
+			t.Errorf("envelope is missing prefix: %s", sealed)
+		}
+		if strings.Contains(sealed, TEST_TOTP_SECRET) {
+			t.Errorf("envelope contains plaintext")
+		}
+		if again, _ := tools.EncryptField(TEST_TOTP_SECRET); again == sealed {
+			t.Errorf("envelope is deterministic")
+		}
+		plaintext, err := tools.DecryptField(sealed)
+		if err != nil {
+			t.Fatalf("decryption failed: %s", err)
+		}
+		if plaintext != TEST_TOTP_SECRET {
+			t.Errorf("unexpected plaintext: %s", plaintext)
+		}
+	})
+
+	t.Run("Decrypt Field - Plaintext", func(t *testing.T) {
+		plaintext, err := tools.DecryptField(TEST_EMAIL_PRIMARY)
+		if err != nil || plaintext != TEST_EMAIL_PRIMARY {
+			t.Errorf("unexpected plaintext: %s (%v)", plaintext, err)
+		}
+	})
+
+	t.Run("Decrypt Field - Tampered", func(t *testing.T) {
+		sealed, _ := tools.EncryptField(TEST_EMAIL_PRIMARY)
+		tampered := sealed[:len(sealed)-2] + "AA"
+		if tampered == sealed {
+			tampered = sealed[:len(sealed)-2] + "BB"
+		}
+		if _, err := tools.DecryptField(tampered); err == nil {
+			t.Errorf("tampered envelope was accepted")
+		}
+		if _, err := tools.DecryptField(tools.FIELD_ENVELOPE_PREFIX + "malformed"); err == nil {
+			t.Errorf("malformed envelope was accepted")
+		}
+	})
+
+	t.Run("Email Index", func(t *testing.T) {
+		if tools.EmailIndex(TEST_EMAIL_PRIMARY) != tools.EmailIndex(strings.ToUpper(TEST_EMAIL_PRIMARY)) {
+			t.Errorf("index is case sensitive")
+		}
+		if tools.EmailIndex(TEST_EMAIL_PRIMARY) == tools.EmailIndex(TEST_EMAIL_SECONDARY) {
+			t.Errorf("different addresses share an index")
+		}
+		if tools.UserNameIndex(TEST_EMAIL_PRIMARY) == tools.EmailIndex(TEST_EMAIL_PRIMARY) {
+			t.Errorf("different columns share an index")
+		}
+	})
+
+	t.Run("Migrate Plaintext Account", func(t *testing.T) {
+		ResetDatabase(t, RESET_BASE, RESET_ACCOUNT, RESET_ACCOUNT_MFA, RESET_PROFILE, RESET_SESSION, RESET_DEVICE)
+		ExecDatabase(t, "UPDATE auth.users SET email_index = NULL WHERE id = $1", TEST_ID_PRIMARY)
+		ExecDatabase(t, "UPDATE auth.devices SET ip_index = NULL WHERE id = $1", TEST_ID_PRIMARY)
+
+		if _, err := tools.MigrateFields(t.Context()); err != nil {
+			t.Fatalf("migration failed: %s", err)
+		}
+		var email, index, secret, address string
+		QueryDatabaseRow(t,
+			`SELECT u.email_address, u.email_index, u.mfa_secret, s.device_ip_address
+			FROM auth.users u JOIN auth.sessions s ON s.user_id = u.id
+			WHERE u.id = $1`,
+			[]any{TEST_ID_PRIMARY},
+			&email, &index, &secret, &address,
+		)
+		for _, stored := range []string{email, secret, address} {
+			if !strings.HasPrefix(stored, tools.FIELD_ENVELOPE_PREFIX) {
+				t.Errorf("value was not encrypted: %s", stored)
+			}
+		}
+		if index != tools.EmailIndex(TEST_EMAIL_PRIMARY) {
+			t.Errorf("address was not indexed")
+		}
+
+		// Device Addresses are Indexed for Lookups
+		var deviceAddress, deviceIndex, deviceAgent string
+		QueryDatabaseRow(t, "SELECT ip_address, ip_index, user_agent FROM auth.devices WHERE id = $1",
+			[]any{TEST_ID_PRIMARY},
+			&deviceAddress, &deviceIndex, &deviceAgent,
+		)
+		for _, stored := range []string{deviceAddress, deviceAgent} {
+			if !strings.HasPrefix(stored, tools.FIELD_ENVELOPE_PREFIX) {
+				t.Errorf("value was not encrypted: %s", stored)
+			}
+		}
+		if deviceIndex != tools.AddressIndex(TEST_IP_ADDRESS) {
+			t.Errorf("device address was not indexed")
+		}
+
+		// Routes see the Plaintext
+		NewTestRequest(t, "GET", "/users/@me").
+			WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+			Send().
+			ExpectStatus(http.StatusOK).
+			ExpectJSON().
+			ExpectString("email", TEST_EMAIL_PRIMARY)
+	})
+}
@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+func Test_Keystore(t *testing.T) {
+
+	t.Run("Rotation", func(t *testing.T) {
+		signedBefore, err := tools.GenerateSignedString()
+		if err != nil {
+			t.Fatalf("cannot sign string: %s", err)
+		}
+		currentHMAC, err := tools.KeystoreCurrent(tools.KEY_USAGE_HMAC)
+		if err != nil {
+			t.Fatalf("missing hmac key: %s", err)
+		}
+		currentSigned, err := tools.KeystoreCurrent(tools.KEY_USAGE_SIGNATURE)
+		if err != nil {
+			t.Fatalf("missing signing key: %s", err)
+		}
+		rotationTime := currentSigned.Activates.Add(tools.KEYSTORE_ROTATION_INTERVAL - tools.KEYSTORE_PUBLISH_DELAY/2)
+
+		t.Run("Publish Upcoming Keys", func(t *testing.T) {
+			if err := tools.KeystoreRotate(t.Context(), rotationTime); err != nil {
+				t.Fatalf("rotation failed: %s", err)
+			}
+			published := tools.KeystorePublished(tools.KEY_USAGE_SIGNATURE)
+			if len(published) != 2 {
+				t.Fatalf("expected 2 published signing keys, got %d", len(published))
+			}
+			NewTestRequest(t, "GET", "/oauth2/jwks").
+				Send().
+				ExpectStatus(http.StatusOK).
+				ExpectField("keys")
+		})
+
+		t.Run("Upcoming Keys are not used before Activation", func(t *testing.T) {
+			if k, _ := tools.KeystoreCurrent(tools.KEY_USAGE_HMAC); k.ID != currentHMAC.ID {
+				t.Errorf("signing with upcoming key %s, want %s", k.ID, currentHMAC.ID)
+			}
+		})
+
+		t.Run("Retired Keys Validate until Expiry", func(t *testing.T) {
+			retired, ok := tools.KeystoreLookup(tools.KEY_USAGE_HMAC, currentHMAC.ID)
+			if !ok {
+				t.Fatalf("retired key is missing")
+			}
+			if retired.Expires == nil || retired.Expires.Before(time.Now().Add(tools.KEYSTORE_ROTATION_OVERLAP)) {
+				t.Errorf("retired key expires too early: %v", retired.Expires)
+			}
+			if !tools.CompareSignedString(signedBefore) {
+				t.Errorf("string signed with retired key no longer validates")
+			}
+		})
+	})
+
+}
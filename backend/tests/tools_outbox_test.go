@@ -0,0 +1,158 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+// Records Emails instead of sending them, failing the given number of times first
+type outboxProvider struct {
+	Failures int
+	Sent     []tools.EmailMessage
+}
+
+func (o *outboxProvider) Start(stop context.Context, await *sync.WaitGroup) error {
+	return nil
+}
+
+func (o *outboxProvider) Send(message tools.EmailMessage) error {
+	if o.Failures > 0 {
+		o.Failures--
+		return errors.New("provider unavailable")
+	}
+	o.Sent = append(o.Sent, message)
+	return nil
+}
+
+func useOutboxProvider(t *testing.T, failures int) *outboxProvider {
+	provider := &outboxProvider{Failures: failures}
+	previous := tools.Email
+	tools.Email = provider
+	t.Cleanup(func() { tools.Email = previous })
+	return provider
+}
+
+func enqueueOutbox(t *testing.T) {
+	tools.TemplateEmailVerify(TEST_EMAIL_PRIMARY, tools.LocalsEmailVerify{
+		Displayname: TEST_DISPLAYNAME_PRIMARY,
+		Token:       TEST_TOKEN_PRIMARY,
+	})
+}
+
+func processOutbox(t *testing.T, expected int) {
+	claimed, err := tools.ProcessOutbox(t.Context())
+	if err != nil {
+		t.Fatalf("processing failed: %s", err)
+	}
+	if claimed != expected {
+		t.Fatalf("expected %d claimed emails, got %d", expected, claimed)
+	}
+}
+
+func Test_Email_Outbox(t *testing.T) {
+
+	t.Run("Enqueue and Send", func(t *testing.T) {
+		ResetDatabase(t, RESET_BASE)
+		provider := useOutboxProvider(t, 0)
+
+		// Duplicates share an Idempotency Key
+		enqueueOutbox(t)
+		enqueueOutbox(t)
+		var count int
+		var address, body, text string
+		QueryDatabaseRow(t, "SELECT COUNT(*), MIN(address), MIN(body), MIN(text) FROM auth.email_outbox",
+			[]any{}, &count, &address, &body, &text,
+		)
+		if count != 1 {
+			t.Fatalf("expected one enqueued email, got %d", count)
+		}
+		if !strings.HasPrefix(address, tools.FIELD_ENVELOPE_PREFIX) ||
+			!strings.HasPrefix(body, tools.FIELD_ENVELOPE_PREFIX) ||
+			!strings.HasPrefix(text, tools.FIELD_ENVELOPE_PREFIX) {
+			t.Errorf("email was stored unencrypted")
+		}
+
+		processOutbox(t, 1)
+		if len(provider.Sent) != 1 || provider.Sent[0].To != TEST_EMAIL_PRIMARY {
+			t.Fatalf("unexpected sent emails: %v", provider.Sent)
+		}
+		m := provider.Sent[0]
+		if !strings.Contains(m.HTML, TEST_TOKEN_PRIMARY) || !strings.Contains(m.Text, TEST_TOKEN_PRIMARY) {
+			t.Errorf("email is missing a body")
+		}
+		if strings.Contains(m.Text, "<") {
+			t.Errorf("plain-text body contains markup: %s", m.Text)
+		}
+		if m.Headers["Message-ID"] == "" || m.Tags["template"] != "EMAIL_VERIFY" {
+			t.Errorf("unexpected headers or tags: %v %v", m.Headers, m.Tags)
+		}
+		var sent bool
+		QueryDatabaseRow(t, "SELECT sent IS NOT NULL FROM auth.email_outbox", []any{}, &sent)
+		if !sent {
+			t.Errorf("email was not marked as sent")
+		}
+		processOutbox(t, 0)
+	})
+
+	t.Run("Retry with Backoff", func(t *testing.T) {
+		ResetDatabase(t, RESET_BASE)
+		provider := useOutboxProvider(t, 1)
+
+		enqueueOutbox(t)
+		processOutbox(t, 1)
+		var attempts int
+		var delayed bool
+		var lastError string
+		QueryDatabaseRow(t,
+			"SELECT attempts, attempt_after > NOW(), last_error FROM auth.email_outbox",
+			[]any{}, &attempts, &delayed, &lastError,
+		)
+		if attempts != 1 || !delayed || lastError == "" {
+			t.Fatalf("unexpected state: attempts=%d delayed=%t error=%q", attempts, delayed, lastError)
+		}
+
+		// Not Due until the Backoff passes
+		processOutbox(t, 0)
+		ExecDatabase(t, "UPDATE auth.email_outbox SET attempt_after = NOW() - INTERVAL '1 second'")
+		processOutbox(t, 1)
+		if len(provider.Sent) != 1 {
+			t.Fatalf("expected email to be sent on retry")
+		}
+	})
+
+	t.Run("Dead-Letter", func(t *testing.T) {
+		ResetDatabase(t, RESET_BASE)
+		provider := useOutboxProvider(t, tools.EMAIL_OUTBOX_ATTEMPT_LIMIT)
+
+		enqueueOutbox(t)
+		ExecDatabase(t, "UPDATE auth.email_outbox SET attempts = $1", tools.EMAIL_OUTBOX_ATTEMPT_LIMIT-1)
+		processOutbox(t, 1)
+		var failed bool
+		QueryDatabaseRow(t, "SELECT failed IS NOT NULL FROM auth.email_outbox", []any{}, &failed)
+		if !failed {
+			t.Fatalf("email was not dead-lettered")
+		}
+		ExecDatabase(t, "UPDATE auth.email_outbox SET attempt_after = NOW() - INTERVAL '1 second'")
+		processOutbox(t, 0)
+		if len(provider.Sent) != 0 {
+			t.Fatalf("dead-lettered email was sent")
+		}
+	})
+
+	t.Run("Backoff", func(t *testing.T) {
+		if tools.OutboxBackoff(1) != tools.EMAIL_OUTBOX_RETRY_DELAY {
+			t.Errorf("unexpected first delay: %s", tools.OutboxBackoff(1))
+		}
+		if tools.OutboxBackoff(2) != 2*tools.EMAIL_OUTBOX_RETRY_DELAY {
+			t.Errorf("unexpected second delay: %s", tools.OutboxBackoff(2))
+		}
+		if tools.OutboxBackoff(100) != tools.EMAIL_OUTBOX_RETRY_LIMIT {
+			t.Errorf("delay exceeds limit: %s", tools.OutboxBackoff(100))
+		}
+	})
+}
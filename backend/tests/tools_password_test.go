@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bakonpancakz/template-auth/tools"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func Test_Password(t *testing.T) {
+
+	t.Run("Compare across Algorithms", func(t *testing.T) {
+		for name, hasher := range tools.PasswordHashers {
+			hash, err := hasher.Hash(TEST_PASSWORD_PRIMARY)
+			if err != nil {
+				t.Fatalf("%s: hashing failed: %s", name, err)
+			}
+			if ok, err := tools.ComparePasswordHash(hash, TEST_PASSWORD_PRIMARY); err != nil || !ok {
+				t.Errorf("%s: expected password to match, err: %v", name, err)
+			}
+			if ok, err := tools.ComparePasswordHash(hash, TEST_PASSWORD_SECONDARY); err != nil || ok {
+				t.Errorf("%s: expected password to mismatch, err: %v", name, err)
+			}
+			if outdated := tools.PasswordHashOutdated(hash); outdated != (name != tools.PASSWORD_HASH_ALGORITHM) {
+				t.Errorf("%s: unexpected outdated state %t", name, outdated)
+			}
+		}
+	})
+
+	t.Run("Compare Modular Crypt Format", func(t *testing.T) {
+		legacy, err := bcrypt.GenerateFromPassword([]byte(TEST_PASSWORD_PRIMARY), bcrypt.MinCost)
+		if err != nil {
+			t.Fatalf("hashing failed: %s", err)
+		}
+		if ok, err := tools.ComparePasswordHash(string(legacy), TEST_PASSWORD_PRIMARY); err != nil || !ok {
+			t.Errorf("expected password to match, err: %v", err)
+		}
+		if !tools.PasswordHashOutdated(string(legacy)) {
+			t.Errorf("expected modular crypt format hash to be outdated")
+		}
+	})
+
+	t.Run("Outdated Parameters", func(t *testing.T) {
+		hash, err := tools.GeneratePasswordHash(TEST_PASSWORD_PRIMARY)
+		if err != nil {
+			t.Fatalf("hashing failed: %s", err)
+		}
+		previous := tools.PASSWORD_ARGON2_ITERATIONS
+		tools.PASSWORD_ARGON2_ITERATIONS++
+		defer func() { tools.PASSWORD_ARGON2_ITERATIONS = previous }()
+		if !tools.PasswordHashOutdated(hash) {
+			t.Errorf("expected hash with previous parameters to be outdated")
+		}
+	})
+
+	t.Run("Unknown Algorithm", func(t *testing.T) {
+		if _, err := tools.ComparePasswordHash("$md5$teto", TEST_PASSWORD_PRIMARY); !errors.Is(err, tools.ErrPasswordHashAlgorithm) {
+			t.Errorf("expected unknown algorithm error, got %v", err)
+		}
+	})
+}
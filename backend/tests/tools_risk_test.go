@@ -0,0 +1,49 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+func Test_Risk_Networks(t *testing.T) {
+
+	t.Run("Parse Networks", func(t *testing.T) {
+		table, err := tools.ParseRiskNetworks(strings.NewReader(strings.Join([]string{
+			"# Example Networks",
+			"203.0.113.0/24 AS64500",
+			"203.0.113.7/32 AS64500 tor",
+			"2001:db8::/32  AS64501 vpn",
+		}, "\n")))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		for address, expected := range map[string]tools.RiskNetwork{
+			"203.0.113.1":         {ASN: "AS64500"},
+			"203.0.113.7":         {ASN: "AS64500", Anonymous: true},
+			"::ffff:203.0.113.7":  {ASN: "AS64500", Anonymous: true},
+			"2001:db8::1":         {ASN: "AS64501", Anonymous: true},
+			"2001:db8:ffff::ffff": {ASN: "AS64501", Anonymous: true},
+		} {
+			if network, ok := table.Lookup(address); !ok || network != expected {
+				t.Errorf("%s: expected %+v, got %+v", address, expected, network)
+			}
+		}
+		if _, ok := table.Lookup("198.51.100.1"); ok {
+			t.Errorf("expected unknown network")
+		}
+	})
+
+	t.Run("Parse Networks - Malformed", func(t *testing.T) {
+		for _, line := range []string{
+			"203.0.113.0 AS64500",
+			"203.0.113.0/24",
+			"203.0.113.0/24 AS64500 hosting",
+		} {
+			if _, err := tools.ParseRiskNetworks(strings.NewReader(line)); err == nil {
+				t.Errorf("expected error for %q", line)
+			}
+		}
+	})
+}
@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/bakonpancakz/template-auth/tools"
+)
+
+func Test_Token_Hashing(t *testing.T) {
+
+	t.Run("Hash Token", func(t *testing.T) {
+		hashed := tools.HashToken(TEST_TOKEN_PRIMARY)
+		if !strings.HasPrefix(hashed, tools.TOKEN_HASH_PREFIX) {
+			t.Errorf("hash is missing prefix: %s", hashed)
+		}
+		if hashed != tools.HashToken(TEST_TOKEN_PRIMARY) {
+			t.Errorf("hash is not deterministic")
+		}
+		if hashed == tools.HashToken(TEST_TOKEN_SECONDARY) {
+			t.Errorf("different tokens share a hash")
+		}
+	})
+
+	t.Run("Candidates", func(t *testing.T) {
+		candidates := tools.TokenCandidates(TEST_TOKEN_PRIMARY)
+		if !slices.Contains(candidates, TEST_TOKEN_PRIMARY_HASH) {
+			t.Errorf("candidates are missing hash")
+		}
+		if !slices.Contains(candidates, TEST_TOKEN_PRIMARY) {
+			t.Errorf("candidates are missing plaintext")
+		}
+		if slices.Contains(tools.TokenCandidates(TEST_TOKEN_PRIMARY_HASH), TEST_TOKEN_PRIMARY_HASH) {
+			t.Errorf("presented hash matches itself")
+		}
+	})
+
+	t.Run("Compare Token", func(t *testing.T) {
+		if !tools.CompareToken(TEST_TOKEN_PRIMARY, TEST_TOKEN_PRIMARY_HASH) {
+			t.Errorf("token does not match its hash")
+		}
+		if !tools.CompareToken(TEST_TOKEN_PRIMARY, TEST_TOKEN_PRIMARY) {
+			t.Errorf("token does not match its plaintext")
+		}
+		if tools.CompareToken(TEST_TOKEN_SECONDARY, TEST_TOKEN_PRIMARY_HASH) {
+			t.Errorf("incorrect token matches hash")
+		}
+		if tools.CompareToken(TEST_TOKEN_PRIMARY_HASH, TEST_TOKEN_PRIMARY_HASH) {
+			t.Errorf("presented hash matches itself")
+		}
+	})
+
+	t.Run("Migrate Plaintext Session", func(t *testing.T) {
+		ResetDatabase(t, RESET_BASE, RESET_ACCOUNT)
+		ExecDatabase(t,
+			"INSERT INTO auth.sessions (id, user_id, token, device_ip_address, device_user_agent, expires) VALUES ($1, $2, $3, $4, $5, $6)",
+			TEST_ID_PRIMARY, TEST_ID_PRIMARY, TEST_TOKEN_PRIMARY, TEST_IP_ADDRESS, TEST_IP_AGENT, TEST_TOKEN_EXPIRES_FUTURE,
+		)
+
+		// Sessions issued before Hashing keep working
+		NewTestRequest(t, "GET", "/users/@me/security/sessions").
+			WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+			Send().
+			ExpectStatus(http.StatusOK)
+
+		if _, err := tools.MigrateTokens(t.Context()); err != nil {
+			t.Fatalf("migration failed: %s", err)
+		}
+		var stored string
+		QueryDatabaseRow(t, "SELECT token FROM auth.sessions WHERE id = $1",
+			[]any{TEST_ID_PRIMARY},
+			&stored,
+		)
+		if stored != TEST_TOKEN_PRIMARY_HASH {
+			t.Errorf("session token was not migrated: %s", stored)
+		}
+
+		// ...and after Migration, but the Stored Hash is useless on its own
+		NewTestRequest(t, "GET", "/users/@me/security/sessions").
+			WithCookie(tools.HTTP_COOKIE_NAME, TEST_TOKEN_PRIMARY).
+			Send().
+			ExpectStatus(http.StatusOK)
+		NewTestRequest(t, "GET", "/users/@me/security/sessions").
+			WithCookie(tools.HTTP_COOKIE_NAME, stored).
+			Send().
+			ExpectStatus(http.StatusUnauthorized)
+	})
+
+	t.Run("Migrate Plaintext Device", func(t *testing.T) {
+		ResetDatabase(t, RESET_BASE, RESET_ACCOUNT, RESET_DEVICE)
+		ExecDatabase(t, "UPDATE auth.devices SET token = $1 WHERE id = $2", TEST_TOKEN_SECONDARY, TEST_ID_PRIMARY)
+
+		if _, err := tools.MigrateTokens(t.Context()); err != nil {
+			t.Fatalf("migration failed: %s", err)
+		}
+		var stored string
+		QueryDatabaseRow(t, "SELECT token FROM auth.devices WHERE id = $1",
+			[]any{TEST_ID_PRIMARY},
+			&stored,
+		)
+		if stored != TEST_TOKEN_SECONDARY_HASH {
+			t.Errorf("device token was not migrated: %s", stored)
+		}
+	})
+}
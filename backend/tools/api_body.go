@@ -104,6 +104,12 @@ func ValidateQuery(w http.ResponseWriter, r *http.Request, b any) bool {
 		}
 		fieldValue := structValue.Field(i)
 
+		// Allocate Optional Fields
+		if val := query.Get(fieldTag); val != "" && fieldValue.Kind() == reflect.Ptr && fieldValue.CanSet() {
+			fieldValue.Set(reflect.New(fieldValue.Type().Elem()))
+			fieldValue = fieldValue.Elem()
+		}
+
 		if val := query.Get(fieldTag); val != "" && fieldValue.CanSet() {
 			switch fieldValue.Kind() {
 			case reflect.String:
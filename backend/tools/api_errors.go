@@ -10,6 +10,7 @@ type APIError struct {
 	Status  int
 	Code    int
 	Message string
+	Reason  string // OAuth2 Error Code or SCIM Error Type for clients that expect one (RFC 6749 Section 5.2, RFC 7644 Section 3.12)
 }
 
 var (
@@ -29,6 +30,10 @@ var (
 	ERROR_UNKNOWN_APPLICATION               = APIError{Status: 404, Code: 1050, Message: "Unknown Application"}
 	ERROR_UNKNOWN_CONNECTION                = APIError{Status: 404, Code: 1060, Message: "Unknown Connection"}
 	ERROR_UNKNOWN_IMAGE                     = APIError{Status: 404, Code: 1070, Message: "Unknown Image"}
+	ERROR_UNKNOWN_PASSKEY                   = APIError{Status: 404, Code: 1080, Message: "Unknown Passkey"}
+	ERROR_UNKNOWN_DEVICE                    = APIError{Status: 404, Code: 1090, Message: "Unknown Device"}
+	ERROR_UNKNOWN_PROVIDER                  = APIError{Status: 404, Code: 1100, Message: "Unknown Provider"}
+	ERROR_UNKNOWN_IDENTITY                  = APIError{Status: 404, Code: 1110, Message: "Unknown Identity"}
 	ERROR_IMAGE_UNSUPPORTED                 = APIError{Status: 400, Code: 2010, Message: "Unsupported Image Format (Supports: WEBP, GIF, JPEG, PNG)"}
 	ERROR_IMAGE_MALFORMED                   = APIError{Status: 400, Code: 2020, Message: "Invalid or Malformed Image Data"}
 	ERROR_ACCESS_REVOKED                    = APIError{Status: 401, Code: 3010, Message: "Access Revoked"}
@@ -39,6 +44,12 @@ var (
 	ERROR_LOGIN_PASSWORD_ALREADY_USED       = APIError{Status: 400, Code: 4040, Message: "Password Already Used"}
 	ERROR_SIGNUP_DUPLICATE_USERNAME         = APIError{Status: 409, Code: 4050, Message: "Username is already in use"}
 	ERROR_SIGNUP_DUPLICATE_EMAIL            = APIError{Status: 409, Code: 4060, Message: "Email Address is already in use"}
+	ERROR_LOGIN_CODE_INCORRECT              = APIError{Status: 401, Code: 4070, Message: "Incorrect or Expired Login Code"}
+	ERROR_LOGIN_CODE_EXHAUSTED              = APIError{Status: 429, Code: 4080, Message: "Too Many Attempts, Please Request a New Login Code"}
+	ERROR_LOGIN_ACCOUNT_DISABLED            = APIError{Status: 403, Code: 4090, Message: "Account Disabled by your Organization"}
+	ERROR_LOGIN_PASSWORD_BREACHED           = APIError{Status: 400, Code: 4100, Message: "Password was found in a Data Breach, please choose another"}
+	ERROR_LOGIN_ACCOUNT_LOCKED              = APIError{Status: 429, Code: 4110, Message: "Too Many Failed Attempts, Account Temporarily Locked"}
+	ERROR_LOGIN_RISK_DENIED                 = APIError{Status: 403, Code: 4120, Message: "Login Blocked due to Suspicious Activity"}
 	ERROR_MFA_EMAIL_SENT                    = APIError{Status: 403, Code: 5010, Message: "Email Sent"}
 	ERROR_MFA_EMAIL_ALREADY_VERIFIED        = APIError{Status: 400, Code: 5020, Message: "Email Address already Verified"}
 	ERROR_MFA_PASSCODE_REQUIRED             = APIError{Status: 403, Code: 5030, Message: "Authenticator Passcode Required"}
@@ -50,6 +61,11 @@ var (
 	ERROR_MFA_DISABLED                      = APIError{Status: 412, Code: 5090, Message: "MFA is Disabled"}
 	ERROR_MFA_SETUP_ALREADY                 = APIError{Status: 400, Code: 5100, Message: "MFA is Already Setup"}
 	ERROR_MFA_SETUP_NOT_INITIALIZED         = APIError{Status: 412, Code: 5110, Message: "MFA Setup not Started"}
+	ERROR_PASSKEY_CHALLENGE_INVALID         = APIError{Status: 400, Code: 5120, Message: "Passkey Challenge Invalid or Expired"}
+	ERROR_PASSKEY_INVALID                   = APIError{Status: 401, Code: 5130, Message: "Passkey Verification Failed"}
+	ERROR_PASSKEY_CLONED                    = APIError{Status: 401, Code: 5140, Message: "Passkey Signature Counter went Backwards"}
+	ERROR_PASSKEY_DUPLICATE                 = APIError{Status: 409, Code: 5150, Message: "Passkey is already Registered"}
+	ERROR_PASSKEY_LIMIT                     = APIError{Status: 400, Code: 5160, Message: "Passkey Limit Reached"}
 	ERROR_OAUTH2_SCOPE_REQUIRED             = APIError{Status: 403, Code: 6010, Message: "Endpoint requires an Additional Scope"}
 	ERROR_OAUTH2_USERS_ONLY                 = APIError{Status: 403, Code: 6020, Message: "Endpoint restricted to Users Only"}
 	ERROR_OAUTH2_FORM_INVALID_REDIRECT_URI  = APIError{Status: 400, Code: 6030, Message: "Invalid 'redirect_uri'"}
@@ -59,12 +75,39 @@ var (
 	ERROR_OAUTH2_FORM_INVALID_ACCESS_TOKEN  = APIError{Status: 400, Code: 6070, Message: "Invalid 'access_token'"}
 	ERROR_OAUTH2_FORM_INVALID_REFRESH_TOKEN = APIError{Status: 400, Code: 6080, Message: "Invalid 'refresh_token'"}
 	ERROR_OAUTH2_FORM_INVALID_SCOPE         = APIError{Status: 400, Code: 6090, Message: "Invalid 'scope'"}
+	ERROR_OAUTH2_FORM_INVALID_CHALLENGE     = APIError{Status: 400, Code: 6100, Message: "Invalid 'code_challenge' or 'code_challenge_method'"}
+	ERROR_OAUTH2_FORM_INVALID_VERIFIER      = APIError{Status: 400, Code: 6110, Message: "Invalid 'code_verifier'"}
+	ERROR_OAUTH2_FORM_INVALID_USER_CODE     = APIError{Status: 400, Code: 6120, Message: "Invalid 'user_code'"}
+	ERROR_OAUTH2_FORM_INVALID_DEVICE_CODE   = APIError{Status: 400, Code: 6130, Message: "Invalid 'device_code'", Reason: "invalid_grant"}
+	ERROR_OAUTH2_DEVICE_PENDING             = APIError{Status: 400, Code: 6140, Message: "Authorization Pending", Reason: "authorization_pending"}
+	ERROR_OAUTH2_DEVICE_SLOW_DOWN           = APIError{Status: 400, Code: 6150, Message: "Polling too Frequently", Reason: "slow_down"}
+	ERROR_OAUTH2_DEVICE_DENIED              = APIError{Status: 400, Code: 6160, Message: "Authorization Denied", Reason: "access_denied"}
+	ERROR_OAUTH2_DEVICE_EXPIRED             = APIError{Status: 400, Code: 6170, Message: "Device Code Expired", Reason: "expired_token"}
+	ERROR_OAUTH2_CONSENT_REQUIRED           = APIError{Status: 403, Code: 6180, Message: "Consent Required", Reason: "consent_required"}
+	ERROR_OAUTH2_APPLICATIONS_ONLY          = APIError{Status: 403, Code: 6200, Message: "Endpoint restricted to Applications Only"}
+	ERROR_OAUTH2_REFRESH_TOKEN_REUSED       = APIError{Status: 400, Code: 6190, Message: "Refresh Token Reused, Connection Revoked", Reason: "invalid_grant"}
+	ERROR_FEDERATION_STATE_INVALID          = APIError{Status: 400, Code: 7010, Message: "Login State Invalid or Expired"}
+	ERROR_FEDERATION_FAILED                 = APIError{Status: 400, Code: 7020, Message: "Provider did not Confirm your Identity"}
+	ERROR_FEDERATION_EMAIL_REQUIRED         = APIError{Status: 400, Code: 7030, Message: "Provider did not share an Email Address"}
+	ERROR_FEDERATION_EMAIL_CONFLICT         = APIError{Status: 409, Code: 7040, Message: "Email Address is already in use, login and link this Provider from your Security Settings"}
+	ERROR_FEDERATION_IDENTITY_LINKED        = APIError{Status: 409, Code: 7050, Message: "Identity is already Linked to an Account"}
+	ERROR_SAML_RESPONSE_INVALID             = APIError{Status: 400, Code: 7060, Message: "Identity Provider Response Invalid"}
+	ERROR_SAML_UNSOLICITED                  = APIError{Status: 400, Code: 7070, Message: "Identity Provider Response was not Requested"}
+	ERROR_SCIM_INVALID_FILTER               = APIError{Status: 400, Code: 8010, Message: "Filter is Invalid or Unsupported", Reason: "invalidFilter"}
+	ERROR_SCIM_INVALID_SYNTAX               = APIError{Status: 400, Code: 8020, Message: "Request is not a valid SCIM Message", Reason: "invalidSyntax"}
+	ERROR_SCIM_INVALID_PATH                 = APIError{Status: 400, Code: 8030, Message: "Path is Invalid or Unsupported", Reason: "invalidPath"}
+	ERROR_SCIM_INVALID_VALUE                = APIError{Status: 400, Code: 8040, Message: "Attribute Value is Invalid or Missing", Reason: "invalidValue"}
+	ERROR_SCIM_UNIQUENESS                   = APIError{Status: 409, Code: 8050, Message: "userName, externalId or Email Address is already in use", Reason: "uniqueness"}
 )
 
 // Cancel Request and Respond with an API Error
 func SendClientError(w http.ResponseWriter, r *http.Request, e APIError) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(e.Status)
+	if e.Reason != "" {
+		fmt.Fprintf(w, `{"code":%d,"message":%q,"error":%q}`, e.Code, e.Message, e.Reason)
+		return
+	}
 	fmt.Fprintf(w, `{"code":%d,"message":%q}`, e.Code, e.Message)
 }
 
@@ -17,8 +17,11 @@ import (
 // ID Reserved for when Session belongs to a User
 const SESSION_NO_APPLICATION_ID = 0
 
+// ID Reserved for when Session belongs to an Application acting as itself
+const SESSION_NO_USER_ID = 0
+
 type SessionData struct {
-	UserID           int64 // Relevant User ID
+	UserID           int64 // Relevant User ID (SESSION_NO_USER_ID for Application)
 	SessionID        int64 // Relevant Session ID
 	ConnectionID     int64 // Relevant Connection ID
 	ConnectionScopes int   // Relevant Connection Scopes (APP_USER for User)
@@ -52,10 +55,14 @@ func NewBodyLimit(limit int64) MiddlewareFunc {
 	}
 }
 
+// Route Patterns accepting Requests from any Origin, such as Endpoints which
+// Identity Providers post Forms to. CORS Headers are never sent for them.
+var CORSExempt = map[string]bool{}
+
 // Apply CORS Headers to Applicable requests
 func UseCORS(w http.ResponseWriter, r *http.Request) bool {
 	origin := r.Header.Get("Origin")
-	if origin == "" {
+	if origin == "" || CORSExempt[r.Pattern] {
 		return true
 	}
 	for _, allowed := range HTTP_CORS_ORIGINS {
@@ -133,12 +140,12 @@ func UseSession(w http.ResponseWriter, r *http.Request) bool {
 		case strings.HasPrefix(h, TOKEN_PREFIX_USER):
 			// Authenticate as User using Header
 			givenApplication = false
-			givenToken = h[len(TOKEN_PREFIX_USER):]
+			givenToken = strings.TrimSpace(h[len(TOKEN_PREFIX_USER):])
 
 		case strings.HasPrefix(h, TOKEN_PREFIX_BEARER):
 			// Authenticate as Application using Header
 			givenApplication = true
-			givenToken = h[len(TOKEN_PREFIX_BEARER):]
+			givenToken = strings.TrimSpace(h[len(TOKEN_PREFIX_BEARER):])
 
 		default:
 			// Unsupported Prefix
@@ -169,10 +176,11 @@ func UseSession(w http.ResponseWriter, r *http.Request) bool {
 		var connectionRevoked bool
 		err := Database.QueryRow(ctx,
 			`SELECT
-				id, user_id, application_id, revoked, scopes, token_expires
+				id, COALESCE(user_id, $2), application_id, revoked, scopes, token_expires
 			FROM auth.connections
-			WHERE token_access = $1`,
-			givenToken,
+			WHERE token_access = ANY($1)`,
+			TokenCandidates(givenToken),
+			SESSION_NO_USER_ID,
 		).Scan(
 			&session.ConnectionID,
 			&session.UserID,
@@ -205,16 +213,18 @@ func UseSession(w http.ResponseWriter, r *http.Request) bool {
 		// Search Sessions
 		var sessionRevoked bool
 		var sessionElevatedUntil int64
+		var sessionExpires, sessionUsed time.Time
 
 		err := Database.QueryRow(ctx,
 			`SELECT
-				id, user_id, revoked, elevated_until
+				id, user_id, revoked, elevated_until, expires, used
 			FROM auth.sessions
-			WHERE token = $1`,
-			givenToken,
+			WHERE token = ANY($1)`,
+			TokenCandidates(givenToken),
 		).Scan(
 			&session.SessionID, &session.UserID,
 			&sessionRevoked, &sessionElevatedUntil,
+			&sessionExpires, &sessionUsed,
 		)
 		if errors.Is(err, pgx.ErrNoRows) {
 			SendClientError(w, r, ERROR_GENERIC_UNAUTHORIZED)
@@ -230,6 +240,25 @@ func UseSession(w http.ResponseWriter, r *http.Request) bool {
 			SendClientError(w, r, ERROR_ACCESS_REVOKED)
 			return false
 		}
+		now := time.Now()
+		if now.After(sessionExpires) || now.Sub(sessionUsed) > SESSION_IDLE_TIMEOUT {
+			SendClientError(w, r, ERROR_ACCESS_EXPIRED)
+			return false
+		}
+
+		// Extend Idle Timeout, recorded at most once per Interval so that
+		// Requests don't cost a write each
+		if now.Sub(sessionUsed) > SESSION_TOUCH_INTERVAL {
+			if _, err := Database.Exec(ctx,
+				"UPDATE auth.sessions SET used = $1 WHERE id = $2 AND used < $3",
+				now,
+				session.SessionID,
+				now.Add(-SESSION_TOUCH_INTERVAL),
+			); err != nil {
+				SendServerError(w, r, err)
+				return false
+			}
+		}
 		if sessionElevatedUntil > time.Now().Unix() {
 			session.Elevated = true
 		}
@@ -241,3 +270,33 @@ func UseSession(w http.ResponseWriter, r *http.Request) bool {
 	*r = *r.WithContext(ctxWithSession)
 	return true
 }
+
+// Restrict Endpoint to Users, expects UseSession earlier in the chain
+func UseUsersOnly(w http.ResponseWriter, r *http.Request) bool {
+	if GetSession(r).ApplicationID != SESSION_NO_APPLICATION_ID {
+		SendClientError(w, r, ERROR_OAUTH2_USERS_ONLY)
+		return false
+	}
+	return true
+}
+
+// Restrict Endpoint to Applications acting as themselves, expects UseSession earlier in the chain
+func UseApplicationsOnly(w http.ResponseWriter, r *http.Request) bool {
+	if GetSession(r).UserID != SESSION_NO_USER_ID {
+		SendClientError(w, r, ERROR_OAUTH2_APPLICATIONS_ONLY)
+		return false
+	}
+	return true
+}
+
+// Restrict Endpoint to Users or Applications granted all given Scopes,
+// expects UseSession earlier in the chain
+func NewScopes(scopes ...ScopeInfo) MiddlewareFunc {
+	return func(w http.ResponseWriter, r *http.Request) bool {
+		if !OAuth2ScopesContains(GetSession(r), scopes...) {
+			SendClientError(w, r, ERROR_OAUTH2_SCOPE_REQUIRED)
+			return false
+		}
+		return true
+	}
+}
@@ -1,22 +1,54 @@
 package tools
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
 	"net/url"
 	"strings"
 )
 
+const (
+	PKCE_METHOD_PLAIN = "plain"
+	PKCE_METHOD_S256  = "S256"
+	USER_CODE_CHARSET = "BCDFGHJKLMNPQRSTVWXZ"
+)
+
 type ScopeInfo struct {
-	Name string
-	Flag int
+	Name        string
+	Flag        int
+	Machine     bool   // Only granted to Applications acting as themselves
+	Operator    bool   // Only granted to Applications allowed by the Operator
+	Description string // Shown to Users when approving an Application
 }
 
 var (
-	SCOPE_READ_IDENTIFY = ScopeInfo{Flag: 1 << 0, Name: "identify"}
-	SCOPE_READ_EMAIL    = ScopeInfo{Flag: 1 << 1, Name: "email"}
-	SCOPE_HASH          = map[string]ScopeInfo{
-		SCOPE_READ_IDENTIFY.Name: SCOPE_READ_IDENTIFY,
-		SCOPE_READ_EMAIL.Name:    SCOPE_READ_EMAIL,
+	SCOPE_READ_IDENTIFY    = ScopeInfo{Flag: 1 << 0, Name: "identify", Description: "View your username, displayname and avatar"}
+	SCOPE_READ_EMAIL       = ScopeInfo{Flag: 1 << 1, Name: "email", Description: "View your email address"}
+	SCOPE_OPENID           = ScopeInfo{Flag: 1 << 2, Name: "openid", Description: "Sign you in with your account"}
+	SCOPE_READ_PROFILES    = ScopeInfo{Flag: 1 << 3, Name: "profiles.read", Description: "View public profiles", Machine: true}
+	SCOPE_WRITE_PROFILE    = ScopeInfo{Flag: 1 << 4, Name: "profile.write", Description: "Edit your displayname, subtitle, biography and accents"}
+	SCOPE_WRITE_AVATAR     = ScopeInfo{Flag: 1 << 5, Name: "avatar.write", Description: "Change or remove your avatar"}
+	SCOPE_WRITE_BANNER     = ScopeInfo{Flag: 1 << 6, Name: "banner.write", Description: "Change or remove your banner"}
+	SCOPE_READ_CONNECTIONS = ScopeInfo{Flag: 1 << 7, Name: "connections.read", Description: "View applications you have connected"}
+	SCOPE_SCIM             = ScopeInfo{Flag: 1 << 8, Name: "scim", Description: "Provision and deprovision accounts using SCIM", Machine: true, Operator: true}
+	SCOPE_LIST             = []ScopeInfo{
+		SCOPE_READ_IDENTIFY,
+		SCOPE_READ_EMAIL,
+		SCOPE_OPENID,
+		SCOPE_READ_PROFILES,
+		SCOPE_WRITE_PROFILE,
+		SCOPE_WRITE_AVATAR,
+		SCOPE_WRITE_BANNER,
+		SCOPE_READ_CONNECTIONS,
+		SCOPE_SCIM,
 	}
+	SCOPE_HASH = func() map[string]ScopeInfo {
+		hash := make(map[string]ScopeInfo, len(SCOPE_LIST))
+		for _, sc := range SCOPE_LIST {
+			hash[sc.Name] = sc
+		}
+		return hash
+	}()
 )
 
 // Test for Given Scopes
@@ -27,17 +59,56 @@ func OAuth2ScopesContains(session *SessionData, scopes ...ScopeInfo) bool {
 	}
 	// Find missing scope
 	for _, s := range scopes {
+		if session.UserID == SESSION_NO_USER_ID && !s.Machine {
+			// Applications acting as themselves have no User to access
+			return false
+		}
 		if (session.ConnectionScopes & s.Flag) == 0 {
 			return false
 		}
+		if s.Operator && (OAuth2ScopesGranted(session.ApplicationID)&s.Flag) == 0 {
+			// The Operator may withdraw a Scope from existing Tokens
+			return false
+		}
 	}
 	return true
 }
 
+// Filter oAuth2 Scopes down to those only granted to Applications
+func OAuth2ScopesMachine(givenScopes int) int {
+	flags := 0
+	for _, sc := range SCOPE_LIST {
+		if sc.Machine {
+			flags = flags | sc.Flag
+		}
+	}
+	return givenScopes & flags
+}
+
+// Filter oAuth2 Scopes down to those only granted by the Operator
+func OAuth2ScopesOperator(givenScopes int) int {
+	flags := 0
+	for _, sc := range SCOPE_LIST {
+		if sc.Operator {
+			flags = flags | sc.Flag
+		}
+	}
+	return givenScopes & flags
+}
+
+// Scopes the Operator has granted an Application through Configuration
+func OAuth2ScopesGranted(applicationID int64) int {
+	flags := 0
+	if _, ok := SCIMApplications[applicationID]; ok {
+		flags = flags | SCOPE_SCIM.Flag
+	}
+	return flags
+}
+
 // Convert oAuth2 Scopes into a String
 func OAuth2ScopesToString(givenScopes int) string {
-	scopes := make([]string, 0, len(SCOPE_HASH))
-	for _, sc := range SCOPE_HASH {
+	scopes := make([]string, 0, len(SCOPE_LIST))
+	for _, sc := range SCOPE_LIST {
 		if (givenScopes & sc.Flag) != 0 {
 			scopes = append(scopes, sc.Name)
 		}
@@ -45,6 +116,20 @@ func OAuth2ScopesToString(givenScopes int) string {
 	return strings.Join(scopes, " ")
 }
 
+// Describe oAuth2 Scopes for display to a User
+func OAuth2ScopesDescribe(givenScopes int) []map[string]any {
+	scopes := make([]map[string]any, 0, len(SCOPE_LIST))
+	for _, sc := range SCOPE_LIST {
+		if (givenScopes & sc.Flag) != 0 {
+			scopes = append(scopes, map[string]any{
+				"name":        sc.Name,
+				"description": sc.Description,
+			})
+		}
+	}
+	return scopes
+}
+
 // Convert String into OAuth2 Scopes
 func OAuth2StringToScopes(s string) (bool, int) {
 	scopes := strings.Fields(s)
@@ -85,3 +170,55 @@ func OAauth2ScopesValidateRedirectURI(given string, allowlist []string) (bool, s
 	}
 	return false, ""
 }
+
+// Validate PKCE Code Challenge, returns the method to store alongside it
+func OAuth2ValidateCodeChallenge(challenge, method string) (bool, string) {
+	if method == "" {
+		// Defaults to 'plain' per RFC 7636 Section 4.3
+		method = PKCE_METHOD_PLAIN
+	}
+	if method != PKCE_METHOD_PLAIN && method != PKCE_METHOD_S256 {
+		return false, ""
+	}
+	if !REGEX_PKCE.MatchString(challenge) {
+		return false, ""
+	}
+	return true, method
+}
+
+// Compare PKCE Code Verifier against the Stored Code Challenge
+func OAuth2CompareCodeVerifier(verifier, challenge, method string) bool {
+	if !REGEX_PKCE.MatchString(verifier) {
+		return false
+	}
+	switch method {
+	case PKCE_METHOD_S256:
+		sum := sha256.Sum256([]byte(verifier))
+		return CompareStringConstant(base64.RawURLEncoding.EncodeToString(sum[:]), challenge)
+	case PKCE_METHOD_PLAIN:
+		return CompareStringConstant(verifier, challenge)
+	default:
+		return false
+	}
+}
+
+// Normalize a User Code as typed by the User, ignoring case and separators
+func OAuth2NormalizeUserCode(given string) (bool, string) {
+	code := make([]byte, 0, 9)
+	for _, c := range strings.ToUpper(given) {
+		if c == '-' || c == ' ' {
+			continue
+		}
+		if !strings.ContainsRune(USER_CODE_CHARSET, c) || len(code) == 9 {
+			return false, ""
+		}
+		if len(code) == 4 {
+			code = append(code, '-')
+		}
+		code = append(code, byte(c))
+	}
+	if len(code) != 9 {
+		return false, ""
+	}
+	return true, string(code)
+}
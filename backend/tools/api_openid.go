@@ -0,0 +1,61 @@
+package tools
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// Generate a Signed ID Token for the Given User and Application
+func OpenIDGenerateToken(ctx context.Context, userID, applicationID int64, scopes int, nonce *string) (string, error) {
+	now := time.Now()
+	claims := map[string]any{
+		"iss": OIDC_ISSUER,
+		"sub": strconv.FormatInt(userID, 10),
+		"aud": strconv.FormatInt(applicationID, 10),
+		"iat": now.Unix(),
+		"exp": now.Add(LIFETIME_OIDC_ID_TOKEN).Unix(),
+	}
+	if nonce != nil {
+		claims["nonce"] = *nonce
+	}
+	if err := OpenIDCollectClaims(ctx, userID, scopes, claims); err != nil {
+		return "", err
+	}
+	key, err := KeystoreCurrent(KEY_USAGE_SIGNATURE)
+	if err != nil {
+		return "", err
+	}
+	return GenerateJWT(key.Signer(), key.ID, claims)
+}
+
+// Collect Standard Claims for the User permitted by the Given Scopes
+func OpenIDCollectClaims(ctx context.Context, userID int64, scopes int, claims map[string]any) error {
+	var user DatabaseUser
+	var profile DatabaseProfile
+	err := Database.QueryRow(ctx,
+		`SELECT
+			u.email_address, u.email_verified,
+			p.username, p.displayname, p.updated
+		FROM auth.users u
+		JOIN auth.profiles p ON u.id = p.id
+		WHERE u.id = $1`,
+		userID,
+	).Scan(
+		Decrypted(&user.EmailAddress), &user.EmailVerified,
+		&profile.Username, &profile.Displayname, &profile.Updated,
+	)
+	if err != nil {
+		return err
+	}
+	if (scopes & SCOPE_READ_IDENTIFY.Flag) != 0 {
+		claims["preferred_username"] = profile.Username
+		claims["name"] = profile.Displayname
+		claims["updated_at"] = profile.Updated.Unix()
+	}
+	if (scopes & SCOPE_READ_EMAIL.Flag) != 0 {
+		claims["email"] = user.EmailAddress
+		claims["email_verified"] = user.EmailVerified
+	}
+	return nil
+}
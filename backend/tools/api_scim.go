@@ -0,0 +1,550 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SCIM_SCHEMA_USER          = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SCIM_SCHEMA_LIST_RESPONSE = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SCIM_SCHEMA_PATCH_OP      = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SCIM_SCHEMA_ERROR         = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SCIM_SCHEMA_CONFIG        = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	SCIM_CONTENT_TYPE         = "application/scim+json"
+	SCIM_PAGE_LIMIT           = 100 // Maximum Resources per Page
+	SCIM_USERNAME_LENGTH_MAX  = 256 // Maximum Length of a SCIM userName
+)
+
+var (
+	ErrSCIMFilter = errors.New("scim filter invalid")
+	ErrSCIMPath   = errors.New("scim path invalid")
+	ErrSCIMValue  = errors.New("scim value invalid")
+
+	// Applications the Operator allows to provision Accounts, mapped to
+	// the Email Domains each has proven it owns
+	SCIMApplications = map[int64][]string{}
+)
+
+func SetupSCIM(stop context.Context, await *sync.WaitGroup) {
+	t := time.Now()
+
+	for _, id := range SCIM_APPLICATIONS {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		applicationID, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			LoggerSCIM.Fatal("Invalid Application ID", id)
+		}
+		domains := EnvSlice("SCIM_"+id+"_EMAIL_DOMAINS", ",", []string{})
+		for i, domain := range domains {
+			domains[i] = strings.ToLower(strings.TrimSpace(domain))
+		}
+		if len(domains) == 0 {
+			LoggerSCIM.Fatal("Application requires Email Domains", id)
+		}
+		SCIMApplications[applicationID] = domains
+	}
+
+	LoggerSCIM.Info("Ready", map[string]any{
+		"time":         time.Since(t).String(),
+		"applications": len(SCIMApplications),
+	})
+}
+
+// Test if an Application may provision Accounts with the given Email Address
+func SCIMEmailAllowed(applicationID int64, emailAddress string) bool {
+	_, domain, ok := strings.Cut(strings.ToLower(emailAddress), "@")
+	return ok && slices.Contains(SCIMApplications[applicationID], domain)
+}
+
+// Account as seen by the Application which provisioned it
+type SCIMUser struct {
+	ID           int64
+	Created      time.Time
+	Updated      time.Time
+	ExternalID   *string
+	UserName     string
+	Displayname  string
+	EmailAddress string
+	Active       bool
+}
+
+// User Resource (RFC 7643 Section 4.1), unsupported Attributes are ignored
+type SCIMUserResource struct {
+	Schemas     []string       `json:"schemas"`
+	ID          string         `json:"id,omitempty"`
+	ExternalID  *string        `json:"externalId,omitempty"`
+	UserName    string         `json:"userName"`
+	DisplayName string         `json:"displayName,omitempty"`
+	Name        *SCIMName      `json:"name,omitempty"`
+	Emails      []SCIMEmail    `json:"emails,omitempty"`
+	Active      *bool          `json:"active,omitempty"`
+	Meta        map[string]any `json:"meta,omitempty"`
+}
+
+type SCIMName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type SCIMEmail struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// Patch Request (RFC 7644 Section 3.5.2)
+type SCIMPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []SCIMPatchOperation `json:"Operations"`
+}
+
+type SCIMPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Convert Account into its Resource Representation
+func (u SCIMUser) Resource() SCIMUserResource {
+	active := u.Active
+	return SCIMUserResource{
+		Schemas:     []string{SCIM_SCHEMA_USER},
+		ID:          strconv.FormatInt(u.ID, 10),
+		ExternalID:  u.ExternalID,
+		UserName:    u.UserName,
+		DisplayName: u.Displayname,
+		Name:        &SCIMName{Formatted: u.Displayname},
+		Emails:      []SCIMEmail{{Value: u.EmailAddress, Type: "work", Primary: true}},
+		Active:      &active,
+		Meta: map[string]any{
+			"resourceType": "User",
+			"created":      u.Created.UTC().Format(time.RFC3339),
+			"lastModified": u.Updated.UTC().Format(time.RFC3339),
+			"location":     strings.TrimSuffix(OIDC_ISSUER, "/") + "/scim/v2/Users/" + strconv.FormatInt(u.ID, 10),
+		},
+	}
+}
+
+// Replace all Attributes of an Account with those given in a Resource
+func (u *SCIMUser) Replace(res SCIMUserResource) {
+	u.ExternalID = res.ExternalID
+	u.UserName = res.UserName
+	u.Displayname = res.DisplayName
+	if u.Displayname == "" && res.Name != nil {
+		u.Displayname = res.Name.Formatted
+		if u.Displayname == "" {
+			u.Displayname = strings.TrimSpace(res.Name.GivenName + " " + res.Name.FamilyName)
+		}
+	}
+	u.EmailAddress = ""
+	for i, email := range res.Emails {
+		if email.Primary || i == 0 {
+			u.EmailAddress = email.Value
+		}
+	}
+	u.Active = res.Active == nil || *res.Active
+}
+
+// Apply a Patch Operation to an Account, Paths are matched without regard to case
+func (u *SCIMUser) Patch(op SCIMPatchOperation) error {
+	operation := strings.ToLower(op.Op)
+	if operation != "add" && operation != "replace" && operation != "remove" {
+		return ErrSCIMValue
+	}
+
+	// Without a Path the Value holds the Attributes to Replace
+	if op.Path == "" {
+		if operation == "remove" {
+			return ErrSCIMPath
+		}
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(op.Value, &values); err != nil {
+			return ErrSCIMValue
+		}
+		for path, value := range values {
+			if err := u.Patch(SCIMPatchOperation{Op: op.Op, Path: path, Value: value}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch strings.ToLower(op.Path) {
+	case "externalid":
+		if operation == "remove" {
+			u.ExternalID = nil
+			return nil
+		}
+		var s string
+		if err := json.Unmarshal(op.Value, &s); err != nil {
+			return ErrSCIMValue
+		}
+		u.ExternalID = &s
+	case "username":
+		return scimPatchString(operation, op.Value, &u.UserName)
+	case "displayname", "name.formatted":
+		return scimPatchString(operation, op.Value, &u.Displayname)
+	case "name":
+		var name SCIMName
+		if operation == "remove" || json.Unmarshal(op.Value, &name) != nil {
+			return ErrSCIMValue
+		}
+		if name.Formatted == "" {
+			name.Formatted = strings.TrimSpace(name.GivenName + " " + name.FamilyName)
+		}
+		u.Displayname = name.Formatted
+	case "emails.value", `emails[type eq "work"].value`, `emails[primary eq true].value`:
+		return scimPatchString(operation, op.Value, &u.EmailAddress)
+	case "emails":
+		var emails []SCIMEmail
+		if operation == "remove" || json.Unmarshal(op.Value, &emails) != nil || len(emails) == 0 {
+			return ErrSCIMValue
+		}
+		u.EmailAddress = emails[0].Value
+		for _, email := range emails {
+			if email.Primary {
+				u.EmailAddress = email.Value
+			}
+		}
+	case "active":
+		// Some Providers send Booleans as Strings
+		var b bool
+		var s string
+		if operation == "remove" {
+			return ErrSCIMValue
+		}
+		if err := json.Unmarshal(op.Value, &b); err != nil {
+			if err := json.Unmarshal(op.Value, &s); err != nil {
+				return ErrSCIMValue
+			}
+			if b, err = strconv.ParseBool(s); err != nil {
+				return ErrSCIMValue
+			}
+		}
+		u.Active = b
+	default:
+		return ErrSCIMPath
+	}
+	return nil
+}
+
+func scimPatchString(operation string, value json.RawMessage, dst *string) error {
+	if operation == "remove" {
+		return ErrSCIMValue
+	}
+	if err := json.Unmarshal(value, dst); err != nil {
+		return ErrSCIMValue
+	}
+	return nil
+}
+
+// Column a Filter Attribute is compared against
+type SCIMAttribute struct {
+	Column    string
+	Kind      int                       // One of SCIM_KIND_*
+	CaseExact bool                      // Compare Strings with regard to case
+	Index     func(value string) string // Column is a Blind Index, only Equality is supported
+}
+
+const (
+	SCIM_KIND_STRING = iota
+	SCIM_KIND_BOOLEAN
+	SCIM_KIND_DATETIME
+)
+
+// Convert a Filter (RFC 7644 Section 3.4.2.2) into an SQL Condition, Values are
+// appended to args and referenced by their Position. Attribute Names are lowercase.
+func SCIMFilterSQL(filter string, attributes map[string]SCIMAttribute, args *[]any) (string, error) {
+	tokens, err := scimTokenize(filter)
+	if err != nil {
+		return "", err
+	}
+	p := scimFilterParser{tokens: tokens, attributes: attributes, args: args}
+	condition, err := p.or("")
+	if err != nil {
+		return "", err
+	}
+	if p.position != len(p.tokens) {
+		return "", ErrSCIMFilter
+	}
+	return condition, nil
+}
+
+type scimToken struct {
+	Value  string
+	Quoted bool
+}
+
+func scimTokenize(filter string) ([]scimToken, error) {
+	var tokens []scimToken
+	for i := 0; i < len(filter); {
+		c := filter[i]
+		switch {
+		case c == ' ':
+			i++
+		case c == '(' || c == ')' || c == '[' || c == ']':
+			tokens = append(tokens, scimToken{Value: string(c)})
+			i++
+		case c == '"':
+			// Strings follow JSON Escaping
+			j := i + 1
+			for ; j < len(filter) && filter[j] != '"'; j++ {
+				if filter[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(filter) {
+				return nil, ErrSCIMFilter
+			}
+			var s string
+			if err := json.Unmarshal([]byte(filter[i:j+1]), &s); err != nil {
+				return nil, ErrSCIMFilter
+			}
+			tokens = append(tokens, scimToken{Value: s, Quoted: true})
+			i = j + 1
+		default:
+			j := i
+			for j < len(filter) && !strings.ContainsRune(` ()[]"`, rune(filter[j])) {
+				j++
+			}
+			tokens = append(tokens, scimToken{Value: filter[i:j]})
+			i = j
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, ErrSCIMFilter
+	}
+	return tokens, nil
+}
+
+type scimFilterParser struct {
+	tokens     []scimToken
+	position   int
+	attributes map[string]SCIMAttribute
+	args       *[]any
+}
+
+func (p *scimFilterParser) peek() (scimToken, bool) {
+	if p.position >= len(p.tokens) {
+		return scimToken{}, false
+	}
+	return p.tokens[p.position], true
+}
+
+func (p *scimFilterParser) keyword(word string) bool {
+	if t, ok := p.peek(); ok && !t.Quoted && strings.EqualFold(t.Value, word) {
+		p.position++
+		return true
+	}
+	return false
+}
+
+func (p *scimFilterParser) or(prefix string) (string, error) {
+	left, err := p.and(prefix)
+	if err != nil {
+		return "", err
+	}
+	for p.keyword("or") {
+		right, err := p.and(prefix)
+		if err != nil {
+			return "", err
+		}
+		left = "(" + left + " OR " + right + ")"
+	}
+	return left, nil
+}
+
+func (p *scimFilterParser) and(prefix string) (string, error) {
+	left, err := p.unary(prefix)
+	if err != nil {
+		return "", err
+	}
+	for p.keyword("and") {
+		right, err := p.unary(prefix)
+		if err != nil {
+			return "", err
+		}
+		left = "(" + left + " AND " + right + ")"
+	}
+	return left, nil
+}
+
+func (p *scimFilterParser) unary(prefix string) (string, error) {
+	if p.keyword("not") {
+		if !p.keyword("(") {
+			return "", ErrSCIMFilter
+		}
+		inner, err := p.or(prefix)
+		if err != nil || !p.keyword(")") {
+			return "", ErrSCIMFilter
+		}
+		return "NOT (" + inner + ")", nil
+	}
+	if p.keyword("(") {
+		inner, err := p.or(prefix)
+		if err != nil || !p.keyword(")") {
+			return "", ErrSCIMFilter
+		}
+		return inner, nil
+	}
+	return p.comparison(prefix)
+}
+
+func (p *scimFilterParser) comparison(prefix string) (string, error) {
+	t, ok := p.peek()
+	if !ok || t.Quoted {
+		return "", ErrSCIMFilter
+	}
+	p.position++
+	path := prefix + strings.ToLower(strings.TrimPrefix(t.Value, SCIM_SCHEMA_USER+":"))
+
+	// Value Paths filter the Values of a Multi-Valued Attribute
+	if p.keyword("[") {
+		inner, err := p.or(path + ".")
+		if err != nil || !p.keyword("]") {
+			return "", ErrSCIMFilter
+		}
+		return inner, nil
+	}
+
+	attribute, ok := p.attributes[path]
+	if !ok {
+		return "", ErrSCIMFilter
+	}
+	op, ok := p.peek()
+	if !ok || op.Quoted {
+		return "", ErrSCIMFilter
+	}
+	p.position++
+	operator := strings.ToLower(op.Value)
+	if operator == "pr" {
+		if attribute.Kind == SCIM_KIND_STRING {
+			return "(" + attribute.Column + " IS NOT NULL AND " + attribute.Column + " <> '')", nil
+		}
+		return attribute.Column + " IS NOT NULL", nil
+	}
+
+	// Parse Comparison Value
+	v, ok := p.peek()
+	if !ok {
+		return "", ErrSCIMFilter
+	}
+	p.position++
+	var value any
+	switch attribute.Kind {
+	case SCIM_KIND_STRING:
+		if !v.Quoted {
+			return "", ErrSCIMFilter
+		}
+		value = v.Value
+	case SCIM_KIND_BOOLEAN:
+		b, err := strconv.ParseBool(v.Value)
+		if v.Quoted || err != nil || (operator != "eq" && operator != "ne") {
+			return "", ErrSCIMFilter
+		}
+		value = b
+	case SCIM_KIND_DATETIME:
+		t, err := time.Parse(time.RFC3339Nano, v.Value)
+		if !v.Quoted || err != nil {
+			return "", ErrSCIMFilter
+		}
+		value = t.UTC()
+	}
+
+	// Generate Condition
+	column := attribute.Column
+	placeholder := func(v any) string {
+		*p.args = append(*p.args, v)
+		return fmt.Sprintf("$%d", len(*p.args))
+	}
+	if attribute.Index != nil {
+		if operator != "eq" && operator != "ne" {
+			return "", ErrSCIMFilter
+		}
+		value = attribute.Index(value.(string))
+	} else if attribute.Kind == SCIM_KIND_STRING && !attribute.CaseExact {
+		column = "LOWER(" + column + ")"
+		value = strings.ToLower(value.(string))
+	}
+	switch operator {
+	case "eq":
+		return column + " = " + placeholder(value), nil
+	case "ne":
+		return "(" + column + " IS NULL OR " + column + " <> " + placeholder(value) + ")", nil
+	case "gt":
+		return column + " > " + placeholder(value), nil
+	case "ge":
+		return column + " >= " + placeholder(value), nil
+	case "lt":
+		return column + " < " + placeholder(value), nil
+	case "le":
+		return column + " <= " + placeholder(value), nil
+	case "co", "sw", "ew":
+		if attribute.Kind != SCIM_KIND_STRING {
+			return "", ErrSCIMFilter
+		}
+		pattern := scimLikeEscaper.Replace(value.(string))
+		switch operator {
+		case "co":
+			pattern = "%" + pattern + "%"
+		case "sw":
+			pattern = pattern + "%"
+		case "ew":
+			pattern = "%" + pattern
+		}
+		return column + " LIKE " + placeholder(pattern), nil
+	}
+	return "", ErrSCIMFilter
+}
+
+var scimLikeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Decode Incoming SCIM Request, Clients may send Attributes we don't support
+func ValidateSCIM(w http.ResponseWriter, r *http.Request, b any) bool {
+	header := strings.ToLower(r.Header.Get("Content-Type"))
+	if !strings.HasPrefix(header, SCIM_CONTENT_TYPE) && !strings.HasPrefix(header, "application/json") {
+		SendSCIMError(w, r, ERROR_SCIM_INVALID_SYNTAX)
+		return false
+	}
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(b); err != nil {
+		SendSCIMError(w, r, ERROR_SCIM_INVALID_SYNTAX)
+		return false
+	}
+	return true
+}
+
+// Respond with a SCIM Resource or Message
+func SendSCIM(w http.ResponseWriter, r *http.Request, s int, b any) {
+	w.Header().Set("Content-Type", SCIM_CONTENT_TYPE+"; charset=utf-8")
+	w.WriteHeader(s)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.Encode(b)
+}
+
+// Respond with an API Error using the SCIM Error Schema (RFC 7644 Section 3.12)
+func SendSCIMError(w http.ResponseWriter, r *http.Request, e APIError) {
+	body := map[string]any{
+		"schemas": []string{SCIM_SCHEMA_ERROR},
+		"status":  strconv.Itoa(e.Status),
+		"detail":  e.Message,
+	}
+	if e.Reason != "" {
+		body["scimType"] = e.Reason
+	}
+	SendSCIM(w, r, e.Status, body)
+}
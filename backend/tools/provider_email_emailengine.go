@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/mail"
 	"sync"
 )
 
@@ -17,15 +18,13 @@ import (
 type emailProviderEmailEngine struct {
 	EndpointUrl string
 	EndpointKey string
-	FromAddress string
-	FromName    string
+	From        mail.Address
 }
 
 func (e *emailProviderEmailEngine) Start(stop context.Context, await *sync.WaitGroup) error {
 	e.EndpointUrl = EMAIL_ENGINE_URL
 	e.EndpointKey = EMAIL_ENGINE_KEY
-	e.FromAddress = EMAIL_SENDER_ADDRESS
-	e.FromName = EMAIL_SENDER_NAME
+	e.From = mail.Address{Name: EMAIL_SENDER_NAME, Address: EMAIL_SENDER_ADDRESS}
 
 	// Make Request to Server
 	res, err := http.DefaultClient.Post("/verify", "application/json", http.NoBody)
@@ -49,18 +48,22 @@ func (e *emailProviderEmailEngine) Start(stop context.Context, await *sync.WaitG
 	}
 }
 
-func (o *emailProviderEmailEngine) Send(toAddress, subject, html string) error {
+func (o *emailProviderEmailEngine) Send(message EmailMessage) error {
+	data, err := EncodeEmailMIME(o.From, message)
+	if err != nil {
+		return err
+	}
 
-	// Generate Envelope
+	// Generate Envelope, the Message is sent Raw as it's already Encoded
 	var payload []byte
 	if d, err := json.Marshal(map[string]any{
-		"to_name":      toAddress,
-		"to_address":   toAddress,
-		"from_address": o.FromAddress,
-		"from_name":    o.FromName,
-		"subject":      subject,
-		"content":      html,
-		"html":         true,
+		"to_name":      message.To,
+		"to_address":   message.To,
+		"from_address": o.From.Address,
+		"from_name":    o.From.Name,
+		"subject":      message.Subject,
+		"raw":          string(data),
+		"tags":         message.Tags,
 	}); err != nil {
 		return err
 	} else {
@@ -3,10 +3,14 @@ package tools
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"fmt"
 	"io"
 	"net/http"
+	"net/mail"
 	"net/url"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 )
@@ -15,7 +19,7 @@ type emailProviderSES struct {
 	AccessKey        string
 	SecretKey        string
 	Region           string
-	From             string
+	From             mail.Address
 	ConfigurationSet string
 }
 
@@ -23,7 +27,7 @@ func (e *emailProviderSES) Start(stop context.Context, await *sync.WaitGroup) er
 	e.AccessKey = EMAIL_SES_ACCESS_KEY
 	e.SecretKey = EMAIL_SES_SECRET_KEY
 	e.Region = EMAIL_SES_REGION
-	e.From = EMAIL_SENDER_ADDRESS
+	e.From = mail.Address{Name: EMAIL_SENDER_NAME, Address: EMAIL_SENDER_ADDRESS}
 	e.ConfigurationSet = EMAIL_SES_CONFIGURATION_SET
 
 	// Test Client by Querying Quota
@@ -51,14 +55,29 @@ func (e *emailProviderSES) Start(stop context.Context, await *sync.WaitGroup) er
 	return nil
 }
 
-func (e *emailProviderSES) Send(toAddress, subject, htmlBody string) error {
+func (e *emailProviderSES) Send(message EmailMessage) error {
+	data, err := EncodeEmailMIME(e.From, message)
+	if err != nil {
+		return err
+	}
+
+	// Raw Emails are sent as is, Tags are attached as Message Tags
 	form := url.Values{}
 	form.Set("ConfigurationSetName", e.ConfigurationSet)
-	form.Set("Action", "SendEmail")
-	form.Set("Source", e.From)
-	form.Set("Destination.ToAddresses.member.1", toAddress)
-	form.Set("Message.Subject.Data", subject)
-	form.Set("Message.Body.Html.Data", htmlBody)
+	form.Set("Action", "SendRawEmail")
+	form.Set("Source", e.From.Address)
+	form.Set("Destinations.member.1", message.To)
+	form.Set("RawMessage.Data", base64.StdEncoding.EncodeToString(data))
+	names := make([]string, 0, len(message.Tags))
+	for name := range message.Tags {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for i, name := range names {
+		member := "Tags.member." + strconv.Itoa(i+1)
+		form.Set(member+".Name", name)
+		form.Set(member+".Value", message.Tags[name])
+	}
 	payload := []byte(form.Encode())
 
 	// Generate Request
@@ -0,0 +1,227 @@
+package tools
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Any SMTP Relay, the Connection is kept open between Emails and reopened
+// whenever it was dropped by the Server or has been Idle for too long.
+
+type emailProviderSMTP struct {
+	Mutex    sync.Mutex
+	Address  string
+	Host     string
+	Security string
+	Auth     smtp.Auth
+	TLS      *tls.Config
+	From     mail.Address
+	Conn     net.Conn
+	Client   *smtp.Client
+	Used     time.Time
+}
+
+func (o *emailProviderSMTP) Start(stop context.Context, await *sync.WaitGroup) error {
+	o.Host = EMAIL_SMTP_HOST
+	o.Address = net.JoinHostPort(EMAIL_SMTP_HOST, strconv.Itoa(EMAIL_SMTP_PORT))
+	o.Security = EMAIL_SMTP_SECURITY
+	o.From = mail.Address{Name: EMAIL_SENDER_NAME, Address: EMAIL_SENDER_ADDRESS}
+	o.TLS = &tls.Config{ServerName: EMAIL_SMTP_HOST, MinVersion: tls.VersionTLS12}
+	if EMAIL_SMTP_TLS_CA != "" {
+		caBytes, err := os.ReadFile(EMAIL_SMTP_TLS_CA)
+		if err != nil {
+			return err
+		}
+		o.TLS.RootCAs = x509.NewCertPool()
+		if !o.TLS.RootCAs.AppendCertsFromPEM(caBytes) {
+			return errors.New("cannot append ca bundle")
+		}
+	}
+	switch o.Security {
+	case "tls", "starttls", "none":
+	default:
+		return fmt.Errorf("unknown security: %s", o.Security)
+	}
+	switch EMAIL_SMTP_AUTH {
+	case "plain":
+		o.Auth = smtp.PlainAuth("", EMAIL_SMTP_USERNAME, EMAIL_SMTP_PASSWORD, EMAIL_SMTP_HOST)
+	case "login":
+		o.Auth = &smtpLoginAuth{EMAIL_SMTP_USERNAME, EMAIL_SMTP_PASSWORD, EMAIL_SMTP_HOST}
+	case "cram-md5":
+		o.Auth = smtp.CRAMMD5Auth(EMAIL_SMTP_USERNAME, EMAIL_SMTP_PASSWORD)
+	case "none":
+	default:
+		return fmt.Errorf("unknown auth: %s", EMAIL_SMTP_AUTH)
+	}
+
+	// Test Connection and Credentials
+	o.Mutex.Lock()
+	defer o.Mutex.Unlock()
+	if err := o.connect(); err != nil {
+		return err
+	}
+
+	// Shutdown Logic
+	await.Add(1)
+	go func() {
+		defer await.Done()
+		<-stop.Done()
+		o.Mutex.Lock()
+		defer o.Mutex.Unlock()
+		o.disconnect()
+	}()
+
+	return nil
+}
+
+func (o *emailProviderSMTP) Send(message EmailMessage) error {
+	data, err := EncodeEmailMIME(o.From, message)
+	if err != nil {
+		return err
+	}
+
+	o.Mutex.Lock()
+	defer o.Mutex.Unlock()
+
+	// Reuse the Connection if the Server still accepts Commands,
+	// nothing has been sent at this point so reconnecting is safe
+	if o.Client != nil && time.Since(o.Used) > EMAIL_SMTP_IDLE_TIMEOUT {
+		o.disconnect()
+	}
+	if o.Client != nil {
+		o.Conn.SetDeadline(time.Now().Add(CONTEXT_TIMEOUT))
+		if err := o.Client.Reset(); err != nil {
+			o.disconnect()
+		}
+	}
+	if o.Client == nil {
+		if err := o.connect(); err != nil {
+			return err
+		}
+	}
+
+	// Deliver Message, the Connection is dropped on Failure as its state is unknown
+	o.Conn.SetDeadline(time.Now().Add(CONTEXT_TIMEOUT))
+	if err := o.deliver(message.To, data); err != nil {
+		o.disconnect()
+		return err
+	}
+	o.Used = time.Now()
+	return nil
+}
+
+func (o *emailProviderSMTP) deliver(toAddress string, message []byte) error {
+	if err := o.Client.Mail(o.From.Address); err != nil {
+		return err
+	}
+	if err := o.Client.Rcpt(toAddress); err != nil {
+		return err
+	}
+	w, err := o.Client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// Open a new Connection, expects Mutex to be held
+func (o *emailProviderSMTP) connect() error {
+	dialer := net.Dialer{Timeout: CONTEXT_TIMEOUT}
+	var conn net.Conn
+	var err error
+	if o.Security == "tls" {
+		conn, err = tls.DialWithDialer(&dialer, "tcp", o.Address, o.TLS)
+	} else {
+		conn, err = dialer.Dial("tcp", o.Address)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(CONTEXT_TIMEOUT))
+
+	client, err := smtp.NewClient(conn, o.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if err := client.Hello(EMAIL_SMTP_HELO); err != nil {
+		client.Close()
+		return err
+	}
+	if o.Security == "starttls" {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return errors.New("server does not support STARTTLS")
+		}
+		if err := client.StartTLS(o.TLS); err != nil {
+			client.Close()
+			return err
+		}
+	}
+	if o.Auth != nil {
+		if err := client.Auth(o.Auth); err != nil {
+			client.Close()
+			return err
+		}
+	}
+	o.Conn, o.Client, o.Used = conn, client, time.Now()
+	return nil
+}
+
+// Close the current Connection if any, expects Mutex to be held
+func (o *emailProviderSMTP) disconnect() {
+	if o.Client == nil {
+		return
+	}
+	o.Conn.SetDeadline(time.Now().Add(time.Second))
+	if err := o.Client.Quit(); err != nil {
+		o.Client.Close()
+	}
+	o.Conn, o.Client = nil, nil
+}
+
+// LOGIN Mechanism, which isn't included in net/smtp. Like PLAIN the
+// Credentials are sent as is, so an Encrypted Connection is required.
+type smtpLoginAuth struct {
+	Username string
+	Password string
+	Host     string
+}
+
+func (a *smtpLoginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && server.Name != "localhost" && server.Name != "127.0.0.1" && server.Name != "::1" {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	if server.Name != a.Host {
+		return "", nil, errors.New("wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *smtpLoginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:":
+		return []byte(a.Username), nil
+	case "password:":
+		return []byte(a.Password), nil
+	}
+	return nil, fmt.Errorf("unexpected challenge: %s", fromServer)
+}
@@ -0,0 +1,153 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"sync"
+	"testing"
+)
+
+// NOTE: Shares keys between all instances using the same database. Material is
+//       wrapped with KEYSTORE_WRAP_KEY so the database alone can't decrypt the
+//       fields or forge the tokens those keys protect.
+
+type keystoreProviderDatabase struct{}
+
+func (o *keystoreProviderDatabase) Start(stop context.Context, await *sync.WaitGroup) error {
+	if Database == nil {
+		panic("keystore requires the database to be setup first")
+	}
+	if len(KEYSTORE_WRAP_KEY) == 0 && !testing.Testing() {
+		return ErrKeyWrap
+	}
+
+	// Wrap Keys stored before Wrapping was introduced
+	ctx, cancel := NewContext()
+	defer cancel()
+	rows, err := Database.Query(ctx, "SELECT id, material FROM auth.keys WHERE wrapped = FALSE")
+	if err != nil {
+		return err
+	}
+	var ids []string
+	var materials [][]byte
+	for rows.Next() {
+		var id string
+		var material []byte
+		if err := rows.Scan(&id, &material); err != nil {
+			rows.Close()
+			return err
+		}
+		wrapped, err := keystoreWrap(id, material)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+		materials = append(materials, wrapped)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for i, id := range ids {
+		if _, err := Database.Exec(ctx,
+			"UPDATE auth.keys SET material = $1, wrapped = TRUE WHERE id = $2 AND wrapped = FALSE",
+			materials[i],
+			id,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *keystoreProviderDatabase) Load(ctx context.Context) ([]KeystoreKey, error) {
+	rows, err := Database.Query(ctx,
+		`SELECT
+			id, usage, algorithm, created, activates, expires, material, wrapped
+		FROM auth.keys`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]KeystoreKey, 0, 4)
+	for rows.Next() {
+		var key KeystoreKey
+		var wrapped bool
+		if err := rows.Scan(
+			&key.ID,
+			&key.Usage,
+			&key.Algorithm,
+			&key.Created,
+			&key.Activates,
+			&key.Expires,
+			&key.Material,
+			&wrapped,
+		); err != nil {
+			return nil, err
+		}
+		if wrapped {
+			material, err := keystoreUnwrap(key.ID, key.Material)
+			if err != nil {
+				LoggerKeystore.Warn("Skipping Undecryptable Key", map[string]any{
+					"kid":   key.ID,
+					"error": err.Error(),
+				})
+				continue
+			}
+			key.Material = material
+		}
+		if err := key.parse(); err != nil {
+			LoggerKeystore.Warn("Skipping Invalid Key", map[string]any{
+				"kid":   key.ID,
+				"error": err.Error(),
+			})
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (o *keystoreProviderDatabase) Save(ctx context.Context, keys ...KeystoreKey) error {
+	for _, k := range keys {
+		material, err := keystoreWrap(k.ID, k.Material)
+		if err != nil {
+			return err
+		}
+		if _, err := Database.Exec(ctx,
+			`INSERT INTO auth.keys (
+				id, usage, algorithm, created, activates, expires, material, wrapped
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, TRUE)
+			ON CONFLICT (id) DO UPDATE SET expires = EXCLUDED.expires`,
+			k.ID,
+			k.Usage,
+			k.Algorithm,
+			k.Created,
+			k.Activates,
+			k.Expires,
+			material,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *keystoreProviderDatabase) Delete(ctx context.Context, ids ...string) error {
+	_, err := Database.Exec(ctx, "DELETE FROM auth.keys WHERE id = ANY($1)", ids)
+	return err
+}
+
+// Encrypt Key Material with the Wrap Key, bound to its Key ID
+func keystoreWrap(kid string, material []byte) ([]byte, error) {
+	kek := sha256.Sum256(KEYSTORE_WRAP_KEY)
+	return fieldSeal(kek[:], material, []byte(kid))
+}
+
+func keystoreUnwrap(kid string, wrapped []byte) ([]byte, error) {
+	kek := sha256.Sum256(KEYSTORE_WRAP_KEY)
+	return fieldOpen(kek[:], wrapped, []byte(kid))
+}
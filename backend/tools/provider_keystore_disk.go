@@ -0,0 +1,80 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+// NOTE: Keys are stored unencrypted as JSON files, one per key.
+// 		 Intended for single instances or keys distributed by an orchestrator.
+
+type keystoreProviderDisk struct {
+	Base string
+}
+
+func (o *keystoreProviderDisk) Start(stop context.Context, await *sync.WaitGroup) error {
+	o.Base = KEYSTORE_DISK_DIRECTORY
+	return os.MkdirAll(o.Base, 0700)
+}
+
+func (o *keystoreProviderDisk) Load(ctx context.Context) ([]KeystoreKey, error) {
+	entries, err := os.ReadDir(o.Base)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]KeystoreKey, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		b, err := os.ReadFile(path.Join(o.Base, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var key KeystoreKey
+		if err := json.Unmarshal(b, &key); err != nil {
+			return nil, fmt.Errorf("invalid key file %s: %w", e.Name(), err)
+		}
+		if err := key.parse(); err != nil {
+			return nil, fmt.Errorf("invalid key file %s: %w", e.Name(), err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (o *keystoreProviderDisk) Save(ctx context.Context, keys ...KeystoreKey) error {
+	for _, k := range keys {
+		b, err := json.MarshalIndent(k, "", "\t")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(o.filename(k.ID), b, 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *keystoreProviderDisk) Delete(ctx context.Context, ids ...string) error {
+	var errs []string
+	for _, id := range ids {
+		if err := os.Remove(o.filename(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("fs errors:\n%s", strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+func (o *keystoreProviderDisk) filename(id string) string {
+	return path.Join(o.Base, path.Clean("/"+id+".json"))
+}
@@ -0,0 +1,47 @@
+package tools
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// NOTE: Keys are lost on shutdown, intended for testing only
+
+type keystoreProviderMemory struct {
+	Mutex sync.Mutex
+	Keys  []KeystoreKey
+}
+
+func (o *keystoreProviderMemory) Start(stop context.Context, await *sync.WaitGroup) error {
+	return nil
+}
+
+func (o *keystoreProviderMemory) Load(ctx context.Context) ([]KeystoreKey, error) {
+	o.Mutex.Lock()
+	defer o.Mutex.Unlock()
+	return slices.Clone(o.Keys), nil
+}
+
+func (o *keystoreProviderMemory) Save(ctx context.Context, keys ...KeystoreKey) error {
+	o.Mutex.Lock()
+	defer o.Mutex.Unlock()
+	for _, k := range keys {
+		i := slices.IndexFunc(o.Keys, func(e KeystoreKey) bool { return e.ID == k.ID })
+		if i == -1 {
+			o.Keys = append(o.Keys, k)
+		} else {
+			o.Keys[i] = k
+		}
+	}
+	return nil
+}
+
+func (o *keystoreProviderMemory) Delete(ctx context.Context, ids ...string) error {
+	o.Mutex.Lock()
+	defer o.Mutex.Unlock()
+	o.Keys = slices.DeleteFunc(o.Keys, func(e KeystoreKey) bool {
+		return slices.Contains(ids, e.ID)
+	})
+	return nil
+}
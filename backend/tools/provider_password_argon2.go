@@ -0,0 +1,99 @@
+package tools
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2id Password Hasher (RFC 9106)
+// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+type passwordHasherArgon2 struct{}
+
+type argon2Parameters struct {
+	Version     int
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	Salt        []byte
+	Key         []byte
+}
+
+func (o *passwordHasherArgon2) Hash(password string) (string, error) {
+	if PASSWORD_ARGON2_MEMORY < 8*PASSWORD_ARGON2_PARALLELISM || PASSWORD_ARGON2_ITERATIONS < 1 ||
+		PASSWORD_ARGON2_PARALLELISM < 1 || PASSWORD_ARGON2_PARALLELISM > 255 {
+		return "", ErrPasswordHashParameters
+	}
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey(
+		[]byte(password),
+		salt,
+		uint32(PASSWORD_ARGON2_ITERATIONS),
+		uint32(PASSWORD_ARGON2_MEMORY),
+		uint8(PASSWORD_ARGON2_PARALLELISM),
+		argon2KeyLength,
+	)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		PASSWORD_ARGON2_MEMORY,
+		PASSWORD_ARGON2_ITERATIONS,
+		PASSWORD_ARGON2_PARALLELISM,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (o *passwordHasherArgon2) Compare(hash, password string) (bool, error) {
+	p, err := o.parse(hash)
+	if err != nil {
+		return false, err
+	}
+	key := argon2.IDKey([]byte(password), p.Salt, p.Iterations, p.Memory, p.Parallelism, uint32(len(p.Key)))
+	return subtle.ConstantTimeCompare(key, p.Key) == 1, nil
+}
+
+func (o *passwordHasherArgon2) Outdated(hash string) bool {
+	p, err := o.parse(hash)
+	return err != nil ||
+		p.Version != argon2.Version ||
+		p.Memory != uint32(PASSWORD_ARGON2_MEMORY) ||
+		p.Iterations != uint32(PASSWORD_ARGON2_ITERATIONS) ||
+		p.Parallelism != uint8(PASSWORD_ARGON2_PARALLELISM) ||
+		len(p.Key) != argon2KeyLength
+}
+
+func (o *passwordHasherArgon2) parse(hash string) (argon2Parameters, error) {
+	var p argon2Parameters
+	s := strings.Split(hash, "$")
+	if len(s) != 6 || s[0] != "" || s[1] != "argon2id" {
+		return p, ErrPasswordHashInvalid
+	}
+	if _, err := fmt.Sscanf(s[2], "v=%d", &p.Version); err != nil || p.Version != argon2.Version {
+		return p, ErrPasswordHashInvalid
+	}
+	if _, err := fmt.Sscanf(s[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil ||
+		p.Iterations < 1 || p.Parallelism < 1 {
+		return p, ErrPasswordHashInvalid
+	}
+	var err error
+	if p.Salt, err = base64.RawStdEncoding.DecodeString(s[4]); err != nil {
+		return p, ErrPasswordHashInvalid
+	}
+	if p.Key, err = base64.RawStdEncoding.DecodeString(s[5]); err != nil || len(p.Key) == 0 {
+		return p, ErrPasswordHashInvalid
+	}
+	return p, nil
+}
@@ -0,0 +1,74 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcrypt Password Hasher
+// $bcrypt$r=12$<salt>$<hash>, or Modular Crypt Format $2b$12$<salt><hash>
+//
+// bcrypt ignores everything past 72 bytes of a Password, so longer
+// Passwords are refused rather than silently truncated
+
+const (
+	bcryptPasswordLimit = 72
+	bcryptSaltLength    = 22 // Encoded Salt Length, followed by the Hash
+)
+
+type passwordHasherBcrypt struct{}
+
+func (o *passwordHasherBcrypt) Hash(password string) (string, error) {
+	hashBytes, err := bcrypt.GenerateFromPassword([]byte(password), PASSWORD_BCRYPT_COST)
+	if err != nil {
+		return "", err
+	}
+	s := strings.Split(string(hashBytes), "$")
+	return fmt.Sprintf("$bcrypt$r=%s$%s$%s", s[2], s[3][:bcryptSaltLength], s[3][bcryptSaltLength:]), nil
+}
+
+func (o *passwordHasherBcrypt) Compare(hash, password string) (bool, error) {
+	if len(password) > bcryptPasswordLimit {
+		return false, nil
+	}
+	mcf, err := o.modular(hash)
+	if err != nil {
+		return false, err
+	}
+	err = bcrypt.CompareHashAndPassword([]byte(mcf), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (o *passwordHasherBcrypt) Outdated(hash string) bool {
+	// Modular Crypt Format Hashes are upgraded to PHC Strings
+	if !strings.HasPrefix(hash, "$bcrypt$") {
+		return true
+	}
+	mcf, err := o.modular(hash)
+	if err != nil {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(mcf))
+	return err != nil || cost != PASSWORD_BCRYPT_COST
+}
+
+// Convert PHC String back into the Modular Crypt Format bcrypt expects
+func (o *passwordHasherBcrypt) modular(hash string) (string, error) {
+	s := strings.Split(hash, "$")
+	switch {
+	case len(s) == 4 && s[0] == "" && passwordHashAlgorithm(hash) == "bcrypt":
+		return hash, nil
+	case len(s) == 5 && s[0] == "" && s[1] == "bcrypt" && strings.HasPrefix(s[2], "r=") && len(s[3]) == bcryptSaltLength:
+		return fmt.Sprintf("$2b$%s$%s%s", strings.TrimPrefix(s[2], "r="), s[3], s[4]), nil
+	}
+	return "", ErrPasswordHashInvalid
+}
@@ -0,0 +1,122 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"sync"
+	"time"
+)
+
+// Breached Password Corpus
+//
+// Bloom Filter over the SHA-1 Digests of Passwords found in Data Breaches,
+// built from the Pwned Passwords list by .github/workflows/breaches.go.
+// Digests are uniformly distributed already so the bit indexes are derived
+// from the digest itself (Kirsch-Mitzenmacher) instead of hashing it again.
+//
+// Archive Layout (Little Endian):
+//
+//	uint8   Hash Functions
+//	uint64  Filter Size in Bits
+//	[]byte  Filter Bits
+type BreachFilter struct {
+	Hashes uint8
+	Size   uint64
+	Bits   []byte
+}
+
+var (
+	ErrBreachArchive = errors.New("breach archive malformed")
+	Breaches         *BreachFilter
+)
+
+func SetupBreaches(stop context.Context, await *sync.WaitGroup) {
+	t := time.Now()
+
+	// Screening is Optional as the Corpus is too large to ship with the Binary
+	if BREACH_CORPUS_FILE == "" {
+		LoggerBreaches.Info("Ready", map[string]any{
+			"enabled": false,
+		})
+		return
+	}
+	data, err := os.ReadFile(BREACH_CORPUS_FILE)
+	if err != nil {
+		LoggerBreaches.Fatal("Failed to Read Archive", err.Error())
+	}
+	filter, err := ParseBreachFilter(data)
+	if err != nil {
+		LoggerBreaches.Fatal("Failed to Parse Archive", err.Error())
+	}
+	Breaches = filter
+
+	LoggerBreaches.Info("Ready", map[string]any{
+		"enabled": true,
+		"time":    time.Since(t).String(),
+		"bits":    filter.Size,
+		"hashes":  filter.Hashes,
+	})
+}
+
+// Create an empty Filter sized for the given Entries and False Positive Rate
+func NewBreachFilter(entries int, rate float64) *BreachFilter {
+	n := math.Max(float64(entries), 1)
+	m := math.Ceil(-n * math.Log(rate) / (math.Ln2 * math.Ln2))
+	k := math.Max(math.Round(m/n*math.Ln2), 1)
+	return &BreachFilter{
+		Hashes: uint8(min(k, math.MaxUint8)),
+		Size:   uint64(m),
+		Bits:   make([]byte, (uint64(m)+7)/8),
+	}
+}
+
+func ParseBreachFilter(data []byte) (*BreachFilter, error) {
+	if len(data) < 9 {
+		return nil, ErrBreachArchive
+	}
+	f := &BreachFilter{
+		Hashes: data[0],
+		Size:   binary.LittleEndian.Uint64(data[1:9]),
+		Bits:   data[9:],
+	}
+	if f.Hashes == 0 || f.Size == 0 || uint64(len(f.Bits)) != (f.Size+7)/8 {
+		return nil, ErrBreachArchive
+	}
+	return f, nil
+}
+
+func (f *BreachFilter) indexes(password string, fn func(bit uint64) bool) bool {
+	digest := sha1.Sum([]byte(password))
+	h1 := binary.LittleEndian.Uint64(digest[0:8])
+	h2 := binary.LittleEndian.Uint64(digest[8:16]) | 1
+	for i := uint64(0); i < uint64(f.Hashes); i++ {
+		if !fn((h1 + i*h2) % f.Size) {
+			return false
+		}
+	}
+	return true
+}
+
+// Record Password as Breached
+func (f *BreachFilter) Insert(password string) {
+	f.indexes(password, func(bit uint64) bool {
+		f.Bits[bit/8] |= 1 << (bit % 8)
+		return true
+	})
+}
+
+// Password was (most likely) found in a Breach
+func (f *BreachFilter) Contains(password string) bool {
+	return f.indexes(password, func(bit uint64) bool {
+		return f.Bits[bit/8]&(1<<(bit%8)) != 0
+	})
+}
+
+// Screen a Password against the Corpus, always passes if none is loaded
+func PasswordBreached(password string) bool {
+	return Breaches != nil && Breaches.Contains(password)
+}
@@ -3,27 +3,36 @@ package tools
 import "time"
 
 type DatabaseUser struct {
-	ID                int64
-	Created           time.Time
-	Updated           time.Time
-	EmailAddress      string
-	EmailVerified     bool
-	IPAddress         string
-	MFAEnabled        bool
-	MFASecret         *string
-	MFACodes          []string
-	MFACodesUsed      int
-	PasswordHash      *string
-	PasswordHistory   []string
-	TokenVerify       *string
-	TokenVerifyEAT    *time.Time
-	TokenLogin        *string
-	TokenLoginData    *string
-	TokenLoginExpires *time.Time
-	TokenReset        *string
-	TokenResetEAT     *time.Time
-	TokenPasscode     *string
-	TokenPasscodeEAT  *time.Time
+	ID                    int64
+	Created               time.Time
+	Updated               time.Time
+	EmailAddress          string
+	EmailVerified         bool
+	MFAEnabled            bool
+	MFASecret             *string
+	MFACodes              []string
+	MFACodesUsed          int
+	PasswordHash          *string
+	PasswordHistory       []string
+	PasswordBreached      *time.Time
+	TokenVerify           *string
+	TokenVerifyEAT        *time.Time
+	TokenLogin            *string
+	TokenLoginData        *string
+	TokenLoginExpires     *time.Time
+	TokenReset            *string
+	TokenResetEAT         *time.Time
+	TokenPasscode         *string
+	TokenPasscodeEAT      *time.Time
+	TokenPasscodeAttempts int
+	TokenMagic            *string
+	TokenMagicPasscode    *string
+	TokenMagicEAT         *time.Time
+	TokenMagicAttempts    int
+	TokenUnlock           *string
+	TokenUnlockEAT        *time.Time
+	LockoutUntil          *time.Time
+	LockoutCount          int
 }
 
 type DatabaseProfile struct {
@@ -49,6 +58,8 @@ type DatabaseSession struct {
 	Revoked         bool
 	Token           string
 	ElevatedUntil   int
+	Expires         time.Time
+	Used            time.Time
 	DeviceIPAddress string
 	DeviceUserAgent string
 }
@@ -63,6 +74,8 @@ type DatabaseApplication struct {
 	IconHash      *string
 	AuthSecret    string
 	AuthRedirects []string
+	AuthPublic    bool
+	AuthScopes    int
 }
 
 type DatabaseConnection struct {
@@ -78,12 +91,79 @@ type DatabaseConnection struct {
 	TokenRefresh  *string
 }
 
-type DatabaseGrant struct {
-	ID            int64
-	Expires       time.Time
+type DatabaseRefreshToken struct {
+	Token        string
+	ConnectionID int64
+	Created      time.Time
+	Parent       *string
+	Rotated      *time.Time
+}
+
+type DatabasePasskey struct {
+	ID           int64
+	Created      time.Time
+	Used         *time.Time
+	UserID       int64
+	Name         string
+	CredentialID string
+	PublicKey    []byte
+	SignCount    int64
+	AAGUID       string
+}
+
+type DatabaseDevice struct {
+	ID           int64
+	Created      time.Time
+	Used         time.Time
+	UserID       int64
+	Token        *string
+	Verified     bool
+	TrustedUntil *time.Time
+	IPAddress    string
+	UserAgent    string
+}
+
+type DatabaseIdentity struct {
+	ID           int64
+	Created      time.Time
+	Used         time.Time
+	UserID       int64
+	Provider     string
+	Subject      string
+	EmailAddress *string
+}
+
+type DatabaseFederationState struct {
+	State      string
+	Expires    time.Time
+	Provider   string
+	Nonce      string
+	Verifier   string
+	LinkUserID *int64
+	UserID     *int64
+}
+
+type DatabaseConsent struct {
 	UserID        int64
 	ApplicationID int64
-	RedirectURI   string
+	Created       time.Time
+	Updated       time.Time
 	Scopes        int
-	Code          string
+}
+
+type DatabaseGrant struct {
+	ID                  int64
+	Expires             time.Time
+	UserID              int64
+	ApplicationID       int64
+	RedirectURI         string
+	Scopes              int
+	Code                string
+	CodeChallenge       *string
+	CodeChallengeMethod *string
+	Nonce               *string
+	UserCode            *string
+	Polled              *time.Time
+	PollInterval        *int
+	Denied              bool
 }
@@ -6,6 +6,7 @@ import (
 	"html/template"
 	"sync"
 	"testing"
+	texttemplate "text/template"
 	"time"
 
 	"github.com/bakonpancakz/template-auth/include"
@@ -19,6 +20,10 @@ type LocalsLoginForgotPassword struct {
 	Displayname string
 	Token       string
 }
+type LocalsLoginPasswordBreached struct {
+	Displayname string
+	Token       string
+}
 type LocalsLoginNewLocation struct {
 	Displayname    string
 	Token          string
@@ -34,11 +39,26 @@ type LocalsLoginNewDevice struct {
 	DeviceBrowser  string
 	DeviceLocation string
 }
+type LocalsLoginAccountLocked struct {
+	Displayname    string
+	Token          string
+	LockedUntil    string
+	Timestamp      string
+	IpAddress      string
+	DeviceBrowser  string
+	DeviceLocation string
+}
 type LocalsLoginPasscode struct {
 	Displayname string
 	Code        string
 	Lifetime    string
 }
+type LocalsLoginEmail struct {
+	Displayname string
+	Token       string
+	Code        string
+	Lifetime    string
+}
 type LocalsNotifyUserDeleted struct {
 	Displayname string
 	Reason      string
@@ -49,21 +69,45 @@ type LocalsNotifyUserEmailModified struct {
 type LocalsNotifyUserPasswordModified struct {
 	Displayname string
 }
+type LocalsNotifyConnectionRevoked struct {
+	Displayname string
+	Application string
+}
 
 var (
 	TemplateEmailVerify                = SetupEmailTemplate[LocalsEmailVerify]("EMAIL_VERIFY", "Verify your Email Address")
 	TemplateLoginForgotPassword        = SetupEmailTemplate[LocalsLoginForgotPassword]("LOGIN_FORGOT_PASSWORD", "Forgot Your Password?")
+	TemplateLoginPasswordBreached      = SetupEmailTemplate[LocalsLoginPasswordBreached]("LOGIN_PASSWORD_BREACHED", "Your Password was found in a Data Breach")
 	TemplateLoginNewLocation           = SetupEmailTemplate[LocalsLoginNewLocation]("LOGIN_NEW_LOCATION", "Allow Login from a New Location")
 	TemplateLoginNewDevice             = SetupEmailTemplate[LocalsLoginNewDevice]("LOGIN_NEW_DEVICE", "Login from a New Device")
+	TemplateLoginAccountLocked         = SetupEmailTemplate[LocalsLoginAccountLocked]("LOGIN_ACCOUNT_LOCKED", "Your Account was Locked")
 	TemplateLoginPasscode              = SetupEmailTemplate[LocalsLoginPasscode]("LOGIN_PASSCODE", "Your One Time Passcode")
+	TemplateLoginEmail                 = SetupEmailTemplate[LocalsLoginEmail]("LOGIN_EMAIL", "Your Login Link")
 	TemplateNotifyUserDeleted          = SetupEmailTemplate[LocalsNotifyUserDeleted]("NOTIFY_USER_DELETED", "Account Deleted")
 	TemplateNotifyUserEmailModified    = SetupEmailTemplate[LocalsNotifyUserEmailModified]("NOTIFY_USER_EMAIL_MODIFIED", "Your Account Password has Changed")
 	TemplateNotifyUserPasswordModified = SetupEmailTemplate[LocalsNotifyUserPasswordModified]("NOTIFY_USER_PASS_MODIFIED", "Your Account Email has Changed")
+	TemplateNotifyConnectionRevoked    = SetupEmailTemplate[LocalsNotifyConnectionRevoked]("NOTIFY_CONNECTION_REVOKED", "An Application was Disconnected")
 )
 
+type EmailMessage struct {
+	To          string
+	Subject     string
+	HTML        string
+	Text        string
+	Headers     map[string]string // Additional Headers, e.g. Message-ID or Reply-To
+	Tags        map[string]string // Provider Metadata for Tracking, never shown to the Recipient
+	Attachments []EmailAttachment
+}
+
+type EmailAttachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
 type EmailProvider interface {
 	Start(stop context.Context, await *sync.WaitGroup) error
-	Send(toAddress, subject, html string) error
+	Send(message EmailMessage) error
 }
 
 var Email EmailProvider
@@ -76,6 +120,8 @@ func SetupEmailProvider(stop context.Context, await *sync.WaitGroup) {
 		Email = &emailProviderSES{}
 	case "emailengine":
 		Email = &emailProviderEmailEngine{}
+	case "smtp":
+		Email = &emailProviderSMTP{}
 	case "none":
 		Email = &emailProviderNone{}
 	case "test":
@@ -97,8 +143,8 @@ func SetupEmailProvider(stop context.Context, await *sync.WaitGroup) {
 
 func SetupEmailTemplate[L any](filename, subjectLine string) func(emailAddress string, locals L) {
 
-	// Parse Template
-	template, err := template.ParseFS(
+	// Parse Templates, every Email has a Plain-Text Alternative
+	htmlTemplate, err := template.ParseFS(
 		include.EmailTemplates,
 		"templates/_TEMPLATE.html",
 		"templates/"+filename+".html",
@@ -106,16 +152,29 @@ func SetupEmailTemplate[L any](filename, subjectLine string) func(emailAddress s
 	if err != nil {
 		panic("cannot parse template: " + err.Error())
 	}
+	textTemplate, err := texttemplate.ParseFS(
+		include.EmailTemplates,
+		"templates/_TEMPLATE.txt",
+		"templates/"+filename+".txt",
+	)
+	if err != nil {
+		panic("cannot parse template: " + err.Error())
+	}
 
-	// Send Function
+	// Enqueue Function, Emails are sent by the Outbox Workers
 	return func(emailAddress string, locals L) {
 
 		// Render Email
-		var buffer bytes.Buffer
-		if err := template.Execute(&buffer, map[string]any{
+		var htmlBuffer, textBuffer bytes.Buffer
+		data := map[string]any{
 			"Host": EMAIL_DEFAULT_HOST,
 			"Data": locals,
-		}); err != nil {
+		}
+		err := htmlTemplate.Execute(&htmlBuffer, data)
+		if err == nil {
+			err = textTemplate.Execute(&textBuffer, data)
+		}
+		if err != nil {
 			LoggerEmail.Error("Render Failed", map[string]any{
 				"address":  emailAddress,
 				"template": filename,
@@ -125,17 +184,24 @@ func SetupEmailTemplate[L any](filename, subjectLine string) func(emailAddress s
 			return
 		}
 
-		// Send Email
-		err := Email.Send(emailAddress, subjectLine, buffer.String())
-		dat := map[string]any{
-			"address":  emailAddress,
-			"template": filename,
-			"error":    err,
-		}
-		if err == nil {
-			LoggerEmail.Info("Email Sent", dat)
-		} else {
-			LoggerEmail.Error("Email Failed", dat)
+		// Enqueue Email, the Message-ID is kept across Retries
+		ctx, cancel := NewContext()
+		defer cancel()
+		body := htmlBuffer.String()
+		_, err = EnqueueEmail(ctx, OutboxEmail{
+			Key:      OutboxKey(filename, emailAddress, subjectLine, body),
+			Template: filename,
+			Address:  emailAddress,
+			Subject:  subjectLine,
+			Body:     body,
+			Text:     textBuffer.String(),
+			Headers:  map[string]string{"Message-ID": EmailMessageID()},
+		})
+		if err != nil {
+			LoggerEmail.Error("Enqueue Failed", map[string]any{
+				"template": filename,
+				"error":    err.Error(),
+			})
 		}
 	}
 }
@@ -0,0 +1,337 @@
+package tools
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrFederationDiscovery = errors.New("federation discovery failed")
+	ErrFederationRequest   = errors.New("federation request failed")
+	ErrFederationIDToken   = errors.New("federation id token invalid")
+	ErrFederationSubject   = errors.New("federation identity has no subject")
+)
+
+// Upstream OpenID Connect or OAuth2 Provider used for Federated Login.
+// Endpoints are discovered from the Issuer unless configured explicitly,
+// which allows plain OAuth2 Providers without an Issuer to be used.
+type FederationProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	AuthorizeURL string
+	TokenURL     string
+	UserinfoURL  string
+	JWKSURL      string
+	mutex        sync.Mutex
+	discovered   time.Time
+	keys         map[string]crypto.PublicKey
+}
+
+// Identity as Reported by a Provider
+type FederationIdentity struct {
+	Subject       string
+	EmailAddress  string
+	EmailVerified bool
+	Displayname   string
+	Username      string
+}
+
+// Configured Providers by Name
+var Federation = map[string]*FederationProvider{}
+
+func SetupFederation(stop context.Context, await *sync.WaitGroup) {
+	t := time.Now()
+
+	for _, name := range FEDERATION_PROVIDERS {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "FEDERATION_" + strings.ToUpper(name) + "_"
+		provider := &FederationProvider{
+			Name:         name,
+			Issuer:       EnvString(prefix+"ISSUER", ""),
+			ClientID:     EnvString(prefix+"CLIENT_ID", ""),
+			ClientSecret: EnvString(prefix+"CLIENT_SECRET", ""),
+			Scopes:       EnvSlice(prefix+"SCOPES", " ", []string{"openid", "email", "profile"}),
+			AuthorizeURL: EnvString(prefix+"AUTHORIZE_URL", ""),
+			TokenURL:     EnvString(prefix+"TOKEN_URL", ""),
+			UserinfoURL:  EnvString(prefix+"USERINFO_URL", ""),
+		}
+		if provider.ClientID == "" ||
+			(provider.Issuer == "" && (provider.AuthorizeURL == "" || provider.TokenURL == "")) {
+			LoggerFederation.Fatal("Incomplete Provider Configuration", name)
+		}
+		Federation[name] = provider
+	}
+
+	LoggerFederation.Info("Ready", map[string]any{
+		"time":      time.Since(t).String(),
+		"providers": len(Federation),
+	})
+}
+
+// Redirect URI registered with the Provider, the Frontend forwards the Callback to us
+func (p *FederationProvider) RedirectURI() string {
+	return strings.TrimSuffix(FEDERATION_REDIRECT_URL, "/") + "/" + p.Name
+}
+
+// Generate Authorization URL using the given State, Nonce and PKCE Verifier
+func (p *FederationProvider) AuthorizationURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	if err := p.discover(ctx, false); err != nil {
+		return "", err
+	}
+	endpoint, err := url.Parse(p.AuthorizeURL)
+	if err != nil {
+		return "", ErrFederationDiscovery
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+	query := endpoint.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.ClientID)
+	query.Set("redirect_uri", p.RedirectURI())
+	query.Set("scope", strings.Join(p.Scopes, " "))
+	query.Set("state", state)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", PKCE_METHOD_S256)
+	if slices.Contains(p.Scopes, "openid") {
+		query.Set("nonce", nonce)
+	}
+	endpoint.RawQuery = query.Encode()
+	return endpoint.String(), nil
+}
+
+// Exchange Authorization Code for the Identity of the User, an ID Token is
+// preferred but Providers without one are queried using their Userinfo Endpoint
+func (p *FederationProvider) Exchange(ctx context.Context, code, verifier, nonce string) (FederationIdentity, error) {
+	if err := p.discover(ctx, false); err != nil {
+		return FederationIdentity{}, err
+	}
+
+	// Redeem Authorization Code
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURI()},
+		"code_verifier": {verifier},
+		"client_id":     {p.ClientID},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return FederationIdentity{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	var token struct {
+		AccessToken string `json:"access_token"`
+		IDToken     string `json:"id_token"`
+	}
+	if err := federationDo(req, &token); err != nil {
+		return FederationIdentity{}, err
+	}
+
+	// Collect Claims
+	claims := map[string]any{}
+	if token.IDToken != "" {
+		if claims, err = p.verifyIDToken(ctx, token.IDToken, nonce); err != nil {
+			return FederationIdentity{}, err
+		}
+	}
+	if _, ok := claims["email"]; !ok && p.UserinfoURL != "" && token.AccessToken != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.UserinfoURL, http.NoBody)
+		if err != nil {
+			return FederationIdentity{}, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+		userinfo := map[string]any{}
+		if err := federationDo(req, &userinfo); err != nil {
+			return FederationIdentity{}, err
+		}
+		// Userinfo must describe the same User as the ID Token (OIDC Core 5.3.2)
+		if sub, ok := claims["sub"]; ok && userinfo["sub"] != sub {
+			return FederationIdentity{}, ErrFederationIDToken
+		}
+		for k, v := range userinfo {
+			if _, ok := claims[k]; !ok {
+				claims[k] = v
+			}
+		}
+	}
+
+	return federationIdentity(claims)
+}
+
+// Verify ID Token Signature and its Issuer, Audience and Nonce
+func (p *FederationProvider) verifyIDToken(ctx context.Context, idToken, nonce string) (map[string]any, error) {
+	lookup := func(kid string) crypto.PublicKey {
+		p.mutex.Lock()
+		defer p.mutex.Unlock()
+		return p.keys[kid]
+	}
+	claims, err := CompareJWT(idToken, lookup)
+	if errors.Is(err, ErrJWTUnknownKey) {
+		// Provider may have rotated its Keys since they were last fetched
+		if err := p.discover(ctx, true); err != nil {
+			return nil, err
+		}
+		claims, err = CompareJWT(idToken, lookup)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if iss, _ := claims["iss"].(string); iss != p.Issuer {
+		return nil, ErrFederationIDToken
+	}
+	if _, ok := claims["exp"].(float64); !ok {
+		return nil, ErrFederationIDToken
+	}
+	switch aud := claims["aud"].(type) {
+	case string:
+		if aud != p.ClientID {
+			return nil, ErrFederationIDToken
+		}
+	case []any:
+		if !slices.Contains(aud, any(p.ClientID)) {
+			return nil, ErrFederationIDToken
+		}
+	default:
+		return nil, ErrFederationIDToken
+	}
+	if given, _ := claims["nonce"].(string); !CompareStringConstant(given, nonce) {
+		return nil, ErrFederationIDToken
+	}
+	return claims, nil
+}
+
+// Fetch Endpoints and Signing Keys from the Issuer, cached for FEDERATION_DISCOVERY_INTERVAL
+func (p *FederationProvider) discover(ctx context.Context, force bool) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.Issuer == "" || (!force && time.Since(p.discovered) < FEDERATION_DISCOVERY_INTERVAL) {
+		return nil
+	}
+
+	// Fetch Discovery Document
+	var document struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		UserinfoEndpoint      string `json:"userinfo_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		strings.TrimSuffix(p.Issuer, "/")+"/.well-known/openid-configuration",
+		http.NoBody,
+	)
+	if err != nil {
+		return err
+	}
+	if err := federationDo(req, &document); err != nil {
+		return err
+	}
+	if document.Issuer != p.Issuer || document.JWKSURI == "" {
+		return ErrFederationDiscovery
+	}
+
+	// Explicitly Configured Endpoints take Priority
+	if p.AuthorizeURL == "" {
+		p.AuthorizeURL = document.AuthorizationEndpoint
+	}
+	if p.TokenURL == "" {
+		p.TokenURL = document.TokenEndpoint
+	}
+	if p.UserinfoURL == "" {
+		p.UserinfoURL = document.UserinfoEndpoint
+	}
+	p.JWKSURL = document.JWKSURI
+
+	// Fetch Signing Keys
+	var set struct {
+		Keys []JWK `json:"keys"`
+	}
+	if req, err = http.NewRequestWithContext(ctx, http.MethodGet, p.JWKSURL, http.NoBody); err != nil {
+		return err
+	}
+	if err := federationDo(req, &set); err != nil {
+		return err
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != KEY_USAGE_SIGNATURE {
+			continue
+		}
+		if key, err := ParseJWK(jwk); err == nil {
+			keys[jwk.KeyID] = key
+		}
+	}
+	p.keys = keys
+	p.discovered = time.Now()
+	return nil
+}
+
+// Send Request to a Provider and Decode its JSON Response
+func federationDo(req *http.Request, dst any) error {
+	req.Header.Set("Accept", "application/json")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return ErrFederationRequest
+	}
+	body := io.LimitReader(res.Body, FEDERATION_RESPONSE_LIMIT)
+	if err := json.NewDecoder(body).Decode(dst); err != nil {
+		return ErrFederationRequest
+	}
+	return nil
+}
+
+// Map Standard Claims, falling back to the Fields used by common OAuth2 Providers
+func federationIdentity(claims map[string]any) (FederationIdentity, error) {
+	text := func(keys ...string) string {
+		for _, k := range keys {
+			switch v := claims[k].(type) {
+			case string:
+				if v != "" {
+					return v
+				}
+			case float64:
+				return strconv.FormatFloat(v, 'f', -1, 64)
+			}
+		}
+		return ""
+	}
+	identity := FederationIdentity{
+		Subject:      text("sub", "id"),
+		EmailAddress: strings.ToLower(text("email")),
+		Displayname:  text("name", "preferred_username", "login"),
+		Username:     text("preferred_username", "login", "nickname"),
+	}
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = v
+	case string:
+		identity.EmailVerified = v == "true"
+	}
+	if identity.Subject == "" {
+		return identity, ErrFederationSubject
+	}
+	return identity, nil
+}
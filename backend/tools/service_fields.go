@@ -0,0 +1,490 @@
+package tools
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Field Encryption
+//
+// Sensitive Columns are sealed with Envelope Encryption, every Value is
+// encrypted with its own random Data Key which is in turn wrapped by the
+// current Master Key from the Keystore (AES-256-GCM for both):
+//
+//	e1:<kid>.<nonce + wrapped data key>.<nonce + ciphertext>
+//
+// Rotating the Master Key only requires the Data Keys to be re-wrapped, see
+// the 'admin_reencrypt_fields' command. Values without the Prefix were stored
+// before encryption was introduced and are returned as is until migrated.
+//
+// Encrypted Columns can't be compared in SQL, so Columns used in Lookups are
+// also stored as a Blind Index (a Keyed Hash) for equality comparisons.
+const FIELD_ENVELOPE_PREFIX = "e1:"
+
+var (
+	ErrFieldEnvelope = errors.New("field envelope malformed")
+	ErrFieldKey      = errors.New("field master key unavailable")
+)
+
+// Column holding Encrypted Values, Rows are identified by their Key Column
+type FieldColumn struct {
+	Table       string
+	Key         string
+	Column      string
+	IndexColumn string                    // Column holding the Blind Index, if any
+	Index       func(value string) string // Generates the Blind Index of a Value
+}
+
+// Columns holding Encrypted Values
+var FieldColumns = []FieldColumn{
+	{Table: "auth.users", Key: "id", Column: "email_address", IndexColumn: "email_index", Index: EmailIndex},
+	{Table: "auth.users", Key: "id", Column: "mfa_secret"},
+	{Table: "auth.sessions", Key: "id", Column: "device_ip_address"},
+	{Table: "auth.sessions", Key: "id", Column: "device_user_agent"},
+	{Table: "auth.devices", Key: "id", Column: "ip_address", IndexColumn: "ip_index", Index: AddressIndex},
+	{Table: "auth.devices", Key: "id", Column: "user_agent"},
+	{Table: "auth.login_assessments", Key: "id", Column: "ip_address"},
+	{Table: "auth.login_assessments", Key: "id", Column: "user_agent"},
+	{Table: "auth.identities", Key: "id", Column: "email_address"},
+	{Table: "auth.scim_users", Key: "user_id", Column: "user_name", IndexColumn: "user_name_index", Index: UserNameIndex},
+	{Table: "auth.email_outbox", Key: "id", Column: "address"},
+	{Table: "auth.email_outbox", Key: "id", Column: "body"},
+	{Table: "auth.email_outbox", Key: "id", Column: "text"},
+}
+
+func SetupFields(stop context.Context, await *sync.WaitGroup) {
+	t := time.Now()
+
+	if len(FIELD_INDEX_KEY) == 0 && !testing.Testing() {
+		LoggerFields.Fatal("Index Key Required", nil)
+	}
+
+	// Lookups depend on the Blind Index, so Rows are migrated before Startup
+	migrated, err := MigrateFields(context.Background())
+	if err != nil {
+		LoggerFields.Fatal("Migration Failed", err.Error())
+	}
+
+	LoggerFields.Info("Ready", map[string]any{
+		"time":     time.Since(t).String(),
+		"migrated": migrated,
+	})
+}
+
+// Generate the Blind Index of an Email Address
+func EmailIndex(email string) string {
+	return fieldIndex("email:" + strings.ToLower(email))
+}
+
+// Generate the Blind Index of an IP Address
+func AddressIndex(address string) string {
+	return fieldIndex("address:" + address)
+}
+
+// Generate the Blind Index of a SCIM userName, which is not Case Exact
+func UserNameIndex(userName string) string {
+	return fieldIndex("username:" + strings.ToLower(userName))
+}
+
+// Values are prefixed with their Kind so Indexes can't be compared across Columns
+func fieldIndex(value string) string {
+	h := hmac.New(sha256.New, FIELD_INDEX_KEY)
+	h.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Encrypt a Value unless it's Empty, which is stored as NULL instead
+func EncryptFieldOptional(plaintext string) (*string, error) {
+	if plaintext == "" {
+		return nil, nil
+	}
+	sealed, err := EncryptField(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	return &sealed, nil
+}
+
+// Encrypt a Value under a new Data Key
+func EncryptField(plaintext string) (string, error) {
+	master, err := KeystoreCurrent(KEY_USAGE_ENCRYPTION)
+	if err != nil {
+		return "", err
+	}
+	dataKey := make([]byte, 32)
+	rand.Read(dataKey)
+
+	wrapped, err := fieldSeal(master.Material, dataKey, []byte(master.ID))
+	if err != nil {
+		return "", err
+	}
+	sealed, err := fieldSeal(dataKey, []byte(plaintext), nil)
+	if err != nil {
+		return "", err
+	}
+	return FIELD_ENVELOPE_PREFIX + master.ID + "." +
+		base64.RawURLEncoding.EncodeToString(wrapped) + "." +
+		base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt a Value, returning Values stored before encryption as is
+func DecryptField(stored string) (string, error) {
+	if !strings.HasPrefix(stored, FIELD_ENVELOPE_PREFIX) {
+		return stored, nil
+	}
+	kid, wrapped, sealed, err := fieldParse(stored)
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := fieldUnwrap(kid, wrapped)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := fieldOpen(dataKey, sealed, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// Wrap the Data Key of a Value with the current Master Key, the Ciphertext
+// itself is left untouched. Reports whether the Value was changed.
+func RewrapField(stored string) (string, bool, error) {
+	if !strings.HasPrefix(stored, FIELD_ENVELOPE_PREFIX) {
+		encrypted, err := EncryptField(stored)
+		return encrypted, err == nil, err
+	}
+	kid, wrapped, sealed, err := fieldParse(stored)
+	if err != nil {
+		return "", false, err
+	}
+	master, err := KeystoreCurrent(KEY_USAGE_ENCRYPTION)
+	if err != nil {
+		return "", false, err
+	}
+	if kid == master.ID {
+		return stored, false, nil
+	}
+	dataKey, err := fieldUnwrap(kid, wrapped)
+	if err != nil {
+		return "", false, err
+	}
+	if wrapped, err = fieldSeal(master.Material, dataKey, []byte(master.ID)); err != nil {
+		return "", false, err
+	}
+	return FIELD_ENVELOPE_PREFIX + master.ID + "." +
+		base64.RawURLEncoding.EncodeToString(wrapped) + "." +
+		base64.RawURLEncoding.EncodeToString(sealed), true, nil
+}
+
+// Scan Target decrypting a Column into dst, which is either *string or **string
+func Decrypted(dst any) sql.Scanner {
+	return &fieldScanner{dst: dst}
+}
+
+type fieldScanner struct {
+	dst any
+}
+
+func (s *fieldScanner) Scan(src any) error {
+	var stored *string
+	switch v := src.(type) {
+	case nil:
+	case string:
+		stored = &v
+	case []byte:
+		str := string(v)
+		stored = &str
+	default:
+		return ErrFieldEnvelope
+	}
+	var plaintext *string
+	if stored != nil {
+		value, err := DecryptField(*stored)
+		if err != nil {
+			return err
+		}
+		plaintext = &value
+	}
+	switch dst := s.dst.(type) {
+	case *string:
+		if plaintext == nil {
+			return ErrFieldEnvelope
+		}
+		*dst = *plaintext
+	case **string:
+		*dst = plaintext
+	default:
+		return ErrFieldEnvelope
+	}
+	return nil
+}
+
+// Split an Envelope into its Master Key ID, Wrapped Data Key and Ciphertext
+func fieldParse(stored string) (string, []byte, []byte, error) {
+	s := strings.Split(strings.TrimPrefix(stored, FIELD_ENVELOPE_PREFIX), ".")
+	if len(s) != 3 {
+		return "", nil, nil, ErrFieldEnvelope
+	}
+	wrapped, err := base64.RawURLEncoding.DecodeString(s[1])
+	if err != nil {
+		return "", nil, nil, ErrFieldEnvelope
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(s[2])
+	if err != nil {
+		return "", nil, nil, ErrFieldEnvelope
+	}
+	return s[0], wrapped, sealed, nil
+}
+
+// Unwrap a Data Key using the Master Key it was wrapped with
+func fieldUnwrap(kid string, wrapped []byte) ([]byte, error) {
+	master, ok := KeystoreLookup(KEY_USAGE_ENCRYPTION, kid)
+	if !ok && Keystore != nil {
+		// Key may have been generated by another Instance since the last Reload
+		ctx, cancel := NewContext()
+		KeystoreRotate(ctx, time.Now())
+		cancel()
+		master, ok = KeystoreLookup(KEY_USAGE_ENCRYPTION, kid)
+	}
+	if !ok {
+		return nil, ErrFieldKey
+	}
+	return fieldOpen(master.Material, wrapped, []byte(kid))
+}
+
+func fieldSeal(key, plaintext, additional []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	rand.Read(nonce)
+	return aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+func fieldOpen(key, sealed, additional []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrFieldEnvelope
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additional)
+}
+
+// Encrypt and Index Rows stored before encryption was introduced, returning the Rows updated
+func MigrateFields(ctx context.Context) (int64, error) {
+	var migrated int64
+	for _, c := range FieldColumns {
+		// Indexed Columns are Encrypted alongside their Index
+		migrate := func(ctx context.Context) (int64, error) { return rewrapColumn(ctx, c, true) }
+		if c.IndexColumn != "" {
+			migrate = func(ctx context.Context) (int64, error) { return migrateIndexed(ctx, c) }
+		}
+		for {
+			batchCtx, cancel := context.WithTimeout(ctx, CONTEXT_TIMEOUT)
+			n, err := migrate(batchCtx)
+			cancel()
+			if err != nil {
+				return migrated, err
+			}
+			migrated += n
+			if n < FIELD_MIGRATE_BATCH {
+				break
+			}
+		}
+	}
+	return migrated, nil
+}
+
+// Re-wrap every Value protected by a retired Master Key, then retire those
+// Keys once nothing depends on them. Returns the Values updated.
+func ReencryptFields(ctx context.Context) (int64, error) {
+	migrated, err := MigrateFields(ctx)
+	if err != nil {
+		return migrated, err
+	}
+	for _, c := range FieldColumns {
+		for {
+			batchCtx, cancel := context.WithTimeout(ctx, CONTEXT_TIMEOUT)
+			n, err := rewrapColumn(batchCtx, c, false)
+			cancel()
+			if err != nil {
+				return migrated, err
+			}
+			migrated += n
+			if n < FIELD_MIGRATE_BATCH {
+				break
+			}
+		}
+	}
+
+	// Retire Keys, unless something was written with them in the meantime
+	current, err := KeystoreCurrent(KEY_USAGE_ENCRYPTION)
+	if err != nil {
+		return migrated, err
+	}
+	now := time.Now()
+	for _, k := range KeystorePublished(KEY_USAGE_ENCRYPTION) {
+		if k.ID == current.ID || k.Activates.After(current.Activates) {
+			continue
+		}
+		var used bool
+		for _, c := range FieldColumns {
+			if err := Database.QueryRow(ctx,
+				"SELECT EXISTS (SELECT 1 FROM "+c.Table+" WHERE "+c.Column+" LIKE $1)",
+				fieldKeyPattern(k.ID),
+			).Scan(&used); err != nil {
+				return migrated, err
+			}
+			if used {
+				break
+			}
+		}
+		if used {
+			continue
+		}
+		k.Expires = &now
+		if err := Keystore.Save(ctx, k); err != nil {
+			return migrated, err
+		}
+		LoggerFields.Info("Key Retired", map[string]any{
+			"kid": k.ID,
+		})
+	}
+	return migrated, KeystoreRotate(ctx, now)
+}
+
+// Encrypt and Index a Batch of Values without a Blind Index
+func migrateIndexed(ctx context.Context, c FieldColumn) (int64, error) {
+	rows, err := Database.Query(ctx,
+		"SELECT "+c.Key+", "+c.Column+" FROM "+c.Table+" WHERE "+c.IndexColumn+" IS NULL LIMIT $1",
+		FIELD_MIGRATE_BATCH,
+	)
+	if err != nil {
+		return 0, err
+	}
+	var keys []int64
+	var values, indexes []string
+	for rows.Next() {
+		var key int64
+		var value string
+		if err := rows.Scan(&key, Decrypted(&value)); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		encrypted, err := EncryptField(value)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		keys = append(keys, key)
+		values = append(values, encrypted)
+		indexes = append(indexes, c.Index(value))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	if _, err := Database.Exec(ctx,
+		"UPDATE "+c.Table+" t SET "+c.Column+" = v.value, "+c.IndexColumn+` = v.index
+		FROM UNNEST($1::BIGINT[], $2::TEXT[], $3::TEXT[]) AS v(key, value, index)
+		WHERE t.`+c.Key+" = v.key AND t."+c.IndexColumn+" IS NULL",
+		keys,
+		values,
+		indexes,
+	); err != nil {
+		return 0, err
+	}
+	return int64(len(keys)), nil
+}
+
+// Encrypt a Batch of Plaintext Values in a Column, or re-wrap a Batch of
+// Values protected by a retired Master Key. Returns the Values processed.
+func rewrapColumn(ctx context.Context, c FieldColumn, plaintextOnly bool) (int64, error) {
+	var query, pattern string
+	if plaintextOnly {
+		query = "SELECT " + c.Key + ", " + c.Column + " FROM " + c.Table + " WHERE " + c.Column + " NOT LIKE $1 LIMIT $2"
+		pattern = FIELD_ENVELOPE_PREFIX + "%"
+	} else {
+		query = "SELECT " + c.Key + ", " + c.Column + " FROM " + c.Table + " WHERE " + c.Column + " NOT LIKE $1 AND " + c.Column + " LIKE '" + FIELD_ENVELOPE_PREFIX + "%' LIMIT $2"
+		current, err := KeystoreCurrent(KEY_USAGE_ENCRYPTION)
+		if err != nil {
+			return 0, err
+		}
+		pattern = fieldKeyPattern(current.ID)
+	}
+	rows, err := Database.Query(ctx, query, pattern, FIELD_MIGRATE_BATCH)
+	if err != nil {
+		return 0, err
+	}
+	var ids []int64
+	var previous, updated []string
+	for rows.Next() {
+		var id int64
+		var stored string
+		if err := rows.Scan(&id, &stored); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		value, changed, err := RewrapField(stored)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if changed {
+			ids = append(ids, id)
+			previous = append(previous, stored)
+			updated = append(updated, value)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	// Replace Values, unless they were changed in the meantime
+	if _, err := Database.Exec(ctx,
+		"UPDATE "+c.Table+" t SET "+c.Column+` = v.updated
+		FROM UNNEST($1::BIGINT[], $2::TEXT[], $3::TEXT[]) AS v(key, previous, updated)
+		WHERE t.`+c.Key+" = v.key AND t."+c.Column+" = v.previous",
+		ids,
+		previous,
+		updated,
+	); err != nil {
+		return 0, err
+	}
+	return int64(len(ids)), nil
+}
+
+// Pattern matching Values protected by the given Master Key
+func fieldKeyPattern(kid string) string {
+	return FIELD_ENVELOPE_PREFIX + strings.ReplaceAll(kid, "_", `\_`) + ".%"
+}
@@ -0,0 +1,349 @@
+package tools
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	KEY_USAGE_HMAC        = "hmac" // Symmetric Key for Signed Strings
+	KEY_USAGE_SIGNATURE   = "sig"  // Asymmetric Key for JSON Web Tokens
+	KEY_USAGE_ENCRYPTION  = "enc"  // Symmetric Master Key for Field Encryption
+	KEY_ALGORITHM_HS256   = "HS256"
+	KEY_ALGORITHM_A256GCM = "A256GCM"
+)
+
+var (
+	ErrKeyAlgorithm = errors.New("unsupported key algorithm")
+	ErrKeyUsage     = errors.New("unsupported key usage")
+	ErrKeyWrap      = errors.New("keystore wrap key required")
+	ErrKeyMissing   = errors.New("keystore has no active key")
+)
+
+type KeystoreKey struct {
+	ID        string     `json:"kid"`       // Key ID
+	Usage     string     `json:"use"`       // Key Usage (KEY_USAGE_*)
+	Algorithm string     `json:"alg"`       // Key Algorithm
+	Created   time.Time  `json:"created"`   // Created At
+	Activates time.Time  `json:"activates"` // Used for Signing starting At
+	Expires   *time.Time `json:"expires"`   // No longer Valid At, set once retired
+	Material  []byte     `json:"material"`  // Raw Secret or PKCS #8 Private Key
+	signer    crypto.Signer
+}
+
+// Retrieve Private Key for an Asymmetric Key
+func (k *KeystoreKey) Signer() crypto.Signer {
+	return k.signer
+}
+
+// Key is currently valid for Verification
+func (k *KeystoreKey) Valid(now time.Time) bool {
+	return k.Expires == nil || now.Before(*k.Expires)
+}
+
+type KeystoreProvider interface {
+	Start(stop context.Context, await *sync.WaitGroup) error
+	Load(ctx context.Context) ([]KeystoreKey, error)
+	Save(ctx context.Context, keys ...KeystoreKey) error
+	Delete(ctx context.Context, ids ...string) error
+}
+
+var (
+	Keystore      KeystoreProvider
+	keyringMutex  sync.RWMutex
+	keyringKeys   []KeystoreKey
+	keyringUsages = map[string]func() string{
+		KEY_USAGE_HMAC:       func() string { return KEY_ALGORITHM_HS256 },
+		KEY_USAGE_SIGNATURE:  func() string { return KEYSTORE_SIGNING_ALGORITHM },
+		KEY_USAGE_ENCRYPTION: func() string { return KEY_ALGORITHM_A256GCM },
+	}
+)
+
+func SetupKeystore(stop context.Context, await *sync.WaitGroup) {
+	t := time.Now()
+
+	switch KEYSTORE_PROVIDER {
+	case "database":
+		Keystore = &keystoreProviderDatabase{}
+	case "disk":
+		Keystore = &keystoreProviderDisk{}
+	case "test":
+		if !testing.Testing() {
+			LoggerKeystore.Fatal("Attempt to use testing provider outside of testing", nil)
+		}
+		Keystore = &keystoreProviderMemory{}
+	default:
+		LoggerKeystore.Fatal("Unknown Provider", KEYSTORE_PROVIDER)
+	}
+
+	if err := Keystore.Start(stop, await); err != nil {
+		LoggerKeystore.Fatal("Startup Failed", err.Error())
+	}
+	ctx, cancel := NewContext()
+	defer cancel()
+	if err := KeystoreRotate(ctx, time.Now()); err != nil {
+		LoggerKeystore.Fatal("Rotation Failed", err.Error())
+	}
+
+	// Periodically reload keys created by other instances and rotate if required
+	await.Add(1)
+	go func() {
+		defer await.Done()
+		ticker := time.NewTicker(KEYSTORE_REFRESH_INTERVAL)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop.Done():
+				LoggerKeystore.Info("Closed", nil)
+				return
+			case <-ticker.C:
+				ctx, cancel := NewContext()
+				if err := KeystoreRotate(ctx, time.Now()); err != nil {
+					LoggerKeystore.Error("Rotation Failed", err.Error())
+				}
+				cancel()
+			}
+		}
+	}()
+
+	LoggerKeystore.Info("Ready", map[string]any{
+		"time": time.Since(t).String(),
+		"keys": len(KeystoreKeys()),
+	})
+}
+
+// Reload Keys from Provider, then generate and retire keys as needed
+func KeystoreRotate(ctx context.Context, now time.Time) error {
+	keys, err := Keystore.Load(ctx)
+	if err != nil {
+		return err
+	}
+	var save []KeystoreKey
+	var remove []string
+
+	for usage, algorithm := range keyringUsages {
+
+		// Find Current and Pending Keys
+		var current, pending *KeystoreKey
+		for i := range keys {
+			k := &keys[i]
+			if k.Usage != usage || !k.Valid(now) {
+				continue
+			}
+			if k.Activates.After(now) {
+				pending = k
+			} else if current == nil || k.Activates.After(current.Activates) {
+				current = k
+			}
+		}
+		if pending != nil {
+			continue
+		}
+
+		// Generate Key ahead of time so it's published before it's used
+		var activates time.Time
+		switch {
+		case current == nil:
+			activates = now
+		case now.After(current.Activates.Add(KEYSTORE_ROTATION_INTERVAL - KEYSTORE_PUBLISH_DELAY)):
+			activates = current.Activates.Add(KEYSTORE_ROTATION_INTERVAL)
+			if activates.Before(now) {
+				activates = now
+			}
+			// Retired keys still verify tokens issued before rotation, encryption
+			// keys are kept until the fields they protect are re-encrypted
+			if usage != KEY_USAGE_ENCRYPTION {
+				expires := activates.Add(KEYSTORE_ROTATION_OVERLAP)
+				current.Expires = &expires
+				save = append(save, *current)
+			}
+		default:
+			continue
+		}
+		key, err := KeystoreGenerate(usage, algorithm(), activates)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		save = append(save, key)
+		LoggerKeystore.Info("Key Generated", map[string]any{
+			"kid":       key.ID,
+			"usage":     key.Usage,
+			"activates": key.Activates,
+		})
+	}
+
+	// Purge Expired Keys
+	for _, k := range keys {
+		if !k.Valid(now) {
+			remove = append(remove, k.ID)
+		}
+	}
+	keys = slices.DeleteFunc(keys, func(k KeystoreKey) bool {
+		return !k.Valid(now)
+	})
+
+	// Persist Changes
+	if len(save) > 0 {
+		if err := Keystore.Save(ctx, save...); err != nil {
+			return err
+		}
+	}
+	if len(remove) > 0 {
+		if err := Keystore.Delete(ctx, remove...); err != nil {
+			return err
+		}
+	}
+
+	keyringMutex.Lock()
+	keyringKeys = keys
+	keyringMutex.Unlock()
+	return nil
+}
+
+// Generate a new Key for the given Usage
+func KeystoreGenerate(usage, algorithm string, activates time.Time) (KeystoreKey, error) {
+	key := KeystoreKey{
+		Usage:     usage,
+		Algorithm: algorithm,
+		Created:   time.Now(),
+		Activates: activates,
+	}
+	switch algorithm {
+	case KEY_ALGORITHM_HS256, KEY_ALGORITHM_A256GCM:
+		key.Material = make([]byte, 32)
+		rand.Read(key.Material)
+	case JWT_ALGORITHM_ES256, JWT_ALGORITHM_RS256:
+		var private crypto.Signer
+		var err error
+		if algorithm == JWT_ALGORITHM_ES256 {
+			private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		} else {
+			private, err = rsa.GenerateKey(rand.Reader, 2048)
+		}
+		if err != nil {
+			return KeystoreKey{}, err
+		}
+		if key.Material, err = x509.MarshalPKCS8PrivateKey(private); err != nil {
+			return KeystoreKey{}, err
+		}
+	default:
+		return KeystoreKey{}, ErrKeyAlgorithm
+	}
+	if err := key.parse(); err != nil {
+		return KeystoreKey{}, err
+	}
+	return key, nil
+}
+
+// Decode Key Material and assign an ID if missing
+func (k *KeystoreKey) parse() error {
+	if _, ok := keyringUsages[k.Usage]; !ok {
+		return ErrKeyUsage
+	}
+	switch k.Algorithm {
+	case KEY_ALGORITHM_HS256, KEY_ALGORITHM_A256GCM:
+		if len(k.Material) < 32 || (k.Algorithm == KEY_ALGORITHM_A256GCM && len(k.Material) != 32) {
+			return ErrKeyAlgorithm
+		}
+		if k.ID == "" {
+			id := make([]byte, 6)
+			rand.Read(id)
+			k.ID = base64.RawURLEncoding.EncodeToString(id)
+		}
+	case JWT_ALGORITHM_ES256, JWT_ALGORITHM_RS256:
+		private, err := x509.ParsePKCS8PrivateKey(k.Material)
+		if err != nil {
+			return err
+		}
+		signer, ok := private.(crypto.Signer)
+		if !ok {
+			return ErrKeyAlgorithm
+		}
+		if alg, err := JWTAlgorithm(signer.Public()); err != nil || alg != k.Algorithm {
+			return ErrKeyAlgorithm
+		}
+		k.signer = signer
+		if k.ID == "" {
+			if k.ID, err = GenerateJWKThumbprint(signer.Public()); err != nil {
+				return err
+			}
+		}
+	default:
+		return ErrKeyAlgorithm
+	}
+	return nil
+}
+
+// Copy of all Keys currently held in Memory
+func KeystoreKeys() []KeystoreKey {
+	keyringMutex.RLock()
+	defer keyringMutex.RUnlock()
+	return slices.Clone(keyringKeys)
+}
+
+// Retrieve the Key that should be used for Signing, Keys are created by
+// SetupKeystore so this only fails if it was called before startup
+func KeystoreCurrent(usage string) (KeystoreKey, error) {
+	keyringMutex.RLock()
+	defer keyringMutex.RUnlock()
+	current, ok := keyringCurrent(usage, time.Now())
+	if !ok {
+		return KeystoreKey{}, ErrKeyMissing
+	}
+	return current, nil
+}
+
+// Find newest Active Key for Usage, expects keyringMutex to be held
+func keyringCurrent(usage string, now time.Time) (KeystoreKey, bool) {
+	var current *KeystoreKey
+	for i := range keyringKeys {
+		k := &keyringKeys[i]
+		if k.Usage == usage && k.Valid(now) && !k.Activates.After(now) &&
+			(current == nil || k.Activates.After(current.Activates)) {
+			current = k
+		}
+	}
+	if current == nil {
+		return KeystoreKey{}, false
+	}
+	return *current, true
+}
+
+// Retrieve a Valid Key by its ID
+func KeystoreLookup(usage, id string) (KeystoreKey, bool) {
+	now := time.Now()
+	keyringMutex.RLock()
+	defer keyringMutex.RUnlock()
+	for _, k := range keyringKeys {
+		if k.ID == id && k.Usage == usage && k.Valid(now) {
+			return k, true
+		}
+	}
+	return KeystoreKey{}, false
+}
+
+// Retrieve all Valid Keys for a given Usage, including those not yet active
+func KeystorePublished(usage string) []KeystoreKey {
+	now := time.Now()
+	keyringMutex.RLock()
+	defer keyringMutex.RUnlock()
+	keys := make([]KeystoreKey, 0, 2)
+	for _, k := range keyringKeys {
+		if k.Usage == usage && k.Valid(now) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
@@ -17,6 +17,17 @@ var (
 	LoggerDatabase    = NewLoggerInstance("database")
 	LoggerEmail       = NewLoggerInstance("email")
 	LoggerLogger      = NewLoggerInstance("logger")
+	LoggerKeystore    = NewLoggerInstance("keystore")
+	LoggerFederation  = NewLoggerInstance("federation")
+	LoggerSAML        = NewLoggerInstance("saml")
+	LoggerPassword    = NewLoggerInstance("password")
+	LoggerBreaches    = NewLoggerInstance("breaches")
+	LoggerRisk        = NewLoggerInstance("risk")
+	LoggerSessions    = NewLoggerInstance("sessions")
+	LoggerTokens      = NewLoggerInstance("tokens")
+	LoggerFields      = NewLoggerInstance("fields")
+	LoggerOutbox      = NewLoggerInstance("outbox")
+	LoggerSCIM        = NewLoggerInstance("scim")
 )
 
 type LoggerProvider interface {
@@ -0,0 +1,266 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Email Outbox
+//
+// Emails are written to the Outbox instead of being sent right away, so they
+// survive Provider outages and Restarts. Workers claim Due Emails with a Lease,
+// an Instance that dies mid-send only delays them until the Lease runs out.
+// Failed Attempts are retried with Exponential Backoff until the Attempt Limit
+// is reached, after which the Email is Dead-Lettered for inspection.
+//
+// Addresses and Bodies (which contain Tokens) are stored Encrypted.
+
+type OutboxEmail struct {
+	Key      string // Idempotency Key, Duplicate Enqueues are ignored
+	Template string
+	Address  string
+	Subject  string
+	Body     string // HTML Body
+	Text     string // Plain-Text Body
+	Headers  map[string]string
+}
+
+var (
+	outboxWake  = make(chan struct{}, 1)
+	outboxAwait *sync.WaitGroup
+)
+
+func SetupOutbox(stop context.Context, await *sync.WaitGroup) {
+	t := time.Now()
+	outboxAwait = await
+
+	for range EMAIL_OUTBOX_WORKERS {
+		await.Add(1)
+		go func() {
+			defer await.Done()
+			ticker := time.NewTicker(EMAIL_OUTBOX_POLL_INTERVAL)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-stop.Done():
+					// Send whatever is Due before Shutdown, anything left is
+					// picked up by the next Instance to start
+					ctx, cancel := context.WithTimeout(context.Background(), EMAIL_OUTBOX_DRAIN_TIMEOUT)
+					drainOutbox(ctx)
+					cancel()
+					return
+				case <-ticker.C:
+				case <-outboxWake:
+				}
+				drainOutbox(context.Background())
+			}
+		}()
+	}
+
+	LoggerOutbox.Info("Ready", map[string]any{
+		"time":    time.Since(t).String(),
+		"workers": EMAIL_OUTBOX_WORKERS,
+	})
+}
+
+// Run Work that ends in an Email in the Background, Shutdown waits for it so
+// the Email is at least Enqueued
+func EmailAsync(fn func()) {
+	if outboxAwait == nil {
+		go fn()
+		return
+	}
+	outboxAwait.Add(1)
+	go func() {
+		defer outboxAwait.Done()
+		fn()
+	}()
+}
+
+// Generate an Idempotency Key from the Contents of an Email
+func OutboxKey(template, address, subject, body string) string {
+	h := sha256.New()
+	for _, s := range []string{template, address, subject, body} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return template + ":" + hex.EncodeToString(h.Sum(nil))
+}
+
+// Write an Email to the Outbox, reports whether it wasn't already Enqueued
+func EnqueueEmail(ctx context.Context, email OutboxEmail) (bool, error) {
+	address, err := EncryptField(email.Address)
+	if err != nil {
+		return false, err
+	}
+	body, err := EncryptField(email.Body)
+	if err != nil {
+		return false, err
+	}
+	text, err := EncryptField(email.Text)
+	if err != nil {
+		return false, err
+	}
+	if email.Headers == nil {
+		email.Headers = map[string]string{}
+	}
+	tag, err := Database.Exec(ctx,
+		`INSERT INTO auth.email_outbox (
+			id, idempotency_key, template, address, subject, body, text, headers
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (idempotency_key) DO NOTHING`,
+		GenerateSnowflake(),
+		email.Key,
+		email.Template,
+		address,
+		email.Subject,
+		body,
+		text,
+		email.Headers,
+	)
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+	select {
+	case outboxWake <- struct{}{}:
+	default:
+	}
+	return true, nil
+}
+
+// Process Batches until no Due Emails remain
+func drainOutbox(ctx context.Context) {
+	for ctx.Err() == nil {
+		batchCtx, cancel := context.WithTimeout(ctx, EMAIL_OUTBOX_LEASE)
+		claimed, err := ProcessOutbox(batchCtx)
+		cancel()
+		if err != nil {
+			LoggerOutbox.Error("Processing Failed", err.Error())
+			return
+		}
+		if claimed < EMAIL_OUTBOX_BATCH {
+			return
+		}
+	}
+}
+
+// Claim and Send a Batch of Due Emails, returning the Emails claimed
+func ProcessOutbox(ctx context.Context) (int, error) {
+	rows, err := Database.Query(ctx,
+		`UPDATE auth.email_outbox SET
+			attempts      = attempts + 1,
+			attempt_after = NOW() + MAKE_INTERVAL(secs => $2)
+		WHERE id IN (
+			SELECT id FROM auth.email_outbox
+			WHERE sent IS NULL AND failed IS NULL AND attempt_after <= NOW()
+			ORDER BY attempt_after
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, template, address, subject, body, text, headers, attempts`,
+		EMAIL_OUTBOX_BATCH,
+		EMAIL_OUTBOX_LEASE.Seconds(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	type claimedEmail struct {
+		ID       int64
+		Template string
+		Address  string
+		Subject  string
+		Body     string
+		Text     string
+		Headers  map[string]string
+		Attempts int
+	}
+	var claimed []claimedEmail
+	for rows.Next() {
+		var e claimedEmail
+		if err := rows.Scan(&e.ID, &e.Template, &e.Address, &e.Subject, &e.Body, &e.Text, &e.Headers, &e.Attempts); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		claimed = append(claimed, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, e := range claimed {
+		dat := map[string]any{
+			"id":       e.ID,
+			"template": e.Template,
+			"attempts": e.Attempts,
+		}
+
+		// Undecryptable Emails will never be sent, so they're Dead-Lettered right away
+		attempts := e.Attempts
+		message := EmailMessage{
+			Subject: e.Subject,
+			Headers: e.Headers,
+			Tags:    map[string]string{"template": e.Template},
+		}
+		message.To, err = DecryptField(e.Address)
+		if err == nil {
+			message.HTML, err = DecryptField(e.Body)
+		}
+		if err == nil {
+			message.Text, err = DecryptField(e.Text)
+		}
+		if err == nil {
+			err = Email.Send(message)
+		} else {
+			attempts = EMAIL_OUTBOX_ATTEMPT_LIMIT
+		}
+
+		switch {
+		case err == nil:
+			_, err = Database.Exec(ctx,
+				"UPDATE auth.email_outbox SET sent = NOW(), last_error = NULL WHERE id = $1",
+				e.ID,
+			)
+			LoggerEmail.Info("Email Sent", dat)
+		case attempts >= EMAIL_OUTBOX_ATTEMPT_LIMIT:
+			dat["error"] = err.Error()
+			_, err = Database.Exec(ctx,
+				"UPDATE auth.email_outbox SET failed = NOW(), last_error = $2 WHERE id = $1",
+				e.ID,
+				err.Error(),
+			)
+			LoggerEmail.Error("Email Dead-Lettered", dat)
+		default:
+			dat["error"] = err.Error()
+			_, err = Database.Exec(ctx,
+				`UPDATE auth.email_outbox SET
+					attempt_after = NOW() + MAKE_INTERVAL(secs => $2),
+					last_error    = $3
+				WHERE id = $1`,
+				e.ID,
+				OutboxBackoff(e.Attempts).Seconds(),
+				err.Error(),
+			)
+			LoggerEmail.Warn("Email Failed", dat)
+		}
+		if err != nil {
+			return len(claimed), err
+		}
+	}
+	return len(claimed), nil
+}
+
+// Delay before retrying an Email that failed the given number of Attempts
+func OutboxBackoff(attempts int) time.Duration {
+	delay := EMAIL_OUTBOX_RETRY_DELAY
+	for i := 1; i < attempts && delay < EMAIL_OUTBOX_RETRY_LIMIT; i++ {
+		delay *= 2
+	}
+	return min(delay, EMAIL_OUTBOX_RETRY_LIMIT)
+}
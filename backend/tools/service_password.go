@@ -0,0 +1,85 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrPasswordHashInvalid    = errors.New("password hash invalid")
+	ErrPasswordHashAlgorithm  = errors.New("password hash algorithm unknown")
+	ErrPasswordHashParameters = errors.New("password hash parameters out of range")
+)
+
+// Hashes are stored in the PHC String Format ($id$params$salt$hash) so any
+// Algorithm can verify a Password no matter which one is currently preferred
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Compare(hash, password string) (bool, error)
+	Outdated(hash string) bool // Parameters differ from the Configured ones
+}
+
+// Supported Algorithms by PHC Identifier
+var PasswordHashers = map[string]PasswordHasher{
+	"argon2id": &passwordHasherArgon2{},
+	"bcrypt":   &passwordHasherBcrypt{},
+}
+
+func SetupPassword(stop context.Context, await *sync.WaitGroup) {
+	t := time.Now()
+
+	// Generate a Hash to ensure the Parameters are usable
+	if _, ok := PasswordHashers[PASSWORD_HASH_ALGORITHM]; !ok {
+		LoggerPassword.Fatal("Unknown Algorithm", PASSWORD_HASH_ALGORITHM)
+	}
+	if _, err := GeneratePasswordHash("Teto"); err != nil {
+		LoggerPassword.Fatal("Invalid Parameters", err.Error())
+	}
+
+	LoggerPassword.Info("Ready", map[string]any{
+		"algorithm": PASSWORD_HASH_ALGORITHM,
+		"time":      time.Since(t).String(),
+	})
+}
+
+// Identify Algorithm of a stored Hash, Modular Crypt Format bcrypt
+// hashes from before PHC strings were used are treated as bcrypt
+func passwordHashAlgorithm(hash string) string {
+	id, _, _ := strings.Cut(strings.TrimPrefix(hash, "$"), "$")
+	switch id {
+	case "2a", "2b", "2y":
+		return "bcrypt"
+	}
+	return id
+}
+
+// Hash Password using the Configured Algorithm
+func GeneratePasswordHash(givenPassword string) (string, error) {
+	hasher, ok := PasswordHashers[PASSWORD_HASH_ALGORITHM]
+	if !ok {
+		return "", ErrPasswordHashAlgorithm
+	}
+	return hasher.Hash(givenPassword)
+}
+
+// Compare Password against a Hash generated by any Supported Algorithm
+func ComparePasswordHash(givenHash, givenPassword string) (bool, error) {
+	hasher, ok := PasswordHashers[passwordHashAlgorithm(givenHash)]
+	if !ok {
+		return false, ErrPasswordHashAlgorithm
+	}
+	return hasher.Compare(givenHash, givenPassword)
+}
+
+// Hash should be regenerated as it uses another Algorithm or outdated Parameters
+func PasswordHashOutdated(givenHash string) bool {
+	algorithm := passwordHashAlgorithm(givenHash)
+	if algorithm != PASSWORD_HASH_ALGORITHM {
+		return true
+	}
+	hasher, ok := PasswordHashers[algorithm]
+	return !ok || hasher.Outdated(givenHash)
+}
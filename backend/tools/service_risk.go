@@ -0,0 +1,364 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Login Risk Assessment
+//
+// Logins are scored from several Signals comparing the Attempt against the
+// Logins the Owner completed or approved before, the total Score decides how
+// much proof of ownership is required.
+//
+// Networks are read from an optional list, one entry per line:
+//
+//	<cidr> <asn> [tor|vpn|proxy]
+//
+// Entries may overlap, the most specific Network wins.
+type RiskDecision int
+
+const (
+	RISK_ALLOW   RiskDecision = iota // Login is allowed
+	RISK_APPROVE                     // Owner must approve the Login via Email
+	RISK_MFA                         // Owner must complete MFA, even on Trusted Devices
+	RISK_DENY                        // Login is refused
+)
+
+const (
+	RISK_SIGNAL_NEW_DEVICE        = "new_device"
+	RISK_SIGNAL_NEW_AGENT         = "new_agent"
+	RISK_SIGNAL_NEW_NETWORK       = "new_network"
+	RISK_SIGNAL_NEW_COUNTRY       = "new_country"
+	RISK_SIGNAL_IMPOSSIBLE_TRAVEL = "impossible_travel"
+	RISK_SIGNAL_FAILURE_VELOCITY  = "failure_velocity"
+	RISK_SIGNAL_ANONYMOUS_NETWORK = "anonymous_network"
+)
+
+type RiskAssessment struct {
+	Score    int
+	Signals  []string
+	Decision RiskDecision
+}
+
+type RiskNetwork struct {
+	ASN       string
+	Anonymous bool
+}
+
+type RiskNetworkTable struct {
+	Networks map[netip.Prefix]RiskNetwork
+	Lengths  []int // Prefix Lengths in use, longest first
+}
+
+var (
+	ErrRiskNetworkEntry = errors.New("risk network entry malformed")
+	RiskNetworks        *RiskNetworkTable
+)
+
+func SetupRisk(stop context.Context, await *sync.WaitGroup) {
+	t := time.Now()
+
+	// Network Signals are Optional as the List is maintained by the Operator
+	if RISK_NETWORKS_FILE == "" {
+		LoggerRisk.Info("Ready", map[string]any{
+			"networks": false,
+		})
+		return
+	}
+	f, err := os.Open(RISK_NETWORKS_FILE)
+	if err != nil {
+		LoggerRisk.Fatal("Failed to Read Networks", err.Error())
+	}
+	defer f.Close()
+	table, err := ParseRiskNetworks(f)
+	if err != nil {
+		LoggerRisk.Fatal("Failed to Parse Networks", err.Error())
+	}
+	RiskNetworks = table
+
+	LoggerRisk.Info("Ready", map[string]any{
+		"networks": true,
+		"time":     time.Since(t).String(),
+		"entries":  len(table.Networks),
+	})
+}
+
+func (d RiskDecision) String() string {
+	switch d {
+	case RISK_APPROVE:
+		return "approve"
+	case RISK_MFA:
+		return "mfa"
+	case RISK_DENY:
+		return "deny"
+	default:
+		return "allow"
+	}
+}
+
+// Create an empty Network Table
+func NewRiskNetworkTable() *RiskNetworkTable {
+	return &RiskNetworkTable{
+		Networks: map[netip.Prefix]RiskNetwork{},
+	}
+}
+
+func ParseRiskNetworks(r io.Reader) (*RiskNetworkTable, error) {
+	table := NewRiskNetworkTable()
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, ErrRiskNetworkEntry
+		}
+		prefix, err := netip.ParsePrefix(fields[0])
+		if err != nil {
+			return nil, ErrRiskNetworkEntry
+		}
+		network := RiskNetwork{ASN: fields[1]}
+		if len(fields) == 3 {
+			switch fields[2] {
+			case "tor", "vpn", "proxy":
+				network.Anonymous = true
+			default:
+				return nil, ErrRiskNetworkEntry
+			}
+		}
+		table.Insert(prefix, network)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// Add Network to the Table, replacing an existing entry for the same Prefix
+func (t *RiskNetworkTable) Insert(prefix netip.Prefix, network RiskNetwork) {
+	prefix = prefix.Masked()
+	t.Networks[prefix] = network
+	if !slices.Contains(t.Lengths, prefix.Bits()) {
+		t.Lengths = append(t.Lengths, prefix.Bits())
+		slices.Sort(t.Lengths)
+		slices.Reverse(t.Lengths)
+	}
+}
+
+// Find the most specific Network containing the IP Address
+func (t *RiskNetworkTable) Lookup(address string) (RiskNetwork, bool) {
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return RiskNetwork{}, false
+	}
+	addr = addr.Unmap()
+	for _, bits := range t.Lengths {
+		prefix, err := addr.Prefix(bits)
+		if err != nil {
+			continue
+		}
+		if network, ok := t.Networks[prefix]; ok {
+			return network, true
+		}
+	}
+	return RiskNetwork{}, false
+}
+
+// Find the Country and Timezone Offset (in Seconds) of an IP Address
+func riskLocation(address string) (string, int32, bool) {
+	var country string
+	var offset int32
+	if ok, result := GeolocateIPV4(address); ok {
+		country, offset = GeolocateString(result.CountryIndex), result.TimezoneOffset
+	} else if ok, result := GeolocateIPV6(address); ok {
+		country, offset = GeolocateString(result.CountryIndex), result.TimezoneOffset
+	}
+	if country == "" || country == "-" {
+		return "", 0, false
+	}
+	return country, offset, true
+}
+
+// Find the Autonomous System of an IP Address, empty if Networks are unavailable
+func riskNetwork(address string) RiskNetwork {
+	if RiskNetworks == nil {
+		return RiskNetwork{}
+	}
+	network, _ := RiskNetworks.Lookup(address)
+	return network
+}
+
+// Score a Login Attempt for an Account whose Password was already verified
+func AssessLogin(ctx context.Context, userID int64, address, agent string, deviceKnown bool) (RiskAssessment, error) {
+
+	var assessment RiskAssessment
+	signal := func(name string, weight int) {
+		assessment.Signals = append(assessment.Signals, name)
+		assessment.Score += weight
+	}
+	currentCountry, currentOffset, currentLocated := riskLocation(address)
+	currentNetwork := riskNetwork(address)
+	currentBrowser := LookupBrowser(agent)
+
+	// Signal: Device
+	if !deviceKnown {
+		signal(RISK_SIGNAL_NEW_DEVICE, RISK_WEIGHT_NEW_DEVICE)
+	}
+
+	// Signal: Anonymous Network
+	if currentNetwork.Anonymous {
+		signal(RISK_SIGNAL_ANONYMOUS_NETWORK, RISK_WEIGHT_ANONYMOUS_NETWORK)
+	}
+
+	// Signal: Failure Velocity
+	var failures int
+	if err := Database.QueryRow(ctx,
+		`SELECT COALESCE(SUM(failures), 0) FROM auth.login_failures
+		WHERE user_id = $1 AND updated > CURRENT_TIMESTAMP - make_interval(secs => $2)`,
+		userID,
+		LOCKOUT_WINDOW.Seconds(),
+	).Scan(&failures); err != nil {
+		return assessment, err
+	}
+	if failures >= RISK_FAILURE_VELOCITY {
+		signal(RISK_SIGNAL_FAILURE_VELOCITY, RISK_WEIGHT_FAILURE_VELOCITY)
+	}
+
+	// Compare against Logins the Owner completed or approved, most recent first
+	rows, err := Database.Query(ctx,
+		`SELECT device_ip_address, device_user_agent, created FROM auth.sessions WHERE user_id = $1
+		UNION ALL
+		SELECT ip_address, user_agent, created FROM auth.login_assessments WHERE user_id = $1 AND approved = TRUE
+		ORDER BY created DESC
+		LIMIT $2`,
+		userID,
+		RISK_HISTORY_LIMIT,
+	)
+	if err != nil {
+		return assessment, err
+	}
+	defer rows.Close()
+
+	var (
+		history       = 0
+		seenCountry   = false
+		seenNetwork   = false
+		seenBrowser   = false
+		travelChecked = false
+	)
+	for rows.Next() {
+		var pastAddress, pastAgent string
+		var pastCreated time.Time
+		if err := rows.Scan(Decrypted(&pastAddress), Decrypted(&pastAgent), &pastCreated); err != nil {
+			return assessment, err
+		}
+		history++
+		pastCountry, pastOffset, pastLocated := riskLocation(pastAddress)
+
+		// Signal: Impossible Travel
+		// The Dataset has no Coordinates, so Distance is estimated from the
+		// Timezone difference between the previous and current Login
+		if !travelChecked && pastLocated && currentLocated {
+			travelChecked = true
+			hours := math.Max(time.Since(pastCreated).Hours(), 1.0/60)
+			zones := math.Abs(float64(currentOffset-pastOffset)) / 3600
+			if pastCountry != currentCountry && zones*RISK_TRAVEL_ZONE_DISTANCE/hours > RISK_TRAVEL_SPEED {
+				signal(RISK_SIGNAL_IMPOSSIBLE_TRAVEL, RISK_WEIGHT_IMPOSSIBLE_TRAVEL)
+			}
+		}
+
+		if pastLocated && pastCountry == currentCountry {
+			seenCountry = true
+		}
+		if currentNetwork.ASN != "" && riskNetwork(pastAddress).ASN == currentNetwork.ASN {
+			seenNetwork = true
+		}
+		if LookupBrowser(pastAgent) == currentBrowser {
+			seenBrowser = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return assessment, err
+	}
+
+	// Signals: Novelty
+	// Accounts without History have nothing to compare against
+	if history > 0 {
+		if currentLocated && !seenCountry {
+			signal(RISK_SIGNAL_NEW_COUNTRY, RISK_WEIGHT_NEW_COUNTRY)
+		}
+		if currentNetwork.ASN != "" && !seenNetwork {
+			signal(RISK_SIGNAL_NEW_NETWORK, RISK_WEIGHT_NEW_NETWORK)
+		}
+		if !seenBrowser {
+			signal(RISK_SIGNAL_NEW_AGENT, RISK_WEIGHT_NEW_AGENT)
+		}
+	}
+
+	// Decide
+	switch {
+	case assessment.Score >= RISK_THRESHOLD_DENY:
+		assessment.Decision = RISK_DENY
+	case assessment.Score >= RISK_THRESHOLD_MFA:
+		assessment.Decision = RISK_MFA
+	case assessment.Score >= RISK_THRESHOLD_APPROVE:
+		assessment.Decision = RISK_APPROVE
+	default:
+		assessment.Decision = RISK_ALLOW
+	}
+	return assessment, nil
+}
+
+// Record Assessment for Audit, Approvals sent by Email are granted to the Device
+func RecordAssessment(ctx context.Context, userID, deviceID int64, address, agent string, assessment RiskAssessment) error {
+	var device *int64
+	if deviceID != 0 {
+		device = &deviceID
+	}
+	signals := assessment.Signals
+	if signals == nil {
+		signals = []string{}
+	}
+	address, err := EncryptField(address)
+	if err != nil {
+		return err
+	}
+	agent, err = EncryptField(agent)
+	if err != nil {
+		return err
+	}
+	_, err = Database.Exec(ctx,
+		`INSERT INTO auth.login_assessments (
+			id, user_id, device_id, ip_address, user_agent, score, signals, decision
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		GenerateSnowflake(),
+		userID,
+		device,
+		address,
+		agent,
+		assessment.Score,
+		signals,
+		assessment.Decision.String(),
+	)
+	if err == nil && assessment.Decision != RISK_ALLOW {
+		LoggerRisk.Info("Risky Login", map[string]any{
+			"user_id":  userID,
+			"score":    assessment.Score,
+			"signals":  signals,
+			"decision": assessment.Decision.String(),
+		})
+	}
+	return err
+}
@@ -0,0 +1,560 @@
+package tools
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SAML_NS_METADATA        = "urn:oasis:names:tc:SAML:2.0:metadata"
+	SAML_NS_PROTOCOL        = "urn:oasis:names:tc:SAML:2.0:protocol"
+	SAML_NS_ASSERTION       = "urn:oasis:names:tc:SAML:2.0:assertion"
+	SAML_BINDING_REDIRECT   = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	SAML_BINDING_POST       = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+	SAML_STATUS_SUCCESS     = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	SAML_CONFIRMATION       = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+	SAML_NAMEID_EMAIL       = "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress"
+	SAML_NAMEID_UNSPECIFIED = "urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified"
+)
+
+var (
+	ErrSAMLMetadata    = errors.New("saml metadata invalid")
+	ErrSAMLResponse    = errors.New("saml response invalid")
+	ErrSAMLStatus      = errors.New("saml response status unsuccessful")
+	ErrSAMLEncrypted   = errors.New("saml encrypted assertions are unsupported")
+	ErrSAMLExpired     = errors.New("saml assertion expired")
+	ErrSAMLAudience    = errors.New("saml assertion intended for another audience")
+	ErrSAMLDestination = errors.New("saml response intended for another destination")
+	ErrSAMLKey         = errors.New("saml service provider key unsupported")
+)
+
+// Identity Provider federated with using SAML 2.0, its Endpoints and Signing
+// Certificates are imported from its Metadata which is reloaded periodically
+type SAMLConnection struct {
+	Name                 string
+	MetadataURL          string
+	MetadataFile         string
+	IDPInitiated         bool     // Accept Responses without a matching AuthnRequest
+	TrustEmail           bool     // Treat Email Addresses within EmailDomains as Verified
+	EmailDomains         []string // Email Domains the Identity Provider is authoritative for
+	AttributeEmail       []string // Attribute Names mapped to the Email Address
+	AttributeUsername    []string // Attribute Names mapped to the Username
+	AttributeDisplayname []string // Attribute Names mapped to the Displayname
+	mutex                sync.Mutex
+	loaded               time.Time
+	entityID             string
+	singleSignOnURL      string
+	certificates         []*x509.Certificate
+}
+
+// Assertion Contents once its Signature and Conditions are Verified
+type SAMLAssertion struct {
+	ID           string
+	InResponseTo string
+	Expires      time.Time
+	NameID       string
+	NameIDFormat string
+	Attributes   map[string][]string
+}
+
+var (
+	SAML            = map[string]*SAMLConnection{}
+	SAMLKey         crypto.Signer
+	SAMLCertificate *x509.Certificate
+)
+
+func SetupSAML(stop context.Context, await *sync.WaitGroup) {
+	t := time.Now()
+
+	for _, name := range SAML_CONNECTIONS {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "SAML_" + strings.ToUpper(name) + "_"
+		connection := &SAMLConnection{
+			Name:                 name,
+			MetadataURL:          EnvString(prefix+"METADATA_URL", ""),
+			MetadataFile:         EnvString(prefix+"METADATA_FILE", ""),
+			IDPInitiated:         EnvString(prefix+"IDP_INITIATED", "false") == "true",
+			TrustEmail:           EnvString(prefix+"TRUST_EMAIL", "false") == "true",
+			EmailDomains:         EnvSlice(prefix+"EMAIL_DOMAINS", ",", []string{}),
+			AttributeEmail:       EnvSlice(prefix+"ATTRIBUTE_EMAIL", ",", SAML_ATTRIBUTE_EMAIL),
+			AttributeUsername:    EnvSlice(prefix+"ATTRIBUTE_USERNAME", ",", SAML_ATTRIBUTE_USERNAME),
+			AttributeDisplayname: EnvSlice(prefix+"ATTRIBUTE_DISPLAYNAME", ",", SAML_ATTRIBUTE_DISPLAYNAME),
+		}
+		if connection.MetadataURL == "" && connection.MetadataFile == "" {
+			LoggerSAML.Fatal("Incomplete Connection Configuration", name)
+		}
+		if connection.TrustEmail && len(connection.EmailDomains) == 0 {
+			LoggerSAML.Fatal("Trusted Email requires Email Domains", name)
+		}
+		for i, domain := range connection.EmailDomains {
+			connection.EmailDomains[i] = strings.ToLower(strings.TrimSpace(domain))
+		}
+		SAML[name] = connection
+	}
+
+	// AuthnRequests are Signed using the Service Provider Key
+	if len(SAML) > 0 {
+		pair, err := tls.LoadX509KeyPair(SAML_SP_CERT, SAML_SP_KEY)
+		if err != nil {
+			LoggerSAML.Fatal("Failed to load Service Provider Key", err.Error())
+			return
+		}
+		SAMLKey = pair.PrivateKey.(crypto.Signer)
+		SAMLCertificate = pair.Leaf
+		if _, err := samlSignatureAlgorithm(SAMLKey.Public()); err != nil {
+			LoggerSAML.Fatal("Failed to load Service Provider Key", err.Error())
+			return
+		}
+	}
+
+	LoggerSAML.Info("Ready", map[string]any{
+		"time":        time.Since(t).String(),
+		"connections": len(SAML),
+	})
+}
+
+// Entity ID of this Service Provider, unique per Connection
+func (c *SAMLConnection) ServiceEntityID() string {
+	return strings.TrimSuffix(OIDC_ISSUER, "/") + "/auth/saml/" + c.Name + "/metadata"
+}
+
+// Assertion Consumer Service the Identity Provider posts Responses to
+func (c *SAMLConnection) ConsumerURL() string {
+	return strings.TrimSuffix(OIDC_ISSUER, "/") + "/auth/saml/" + c.Name + "/acs"
+}
+
+// Generate Metadata describing this Service Provider for the Identity Provider
+func (c *SAMLConnection) Metadata() []byte {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	fmt.Fprintf(&b, `<md:EntityDescriptor xmlns:md="%s" entityID="%s">`, SAML_NS_METADATA, xmlEscapeAttr(c.ServiceEntityID()))
+	fmt.Fprintf(&b, `<md:SPSSODescriptor AuthnRequestsSigned="true" WantAssertionsSigned="true" protocolSupportEnumeration="%s">`, SAML_NS_PROTOCOL)
+	if SAMLCertificate != nil {
+		fmt.Fprintf(&b, `<md:KeyDescriptor use="signing"><ds:KeyInfo xmlns:ds="%s"><ds:X509Data><ds:X509Certificate>%s</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>`,
+			XML_NS_DSIG, base64.StdEncoding.EncodeToString(SAMLCertificate.Raw))
+	}
+	fmt.Fprintf(&b, `<md:NameIDFormat>%s</md:NameIDFormat>`, SAML_NAMEID_UNSPECIFIED)
+	fmt.Fprintf(&b, `<md:AssertionConsumerService Binding="%s" Location="%s" index="0" isDefault="true"/>`, SAML_BINDING_POST, xmlEscapeAttr(c.ConsumerURL()))
+	b.WriteString(`</md:SPSSODescriptor></md:EntityDescriptor>`)
+	return b.Bytes()
+}
+
+// Generate Signed AuthnRequest using the HTTP-Redirect Binding, the Identity
+// Provider must answer with the given Request ID
+func (c *SAMLConnection) AuthnRequestURL(ctx context.Context, requestID string) (string, error) {
+	if err := c.load(ctx); err != nil {
+		return "", err
+	}
+
+	// Generate Request
+	request := fmt.Sprintf(
+		`<samlp:AuthnRequest xmlns:samlp="%s" xmlns:saml="%s" ID="%s" Version="2.0" IssueInstant="%s" Destination="%s" AssertionConsumerServiceURL="%s" ProtocolBinding="%s">`+
+			`<saml:Issuer>%s</saml:Issuer><samlp:NameIDPolicy Format="%s" AllowCreate="true"/></samlp:AuthnRequest>`,
+		SAML_NS_PROTOCOL,
+		SAML_NS_ASSERTION,
+		xmlEscapeAttr(requestID),
+		time.Now().UTC().Format(time.RFC3339),
+		xmlEscapeAttr(c.singleSignOnURL),
+		xmlEscapeAttr(c.ConsumerURL()),
+		SAML_BINDING_POST,
+		xmlEscapeText(c.ServiceEntityID()),
+		SAML_NAMEID_UNSPECIFIED,
+	)
+	var compressed bytes.Buffer
+	w, _ := flate.NewWriter(&compressed, flate.DefaultCompression)
+	w.Write([]byte(request))
+	w.Close()
+
+	// Sign Query String (SAML Bindings 3.4.4.1)
+	algorithm, err := samlSignatureAlgorithm(SAMLKey.Public())
+	if err != nil {
+		return "", err
+	}
+	query := "SAMLRequest=" + url.QueryEscape(base64.StdEncoding.EncodeToString(compressed.Bytes())) +
+		"&SigAlg=" + url.QueryEscape(algorithm)
+	digest := sha256.Sum256([]byte(query))
+	signature, err := SAMLKey.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+	if algorithm == XML_ALG_ECDSA_SHA256 {
+		// XML Signatures use the raw R || S form rather than ASN.1
+		var sig struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(signature, &sig); err != nil {
+			return "", err
+		}
+		signature = make([]byte, 64)
+		sig.R.FillBytes(signature[:32])
+		sig.S.FillBytes(signature[32:])
+	}
+	query += "&Signature=" + url.QueryEscape(base64.StdEncoding.EncodeToString(signature))
+
+	if strings.Contains(c.singleSignOnURL, "?") {
+		return c.singleSignOnURL + "&" + query, nil
+	}
+	return c.singleSignOnURL + "?" + query, nil
+}
+
+// Verify Response posted by the Identity Provider and return its Assertion.
+// Either the Response or the Assertion must be Signed, only Signed Elements are read.
+func (c *SAMLConnection) ParseResponse(ctx context.Context, encoded string) (SAMLAssertion, error) {
+	if err := c.load(ctx); err != nil {
+		return SAMLAssertion{}, err
+	}
+	c.mutex.Lock()
+	entityID, certificates := c.entityID, c.certificates
+	c.mutex.Unlock()
+
+	// Decode Response
+	data, err := xmlDecodeBase64(encoded)
+	if err != nil {
+		return SAMLAssertion{}, ErrSAMLResponse
+	}
+	response, err := ParseXML(data)
+	if err != nil {
+		return SAMLAssertion{}, err
+	}
+	if response.Space != SAML_NS_PROTOCOL || response.Local != "Response" {
+		return SAMLAssertion{}, ErrSAMLResponse
+	}
+	if dst := response.Attr("Destination"); dst != "" && dst != c.ConsumerURL() {
+		return SAMLAssertion{}, ErrSAMLDestination
+	}
+	if issuer := response.Child(SAML_NS_ASSERTION, "Issuer"); issuer != nil && strings.TrimSpace(issuer.Text()) != entityID {
+		return SAMLAssertion{}, ErrSAMLResponse
+	}
+	status := response.Child(SAML_NS_PROTOCOL, "Status")
+	if status == nil {
+		return SAMLAssertion{}, ErrSAMLResponse
+	}
+	if code := status.Child(SAML_NS_PROTOCOL, "StatusCode"); code == nil || code.Attr("Value") != SAML_STATUS_SUCCESS {
+		return SAMLAssertion{}, ErrSAMLStatus
+	}
+	if len(response.ChildElements(SAML_NS_ASSERTION, "EncryptedAssertion")) > 0 {
+		return SAMLAssertion{}, ErrSAMLEncrypted
+	}
+
+	// Verify Signatures, an Assertion must be Signed unless the Response is
+	assertions := response.ChildElements(SAML_NS_ASSERTION, "Assertion")
+	if len(assertions) != 1 {
+		return SAMLAssertion{}, ErrSAMLResponse
+	}
+	assertion := assertions[0]
+	responseErr := XMLVerifySignature(response, certificates)
+	if responseErr != nil && !errors.Is(responseErr, ErrXMLSignatureMissing) {
+		return SAMLAssertion{}, responseErr
+	}
+	assertionErr := XMLVerifySignature(assertion, certificates)
+	if assertionErr != nil && (responseErr != nil || !errors.Is(assertionErr, ErrXMLSignatureMissing)) {
+		return SAMLAssertion{}, assertionErr
+	}
+
+	// Verify Assertion Issuer
+	now := time.Now()
+	if issuer := assertion.Child(SAML_NS_ASSERTION, "Issuer"); issuer == nil || strings.TrimSpace(issuer.Text()) != entityID {
+		return SAMLAssertion{}, ErrSAMLResponse
+	}
+	result := SAMLAssertion{
+		ID:           assertion.Attr("ID"),
+		InResponseTo: response.Attr("InResponseTo"),
+		Attributes:   map[string][]string{},
+	}
+	if result.ID == "" {
+		return SAMLAssertion{}, ErrSAMLResponse
+	}
+
+	// Verify Subject
+	subject := assertion.Child(SAML_NS_ASSERTION, "Subject")
+	if subject == nil {
+		return SAMLAssertion{}, ErrSAMLResponse
+	}
+	nameID := subject.Child(SAML_NS_ASSERTION, "NameID")
+	if nameID == nil || strings.TrimSpace(nameID.Text()) == "" {
+		return SAMLAssertion{}, ErrSAMLResponse
+	}
+	result.NameID = strings.TrimSpace(nameID.Text())
+	result.NameIDFormat = nameID.Attr("Format")
+	confirmed := false
+	for _, confirmation := range subject.ChildElements(SAML_NS_ASSERTION, "SubjectConfirmation") {
+		data := confirmation.Child(SAML_NS_ASSERTION, "SubjectConfirmationData")
+		if confirmation.Attr("Method") != SAML_CONFIRMATION || data == nil {
+			continue
+		}
+		expires, err := time.Parse(time.RFC3339Nano, data.Attr("NotOnOrAfter"))
+		if err != nil || now.After(expires.Add(SAML_CLOCK_SKEW)) {
+			continue
+		}
+		if data.Attr("Recipient") != c.ConsumerURL() || data.Attr("InResponseTo") != result.InResponseTo {
+			continue
+		}
+		confirmed = true
+		result.Expires = expires
+		break
+	}
+	if !confirmed {
+		return SAMLAssertion{}, ErrSAMLExpired
+	}
+
+	// Verify Conditions
+	conditions := assertion.Child(SAML_NS_ASSERTION, "Conditions")
+	if conditions == nil {
+		return SAMLAssertion{}, ErrSAMLResponse
+	}
+	if v := conditions.Attr("NotBefore"); v != "" {
+		notBefore, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil || now.Add(SAML_CLOCK_SKEW).Before(notBefore) {
+			return SAMLAssertion{}, ErrSAMLExpired
+		}
+	}
+	if v := conditions.Attr("NotOnOrAfter"); v != "" {
+		notOnOrAfter, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil || now.After(notOnOrAfter.Add(SAML_CLOCK_SKEW)) {
+			return SAMLAssertion{}, ErrSAMLExpired
+		}
+		if notOnOrAfter.Before(result.Expires) {
+			result.Expires = notOnOrAfter
+		}
+	}
+	for _, restriction := range conditions.ChildElements(SAML_NS_ASSERTION, "AudienceRestriction") {
+		audiences := restriction.ChildElements(SAML_NS_ASSERTION, "Audience")
+		if !slices.ContainsFunc(audiences, func(a *XMLElement) bool {
+			return strings.TrimSpace(a.Text()) == c.ServiceEntityID()
+		}) {
+			return SAMLAssertion{}, ErrSAMLAudience
+		}
+	}
+
+	// Collect Attributes
+	for _, statement := range assertion.ChildElements(SAML_NS_ASSERTION, "AttributeStatement") {
+		for _, attribute := range statement.ChildElements(SAML_NS_ASSERTION, "Attribute") {
+			var values []string
+			for _, value := range attribute.ChildElements(SAML_NS_ASSERTION, "AttributeValue") {
+				values = append(values, strings.TrimSpace(value.Text()))
+			}
+			for _, key := range []string{attribute.Attr("Name"), attribute.Attr("FriendlyName")} {
+				if key != "" {
+					result.Attributes[key] = append(result.Attributes[key], values...)
+				}
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// Map Assertion to an Identity using the Attribute Names configured for the Connection
+func (c *SAMLConnection) Identity(a SAMLAssertion) FederationIdentity {
+	attribute := func(names []string) string {
+		for _, name := range names {
+			for _, v := range a.Attributes[name] {
+				if v != "" {
+					return v
+				}
+			}
+		}
+		return ""
+	}
+	identity := FederationIdentity{
+		Subject:      a.NameID,
+		EmailAddress: strings.ToLower(attribute(c.AttributeEmail)),
+		Username:     attribute(c.AttributeUsername),
+		Displayname:  attribute(c.AttributeDisplayname),
+	}
+	if identity.EmailAddress == "" && a.NameIDFormat == SAML_NAMEID_EMAIL {
+		identity.EmailAddress = strings.ToLower(a.NameID)
+	}
+
+	// Any Identity Provider can assert any Address, only trust it for Domains it manages
+	if _, domain, ok := strings.Cut(identity.EmailAddress, "@"); ok && c.TrustEmail {
+		identity.EmailVerified = slices.Contains(c.EmailDomains, domain)
+	}
+	return identity
+}
+
+// Bind a Request to the Browser which started it, the Binding is Signed so a
+// Response started in another Browser can't be forced into this one
+func SAMLRequestBinding(requestID string) (string, error) {
+	k, err := KeystoreCurrent(KEY_USAGE_HMAC)
+	if err != nil {
+		return "", err
+	}
+	return k.ID + "." + requestID + "." + samlBindingSignature(k.Material, requestID), nil
+}
+
+// Ensure the Binding was Generated by the Server for the given Request
+func SAMLCompareRequestBinding(binding, requestID string) bool {
+	s := strings.Split(binding, ".")
+	if len(s) != 3 || !CompareStringConstant(s[1], requestID) {
+		return false
+	}
+	k, ok := KeystoreLookup(KEY_USAGE_HMAC, s[0])
+	if !ok {
+		return false
+	}
+	return CompareStringConstant(s[2], samlBindingSignature(k.Material, requestID))
+}
+
+func samlBindingSignature(key []byte, requestID string) string {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte("saml:" + requestID))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Determine Signature Algorithm for the Service Provider Key
+func samlSignatureAlgorithm(key crypto.PublicKey) (string, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return XML_ALG_RSA_SHA256, nil
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return "", ErrSAMLKey
+		}
+		return XML_ALG_ECDSA_SHA256, nil
+	default:
+		return "", ErrSAMLKey
+	}
+}
+
+// Generate an ID for Requests, XML IDs may not begin with a Digit
+func GenerateSAMLID() string {
+	b := make([]byte, 20)
+	rand.Read(b)
+	return "_" + hex.EncodeToString(b)
+}
+
+// Import Identity Provider Metadata, cached for SAML_METADATA_INTERVAL
+func (c *SAMLConnection) load(ctx context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if time.Since(c.loaded) < SAML_METADATA_INTERVAL {
+		return nil
+	}
+
+	// Read Metadata
+	var data []byte
+	var err error
+	if c.MetadataFile != "" {
+		data, err = os.ReadFile(c.MetadataFile)
+	} else {
+		data, err = samlFetch(ctx, c.MetadataURL)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Parse Metadata, Aggregates may describe several Entities
+	var metadata struct {
+		XMLName  xml.Name
+		EntityID string               `xml:"entityID,attr"`
+		IDP      *samlIDPDescriptor   `xml:"urn:oasis:names:tc:SAML:2.0:metadata IDPSSODescriptor"`
+		Entities []samlEntityMetadata `xml:"urn:oasis:names:tc:SAML:2.0:metadata EntityDescriptor"`
+	}
+	if err := xml.Unmarshal(data, &metadata); err != nil {
+		return ErrSAMLMetadata
+	}
+	entity := samlEntityMetadata{EntityID: metadata.EntityID, IDP: metadata.IDP}
+	for _, e := range metadata.Entities {
+		if entity.IDP == nil && e.IDP != nil {
+			entity = e
+		}
+	}
+	if entity.IDP == nil || entity.EntityID == "" {
+		return ErrSAMLMetadata
+	}
+
+	// Collect Endpoint and Signing Certificates
+	var singleSignOnURL string
+	for _, service := range entity.IDP.SingleSignOnServices {
+		if service.Binding == SAML_BINDING_REDIRECT {
+			singleSignOnURL = service.Location
+			break
+		}
+	}
+	var certificates []*x509.Certificate
+	for _, key := range entity.IDP.KeyDescriptors {
+		if key.Use != "" && key.Use != "signing" {
+			continue
+		}
+		for _, encoded := range key.Certificates {
+			raw, err := xmlDecodeBase64(encoded)
+			if err != nil {
+				return ErrSAMLMetadata
+			}
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return ErrSAMLMetadata
+			}
+			certificates = append(certificates, cert)
+		}
+	}
+	if singleSignOnURL == "" || len(certificates) == 0 {
+		return ErrSAMLMetadata
+	}
+
+	c.entityID = entity.EntityID
+	c.singleSignOnURL = singleSignOnURL
+	c.certificates = certificates
+	c.loaded = time.Now()
+	return nil
+}
+
+type samlEntityMetadata struct {
+	EntityID string             `xml:"entityID,attr"`
+	IDP      *samlIDPDescriptor `xml:"urn:oasis:names:tc:SAML:2.0:metadata IDPSSODescriptor"`
+}
+
+type samlIDPDescriptor struct {
+	KeyDescriptors []struct {
+		Use          string   `xml:"use,attr"`
+		Certificates []string `xml:"http://www.w3.org/2000/09/xmldsig# KeyInfo>X509Data>X509Certificate"`
+	} `xml:"urn:oasis:names:tc:SAML:2.0:metadata KeyDescriptor"`
+	SingleSignOnServices []struct {
+		Binding  string `xml:"Binding,attr"`
+		Location string `xml:"Location,attr"`
+	} `xml:"urn:oasis:names:tc:SAML:2.0:metadata SingleSignOnService"`
+}
+
+// Fetch Metadata from the Identity Provider
+func samlFetch(ctx context.Context, endpoint string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, ErrSAMLMetadata
+	}
+	return io.ReadAll(io.LimitReader(res.Body, FEDERATION_RESPONSE_LIMIT))
+}
@@ -0,0 +1,93 @@
+package tools
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Sessions expire after an absolute Lifetime or once left Idle for too long,
+// rows are purged here so Deployments without "pg_cron" don't grow forever.
+// Every Instance purges, deleting the same rows twice is harmless.
+func SetupSessions(stop context.Context, await *sync.WaitGroup) {
+	t := time.Now()
+
+	// Sessions created before Expiry was tracked are given the configured
+	// Lifetime, which the Schema can't know about
+	ctx, cancel := NewContext()
+	backfilled, err := BackfillSessions(ctx)
+	cancel()
+	if err != nil {
+		LoggerSessions.Fatal("Backfill Failed", err.Error())
+	}
+
+	await.Add(1)
+	go func() {
+		defer await.Done()
+		ticker := time.NewTicker(SESSION_PURGE_INTERVAL)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop.Done():
+				LoggerSessions.Info("Closed", nil)
+				return
+			case <-ticker.C:
+				ctx, cancel := NewContext()
+				if purged, err := PurgeSessions(ctx, time.Now()); err != nil {
+					LoggerSessions.Error("Purge Failed", err.Error())
+				} else if purged > 0 {
+					LoggerSessions.Info("Purged Sessions", map[string]any{
+						"count": purged,
+					})
+				}
+				cancel()
+			}
+		}
+	}()
+
+	LoggerSessions.Info("Ready", map[string]any{
+		"time":       time.Since(t).String(),
+		"backfilled": backfilled,
+	})
+}
+
+// Set the Expiry of Sessions created before it was tracked
+func BackfillSessions(ctx context.Context) (int64, error) {
+	tag, err := Database.Exec(ctx,
+		`UPDATE auth.sessions
+		SET expires = created + make_interval(secs => $1)
+		WHERE expires IS NULL`,
+		LIFETIME_TOKEN_USER_COOKIE.Seconds(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// Delete Expired, Idle and long Revoked Sessions in Batches to keep Locks short
+func PurgeSessions(ctx context.Context, now time.Time) (int64, error) {
+	var purged int64
+	for {
+		tag, err := Database.Exec(ctx,
+			`DELETE FROM auth.sessions WHERE id IN (
+				SELECT id FROM auth.sessions
+				WHERE expires < $1
+				OR used < $2
+				OR (revoked = TRUE AND updated < $3)
+				LIMIT $4
+			)`,
+			now,
+			now.Add(-SESSION_IDLE_TIMEOUT),
+			now.Add(-SESSION_REVOKED_RETENTION),
+			SESSION_PURGE_BATCH,
+		)
+		if err != nil {
+			return purged, err
+		}
+		purged += tag.RowsAffected()
+		if tag.RowsAffected() < SESSION_PURGE_BATCH {
+			return purged, nil
+		}
+	}
+}
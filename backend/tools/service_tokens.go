@@ -0,0 +1,171 @@
+package tools
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Bearer Tokens at Rest
+//
+// Tokens are stored as a Keyed Hash so a leaked Database (or Backup) can't be
+// used to impersonate anyone. Hashes carry a Prefix so rows written before
+// hashing was introduced can be told apart and migrated in the background,
+// until then Lookups also match the Plaintext form of the presented Token.
+//
+// Changing the Hash Key invalidates every outstanding Token.
+const TOKEN_HASH_PREFIX = "h1:"
+
+// Columns holding Bearer Tokens
+var TokenColumns = []struct{ Table, Column string }{
+	{"auth.sessions", "token"},
+	{"auth.connections", "token_access"},
+	{"auth.connections", "token_refresh"},
+	{"auth.refresh_tokens", "token"},
+	{"auth.refresh_tokens", "parent"},
+	{"auth.grants", "code"},
+	{"auth.users", "token_verify"},
+	{"auth.users", "token_login"},
+	{"auth.users", "token_reset"},
+	{"auth.users", "token_magic"},
+	{"auth.users", "token_passcode"},
+	{"auth.users", "token_unlock"},
+	{"auth.devices", "token"},
+}
+
+func SetupTokens(stop context.Context, await *sync.WaitGroup) {
+	t := time.Now()
+
+	if len(TOKEN_HASH_KEY) == 0 && !testing.Testing() {
+		LoggerTokens.Fatal("Hash Key Required", nil)
+	}
+
+	// Plaintext Rows are hashed in Batches until none remain, every Instance
+	// migrates and hashing the same row twice is prevented by the Prefix
+	await.Add(1)
+	go func() {
+		defer await.Done()
+		var total int64
+		for {
+			ctx, cancel := NewContext()
+			migrated, err := MigrateTokens(ctx)
+			cancel()
+			total += migrated
+
+			wait := time.Duration(0)
+			switch {
+			case err != nil:
+				LoggerTokens.Error("Migration Failed", err.Error())
+				wait = TOKEN_MIGRATE_RETRY
+			case migrated == 0:
+				if total > 0 {
+					LoggerTokens.Info("Migration Complete", map[string]any{
+						"count": total,
+					})
+				}
+				return
+			}
+			select {
+			case <-stop.Done():
+				LoggerTokens.Info("Closed", nil)
+				return
+			case <-time.After(wait):
+			}
+		}
+	}()
+
+	LoggerTokens.Info("Ready", map[string]any{
+		"time":   time.Since(t).String(),
+		"legacy": TOKEN_LEGACY_ENABLED,
+	})
+}
+
+// Generate the Stored form of a Token
+func HashToken(token string) string {
+	h := hmac.New(sha256.New, TOKEN_HASH_KEY)
+	h.Write([]byte(token))
+	return TOKEN_HASH_PREFIX + base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Stored forms a presented Token may match, for use with "= ANY($n)".
+// Presented Hashes are never matched as Plaintext, otherwise a leaked
+// Hash would work just as well as the Token itself.
+func TokenCandidates(token string) []string {
+	candidates := []string{HashToken(token)}
+	if TOKEN_LEGACY_ENABLED && !strings.HasPrefix(token, TOKEN_HASH_PREFIX) {
+		candidates = append(candidates, token)
+	}
+	return candidates
+}
+
+// Compare a presented Token against its Stored form in constant time
+func CompareToken(given, stored string) bool {
+	if strings.HasPrefix(stored, TOKEN_HASH_PREFIX) {
+		return CompareStringConstant(HashToken(given), stored)
+	}
+	return TOKEN_LEGACY_ENABLED &&
+		!strings.HasPrefix(given, TOKEN_HASH_PREFIX) &&
+		CompareStringConstant(given, stored)
+}
+
+// Hash a Batch of Plaintext Tokens in every Column, returning the Rows updated
+func MigrateTokens(ctx context.Context) (int64, error) {
+	var migrated int64
+	for _, c := range TokenColumns {
+
+		// Collect Plaintext Tokens
+		rows, err := Database.Query(ctx,
+			fmt.Sprintf(
+				`SELECT DISTINCT %[2]s FROM %[1]s
+				WHERE %[2]s IS NOT NULL AND %[2]s NOT LIKE $1
+				LIMIT $2`,
+				c.Table, c.Column,
+			),
+			TOKEN_HASH_PREFIX+"%",
+			TOKEN_MIGRATE_BATCH,
+		)
+		if err != nil {
+			return migrated, err
+		}
+		var plain, hashed []string
+		for rows.Next() {
+			var token string
+			if err := rows.Scan(&token); err != nil {
+				rows.Close()
+				return migrated, err
+			}
+			plain = append(plain, token)
+			hashed = append(hashed, HashToken(token))
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return migrated, err
+		}
+		if len(plain) == 0 {
+			continue
+		}
+
+		// Replace Tokens, matching on the Value so concurrent Writes aren't overwritten
+		tag, err := Database.Exec(ctx,
+			fmt.Sprintf(
+				`UPDATE %[1]s SET %[2]s = v.hashed
+				FROM UNNEST($1::TEXT[], $2::TEXT[]) AS v(plain, hashed)
+				WHERE %[1]s.%[2]s = v.plain`,
+				c.Table, c.Column,
+			),
+			plain,
+			hashed,
+		)
+		if err != nil {
+			return migrated, err
+		}
+		migrated += tag.RowsAffected()
+	}
+	return migrated, nil
+}
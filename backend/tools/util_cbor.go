@@ -0,0 +1,127 @@
+package tools
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+const CBOR_DEPTH_MAX = 8 // Deepest Nesting accepted while Decoding
+
+var ErrCBORMalformed = errors.New("malformed cbor")
+
+// Decode a single CBOR Data Item, returning it alongside any remaining bytes
+//
+// Only supports the subset used by WebAuthn: Integers, Byte and Text Strings,
+// Arrays, Maps, Booleans and Null. Maps are keyed by int64 or string.
+func DecodeCBOR(b []byte) (any, []byte, error) {
+	return decodeCBOR(b, 0)
+}
+
+func decodeCBOR(b []byte, depth int) (any, []byte, error) {
+	if len(b) == 0 || depth > CBOR_DEPTH_MAX {
+		return nil, nil, ErrCBORMalformed
+	}
+	major, info := b[0]>>5, b[0]&0x1F
+	b = b[1:]
+
+	// Read Argument
+	var arg uint64
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info == 24 && len(b) >= 1:
+		arg, b = uint64(b[0]), b[1:]
+	case info == 25 && len(b) >= 2:
+		arg, b = uint64(binary.BigEndian.Uint16(b)), b[2:]
+	case info == 26 && len(b) >= 4:
+		arg, b = uint64(binary.BigEndian.Uint32(b)), b[4:]
+	case info == 27 && len(b) >= 8:
+		arg, b = binary.BigEndian.Uint64(b), b[8:]
+	default:
+		// Indefinite Lengths are not used by WebAuthn
+		return nil, nil, ErrCBORMalformed
+	}
+
+	switch major {
+	// Unsigned Integer
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, nil, ErrCBORMalformed
+		}
+		return int64(arg), b, nil
+
+	// Negative Integer
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, nil, ErrCBORMalformed
+		}
+		return -1 - int64(arg), b, nil
+
+	// Byte and Text Strings
+	case 2, 3:
+		if arg > uint64(len(b)) {
+			return nil, nil, ErrCBORMalformed
+		}
+		if major == 3 {
+			return string(b[:arg]), b[arg:], nil
+		}
+		return b[:arg:arg], b[arg:], nil
+
+	// Array
+	case 4:
+		if arg > uint64(len(b)) {
+			return nil, nil, ErrCBORMalformed
+		}
+		items := make([]any, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			item, rest, err := decodeCBOR(b, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			items, b = append(items, item), rest
+		}
+		return items, b, nil
+
+	// Map
+	case 5:
+		if arg > uint64(len(b))/2 {
+			return nil, nil, ErrCBORMalformed
+		}
+		items := make(map[any]any, arg)
+		for i := uint64(0); i < arg; i++ {
+			key, rest, err := decodeCBOR(b, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, ErrCBORMalformed
+			}
+			if _, ok := items[key]; ok {
+				return nil, nil, ErrCBORMalformed
+			}
+			value, rest, err := decodeCBOR(rest, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			items[key], b = value, rest
+		}
+		return items, b, nil
+
+	// Simple Values
+	case 7:
+		switch info {
+		case 20:
+			return false, b, nil
+		case 21:
+			return true, b, nil
+		case 22:
+			return nil, b, nil
+		}
+	}
+
+	// Tags and Floats are not used by WebAuthn
+	return nil, nil, ErrCBORMalformed
+}
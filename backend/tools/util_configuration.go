@@ -19,13 +19,65 @@ const (
 	CONTEXT_TIMEOUT                          = 10 * time.Second    // Default Context Timeout
 	LIFETIME_OAUTH2_GRANT_TOKEN              = 15 * time.Second    // Lifetime for OAuth2 Grant Token
 	LIFETIME_OAUTH2_ACCESS_TOKEN             = 7 * 24 * time.Hour  // Lifetime for OAuth2 Access Token
+	LIFETIME_OAUTH2_CLIENT_TOKEN             = time.Hour           // Lifetime for OAuth2 Client Credentials Access Token
+	LIFETIME_OAUTH2_DEVICE_CODE              = 10 * time.Minute    // Lifetime for OAuth2 Device Code
+	OAUTH2_DEVICE_INTERVAL                   = 5 * time.Second     // Minimum Interval between OAuth2 Device Code Polls
+	LIFETIME_OIDC_ID_TOKEN                   = time.Hour           // Lifetime for OpenID Connect ID Token
+	KEYSTORE_REFRESH_INTERVAL                = time.Minute         // Interval to Reload Keys and check for Rotation
+	KEYSTORE_PUBLISH_DELAY                   = 24 * time.Hour      // Duration a Key is Published before it's used for Signing
 	LIFETIME_TOKEN_USER_ELEVATION            = 10 * time.Minute    // Lifetime for User Elevation
-	LIFETIME_TOKEN_USER_COOKIE               = 30 * 24 * time.Hour // Lifetime for User Cookie
+	LIFETIME_WEBAUTHN_CHALLENGE              = 5 * time.Minute     // Lifetime for Passkey Ceremony Challenge
+	LIFETIME_FEDERATION_STATE                = 10 * time.Minute    // Lifetime for Federated Login State
+	FEDERATION_DISCOVERY_INTERVAL            = time.Hour           // Interval to Refresh Provider Endpoints and Keys
+	FEDERATION_RESPONSE_LIMIT                = 1 << 20             // Maximum Size of Provider Responses
+	LIFETIME_SAML_REQUEST                    = 10 * time.Minute    // Lifetime for SAML AuthnRequest
+	SAML_METADATA_INTERVAL                   = 24 * time.Hour      // Interval to Refresh Identity Provider Metadata
+	SAML_CLOCK_SKEW                          = 3 * time.Minute     // Tolerated Clock Difference with Identity Providers
+	PASSKEY_LIMIT                            = 10                  // Maximum Passkeys per Account
+	LIFETIME_TOKEN_USER_COOKIE               = 30 * 24 * time.Hour // Lifetime for User Session and its Cookie
+	SESSION_IDLE_TIMEOUT                     = 7 * 24 * time.Hour  // Duration a User Session may go Unused before it Expires
+	SESSION_TOUCH_INTERVAL                   = 10 * time.Minute    // Minimum Interval between recording Session Usage
+	SESSION_REVOKED_RETENTION                = 24 * time.Hour      // Duration Revoked Sessions are kept before being Purged
+	SESSION_PURGE_INTERVAL                   = time.Hour           // Interval to Purge Expired Sessions
+	SESSION_PURGE_BATCH                      = 1000                // Sessions Deleted per Statement while Purging
+	TOKEN_MIGRATE_BATCH                      = 1000                // Plaintext Tokens Hashed per Column per Statement
+	TOKEN_MIGRATE_RETRY                      = time.Minute         // Interval to Retry a Failed Token Migration
+	FIELD_MIGRATE_BATCH                      = 1000                // Rows Encrypted per Statement while Migrating
+	EMAIL_OUTBOX_POLL_INTERVAL               = 5 * time.Second     // Interval Workers check the Outbox for Due Emails
+	EMAIL_OUTBOX_BATCH                       = 10                  // Emails Claimed by a Worker at once
+	EMAIL_OUTBOX_LEASE                       = 5 * time.Minute     // Duration Claimed Emails are held before another Worker may retry them
+	EMAIL_OUTBOX_RETRY_DELAY                 = 30 * time.Second    // Delay before the First Retry, doubled for each after
+	EMAIL_OUTBOX_RETRY_LIMIT                 = 6 * time.Hour       // Maximum Delay between Retries
+	EMAIL_OUTBOX_ATTEMPT_LIMIT               = 10                  // Attempts before an Email is Dead-Lettered
+	EMAIL_OUTBOX_DRAIN_TIMEOUT               = 30 * time.Second    // Duration Workers keep sending Due Emails during Shutdown
+	EMAIL_SMTP_IDLE_TIMEOUT                  = time.Minute         // Duration an SMTP Connection may sit Idle before it's Reopened
+	LIFETIME_TOKEN_DEVICE_COOKIE             = 8760 * time.Hour    // Lifetime for Device Cookie (1 Year)
 	LIFETIME_TOKEN_EMAIL_PASSCODE            = 15 * time.Minute    // Lifetime for MFA Passcode
+	PASSCODE_ATTEMPT_LIMIT                   = 5                   // Incorrect Guesses before an Emailed Passcode is Discarded
+	LOCKOUT_WINDOW                           = time.Hour           // Duration Failed Guesses are Counted towards a Lockout
+	LOCKOUT_PASSWORD_LIMIT                   = 10                  // Incorrect Passwords before an Account is Locked
+	LOCKOUT_PASSCODE_LIMIT                   = 5                   // Incorrect Passcodes before an Account is Locked
+	LOCKOUT_RECOVERY_LIMIT                   = 5                   // Incorrect Recovery Codes before an Account is Locked
+	LOCKOUT_DURATION                         = 5 * time.Minute     // Duration of the First Lockout, doubled for each after
+	LOCKOUT_DURATION_LIMIT                   = 24 * time.Hour      // Maximum Duration of a Lockout
+	LIFETIME_TOKEN_EMAIL_UNLOCK              = 24 * time.Hour      // Lifetime for Unlock Account Token
+	RISK_HISTORY_LIMIT                       = 50                  // Previous Logins compared against when Assessing a Login
+	RISK_FAILURE_VELOCITY                    = 3                   // Failed Guesses within the Lockout Window considered Suspicious
+	RISK_TRAVEL_SPEED                        = 1000                // Fastest plausible Travel between Logins (km/h)
+	RISK_TRAVEL_ZONE_DISTANCE                = 1670                // Distance spanned by an Hour of Timezone Offset (km)
+	RISK_WEIGHT_NEW_DEVICE                   = 20                  // Score for a Device not allowed by the Owner
+	RISK_WEIGHT_NEW_AGENT                    = 10                  // Score for a Browser not used before
+	RISK_WEIGHT_NEW_NETWORK                  = 10                  // Score for an Autonomous System not used before
+	RISK_WEIGHT_NEW_COUNTRY                  = 30                  // Score for a Country not logged in from before
+	RISK_WEIGHT_IMPOSSIBLE_TRAVEL            = 50                  // Score for a Location unreachable since the last Login
+	RISK_WEIGHT_FAILURE_VELOCITY             = 20                  // Score for recent Failed Guesses
+	RISK_WEIGHT_ANONYMOUS_NETWORK            = 40                  // Score for Tor, VPN or Proxy Networks
+	RISK_THRESHOLD_APPROVE                   = 20                  // Score requiring the Owner to approve the Login via Email
+	RISK_THRESHOLD_MFA                       = 50                  // Score requiring MFA, even on Trusted Devices
+	RISK_THRESHOLD_DENY                      = 100                 // Score at which Logins are refused
 	LIFETIME_TOKEN_EMAIL_LOGIN               = 24 * time.Hour      // Lifetime for Verify Login Token
 	LIFETIME_TOKEN_EMAIL_VERIFY              = 24 * time.Hour      // Lifetime for Verify Email Token
 	LIFETIME_TOKEN_EMAIL_RESET               = 24 * time.Hour      // Lifetime for Password Reset Token
-	PASSWORD_HASH_EFFORT                     = 12                  // Password Hashing Effort
 	PASSWORD_HISTORY_LIMIT                   = 3                   // Password History Length
 	MFA_PASSCODE_LENGTH                      = 6                   // TOTP Passcode String Length (Do Not Change)
 	MFA_RECOVERY_LENGTH                      = 8                   // TOTP Recovery Code Length (Do Not Change)
@@ -46,12 +98,23 @@ var (
 	EMAIL_SENDER_ADDRESS        = EnvString("EMAIL_SENDER_ADDRESS", "noreply@example.org")
 	EMAIL_DEFAULT_DISPLAYNAME   = EnvString("EMAIL_DEFAULT_DISPLAYNAME", "User")
 	EMAIL_DEFAULT_HOST          = EnvString("EMAIL_DEFAULT_HOST", "https://example.org")
+	EMAIL_REPLY_TO              = EnvString("EMAIL_REPLY_TO", "")
+	EMAIL_LIST_UNSUBSCRIBE      = EnvString("EMAIL_LIST_UNSUBSCRIBE", "")
 	EMAIL_ENGINE_URL            = EnvString("EMAIL_ENGINE_URL", "http://localhost:8080")
 	EMAIL_ENGINE_KEY            = EnvString("EMAIL_ENGINE_KEY", "teto")
 	EMAIL_SES_ACCESS_KEY        = EnvString("EMAIL_SES_ACCESS_KEY", "xyz")
 	EMAIL_SES_SECRET_KEY        = EnvString("EMAIL_SES_SECRET_KEY", "123")
 	EMAIL_SES_REGION            = EnvString("EMAIL_SES_REGION", "unknown")
 	EMAIL_SES_CONFIGURATION_SET = EnvString("EMAIL_SES_CONFIGURATION_SET", "unknown")
+	EMAIL_SMTP_HOST             = EnvString("EMAIL_SMTP_HOST", "localhost")
+	EMAIL_SMTP_PORT             = EnvNumber("EMAIL_SMTP_PORT", 587)
+	EMAIL_SMTP_SECURITY         = EnvString("EMAIL_SMTP_SECURITY", "starttls")
+	EMAIL_SMTP_AUTH             = EnvString("EMAIL_SMTP_AUTH", "plain")
+	EMAIL_SMTP_USERNAME         = EnvString("EMAIL_SMTP_USERNAME", "")
+	EMAIL_SMTP_PASSWORD         = EnvString("EMAIL_SMTP_PASSWORD", "")
+	EMAIL_SMTP_HELO             = EnvString("EMAIL_SMTP_HELO", "localhost")
+	EMAIL_SMTP_TLS_CA           = EnvString("EMAIL_SMTP_TLS_CA", "")
+	EMAIL_OUTBOX_WORKERS        = EnvNumber("EMAIL_OUTBOX_WORKERS", 2)
 	STORAGE_PROVIDER            = EnvString("STORAGE_PROVIDER", "none")
 	STORAGE_DISK_DIRECTORY      = EnvString("STORAGE_DISK_DIRECTORY", "data")
 	STORAGE_DISK_PERMISSIONS    = EnvNumber("STORAGE_DISK_PERMISSIONS", 2760)
@@ -71,15 +134,49 @@ var (
 	HTTP_COOKIE_NAME            = EnvString("HTTP_COOKIE_NAME", "session")
 	HTTP_COOKIE_DOMAIN          = EnvString("HTTP_COOKIE_DOMAIN", "")
 	HTTP_COOKIE_SECURE          = EnvString("HTTP_COOKIE_SECURE", "false") == "true"
+	HTTP_DEVICE_COOKIE_NAME     = EnvString("HTTP_DEVICE_COOKIE_NAME", "device")
+	HTTP_FEDERATION_COOKIE_NAME = EnvString("HTTP_FEDERATION_COOKIE_NAME", "federation")
+	HTTP_SAML_COOKIE_NAME       = EnvString("HTTP_SAML_COOKIE_NAME", "saml")
 	HTTP_CORS_ORIGINS           = EnvSlice("HTTP_CORS_ORIGINS", ",", []string{"http://localhost:5173"})
 	HTTP_IP_HEADERS             = EnvSlice("HTTP_IP_HEADERS", ",", []string{"X-Forwarded-By"})
 	HTTP_IP_PROXIES             = EnvSlice("HTTP_IP_PROXIES", ",", []string{"127.0.0.1/8"})
-	HTTP_KEY                    = []byte(EnvString("HTTP_KEY", "teto"))
+	HTTP_KEY                    = []byte(EnvString("HTTP_KEY", ""))
 	HTTP_SERVER_TOKEN           = EnvString("HTTP_SERVER_TOKEN", "true") == "true"
 	HTTP_TLS_ENABLED            = EnvString("HTTP_TLS_ENABLED", "false") == "true"
 	HTTP_TLS_CERT               = EnvString("HTTP_TLS_CERT", "tls_crt.pem")
 	HTTP_TLS_KEY                = EnvString("HTTP_TLS_KEY", "tls_key.pem")
 	HTTP_TLS_CA                 = EnvString("HTTP_TLS_CA", "tls_ca.pem")
+	OIDC_ISSUER                 = EnvString("OIDC_ISSUER", "http://localhost:8080")
+	KEYSTORE_PROVIDER           = EnvString("KEYSTORE_PROVIDER", "database")
+	KEYSTORE_DISK_DIRECTORY     = EnvString("KEYSTORE_DISK_DIRECTORY", "keys")
+	KEYSTORE_WRAP_KEY           = []byte(EnvString("KEYSTORE_WRAP_KEY", ""))
+	KEYSTORE_SIGNING_ALGORITHM  = EnvString("KEYSTORE_SIGNING_ALGORITHM", "ES256")
+	KEYSTORE_ROTATION_INTERVAL  = time.Duration(EnvNumber("KEYSTORE_ROTATION_HOURS", 30*24)) * time.Hour
+	KEYSTORE_ROTATION_OVERLAP   = time.Duration(EnvNumber("KEYSTORE_OVERLAP_HOURS", 90*24)) * time.Hour
+	WEBAUTHN_RP_ID              = EnvString("WEBAUTHN_RP_ID", "localhost")
+	WEBAUTHN_RP_NAME            = EnvString("WEBAUTHN_RP_NAME", "template-auth")
+	WEBAUTHN_ORIGINS            = EnvSlice("WEBAUTHN_ORIGINS", ",", []string{"http://localhost:5173"})
+	DEVICE_TRUST_INTERVAL       = time.Duration(EnvNumber("DEVICE_TRUST_DAYS", 30)) * 24 * time.Hour
+	FEDERATION_PROVIDERS        = EnvSlice("FEDERATION_PROVIDERS", ",", []string{})
+	FEDERATION_REDIRECT_URL     = EnvString("FEDERATION_REDIRECT_URL", "http://localhost:5173/login/federated")
+	SAML_CONNECTIONS            = EnvSlice("SAML_CONNECTIONS", ",", []string{})
+	SAML_REDIRECT_URL           = EnvString("SAML_REDIRECT_URL", "http://localhost:5173/login/saml")
+	SAML_SP_CERT                = EnvString("SAML_SP_CERT", "saml_crt.pem")
+	SAML_SP_KEY                 = EnvString("SAML_SP_KEY", "saml_key.pem")
+	SAML_ATTRIBUTE_EMAIL        = EnvSlice("SAML_ATTRIBUTE_EMAIL", ",", []string{"email", "mail", "urn:oid:0.9.2342.19200300.100.1.3", "http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress"})
+	SAML_ATTRIBUTE_USERNAME     = EnvSlice("SAML_ATTRIBUTE_USERNAME", ",", []string{"username", "uid", "urn:oid:0.9.2342.19200300.100.1.1", "http://schemas.xmlsoap.org/ws/2005/05/identity/claims/name"})
+	SAML_ATTRIBUTE_DISPLAYNAME  = EnvSlice("SAML_ATTRIBUTE_DISPLAYNAME", ",", []string{"displayName", "cn", "urn:oid:2.16.840.1.113730.3.1.241", "http://schemas.microsoft.com/identity/claims/displayname"})
+	SCIM_APPLICATIONS           = EnvSlice("SCIM_APPLICATIONS", ",", []string{})
+	PASSWORD_HASH_ALGORITHM     = EnvString("PASSWORD_HASH_ALGORITHM", "argon2id")
+	PASSWORD_ARGON2_MEMORY      = EnvNumber("PASSWORD_ARGON2_MEMORY", 19456) // KiB
+	PASSWORD_ARGON2_ITERATIONS  = EnvNumber("PASSWORD_ARGON2_ITERATIONS", 2)
+	PASSWORD_ARGON2_PARALLELISM = EnvNumber("PASSWORD_ARGON2_PARALLELISM", 1)
+	PASSWORD_BCRYPT_COST        = EnvNumber("PASSWORD_BCRYPT_COST", 12)
+	BREACH_CORPUS_FILE          = EnvString("BREACH_CORPUS_FILE", "")
+	RISK_NETWORKS_FILE          = EnvString("RISK_NETWORKS_FILE", "")
+	TOKEN_HASH_KEY              = []byte(EnvString("TOKEN_HASH_KEY", ""))
+	TOKEN_LEGACY_ENABLED        = EnvString("TOKEN_LEGACY_ENABLED", "true") == "true"
+	FIELD_INDEX_KEY             = []byte(EnvString("FIELD_INDEX_KEY", ""))
 )
 
 // Default Context Timeout
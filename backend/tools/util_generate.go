@@ -8,13 +8,10 @@ import (
 	"crypto/subtle"
 	"encoding/base64"
 	"encoding/binary"
-	"errors"
 	"fmt"
 	"math/big"
 	"strings"
 	"time"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
 // Picks a Random Number between 0-999999 for One-Time Passcodes
@@ -34,21 +31,60 @@ func GenerateRecoveryCodes() []string {
 	return codes
 }
 
+// Generate a Short Code for Users to type on another Device (e.g. BCDF-GHJK).
+// Vowels are omitted to prevent spelling out words and lookalike characters.
+func GenerateUserCode() string {
+	b := make([]byte, 9)
+	for i := range b {
+		if i == 4 {
+			b[i] = '-'
+			continue
+		}
+		n, _ := rand.Int(rand.Reader, big.NewInt(int64(len(USER_CODE_CHARSET))))
+		b[i] = USER_CODE_CHARSET[n.Int64()]
+	}
+	return string(b)
+}
+
+// Generate a Random Challenge for Passkey Ceremonies, encoded for WebAuthn JSON
+func GeneratePasskeyChallenge() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return WebAuthnEncode(b)
+}
+
+// Generate a Random URL-Safe Token for Parameters sent to other Servers (e.g. State, Nonce)
+func GenerateRandomToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
 // Generate a String with a Signature, which can be verified with CompareSignedString func
 // to ensure it was generated by the server. Additionally the string is generally unique.
-func GenerateSignedString() string {
+func GenerateSignedString() (string, error) {
+	k, err := KeystoreCurrent(KEY_USAGE_HMAC)
+	if err != nil {
+		return "", err
+	}
+	return GenerateSignedStringWith(k), nil
+}
+
+// Generate a Signed String using the given HMAC Key
+func GenerateSignedStringWith(k KeystoreKey) string {
 	// Generate Payload
 	b := make([]byte, 32)
 	t := time.Now().Unix() - EPOCH_SECONDS
 	rand.Read(b[binary.PutVarint(b, t):])
 
 	// Generate Signature
-	h := hmac.New(sha256.New, HTTP_KEY)
+	h := hmac.New(sha256.New, k.Material)
 	h.Write(b)
 	s := h.Sum(nil)
 
 	return fmt.Sprintf(
-		"%s.%s",
+		"%s.%s.%s",
+		k.ID,
 		base64.RawURLEncoding.EncodeToString(b),
 		base64.RawURLEncoding.EncodeToString(s),
 	)
@@ -56,8 +92,24 @@ func GenerateSignedString() string {
 
 // Ensure that givenString was Generated by the Server by checking it's signature
 func CompareSignedString(givenString string) bool {
-	s := strings.SplitN(givenString, ".", 2)
-	if len(s) != 2 {
+	s := strings.Split(givenString, ".")
+
+	// Strings issued before the Keystore do not include a Key ID
+	var key []byte
+	switch len(s) {
+	case 2:
+		if len(HTTP_KEY) == 0 {
+			return false
+		}
+		key = HTTP_KEY
+	case 3:
+		k, ok := KeystoreLookup(KEY_USAGE_HMAC, s[0])
+		if !ok {
+			return false
+		}
+		key = k.Material
+		s = s[1:]
+	default:
 		return false
 	}
 
@@ -72,38 +124,11 @@ func CompareSignedString(givenString string) bool {
 	}
 
 	// Test Signature
-	h := hmac.New(sha256.New, HTTP_KEY)
+	h := hmac.New(sha256.New, key)
 	h.Write(givenPayload)
 	return hmac.Equal(h.Sum(nil), givenSignature)
 }
 
-// Wrapper to Hash Password with Predefined Effort
-func GeneratePasswordHash(givenPassword string) (string, error) {
-	hashBytes, err := bcrypt.GenerateFromPassword(
-		[]byte(givenPassword),
-		PASSWORD_HASH_EFFORT,
-	)
-	if err != nil {
-		return "", err
-	}
-	return string(hashBytes), nil
-}
-
-// Wrapper to Compare Password Against Given String
-func ComparePasswordHash(givenHash, givenPassword string) (bool, error) {
-	err := bcrypt.CompareHashAndPassword(
-		[]byte(givenHash),
-		[]byte(givenPassword),
-	)
-	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
-		return false, nil
-	}
-	if err != nil {
-		return false, err
-	}
-	return true, nil
-}
-
 // Compare two strings in constant time to prevent leaking of sensitive info
 func CompareStringConstant(a, b string) bool {
 	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
@@ -115,10 +140,12 @@ func GenerateImageHash(image []byte) string {
 }
 
 // Generate Plaintext and Hashed Versions of a Application Secret
-func GenerateApplicationSecret() (plain string, hashed string) {
-	plain = GenerateSignedString()
+func GenerateApplicationSecret() (plain string, hashed string, err error) {
+	if plain, err = GenerateSignedString(); err != nil {
+		return "", "", err
+	}
 	hashed = fmt.Sprintf("%X", sha256.Sum256([]byte(plain)))
-	return plain, hashed
+	return plain, hashed, nil
 }
 
 // Validate Plaintext Application Secret against Hash
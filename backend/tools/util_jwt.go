@@ -0,0 +1,260 @@
+package tools
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const (
+	JWT_ALGORITHM_RS256 = "RS256"
+	JWT_ALGORITHM_ES256 = "ES256"
+)
+
+var (
+	ErrJWTMalformed   = errors.New("jwt malformed")
+	ErrJWTAlgorithm   = errors.New("jwt algorithm unsupported")
+	ErrJWTSignature   = errors.New("jwt signature invalid")
+	ErrJWTExpired     = errors.New("jwt expired")
+	ErrJWTUnknownKey  = errors.New("jwt signed with unknown key")
+	ErrJWKUnsupported = errors.New("jwk type unsupported")
+)
+
+// JSON Web Key as described in RFC 7517
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid,omitempty"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+// Determine JWT Algorithm for the Given Key
+func JWTAlgorithm(key crypto.PublicKey) (string, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return JWT_ALGORITHM_RS256, nil
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return "", ErrJWTAlgorithm
+		}
+		return JWT_ALGORITHM_ES256, nil
+	default:
+		return "", ErrJWTAlgorithm
+	}
+}
+
+// Generate a Signed JWT using the given Key and Claims
+func GenerateJWT(signer crypto.Signer, kid string, claims map[string]any) (string, error) {
+	alg, err := JWTAlgorithm(signer.Public())
+	if err != nil {
+		return "", err
+	}
+
+	// Encode Segments
+	header, err := json.Marshal(map[string]any{"alg": alg, "typ": "JWT", "kid": kid})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	unsigned := fmt.Sprintf("%s.%s",
+		base64.RawURLEncoding.EncodeToString(header),
+		base64.RawURLEncoding.EncodeToString(payload),
+	)
+
+	// Generate Signature
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+	if alg == JWT_ALGORITHM_ES256 {
+		// JWS uses the raw R || S form rather than ASN.1
+		var sig struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(signature, &sig); err != nil {
+			return "", err
+		}
+		signature = make([]byte, 64)
+		sig.R.FillBytes(signature[:32])
+		sig.S.FillBytes(signature[32:])
+	}
+
+	return fmt.Sprintf("%s.%s", unsigned, base64.RawURLEncoding.EncodeToString(signature)), nil
+}
+
+// Verify a JWT Signature and Expiry, returning its Claims.
+// The lookup function should return the public key for the given key id.
+func CompareJWT(token string, lookup func(kid string) crypto.PublicKey) (map[string]any, error) {
+	segments := strings.Split(token, ".")
+	if len(segments) != 3 {
+		return nil, ErrJWTMalformed
+	}
+
+	// Decode Segments
+	var header struct {
+		Algorithm string `json:"alg"`
+		KeyID     string `json:"kid"`
+	}
+	headerBytes, err := base64.RawURLEncoding.DecodeString(segments[0])
+	if err != nil {
+		return nil, ErrJWTMalformed
+	}
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return nil, ErrJWTMalformed
+	}
+	payloadBytes, err := base64.RawURLEncoding.DecodeString(segments[1])
+	if err != nil {
+		return nil, ErrJWTMalformed
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(segments[2])
+	if err != nil {
+		return nil, ErrJWTMalformed
+	}
+
+	// Test Signature
+	key := lookup(header.KeyID)
+	if key == nil {
+		return nil, ErrJWTUnknownKey
+	}
+	if alg, err := JWTAlgorithm(key); err != nil || alg != header.Algorithm {
+		// Prevent Algorithm Confusion
+		return nil, ErrJWTAlgorithm
+	}
+	digest := sha256.Sum256([]byte(segments[0] + "." + segments[1]))
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], signature) != nil {
+			return nil, ErrJWTSignature
+		}
+	case *ecdsa.PublicKey:
+		if len(signature) != 64 {
+			return nil, ErrJWTSignature
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(k, digest[:], r, s) {
+			return nil, ErrJWTSignature
+		}
+	}
+
+	// Decode Claims
+	claims := make(map[string]any)
+	if err := json.Unmarshal(payloadBytes, &claims); err != nil {
+		return nil, ErrJWTMalformed
+	}
+	if exp, ok := claims["exp"].(float64); ok && time.Now().Unix() > int64(exp) {
+		return nil, ErrJWTExpired
+	}
+	return claims, nil
+}
+
+// Export Public Key as a JWK
+func GenerateJWK(key crypto.PublicKey, kid string) (JWK, error) {
+	alg, err := JWTAlgorithm(key)
+	if err != nil {
+		return JWK{}, err
+	}
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			KeyType:   "RSA",
+			KeyID:     kid,
+			Use:       "sig",
+			Algorithm: alg,
+			N:         base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+			E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		x, y := make([]byte, 32), make([]byte, 32)
+		k.X.FillBytes(x)
+		k.Y.FillBytes(y)
+		return JWK{
+			KeyType:   "EC",
+			KeyID:     kid,
+			Use:       "sig",
+			Algorithm: alg,
+			Curve:     "P-256",
+			X:         base64.RawURLEncoding.EncodeToString(x),
+			Y:         base64.RawURLEncoding.EncodeToString(y),
+		}, nil
+	default:
+		return JWK{}, ErrJWKUnsupported
+	}
+}
+
+// Import Public Key from a JWK
+func ParseJWK(jwk JWK) (crypto.PublicKey, error) {
+	decode := func(s string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil || len(b) == 0 {
+			return nil, ErrJWKUnsupported
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+	switch jwk.KeyType {
+	case "RSA":
+		n, err := decode(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(jwk.E)
+		if err != nil || !e.IsInt64() {
+			return nil, ErrJWKUnsupported
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if jwk.Curve != "P-256" {
+			return nil, ErrJWKUnsupported
+		}
+		x, err := decode(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+		if !key.Curve.IsOnCurve(x, y) {
+			return nil, ErrJWKUnsupported
+		}
+		return key, nil
+	default:
+		return nil, ErrJWKUnsupported
+	}
+}
+
+// Generate a Key ID using the JWK Thumbprint (RFC 7638)
+func GenerateJWKThumbprint(key crypto.PublicKey) (string, error) {
+	jwk, err := GenerateJWK(key, "")
+	if err != nil {
+		return "", err
+	}
+	var members string
+	switch jwk.KeyType {
+	case "RSA":
+		members = fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`, jwk.E, jwk.N)
+	case "EC":
+		members = fmt.Sprintf(`{"crv":"%s","kty":"EC","x":"%s","y":"%s"}`, jwk.Curve, jwk.X, jwk.Y)
+	}
+	sum := sha256.Sum256([]byte(members))
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
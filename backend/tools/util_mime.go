@@ -0,0 +1,230 @@
+package tools
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Headers set by the Encoder itself, which Messages may not override
+var mimeReservedHeaders = []string{
+	"From", "To", "Subject", "Date", "Mime-Version",
+	"Content-Type", "Content-Transfer-Encoding", "Content-Disposition",
+}
+
+// Generate a Message-ID in the Domain of the Sender Address
+func EmailMessageID() string {
+	_, domain, ok := strings.Cut(EMAIL_SENDER_ADDRESS, "@")
+	if !ok {
+		domain = "localhost"
+	}
+	return "<" + mimeRandom() + "@" + domain + ">"
+}
+
+// Encode an Email as a MIME Message. The Text and HTML Bodies are sent as
+// Alternatives, Attachments are added alongside them in a Mixed Part.
+func EncodeEmailMIME(from mail.Address, message EmailMessage) ([]byte, error) {
+	to, err := mail.ParseAddress(message.To)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect Headers, Defaults are only used if the Message doesn't set them
+	headers := map[string]string{}
+	if EMAIL_REPLY_TO != "" {
+		headers["Reply-To"] = EMAIL_REPLY_TO
+	}
+	if EMAIL_LIST_UNSUBSCRIBE != "" {
+		var links []string
+		for _, l := range strings.Split(EMAIL_LIST_UNSUBSCRIBE, ",") {
+			links = append(links, "<"+strings.TrimSpace(l)+">")
+		}
+		headers["List-Unsubscribe"] = strings.Join(links, ", ")
+	}
+	for key, value := range message.Headers {
+		key = textproto.CanonicalMIMEHeaderKey(key)
+		if slices.Contains(mimeReservedHeaders, key) {
+			return nil, fmt.Errorf("reserved header: %s", key)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("invalid header: %s", key)
+		}
+		headers[key] = value
+	}
+	if _, ok := headers["Message-Id"]; !ok {
+		headers["Message-Id"] = EmailMessageID()
+	}
+	if replyTo, ok := headers["Reply-To"]; ok {
+		list, err := mail.ParseAddressList(replyTo)
+		if err != nil {
+			return nil, err
+		}
+		var formatted []string
+		for _, a := range list {
+			formatted = append(formatted, a.String())
+		}
+		headers["Reply-To"] = strings.Join(formatted, ", ")
+	}
+
+	// Encode Body
+	bodyHeader, body, err := mimeBody(message)
+	if err != nil {
+		return nil, err
+	}
+	if len(message.Attachments) > 0 {
+		var b bytes.Buffer
+		mixed := multipart.NewWriter(&b)
+		mixed.SetBoundary(mimeBoundary())
+		part, err := mixed.CreatePart(bodyHeader)
+		if err != nil {
+			return nil, err
+		}
+		part.Write(body)
+		for _, a := range message.Attachments {
+			if err := mimeAttachment(mixed, a); err != nil {
+				return nil, err
+			}
+		}
+		if err := mixed.Close(); err != nil {
+			return nil, err
+		}
+		bodyHeader = textproto.MIMEHeader{}
+		bodyHeader.Set("Content-Type", mime.FormatMediaType("multipart/mixed", map[string]string{
+			"boundary": mixed.Boundary(),
+		}))
+		body = b.Bytes()
+	}
+
+	// Write Message
+	var b bytes.Buffer
+	write := func(key, value string) {
+		if key == "Message-Id" {
+			key = "Message-ID"
+		}
+		b.WriteString(key + ": " + value + "\r\n")
+	}
+	write("From", from.String())
+	write("To", to.String())
+	write("Subject", mime.QEncoding.Encode("utf-8", message.Subject))
+	write("Date", time.Now().Format(time.RFC1123Z))
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		write(key, mime.QEncoding.Encode("utf-8", headers[key]))
+	}
+	write("MIME-Version", "1.0")
+	for _, key := range []string{"Content-Type", "Content-Transfer-Encoding"} {
+		if value := bodyHeader.Get(key); value != "" {
+			// Fold Parameters, Boundaries would push the Line past its Limit
+			write(key, strings.ReplaceAll(value, "; ", ";\r\n "))
+		}
+	}
+	b.WriteString("\r\n")
+	b.Write(body)
+	return b.Bytes(), nil
+}
+
+// Encode the Text and HTML Bodies, as Alternatives if both are present
+func mimeBody(message EmailMessage) (textproto.MIMEHeader, []byte, error) {
+	switch {
+	case message.Text != "" && message.HTML != "":
+		var b bytes.Buffer
+		alternative := multipart.NewWriter(&b)
+		alternative.SetBoundary(mimeBoundary())
+		for _, p := range []struct{ ContentType, Content string }{
+			{"text/plain", message.Text}, // Least preferred first
+			{"text/html", message.HTML},
+		} {
+			header, content, err := mimeText(p.ContentType, p.Content)
+			if err != nil {
+				return nil, nil, err
+			}
+			part, err := alternative.CreatePart(header)
+			if err != nil {
+				return nil, nil, err
+			}
+			part.Write(content)
+		}
+		if err := alternative.Close(); err != nil {
+			return nil, nil, err
+		}
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", mime.FormatMediaType("multipart/alternative", map[string]string{
+			"boundary": alternative.Boundary(),
+		}))
+		return header, b.Bytes(), nil
+	case message.HTML != "":
+		return mimeText("text/html", message.HTML)
+	case message.Text != "":
+		return mimeText("text/plain", message.Text)
+	default:
+		return nil, nil, errors.New("email has no body")
+	}
+}
+
+// Encode a Text Part as Quoted-Printable, which keeps Lines within Limits
+func mimeText(contentType, content string) (textproto.MIMEHeader, []byte, error) {
+	var b bytes.Buffer
+	qp := quotedprintable.NewWriter(&b)
+	if _, err := qp.Write([]byte(content)); err != nil {
+		return nil, nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, nil, err
+	}
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", contentType+"; charset=utf-8")
+	header.Set("Content-Transfer-Encoding", "quoted-printable")
+	return header, b.Bytes(), nil
+}
+
+// Encode an Attachment as Base64, wrapped at 76 Characters
+func mimeAttachment(w *multipart.Writer, a EmailAttachment) error {
+	contentType := a.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", mime.FormatMediaType(contentType, map[string]string{"name": a.Filename}))
+	header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename}))
+	header.Set("Content-Transfer-Encoding", "base64")
+	if header.Get("Content-Type") == "" || header.Get("Content-Disposition") == "" {
+		return fmt.Errorf("invalid attachment: %s", a.Filename)
+	}
+	part, err := w.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	encoded := base64.StdEncoding.EncodeToString(a.Data)
+	for len(encoded) > 76 {
+		part.Write([]byte(encoded[:76] + "\r\n"))
+		encoded = encoded[76:]
+	}
+	_, err = part.Write([]byte(encoded + "\r\n"))
+	return err
+}
+
+// Boundaries start with "=_" which can't occur in Quoted-Printable or Base64 Content
+func mimeBoundary() string {
+	return "=_" + mimeRandom()
+}
+
+func mimeRandom() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -24,6 +24,7 @@ const (
 	REDIRECT_URI_STRING_LEN_MAX         = 128
 	VALIDATOR_REQUIRED                  = "REQUIRED"
 	VALIDATOR_URI_INVALID               = "VALIDATOR_URI_INVALID"
+	VALIDATOR_URI_INVALID_SCHEME        = "URI_INVALID_SCHEME"
 	VALIDATOR_SLICE_TOO_FEW_ITEMS       = "SLICE_TOO_FEW_ITEMS"
 	VALIDATOR_SLICE_TOO_MANY_ITEMS      = "SLICE_TOO_MANY_ITEMS"
 	VALIDATOR_INTEGER_TOO_SMALL         = "INTEGER_TOO_SMALL"
@@ -47,6 +48,7 @@ var (
 	REGEX_HAS_UPPER   = regexp.MustCompile(`\p{Lu}`) // uppercase letter (any script)
 	REGEX_HAS_LOWER   = regexp.MustCompile(`\p{Ll}`) // lowercase letter (any script)
 	REGEX_HAS_NUMBER  = regexp.MustCompile(`[0-9]`)  // numbers
+	REGEX_PKCE        = regexp.MustCompile(`^[A-Za-z0-9\-._~]{43,128}$`)
 )
 
 type ValidateFunc func(value any, param string) *ValidationError
@@ -0,0 +1,228 @@
+package tools
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"slices"
+	"strings"
+)
+
+const (
+	WEBAUTHN_CEREMONY_CREATE = "webauthn.create"
+	WEBAUTHN_CEREMONY_GET    = "webauthn.get"
+	WEBAUTHN_FLAG_UP         = 1 << 0 // User Present
+	WEBAUTHN_FLAG_UV         = 1 << 2 // User Verified
+	WEBAUTHN_FLAG_AT         = 1 << 6 // Attested Credential Data Included
+	COSE_ALG_ES256           = -7
+	COSE_ALG_EDDSA           = -8
+	COSE_ALG_RS256           = -257
+)
+
+var (
+	ErrWebAuthnMalformed = errors.New("webauthn data malformed")
+	ErrWebAuthnCeremony  = errors.New("webauthn ceremony type mismatch")
+	ErrWebAuthnOrigin    = errors.New("webauthn origin not allowed")
+	ErrWebAuthnRPID      = errors.New("webauthn relying party mismatch")
+	ErrWebAuthnFlags     = errors.New("webauthn user not present or verified")
+	ErrWebAuthnAlgorithm = errors.New("webauthn algorithm unsupported")
+	ErrWebAuthnSignature = errors.New("webauthn signature invalid")
+)
+
+// Algorithms offered to Authenticators, in order of preference
+var WEBAUTHN_ALGORITHMS = []int{COSE_ALG_ES256, COSE_ALG_EDDSA, COSE_ALG_RS256}
+
+// Parsed Authenticator Data as described in WebAuthn Level 2 Section 6.1
+type WebAuthnAuthenticatorData struct {
+	Flags        byte
+	SignCount    uint32
+	AAGUID       []byte
+	CredentialID []byte
+	PublicKey    []byte // COSE Encoded
+}
+
+// Encode Bytes for use in WebAuthn JSON Structures
+func WebAuthnEncode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Decode Bytes from WebAuthn JSON Structures, padding is optional
+func WebAuthnDecode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// Validate Client Data for the Given Ceremony, returns the embedded Challenge
+func WebAuthnParseClientData(raw []byte, ceremony string) (string, error) {
+	var clientData struct {
+		Type        string `json:"type"`
+		Challenge   string `json:"challenge"`
+		Origin      string `json:"origin"`
+		CrossOrigin bool   `json:"crossOrigin"`
+	}
+	if err := json.Unmarshal(raw, &clientData); err != nil {
+		return "", ErrWebAuthnMalformed
+	}
+	if clientData.Type != ceremony {
+		return "", ErrWebAuthnCeremony
+	}
+	if clientData.CrossOrigin || !slices.Contains(WEBAUTHN_ORIGINS, clientData.Origin) {
+		return "", ErrWebAuthnOrigin
+	}
+	return clientData.Challenge, nil
+}
+
+// Parse Authenticator Data, requires the User to be Present and Verified
+func WebAuthnParseAuthenticatorData(raw []byte) (*WebAuthnAuthenticatorData, error) {
+	if len(raw) < 37 {
+		return nil, ErrWebAuthnMalformed
+	}
+	rpHash := sha256.Sum256([]byte(WEBAUTHN_RP_ID))
+	if subtle.ConstantTimeCompare(raw[:32], rpHash[:]) != 1 {
+		return nil, ErrWebAuthnRPID
+	}
+	data := WebAuthnAuthenticatorData{
+		Flags:     raw[32],
+		SignCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+	if data.Flags&WEBAUTHN_FLAG_UP == 0 || data.Flags&WEBAUTHN_FLAG_UV == 0 {
+		return nil, ErrWebAuthnFlags
+	}
+
+	// Attested Credential Data is only present during Registration
+	if data.Flags&WEBAUTHN_FLAG_AT != 0 {
+		rest := raw[37:]
+		if len(rest) < 18 {
+			return nil, ErrWebAuthnMalformed
+		}
+		length := int(binary.BigEndian.Uint16(rest[16:18]))
+		if length == 0 || len(rest) < 18+length {
+			return nil, ErrWebAuthnMalformed
+		}
+		data.AAGUID = rest[:16]
+		data.CredentialID = rest[18 : 18+length]
+
+		// Public Key length is only known after decoding it
+		keyStart := rest[18+length:]
+		if _, keyEnd, err := DecodeCBOR(keyStart); err != nil {
+			return nil, ErrWebAuthnMalformed
+		} else {
+			data.PublicKey = keyStart[:len(keyStart)-len(keyEnd)]
+		}
+	}
+	return &data, nil
+}
+
+// Parse Attestation Object from a Registration Ceremony
+//
+// Attestation Statements are not verified, the Server requests 'none'
+// conveyance as it does not restrict which Authenticators may be used.
+func WebAuthnParseAttestation(raw []byte) (*WebAuthnAuthenticatorData, error) {
+	decoded, rest, err := DecodeCBOR(raw)
+	if err != nil || len(rest) != 0 {
+		return nil, ErrWebAuthnMalformed
+	}
+	attestation, ok := decoded.(map[any]any)
+	if !ok {
+		return nil, ErrWebAuthnMalformed
+	}
+	authData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return nil, ErrWebAuthnMalformed
+	}
+	data, err := WebAuthnParseAuthenticatorData(authData)
+	if err != nil {
+		return nil, err
+	}
+	if data.CredentialID == nil {
+		return nil, ErrWebAuthnMalformed
+	}
+	if _, _, err := WebAuthnParsePublicKey(data.PublicKey); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Parse COSE Encoded Public Key as described in RFC 9053
+func WebAuthnParsePublicKey(raw []byte) (crypto.PublicKey, int, error) {
+	decoded, rest, err := DecodeCBOR(raw)
+	if err != nil || len(rest) != 0 {
+		return nil, 0, ErrWebAuthnMalformed
+	}
+	key, ok := decoded.(map[any]any)
+	if !ok {
+		return nil, 0, ErrWebAuthnMalformed
+	}
+	kty, _ := key[int64(1)].(int64)
+	alg, _ := key[int64(3)].(int64)
+
+	switch {
+	case kty == 2 && alg == COSE_ALG_ES256:
+		crv, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		y, _ := key[int64(-3)].([]byte)
+		if crv != 1 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, ErrWebAuthnMalformed
+		}
+		pub, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), append(append([]byte{0x04}, x...), y...))
+		if err != nil {
+			return nil, 0, ErrWebAuthnMalformed
+		}
+		return pub, COSE_ALG_ES256, nil
+
+	case kty == 1 && alg == COSE_ALG_EDDSA:
+		crv, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		if crv != 6 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, ErrWebAuthnMalformed
+		}
+		return ed25519.PublicKey(x), COSE_ALG_EDDSA, nil
+
+	case kty == 3 && alg == COSE_ALG_RS256:
+		n, _ := key[int64(-1)].([]byte)
+		e, _ := key[int64(-2)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, ErrWebAuthnMalformed
+		}
+		exponent := new(big.Int).SetBytes(e)
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, COSE_ALG_RS256, nil
+
+	default:
+		return nil, 0, ErrWebAuthnAlgorithm
+	}
+}
+
+// Verify Assertion Signature over Authenticator Data and Client Data
+func WebAuthnVerifySignature(publicKey, authData, clientData, signature []byte) error {
+	key, alg, err := WebAuthnParsePublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+	clientHash := sha256.Sum256(clientData)
+	message := append(append([]byte{}, authData...), clientHash[:]...)
+	digest := sha256.Sum256(message)
+
+	switch alg {
+	case COSE_ALG_ES256:
+		if !ecdsa.VerifyASN1(key.(*ecdsa.PublicKey), digest[:], signature) {
+			return ErrWebAuthnSignature
+		}
+	case COSE_ALG_EDDSA:
+		if !ed25519.Verify(key.(ed25519.PublicKey), message, signature) {
+			return ErrWebAuthnSignature
+		}
+	case COSE_ALG_RS256:
+		if rsa.VerifyPKCS1v15(key.(*rsa.PublicKey), crypto.SHA256, digest[:], signature) != nil {
+			return ErrWebAuthnSignature
+		}
+	}
+	return nil
+}
@@ -0,0 +1,432 @@
+package tools
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"io"
+	"math/big"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const (
+	XML_NS_XML           = "http://www.w3.org/XML/1998/namespace"
+	XML_NS_DSIG          = "http://www.w3.org/2000/09/xmldsig#"
+	XML_NS_EXC_C14N      = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	XML_ALG_EXC_C14N     = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	XML_ALG_ENVELOPED    = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	XML_ALG_SHA256       = "http://www.w3.org/2001/04/xmlenc#sha256"
+	XML_ALG_SHA512       = "http://www.w3.org/2001/04/xmlenc#sha512"
+	XML_ALG_RSA_SHA256   = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	XML_ALG_RSA_SHA512   = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha512"
+	XML_ALG_ECDSA_SHA256 = "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha256"
+)
+
+var (
+	ErrXMLMalformed        = errors.New("xml malformed")
+	ErrXMLDoctype          = errors.New("xml document type declarations are not allowed")
+	ErrXMLSignatureMissing = errors.New("xml signature missing")
+	ErrXMLSignatureInvalid = errors.New("xml signature invalid")
+	ErrXMLUnsupported      = errors.New("xml signature algorithm unsupported")
+)
+
+// Element of an XML Document which keeps the Prefixes and Namespace Declarations
+// needed for Canonicalization, encoding/xml discards them once resolved
+type XMLElement struct {
+	Prefix     string
+	Local      string
+	Space      string            // Resolved Namespace URI
+	Attrs      []XMLAttr         // Attributes excluding Namespace Declarations
+	Namespaces map[string]string // Namespace Declarations by Prefix ("" for Default)
+	Children   []any             // *XMLElement or string
+	Parent     *XMLElement
+}
+
+type XMLAttr struct {
+	Prefix string
+	Local  string
+	Space  string
+	Value  string
+}
+
+// Parse Document into a Tree, Comments and Processing Instructions are dropped
+func ParseXML(data []byte) (*XMLElement, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var root, current *XMLElement
+	for {
+		tok, err := dec.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, ErrXMLMalformed
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if current == nil && root != nil {
+				return nil, ErrXMLMalformed
+			}
+			el := &XMLElement{
+				Prefix:     tok.Name.Space,
+				Local:      tok.Name.Local,
+				Namespaces: map[string]string{},
+				Parent:     current,
+			}
+			for _, a := range tok.Attr {
+				switch {
+				case a.Name.Space == "" && a.Name.Local == "xmlns":
+					el.Namespaces[""] = a.Value
+				case a.Name.Space == "xmlns":
+					el.Namespaces[a.Name.Local] = a.Value
+				default:
+					el.Attrs = append(el.Attrs, XMLAttr{Prefix: a.Name.Space, Local: a.Name.Local, Value: a.Value})
+				}
+			}
+			var ok bool
+			if el.Space, ok = el.LookupNamespace(el.Prefix); !ok {
+				return nil, ErrXMLMalformed
+			}
+			for i, a := range el.Attrs {
+				if a.Prefix == "" {
+					continue
+				}
+				if el.Attrs[i].Space, ok = el.LookupNamespace(a.Prefix); !ok {
+					return nil, ErrXMLMalformed
+				}
+			}
+			if current == nil {
+				root = el
+			} else {
+				current.Children = append(current.Children, el)
+			}
+			current = el
+		case xml.EndElement:
+			if current == nil || tok.Name.Space != current.Prefix || tok.Name.Local != current.Local {
+				return nil, ErrXMLMalformed
+			}
+			current = current.Parent
+		case xml.CharData:
+			if current != nil {
+				current.Children = append(current.Children, string(tok))
+			}
+		case xml.Directive:
+			// Entity Declarations could be used to expand the Document
+			return nil, ErrXMLDoctype
+		}
+	}
+	if root == nil || current != nil {
+		return nil, ErrXMLMalformed
+	}
+	return root, nil
+}
+
+// Resolve Prefix to its Namespace URI using Declarations in Scope
+func (e *XMLElement) LookupNamespace(prefix string) (string, bool) {
+	if prefix == "xml" {
+		return XML_NS_XML, true
+	}
+	for el := e; el != nil; el = el.Parent {
+		if uri, ok := el.Namespaces[prefix]; ok {
+			return uri, true
+		}
+	}
+	return "", prefix == ""
+}
+
+// Value of an Unqualified Attribute, empty if missing
+func (e *XMLElement) Attr(local string) string {
+	for _, a := range e.Attrs {
+		if a.Space == "" && a.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// Direct Children matching the given Namespace and Name
+func (e *XMLElement) ChildElements(space, local string) []*XMLElement {
+	var found []*XMLElement
+	for _, c := range e.Children {
+		if el, ok := c.(*XMLElement); ok && el.Space == space && el.Local == local {
+			found = append(found, el)
+		}
+	}
+	return found
+}
+
+// First Direct Child matching the given Namespace and Name, nil if missing
+func (e *XMLElement) Child(space, local string) *XMLElement {
+	if found := e.ChildElements(space, local); len(found) > 0 {
+		return found[0]
+	}
+	return nil
+}
+
+// Text Content of the Element and its Descendants
+func (e *XMLElement) Text() string {
+	var b strings.Builder
+	for _, c := range e.Children {
+		switch c := c.(type) {
+		case string:
+			b.WriteString(c)
+		case *XMLElement:
+			b.WriteString(c.Text())
+		}
+	}
+	return b.String()
+}
+
+// Serialize Element using Exclusive XML Canonicalization (without Comments).
+// Prefixes in the InclusiveNamespaces PrefixList are rendered as in Inclusive
+// Canonicalization, the exclude Element is omitted for Enveloped Signatures.
+func XMLCanonicalize(e *XMLElement, inclusive []string, exclude *XMLElement) []byte {
+	var b bytes.Buffer
+	xmlCanonicalize(&b, e, map[string]string{}, inclusive, exclude)
+	return b.Bytes()
+}
+
+func xmlCanonicalize(b *bytes.Buffer, e *XMLElement, rendered map[string]string, inclusive []string, exclude *XMLElement) {
+
+	// Namespaces Visibly Utilized by the Element or its Attributes
+	prefixes := []string{e.Prefix}
+	for _, a := range e.Attrs {
+		if a.Prefix != "" && a.Prefix != "xml" {
+			prefixes = append(prefixes, a.Prefix)
+		}
+	}
+	for _, p := range inclusive {
+		if p == "#default" {
+			p = ""
+		}
+		if _, ok := e.LookupNamespace(p); ok && p != "xml" {
+			prefixes = append(prefixes, p)
+		}
+	}
+	slices.Sort(prefixes)
+	prefixes = slices.Compact(prefixes)
+
+	// Render Declarations not already Rendered by an Output Ancestor
+	scope := rendered
+	var declarations []string
+	for _, p := range prefixes {
+		uri, _ := e.LookupNamespace(p)
+		previous, ok := rendered[p]
+		if (ok && previous == uri) || (!ok && p == "" && uri == "") {
+			continue
+		}
+		if len(declarations) == 0 {
+			scope = make(map[string]string, len(rendered)+1)
+			for k, v := range rendered {
+				scope[k] = v
+			}
+		}
+		scope[p] = uri
+		if p == "" {
+			declarations = append(declarations, ` xmlns="`+xmlEscapeAttr(uri)+`"`)
+		} else {
+			declarations = append(declarations, ` xmlns:`+p+`="`+xmlEscapeAttr(uri)+`"`)
+		}
+	}
+
+	// Attributes are sorted by Namespace URI then Local Name
+	attrs := slices.Clone(e.Attrs)
+	sort.SliceStable(attrs, func(i, j int) bool {
+		if attrs[i].Space != attrs[j].Space {
+			return attrs[i].Space < attrs[j].Space
+		}
+		return attrs[i].Local < attrs[j].Local
+	})
+
+	b.WriteByte('<')
+	b.WriteString(xmlQualifiedName(e.Prefix, e.Local))
+	for _, d := range declarations {
+		b.WriteString(d)
+	}
+	for _, a := range attrs {
+		b.WriteByte(' ')
+		b.WriteString(xmlQualifiedName(a.Prefix, a.Local))
+		b.WriteString(`="`)
+		b.WriteString(xmlEscapeAttr(a.Value))
+		b.WriteByte('"')
+	}
+	b.WriteByte('>')
+	for _, c := range e.Children {
+		switch c := c.(type) {
+		case string:
+			b.WriteString(xmlEscapeText(c))
+		case *XMLElement:
+			if c != exclude {
+				xmlCanonicalize(b, c, scope, inclusive, exclude)
+			}
+		}
+	}
+	b.WriteString("</")
+	b.WriteString(xmlQualifiedName(e.Prefix, e.Local))
+	b.WriteByte('>')
+}
+
+func xmlQualifiedName(prefix, local string) string {
+	if prefix == "" {
+		return local
+	}
+	return prefix + ":" + local
+}
+
+var (
+	xmlTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	xmlAttrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
+
+func xmlEscapeText(s string) string { return xmlTextEscaper.Replace(s) }
+func xmlEscapeAttr(s string) string { return xmlAttrEscaper.Replace(s) }
+
+// Verify the Enveloped Signature of an Element using one of the trusted Certificates.
+// The Signature must be a direct child of the Element and reference only the Element
+// itself, so callers may trust everything inside it once this returns nil.
+func XMLVerifySignature(e *XMLElement, certificates []*x509.Certificate) error {
+
+	// Locate Signature
+	signatures := e.ChildElements(XML_NS_DSIG, "Signature")
+	if len(signatures) == 0 {
+		return ErrXMLSignatureMissing
+	}
+	if len(signatures) > 1 {
+		return ErrXMLSignatureInvalid
+	}
+	signature := signatures[0]
+	signedInfo := signature.Child(XML_NS_DSIG, "SignedInfo")
+	if signedInfo == nil {
+		return ErrXMLSignatureInvalid
+	}
+
+	// Parse Signed Info
+	canonicalization := signedInfo.Child(XML_NS_DSIG, "CanonicalizationMethod")
+	method := signedInfo.Child(XML_NS_DSIG, "SignatureMethod")
+	references := signedInfo.ChildElements(XML_NS_DSIG, "Reference")
+	if canonicalization == nil || method == nil || len(references) != 1 {
+		return ErrXMLSignatureInvalid
+	}
+	if canonicalization.Attr("Algorithm") != XML_ALG_EXC_C14N {
+		return ErrXMLUnsupported
+	}
+	reference := references[0]
+	if id := e.Attr("ID"); id == "" || reference.Attr("URI") != "#"+id {
+		return ErrXMLSignatureInvalid
+	}
+
+	// Parse Reference Transforms
+	enveloped := false
+	var inclusive []string
+	if transforms := reference.Child(XML_NS_DSIG, "Transforms"); transforms != nil {
+		for _, t := range transforms.ChildElements(XML_NS_DSIG, "Transform") {
+			switch t.Attr("Algorithm") {
+			case XML_ALG_ENVELOPED:
+				enveloped = true
+			case XML_ALG_EXC_C14N:
+				inclusive = xmlInclusivePrefixes(t)
+			default:
+				return ErrXMLUnsupported
+			}
+		}
+	}
+	if !enveloped {
+		return ErrXMLSignatureInvalid
+	}
+
+	// Compare Digest
+	digestMethod := reference.Child(XML_NS_DSIG, "DigestMethod")
+	digestValue := reference.Child(XML_NS_DSIG, "DigestValue")
+	if digestMethod == nil || digestValue == nil {
+		return ErrXMLSignatureInvalid
+	}
+	digestHash, ok := xmlDigestAlgorithm(digestMethod.Attr("Algorithm"))
+	if !ok {
+		return ErrXMLUnsupported
+	}
+	expectedDigest, err := xmlDecodeBase64(digestValue.Text())
+	if err != nil {
+		return ErrXMLSignatureInvalid
+	}
+	h := digestHash.New()
+	h.Write(XMLCanonicalize(e, inclusive, signature))
+	if !bytes.Equal(h.Sum(nil), expectedDigest) {
+		return ErrXMLSignatureInvalid
+	}
+
+	// Verify Signature of Signed Info
+	signatureValue := signature.Child(XML_NS_DSIG, "SignatureValue")
+	if signatureValue == nil {
+		return ErrXMLSignatureInvalid
+	}
+	signatureBytes, err := xmlDecodeBase64(signatureValue.Text())
+	if err != nil {
+		return ErrXMLSignatureInvalid
+	}
+	var signatureHash crypto.Hash
+	switch method.Attr("Algorithm") {
+	case XML_ALG_RSA_SHA256, XML_ALG_ECDSA_SHA256:
+		signatureHash = crypto.SHA256
+	case XML_ALG_RSA_SHA512:
+		signatureHash = crypto.SHA512
+	default:
+		return ErrXMLUnsupported
+	}
+	h = signatureHash.New()
+	h.Write(XMLCanonicalize(signedInfo, xmlInclusivePrefixes(canonicalization), nil))
+	hashed := h.Sum(nil)
+
+	for _, cert := range certificates {
+		switch key := cert.PublicKey.(type) {
+		case *rsa.PublicKey:
+			if method.Attr("Algorithm") == XML_ALG_ECDSA_SHA256 {
+				continue
+			}
+			if rsa.VerifyPKCS1v15(key, signatureHash, hashed, signatureBytes) == nil {
+				return nil
+			}
+		case *ecdsa.PublicKey:
+			// XML Signatures use the raw R || S form rather than ASN.1
+			if method.Attr("Algorithm") != XML_ALG_ECDSA_SHA256 || len(signatureBytes)%2 != 0 {
+				continue
+			}
+			half := len(signatureBytes) / 2
+			r := new(big.Int).SetBytes(signatureBytes[:half])
+			s := new(big.Int).SetBytes(signatureBytes[half:])
+			if ecdsa.Verify(key, hashed, r, s) {
+				return nil
+			}
+		}
+	}
+	return ErrXMLSignatureInvalid
+}
+
+func xmlDigestAlgorithm(algorithm string) (crypto.Hash, bool) {
+	switch algorithm {
+	case XML_ALG_SHA256:
+		return crypto.SHA256, true
+	case XML_ALG_SHA512:
+		return crypto.SHA512, true
+	}
+	return 0, false
+}
+
+// Read the PrefixList of an InclusiveNamespaces Element below a Transform
+func xmlInclusivePrefixes(transform *XMLElement) []string {
+	if list := transform.Child(XML_NS_EXC_C14N, "InclusiveNamespaces"); list != nil {
+		return strings.Fields(list.Attr("PrefixList"))
+	}
+	return nil
+}
+
+// Base64 in XML may be wrapped across several Lines
+func xmlDecodeBase64(s string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), ""))
+}